@@ -10,9 +10,17 @@
  * - Input: EvaluationContext JSON via stdin
  * - Output: EvaluatorDecision JSON via stdout
  * - Errors: Write to stderr and exit with non-zero code
+ *
+ * With --persistent it runs as a long-lived worker instead: one JSON request
+ * per line on stdin, one JSON response per line on stdout.
  */
 
 async function main() {
+  if (process.argv.includes("--persistent")) {
+    await serve();
+    return;
+  }
+
   try {
     // Read input from stdin
     const input = await Bun.stdin.text();
@@ -33,6 +41,39 @@ async function main() {
   }
 }
 
+/**
+ * Persistent worker loop: answer each request line with one response line
+ */
+async function serve() {
+  const decoder = new TextDecoder();
+  let buffered = "";
+
+  for await (const chunk of Bun.stdin.stream()) {
+    buffered += decoder.decode(chunk, { stream: true });
+
+    let newline;
+    while ((newline = buffered.indexOf("\n")) >= 0) {
+      const line = buffered.slice(0, newline);
+      buffered = buffered.slice(newline + 1);
+      if (line.trim() === "") continue;
+
+      const request = JSON.parse(line);
+      const response = { id: request.id };
+
+      if (request.type === "evaluate") {
+        try {
+          const { ir, request: req, response: res, vars } = request.context;
+          response.decision = evaluate(ir || {}, req || {}, res || {}, vars || {});
+        } catch (error) {
+          response.error = `Evaluator error: ${error.message}`;
+        }
+      }
+
+      process.stdout.write(JSON.stringify(response) + "\n");
+    }
+  }
+}
+
 /**
  * Evaluation logic - customize this based on your needs
  */
@@ -9,6 +9,9 @@ Contract:
 - Input: EvaluationContext JSON via stdin
 - Output: EvaluatorDecision JSON via stdout
 - Errors: Write to stderr and exit with non-zero code
+
+With --persistent it runs as a long-lived worker instead: one JSON request
+per line on stdin, one JSON response per line on stdout.
 """
 
 import json
@@ -93,20 +96,47 @@ def evaluate(ir: Dict, request: Dict, response: Dict, vars: Dict) -> Dict:
     return decision
 
 
+def evaluate_context(context: Dict) -> Dict:
+    return evaluate(
+        context.get("ir") or {},
+        context.get("request") or {},
+        context.get("response") or {},
+        context.get("vars") or {},
+    )
+
+
+def serve():
+    """
+    Persistent worker loop: answer each request line with one response line
+    """
+    for line in sys.stdin:
+        if not line.strip():
+            continue
+
+        request = json.loads(line)
+        response: Dict[str, Any] = {"id": request.get("id")}
+
+        if request.get("type") == "evaluate":
+            try:
+                response["decision"] = evaluate_context(request.get("context") or {})
+            except Exception as error:
+                response["error"] = f"Evaluator error: {error}"
+
+        print(json.dumps(response), flush=True)
+
+
 def main():
+    if "--persistent" in sys.argv[1:]:
+        serve()
+        return
+
     try:
         # Read input from stdin
         input_data = sys.stdin.read()
         context = json.loads(input_data)
 
-        # Extract key data
-        ir = context.get("ir", {})
-        request = context.get("request", {})
-        response = context.get("response", {})
-        vars = context.get("vars", {})
-
         # Evaluate
-        decision = evaluate(ir, request, response, vars)
+        decision = evaluate_context(context)
 
         # Write decision to stdout
         print(json.dumps(decision, indent=2))
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/vikasavnish/httptool/pkg/contract"
+)
+
+// loadContract loads the spec named by --contract, or returns nil when the
+// flag is not given
+func loadContract(args []string) (*contract.Checker, error) {
+	files := flagValues(args, "--contract")
+	if len(files) == 0 {
+		return nil, nil
+	}
+	checker, err := contract.Load(files[len(files)-1])
+	if err != nil {
+		return nil, fmt.Errorf("failed to load contract %s: %w", files[len(files)-1], err)
+	}
+	return checker, nil
+}
+
+func printContractReport(report *contract.Report) {
+	fmt.Printf("\n📜 Contract: %s %s\n", report.Title, report.Version)
+
+	for _, req := range report.Requests {
+		mark := "✓"
+		if req.Failed > 0 {
+			mark = "✗"
+		}
+		operation := req.Operation
+		if operation == "" {
+			operation = "(no operation)"
+		}
+		calls := plural(req.Calls, "call")
+		if req.Failed > 0 {
+			calls += fmt.Sprintf(", %d failed", req.Failed)
+		}
+		fmt.Printf("  %s %-24s %-32s %s\n", mark, req.Name, operation, calls)
+		for _, v := range req.Violations {
+			if v.Count > 1 {
+				fmt.Printf("      %s (%d×)\n", v.Message, v.Count)
+			} else {
+				fmt.Printf("      %s\n", v.Message)
+			}
+		}
+	}
+
+	covered := report.Covered()
+	total := len(report.Operations)
+	percent := 0.0
+	if total > 0 {
+		percent = float64(covered) / float64(total) * 100
+	}
+	fmt.Printf("  Coverage: %d/%d operations (%.1f%%)\n", covered, total, percent)
+
+	var missed []string
+	for _, op := range report.Operations {
+		if op.Calls == 0 {
+			missed = append(missed, op.Operation)
+		}
+	}
+	if len(missed) > 0 {
+		fmt.Printf("  Not called: %s\n", strings.Join(missed, ", "))
+	}
+}
+
+func plural(n int, word string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, word)
+	}
+	return fmt.Sprintf("%d %ss", n, word)
+}
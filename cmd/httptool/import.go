@@ -0,0 +1,268 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/vikasavnish/httptool/pkg/har"
+	"github.com/vikasavnish/httptool/pkg/ir"
+	"github.com/vikasavnish/httptool/pkg/openapi"
+	"github.com/vikasavnish/httptool/pkg/wrappers"
+)
+
+func handleImportCommand() {
+	if len(os.Args) < 3 {
+		printImportUsage()
+		os.Exit(1)
+	}
+
+	switch os.Args[2] {
+	case "har":
+		handleImportHAR()
+	case "postman":
+		handleImportPostman()
+	case "openapi":
+		handleImportOpenAPI()
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown import format: %s\n", os.Args[2])
+		printImportUsage()
+		os.Exit(1)
+	}
+}
+
+func printImportUsage() {
+	fmt.Print(`httptool import - Convert captures and collections to IR or .httpx
+
+Usage:
+  httptool import har <session.har> [options]
+  httptool import postman <collection.json> [--env <environment.json>] [--httpx <file>]
+  httptool import openapi <api.yaml> [--server <url>] [--httpx <file> | --ir-dir <dir>]
+
+HAR options:
+  --domain <host>        Keep requests to this host and its subdomains. Repeatable
+  --content-type <type>  Keep responses of this MIME type, or containing it
+                         (e.g. json). Repeatable
+  --min-think <D>        Shorter gaps between requests are not think time (default 100ms)
+  --httpx <file>         Write a .httpx scenario (default: print it)
+  --ir-dir <dir>         Write one IR JSON file per request instead
+  --name <name>          Scenario name (default: from the file name)
+
+Postman options:
+  --env <file>           Environment whose values override collection variables
+  --httpx <file>         Write a .httpx scenario (default: print it)
+
+OpenAPI options:
+  --server <url>         Base URL (default: the spec's first server)
+  --httpx <file>         Write the requests and a smoke scenario (default: print them)
+  --ir-dir <dir>         Write one IR template per operation instead
+
+Examples:
+  httptool import har session.har --domain api.example.com --content-type json --httpx session.httpx
+  httptool import har session.har --ir-dir requests/
+  httptool import postman shop.postman_collection.json --env local.postman_environment.json --httpx shop.httpx
+  httptool import openapi api.yaml --server http://localhost:8080 --httpx api.httpx
+`)
+}
+
+func handleImportHAR() {
+	if len(os.Args) < 4 {
+		fmt.Fprintln(os.Stderr, "Usage: httptool import har <session.har> [--domain host] [--content-type type] [--httpx file | --ir-dir dir]")
+		os.Exit(1)
+	}
+	path := os.Args[3]
+
+	opts := har.ImportOptions{
+		Domains:      flagValues(os.Args, "--domain"),
+		ContentTypes: flagValues(os.Args, "--content-type"),
+	}
+	if values := flagValues(os.Args, "--min-think"); len(values) > 0 {
+		d, err := time.ParseDuration(values[len(values)-1])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid --min-think: %v\n", err)
+			os.Exit(1)
+		}
+		opts.MinThink = d
+	}
+
+	h, err := har.Load(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read HAR: %v\n", err)
+		os.Exit(1)
+	}
+
+	requests, err := har.Import(h, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Import error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(requests) == 0 {
+		fmt.Fprintf(os.Stderr, "No requests left after filtering %d entries\n", len(h.Log.Entries))
+		os.Exit(1)
+	}
+
+	if dirs := flagValues(os.Args, "--ir-dir"); len(dirs) > 0 {
+		irs := make(map[string]*ir.IR, len(requests))
+		for _, req := range requests {
+			irs[req.Name] = req.IR
+		}
+		writeIRDir(dirs[len(dirs)-1], irs)
+		return
+	}
+
+	name := scenarioName(path)
+	if names := flagValues(os.Args, "--name"); len(names) > 0 {
+		name = names[len(names)-1]
+	}
+	write := func(w io.Writer) error { return har.WriteScenario(w, name, requests) }
+
+	if files := flagValues(os.Args, "--httpx"); len(files) > 0 {
+		file := files[len(files)-1]
+		if err := writeReport(file, write); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to write %s: %v\n", file, err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "✓ Wrote scenario '%s' with %d requests to %s\n", name, len(requests), file)
+		return
+	}
+	if err := write(os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write scenario: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func handleImportPostman() {
+	if len(os.Args) < 4 {
+		fmt.Fprintln(os.Stderr, "Usage: httptool import postman <collection.json> [--env environment.json] [--httpx file]")
+		os.Exit(1)
+	}
+
+	collection, err := wrappers.LoadPostmanCollection(os.Args[3])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read collection: %v\n", err)
+		os.Exit(1)
+	}
+
+	var env *wrappers.PostmanEnvironment
+	if files := flagValues(os.Args, "--env"); len(files) > 0 {
+		env, err = wrappers.LoadPostmanEnvironment(files[len(files)-1])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to read environment: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	file, warnings, err := wrappers.NewPostmanWrapper().ConvertCollection(collection, env)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Import error: %v\n", err)
+		os.Exit(1)
+	}
+	for _, warning := range warnings {
+		fmt.Fprintf(os.Stderr, "⚠ %s\n", warning)
+	}
+
+	writeHTTPX(file)
+}
+
+func handleImportOpenAPI() {
+	if len(os.Args) < 4 {
+		fmt.Fprintln(os.Stderr, "Usage: httptool import openapi <api.yaml> [--server url] [--httpx file | --ir-dir dir]")
+		os.Exit(1)
+	}
+
+	spec, err := openapi.Load(os.Args[3])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read spec: %v\n", err)
+		os.Exit(1)
+	}
+
+	var server string
+	if values := flagValues(os.Args, "--server"); len(values) > 0 {
+		server = values[len(values)-1]
+	}
+
+	file, warnings, err := wrappers.NewOpenAPIWrapper().ConvertSpec(spec, server)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Import error: %v\n", err)
+		os.Exit(1)
+	}
+	for _, warning := range warnings {
+		fmt.Fprintf(os.Stderr, "⚠ %s\n", warning)
+	}
+
+	if dirs := flagValues(os.Args, "--ir-dir"); len(dirs) > 0 {
+		irs := make(map[string]*ir.IR, len(file.Requests))
+		for _, req := range file.Requests {
+			irs[req.Name] = req.IR
+		}
+		writeIRDir(dirs[len(dirs)-1], irs)
+		return
+	}
+	writeHTTPX(file)
+}
+
+// writeIRDir writes one <name>.json IR file per request
+func writeIRDir(dir string, irs map[string]*ir.IR) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create %s: %v\n", dir, err)
+		os.Exit(1)
+	}
+	for name, irSpec := range irs {
+		data, _ := json.MarshalIndent(irSpec, "", "  ")
+		file := filepath.Join(dir, name+".json")
+		if err := os.WriteFile(file, append(data, '\n'), 0o644); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to write %s: %v\n", file, err)
+			os.Exit(1)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "✓ Wrote %d IR files to %s\n", len(irs), dir)
+}
+
+// writeHTTPX writes an imported scenario to the --httpx file, or prints it
+func writeHTTPX(file *wrappers.HTTPXFile) {
+	if files := flagValues(os.Args, "--httpx"); len(files) > 0 {
+		path := files[len(files)-1]
+		if err := writeReport(path, file.Write); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to write %s: %v\n", path, err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "✓ Wrote scenario '%s' with %d requests to %s\n", file.Scenario.Name, len(file.Requests), path)
+		return
+	}
+	if err := file.Write(os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write scenario: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+var nonIdent = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+// scenarioName derives a .httpx identifier from a file name
+func scenarioName(path string) string {
+	base := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	name := strings.Trim(nonIdent.ReplaceAllString(base, "_"), "_")
+	if name == "" || name[0] >= '0' && name[0] <= '9' {
+		name = "imported_" + name
+	}
+	return name
+}
+
+// flagValues collects the values of a repeatable flag, given as --flag value
+// or --flag=value
+func flagValues(args []string, flag string) []string {
+	var values []string
+	for i, arg := range args {
+		switch {
+		case arg == flag && i+1 < len(args):
+			values = append(values, args[i+1])
+		case strings.HasPrefix(arg, flag+"="):
+			values = append(values, strings.TrimPrefix(arg, flag+"="))
+		}
+	}
+	return values
+}
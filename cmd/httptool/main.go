@@ -9,6 +9,7 @@ import (
 
 	"github.com/vikasavnish/httptool/pkg/evaluator"
 	"github.com/vikasavnish/httptool/pkg/executor"
+	"github.com/vikasavnish/httptool/pkg/har"
 	"github.com/vikasavnish/httptool/pkg/ir"
 	"github.com/vikasavnish/httptool/pkg/parser"
 )
@@ -32,6 +33,10 @@ func main() {
 		handleValidate()
 	case "scenario":
 		handleScenarioCommand()
+	case "workflow":
+		handleWorkflowCommand()
+	case "import":
+		handleImportCommand()
 	case "help", "--help", "-h":
 		printUsage()
 	default:
@@ -64,7 +69,7 @@ func handleScenarioCommand() {
 }
 
 func printScenarioUsage() {
-	fmt.Println(`httptool scenario - Load Testing DSL
+	fmt.Print(`httptool scenario - Load Testing DSL
 
 Usage:
   httptool scenario run <scenario.httpx>         Run a load testing scenario
@@ -76,6 +81,12 @@ Options:
   --dry-run           Validate and show plan without executing
   --vus <N>           Override virtual users (future)
   --duration <D>      Override duration (future)
+  --out <kind=path>   Write results; kind is json (summary), ndjson (one line
+                      per request), junit (XML for CI) or har (requests and
+                      responses for browser devtools). Repeatable
+  --persistent-evaluators
+                      Keep evaluators running as workers instead of starting
+                      one per evaluation; they must support --persistent
 
 Examples:
   # Run scenario
@@ -87,6 +98,9 @@ Examples:
   # Dry run
   httptool scenario run user-journey.httpx --dry-run
 
+  # Archive results and feed a CI test reporter
+  httptool scenario run load.httpx --out json=summary.json --out junit=report.xml
+
   # Validate syntax
   httptool scenario validate scenario.httpx
 
@@ -190,16 +204,45 @@ func handleValidate() {
 }
 
 func executeIR(irSpec *ir.IR) {
+	outputs, err := parseOutputs(os.Args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	for _, out := range outputs {
+		if out.kind != "har" {
+			fmt.Fprintf(os.Stderr, "--out %s is only available for scenarios; a single request can be written as har\n", out.kind)
+			os.Exit(1)
+		}
+	}
+	checker, err := loadContract(os.Args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
 	// Create executor
 	exec := executor.NewExecutor()
 
 	// Execute request
+	start := time.Now()
 	ctx, err := exec.Execute(irSpec)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Execution error: %v\n", err)
 		os.Exit(1)
 	}
 
+	if len(outputs) > 0 {
+		recorder := har.NewRecorder()
+		recorder.Add(start, ctx)
+		for _, out := range outputs {
+			if err := writeReport(out.path, recorder.Write); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to write %s: %v\n", out.path, err)
+				os.Exit(1)
+			}
+		}
+	}
+
 	// Create evaluator manager
 	timeout := 5 * time.Second
 	if irSpec.Evaluation != nil && irSpec.Evaluation.TimeoutMs > 0 {
@@ -207,11 +250,25 @@ func executeIR(irSpec *ir.IR) {
 	}
 
 	evalMgr := evaluator.NewManager(timeout)
+	if hasFlag(os.Args, "--persistent-evaluators") {
+		evalMgr = evaluator.NewPooledManager(timeout, evaluator.PoolConfig{Workers: 1})
+	}
 
 	// Run evaluator
 	var decision *ir.EvaluatorDecision
 
-	if irSpec.Evaluation != nil && irSpec.Evaluation.Evaluator != "" {
+	if irSpec.Evaluation != nil && irSpec.Evaluation.Schema != "" {
+		decision, err = evaluator.SchemaDecision(ctx, irSpec.Evaluation.Schema)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Schema error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	switch {
+	case decision != nil:
+		// The body broke its schema; the evaluator is not consulted
+	case irSpec.Evaluation != nil && irSpec.Evaluation.Evaluator != "":
 		decision, err = evalMgr.Evaluate(
 			context.Background(),
 			ctx,
@@ -219,20 +276,29 @@ func executeIR(irSpec *ir.IR) {
 			irSpec.Evaluation.EvaluatorPath,
 		)
 		if err != nil {
+			// An evaluator that cannot decide fails the request
 			fmt.Fprintf(os.Stderr, "Evaluation error: %v\n", err)
-			// Fall back to default evaluator
-			decision, _ = evaluator.DefaultEvaluator(ctx)
+			decision = &ir.EvaluatorDecision{Decision: "fail", Reason: fmt.Sprintf("evaluator error: %v", err)}
 		}
-	} else {
+	default:
 		// Use default evaluator
 		decision, _ = evaluator.DefaultEvaluator(ctx)
 	}
+	evalMgr.Close()
 
 	// Output results
 	printResults(ctx, decision)
 
+	passed := true
+	if checker != nil {
+		checker.Check("", ctx)
+		report := checker.Report()
+		printContractReport(report)
+		passed = report.Passed()
+	}
+
 	// Exit based on decision
-	if decision.Decision == "fail" {
+	if decision.Decision == "fail" || !passed {
 		os.Exit(1)
 	}
 }
@@ -241,6 +307,21 @@ func printResults(ctx *ir.EvaluationContext, decision *ir.EvaluatorDecision) {
 	fmt.Printf("Request:  %s %s\n", ctx.Request.Method, ctx.Request.URL)
 	fmt.Printf("Status:   %d\n", ctx.Response.Status)
 	fmt.Printf("Latency:  %.2fms\n", ctx.Response.LatencyMs)
+	if t := ctx.Response.Timing; t != nil {
+		connection := "new connection"
+		if t.ConnReused {
+			connection = "reused connection"
+		}
+		fmt.Printf("Timing:   dns %.2fms, connect %.2fms, tls %.2fms, wait %.2fms, ttfb %.2fms, transfer %.2fms (%s)\n",
+			t.DNSMs, t.ConnectMs, t.TLSMs, t.WaitMs, t.TTFBMs, t.TransferMs, connection)
+	}
+	if ctx.Response.Protocol != "" {
+		protocol := ctx.Response.Protocol
+		if ctx.Response.TLSVersion != "" {
+			protocol += fmt.Sprintf(" (%s, %s)", ctx.Response.TLSVersion, ctx.Response.TLSCipher)
+		}
+		fmt.Printf("Protocol: %s\n", protocol)
+	}
 	fmt.Printf("Size:     %d bytes\n", ctx.Response.SizeBytes)
 
 	if ctx.Response.Error != "" {
@@ -273,7 +354,7 @@ func printResults(ctx *ir.EvaluationContext, decision *ir.EvaluatorDecision) {
 }
 
 func printUsage() {
-	fmt.Println(`httptool - HTTP Execution & Evaluation Engine
+	fmt.Print(`httptool - HTTP Execution & Evaluation Engine
 
 Usage:
   httptool convert <curl-command>    Convert curl command to IR JSON
@@ -281,6 +362,10 @@ Usage:
   httptool run <ir-file.json>        Execute from IR file
   httptool validate <ir-file.json>   Validate IR file
   httptool scenario <command>        Load testing scenarios (run, validate, convert)
+  httptool workflow run <file.json>  Run a multi-step IR workflow
+  httptool import har <file.har>     Convert a browser capture to .httpx or IR
+  httptool import postman <file>     Convert a Postman v2.1 collection to .httpx
+  httptool import openapi <file>     Generate requests and a smoke test from an OpenAPI 3 spec
   httptool help                      Show this help
 
 Examples:
@@ -293,9 +378,24 @@ Examples:
   # Run from IR file
   httptool run request.json
 
+  # Save the exchange for browser devtools
+  httptool exec 'curl https://api.example.com/users' --out har=users.har
+
+  # Check the response against an OpenAPI spec
+  httptool exec 'curl https://api.example.com/users/42' --contract api.yaml
+
+  # Keep an evaluator that supports --persistent running between evaluations
+  httptool run request.json --persistent-evaluators
+
   # Run load testing scenario
   httptool scenario run examples/scenarios/simple-load.httpx
 
+  # Run a workflow of IR steps
+  httptool workflow run examples/workflow-example.json
+
+  # Turn a Postman collection into a scenario
+  httptool import postman shop.postman_collection.json --env local.postman_environment.json --httpx shop.httpx
+
 Environment Variables:
   VERBOSE=1       Show response headers / per-VU details
   SHOW_BODY=1     Show response body
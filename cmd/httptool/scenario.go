@@ -3,16 +3,24 @@ package main
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/vikasavnish/httptool/pkg/har"
+	"github.com/vikasavnish/httptool/pkg/histogram"
+	"github.com/vikasavnish/httptool/pkg/ir"
+	"github.com/vikasavnish/httptool/pkg/report"
 	"github.com/vikasavnish/httptool/pkg/scenario"
 )
 
 func handleScenarioRun() {
 	if len(os.Args) < 3 {
-		fmt.Fprintln(os.Stderr, "Usage: httptool scenario run <scenario.httpx> [--scenario name] [--vus N] [--duration D] [--progress] [--verbose]")
+		fmt.Fprintln(os.Stderr, "Usage: httptool scenario run <scenario.httpx> [--scenario name] [--vus N] [--duration D] [--progress] [--verbose] [--out kind=path] [--contract api.yaml] [--persistent-evaluators]")
 		os.Exit(1)
 	}
 
@@ -21,6 +29,16 @@ func handleScenarioRun() {
 	// Check for flags
 	showProgress := hasFlag(os.Args, "--progress")
 	verbose := hasFlag(os.Args, "--verbose") || os.Getenv("VERBOSE") == "1"
+	outputs, err := parseOutputs(os.Args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	checker, err := loadContract(os.Args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
 
 	// Read scenario file
 	data, err := os.ReadFile(scenarioFile)
@@ -32,6 +50,7 @@ func handleScenarioRun() {
 	// Parse scenario
 	fmt.Printf("📋 Parsing scenario: %s\n", scenarioFile)
 	parser := scenario.NewParser(string(data))
+	parser.BaseDir = filepath.Dir(scenarioFile)
 	s, err := parser.Parse()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Parse error: %v\n", err)
@@ -41,6 +60,9 @@ func handleScenarioRun() {
 	fmt.Printf("✓ Parsed successfully\n")
 	fmt.Printf("  Variables: %d\n", len(s.Variables))
 	fmt.Printf("  Requests: %d\n", len(s.Requests))
+	if len(s.Data) > 0 {
+		fmt.Printf("  Data sets: %d\n", len(s.Data))
+	}
 	fmt.Printf("  Scenarios: %d\n", len(s.Scenarios))
 
 	// Determine which scenario to run
@@ -71,17 +93,50 @@ func handleScenarioRun() {
 
 	// Display load config
 	fmt.Printf("\n⚡ Load Configuration:\n")
-	if compiled.Load.VUs > 0 {
+	if len(compiled.Load.Stages) > 0 {
+		rate := false
+		for _, stage := range compiled.Load.Stages {
+			rate = rate || stage.RPS > 0
+		}
+		fmt.Printf("  Stages:\n")
+		for _, stage := range compiled.Load.Stages {
+			if rate {
+				fmt.Printf("    %s -> %d req/s\n", stage.Duration, stage.RPS)
+			} else {
+				fmt.Printf("    %s -> %d VUs\n", stage.Duration, stage.VUs)
+			}
+		}
+		if rate && compiled.Load.MaxVUs > 0 {
+			fmt.Printf("  Max VUs: %d\n", compiled.Load.MaxVUs)
+		}
+	} else if compiled.Load.VUs > 0 {
 		fmt.Printf("  Virtual Users: %d\n", compiled.Load.VUs)
 		fmt.Printf("  Duration: %s\n", compiled.Load.Duration)
+		if compiled.Load.RampUp != "" {
+			fmt.Printf("  Ramp-up: %s\n", compiled.Load.RampUp)
+		}
 	} else if compiled.Load.RPS > 0 {
 		fmt.Printf("  Requests/sec: %d\n", compiled.Load.RPS)
 		fmt.Printf("  Duration: %s\n", compiled.Load.Duration)
+		if compiled.Load.MaxVUs > 0 {
+			fmt.Printf("  Max VUs: %d\n", compiled.Load.MaxVUs)
+		}
 	} else if compiled.Load.Iterations > 0 {
 		fmt.Printf("  Iterations: %d\n", compiled.Load.Iterations)
 		fmt.Printf("  Virtual Users: %d\n", compiled.Load.VUs)
 	}
 
+	if len(compiled.Thresholds) > 0 {
+		fmt.Printf("\n🎯 Thresholds:\n")
+		for _, t := range compiled.Thresholds {
+			if t.AbortOnFail {
+				fmt.Printf("  %s (abort on fail)\n", t.Expr)
+			} else {
+				fmt.Printf("  %s\n", t.Expr)
+			}
+		}
+	}
+
 	// Check for dry-run
 	if hasFlag(os.Args, "--dry-run") {
 		fmt.Println("\n✓ Dry run complete (no execution)")
@@ -91,6 +146,9 @@ func handleScenarioRun() {
 	// Execute scenario
 	fmt.Printf("\n🏃 Executing scenario...\n\n")
 	executor := scenario.NewExecutor()
+	if hasFlag(os.Args, "--persistent-evaluators") {
+		executor.PersistentEvaluators()
+	}
 
 	// Setup progress tracking
 	var progressChan chan scenario.ProgressUpdate
@@ -101,6 +159,40 @@ func handleScenarioRun() {
 		go printProgress(progressChan, progressDone, verbose)
 	}
 
+	// Stream requests to NDJSON outputs as they finish
+	var streams []*os.File
+	var recorders []*report.NDJSON
+	for _, out := range outputs {
+		if out.kind != "ndjson" {
+			continue
+		}
+		f, err := os.Create(out.path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to create %s: %v\n", out.path, err)
+			os.Exit(1)
+		}
+		stream := report.NewNDJSON(f)
+		executor.OnRequest(stream.Record)
+		streams = append(streams, f)
+		recorders = append(recorders, stream)
+	}
+
+	// Record requests and responses for HAR outputs
+	var recorder *har.Recorder
+	for _, out := range outputs {
+		if out.kind == "har" && recorder == nil {
+			recorder = har.NewRecorder()
+			executor.OnExchange(recorder.Add)
+		}
+	}
+
+	// Check every response against the contract; IR IDs are request names
+	if checker != nil {
+		executor.OnExchange(func(_ time.Time, ctx *ir.EvaluationContext) {
+			checker.Check(ctx.IR.Metadata.ID, ctx)
+		})
+	}
+
 	startTime := time.Now()
 	result, err := executor.Execute(context.Background(), compiled)
 	if err != nil {
@@ -108,6 +200,13 @@ func handleScenarioRun() {
 		os.Exit(1)
 	}
 
+	for i, f := range streams {
+		if err := recorders[i].Err(); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to write %s: %v\n", f.Name(), err)
+		}
+		f.Close()
+	}
+
 	// Wait for progress printer to finish
 	if showProgress {
 		close(progressChan)
@@ -116,6 +215,37 @@ func handleScenarioRun() {
 
 	// Print results
 	printScenarioResults(result, startTime, verbose)
+
+	// Write machine-readable reports
+	for _, out := range outputs {
+		var err error
+		switch out.kind {
+		case "json":
+			err = writeReport(out.path, func(w io.Writer) error { return report.WriteJSON(w, result) })
+		case "junit":
+			err = writeReport(out.path, func(w io.Writer) error { return report.WriteJUnit(w, result) })
+		case "har":
+			err = writeReport(out.path, recorder.Write)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to write %s: %v\n", out.path, err)
+			os.Exit(1)
+		}
+		fmt.Printf("📝 Wrote %s report: %s\n", out.kind, out.path)
+	}
+
+	contractPassed := true
+	if checker != nil {
+		report := checker.Report()
+		printContractReport(report)
+		contractPassed = report.Passed()
+	}
+
+	// Failed thresholds and contract violations fail the run so CI can gate
+	// on it
+	if !result.ThresholdsPassed() || !contractPassed {
+		os.Exit(1)
+	}
 }
 
 func handleScenarioValidate() {
@@ -135,6 +265,7 @@ func handleScenarioValidate() {
 
 	// Parse scenario
 	parser := scenario.NewParser(string(data))
+	parser.BaseDir = filepath.Dir(scenarioFile)
 	s, err := parser.Parse()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "❌ Validation failed: %v\n", err)
@@ -154,6 +285,9 @@ func handleScenarioValidate() {
 	fmt.Println("✓ Scenario file is valid")
 	fmt.Printf("  Variables: %d\n", len(s.Variables))
 	fmt.Printf("  Requests: %d\n", len(s.Requests))
+	if len(s.Data) > 0 {
+		fmt.Printf("  Data sets: %d\n", len(s.Data))
+	}
 	fmt.Printf("  Scenarios: %d\n", len(s.Scenarios))
 
 	if len(s.Scenarios) > 0 {
@@ -180,6 +314,7 @@ func handleScenarioConvert() {
 	}
 
 	parser := scenario.NewParser(string(data))
+	parser.BaseDir = filepath.Dir(scenarioFile)
 	s, err := parser.Parse()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Parse error: %v\n", err)
@@ -295,14 +430,70 @@ func printScenarioResults(result *scenario.ScenarioResult, startTime time.Time,
 		fmt.Printf("  ✗ Failed:            %d (%.1f%%)\n",
 			result.Stats.FailedRequests,
 			float64(result.Stats.FailedRequests)/float64(result.Stats.TotalRequests)*100)
+		if result.Stats.Checks > 0 {
+			fmt.Printf("  Checks:              %d/%d passed\n",
+				result.Stats.Checks-result.Stats.ChecksFailed, result.Stats.Checks)
+		}
+		if result.Stats.Retries > 0 {
+			fmt.Printf("  Retries:             %d\n", result.Stats.Retries)
+		}
+		if result.Stats.DroppedIterations > 0 {
+			fmt.Printf("  ⚠ Dropped Iterations: %d (all VUs busy)\n", result.Stats.DroppedIterations)
+		}
 		fmt.Println()
 
 		fmt.Println("⚡ Latency:")
-		fmt.Printf("  Avg:  %8.2f ms\n", result.Stats.AvgLatency)
-		fmt.Printf("  Min:  %8.2f ms\n", result.Stats.MinLatency)
-		fmt.Printf("  Max:  %8.2f ms\n", result.Stats.MaxLatency)
+		fmt.Printf("  Avg:   %8.2f ms\n", result.Stats.AvgLatency)
+		fmt.Printf("  Min:   %8.2f ms\n", result.Stats.MinLatency)
+		fmt.Printf("  Max:   %8.2f ms\n", result.Stats.MaxLatency)
+		fmt.Printf("  p50:   %8.2f ms\n", result.Stats.Latency.P50)
+		fmt.Printf("  p90:   %8.2f ms\n", result.Stats.Latency.P90)
+		fmt.Printf("  p95:   %8.2f ms\n", result.Stats.Latency.P95)
+		fmt.Printf("  p99:   %8.2f ms\n", result.Stats.Latency.P99)
+		fmt.Printf("  p99.9: %8.2f ms\n", result.Stats.Latency.P999)
 		fmt.Println()
 
+		if len(result.Stats.ByRequest) > 0 {
+			names := make([]string, 0, len(result.Stats.ByRequest))
+			for name := range result.Stats.ByRequest {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			fmt.Println("📋 By Request (ms):")
+			printPercentileHeader("Request")
+			for _, name := range names {
+				printPercentileRow(name, result.Stats.ByRequest[name])
+			}
+			fmt.Println()
+		}
+
+		if len(result.Stats.ByStatus) > 0 {
+			statuses := make([]int, 0, len(result.Stats.ByStatus))
+			for status := range result.Stats.ByStatus {
+				statuses = append(statuses, status)
+			}
+			sort.Ints(statuses)
+
+			fmt.Println("🔢 By Status (ms):")
+			printPercentileHeader("Status")
+			for _, status := range statuses {
+				printPercentileRow(strconv.Itoa(status), result.Stats.ByStatus[status])
+			}
+			fmt.Println()
+		}
+
+		if len(result.Stats.Timing) > 0 {
+			fmt.Println("🌐 Timing (ms):")
+			printPercentileHeader("Phase")
+			for _, phase := range scenario.TimingPhases {
+				printPercentileRow(phase, result.Stats.Timing[phase])
+			}
+			fmt.Printf("  Reused connections: %d of %d requests\n",
+				result.Stats.ReusedConnections, result.Stats.Timing["wait"].Count)
+			fmt.Println()
+		}
+
 		fmt.Printf("📦 Data Transferred: %.2f MB\n", float64(result.Stats.TotalBytes)/(1024*1024))
 		fmt.Println()
 
@@ -312,6 +503,21 @@ func printScenarioResults(result *scenario.ScenarioResult, startTime time.Time,
 		}
 	}
 
+	if len(result.Thresholds) > 0 || result.AbortReason != "" {
+		fmt.Println()
+		fmt.Println("🎯 Thresholds:")
+		for _, t := range result.Thresholds {
+			mark := "✓"
+			if !t.Passed {
+				mark = "✗"
+			}
+			fmt.Printf("  %s %-32s actual: %s\n", mark, t.Threshold.Expr, t.Threshold.FormatValue(t.Actual))
+		}
+		if result.AbortReason != "" {
+			fmt.Printf("  ⚠ Aborted: %s\n", result.AbortReason)
+		}
+	}
+
 	fmt.Println(strings.Repeat("=", 70))
 	fmt.Println()
 
@@ -319,34 +525,26 @@ func printScenarioResults(result *scenario.ScenarioResult, startTime time.Time,
 	if verbose {
 		fmt.Println("Per-VU Results:")
 		for _, vu := range result.VUResults {
-			successCount := 0
-			errorCount := 0
-			totalLatency := time.Duration(0)
-
-			for _, iter := range vu.Iterations {
-				for _, req := range iter.Requests {
-					if req.Error != "" || req.AssertionsFailed > 0 {
-						errorCount++
-					} else {
-						successCount++
-					}
-					totalLatency += req.Latency
-				}
-			}
-
 			avgLatency := time.Duration(0)
-			totalReqs := successCount + errorCount
-			if totalReqs > 0 {
-				avgLatency = totalLatency / time.Duration(totalReqs)
+			if vu.Requests > 0 {
+				avgLatency = vu.TotalLatency / time.Duration(vu.Requests)
 			}
 
 			fmt.Printf("  VU %d: %d iterations, %d requests (✓ %d, ✗ %d), avg latency: %dms\n",
-				vu.VUID, len(vu.Iterations), totalReqs, successCount, errorCount, avgLatency.Milliseconds())
+				vu.VUID, vu.Iterations, vu.Requests, vu.Requests-vu.Failed, vu.Failed, avgLatency.Milliseconds())
 		}
 		fmt.Println()
 	}
 }
 
+func printPercentileHeader(label string) {
+	fmt.Printf("  %-24s %8s %8s %8s %8s %8s %8s\n", label, "count", "p50", "p90", "p95", "p99", "p99.9")
+}
+
+func printPercentileRow(label string, s histogram.Summary) {
+	fmt.Printf("  %-24s %8d %8.2f %8.2f %8.2f %8.2f %8.2f\n", label, s.Count, s.P50, s.P90, s.P95, s.P99, s.P999)
+}
+
 func findScenarioToRun(s *scenario.Scenario, args []string) string {
 	// Check for --scenario flag
 	for i, arg := range args {
@@ -377,6 +575,53 @@ func findScenarioToRun(s *scenario.Scenario, args []string) string {
 	return ""
 }
 
+// output is a --out kind=path destination for results
+type output struct {
+	kind string // json, ndjson, junit or har
+	path string
+}
+
+// parseOutputs collects every --out kind=path (or --out=kind=path) flag
+func parseOutputs(args []string) ([]output, error) {
+	var outputs []output
+	for i, arg := range args {
+		var spec string
+		switch {
+		case arg == "--out" && i+1 < len(args):
+			spec = args[i+1]
+		case strings.HasPrefix(arg, "--out="):
+			spec = strings.TrimPrefix(arg, "--out=")
+		default:
+			continue
+		}
+
+		kind, path, ok := strings.Cut(spec, "=")
+		if !ok || path == "" {
+			return nil, fmt.Errorf("invalid --out %q, expected kind=path", spec)
+		}
+		switch kind {
+		case "json", "ndjson", "junit", "har":
+		default:
+			return nil, fmt.Errorf("unknown --out kind %q (use json, ndjson, junit or har)", kind)
+		}
+		outputs = append(outputs, output{kind: kind, path: path})
+	}
+	return outputs, nil
+}
+
+// writeReport creates path and writes a report to it
+func writeReport(path string, write func(io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
 func hasFlag(args []string, flag string) bool {
 	for _, arg := range args {
 		if arg == flag {
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vikasavnish/httptool/pkg/orchestrator"
+	"github.com/vikasavnish/httptool/pkg/workflow"
+)
+
+func handleWorkflowCommand() {
+	if len(os.Args) < 3 {
+		printWorkflowUsage()
+		os.Exit(1)
+	}
+
+	switch os.Args[2] {
+	case "run":
+		handleWorkflowRun()
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown workflow command: %s\n", os.Args[2])
+		printWorkflowUsage()
+		os.Exit(1)
+	}
+}
+
+func printWorkflowUsage() {
+	fmt.Print(`httptool workflow - Multi-step IR workflows
+
+Usage:
+  httptool workflow run <workflow.json>   Run the steps in order
+
+Options:
+  --max-retries <N>   Attempts per step, including the first (default 3)
+
+Steps run through the evaluator named in their IR. Values the evaluator
+extracts fill in ${name} references of later steps, and a branch decision
+jumps to the step named in actions.goto. The run stops at the first failure.
+
+Example:
+  httptool workflow run examples/workflow-example.json
+`)
+}
+
+func handleWorkflowRun() {
+	if len(os.Args) < 4 {
+		fmt.Fprintln(os.Stderr, "Usage: httptool workflow run <workflow.json> [--max-retries N]")
+		os.Exit(1)
+	}
+
+	maxRetries := 3
+	for i, arg := range os.Args {
+		if arg == "--max-retries" && i+1 < len(os.Args) {
+			n, err := strconv.Atoi(os.Args[i+1])
+			if err != nil || n < 1 {
+				fmt.Fprintf(os.Stderr, "--max-retries must be a positive number, got %s\n", os.Args[i+1])
+				os.Exit(1)
+			}
+			maxRetries = n
+		}
+	}
+
+	wf, err := workflow.Load(os.Args[3])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load workflow: %v\n", err)
+		os.Exit(1)
+	}
+
+	o := orchestrator.NewOrchestrator(maxRetries, 5*time.Second)
+	defer o.Close()
+
+	report, err := workflow.NewRunner(o).Run(context.Background(), wf)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Workflow error: %v\n", err)
+		os.Exit(1)
+	}
+
+	printWorkflowReport(report)
+	if report.Failed() {
+		os.Exit(1)
+	}
+}
+
+func printWorkflowReport(report *workflow.Report) {
+	fmt.Println(strings.Repeat("=", 70))
+	fmt.Printf("  Workflow: %s\n", report.Name)
+	fmt.Println(strings.Repeat("=", 70))
+	fmt.Println()
+
+	for _, step := range report.Steps {
+		mark := "✓"
+		if step.Error != nil {
+			mark = "✗"
+		}
+		fmt.Printf("%s %s\n", mark, step.Name)
+
+		if result := step.Result; result != nil {
+			if result.Context != nil {
+				resp := result.Context.Response
+				fmt.Printf("    %s %s → %d in %.2fms", result.Context.Request.Method, result.Context.Request.URL, resp.Status, resp.LatencyMs)
+				if result.Attempt > 1 {
+					fmt.Printf(" (attempt %d)", result.Attempt)
+				}
+				fmt.Println()
+			}
+			if len(result.Branches) > 0 {
+				fmt.Printf("    ↪ %s\n", strings.Join(result.Branches, " → "))
+			}
+			if result.Decision != nil {
+				fmt.Printf("    Decision: %s", result.Decision.Decision)
+				if result.Decision.Reason != "" {
+					fmt.Printf(" (%s)", result.Decision.Reason)
+				}
+				fmt.Println()
+			}
+			names := make([]string, 0, len(result.Extracted))
+			for name := range result.Extracted {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				fmt.Printf("    Extracted: %s = %v\n", name, result.Extracted[name])
+			}
+		}
+		if step.Error != nil {
+			fmt.Printf("    Error: %v\n", step.Error)
+		}
+	}
+	for _, name := range report.Skipped {
+		fmt.Printf("- %s (skipped)\n", name)
+	}
+
+	fmt.Printf("\n%d of %d steps passed in %v", report.Passed(), len(report.Steps)+len(report.Skipped), report.EndTime.Sub(report.StartTime).Round(time.Millisecond))
+	if len(report.Skipped) > 0 {
+		fmt.Printf(", %d skipped", len(report.Skipped))
+	}
+	fmt.Println()
+}
@@ -0,0 +1,196 @@
+// Package arrival implements open-model load generation: iterations start at
+// a scheduled rate regardless of how long earlier ones take, and run on a
+// bounded pool of VUs.
+package arrival
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// Stage ramps the arrival rate linearly to Rate (per second) over Duration
+type Stage struct {
+	Duration time.Duration
+	Rate     float64
+}
+
+// Schedule describes the arrival rate over time
+type Schedule struct {
+	StartRate float64 // Rate at the beginning of the first stage
+	Stages    []Stage
+}
+
+// Constant returns a schedule with a fixed rate for the given duration
+func Constant(rate float64, duration time.Duration) Schedule {
+	return Schedule{
+		StartRate: rate,
+		Stages:    []Stage{{Duration: duration, Rate: rate}},
+	}
+}
+
+// Validate checks that the schedule has a positive total rate
+func (s Schedule) Validate() error {
+	if s.StartRate < 0 {
+		return fmt.Errorf("arrival rate must not be negative")
+	}
+
+	total := 0.0
+	from := s.StartRate
+	for i, stage := range s.Stages {
+		if stage.Duration < 0 || stage.Rate < 0 {
+			return fmt.Errorf("stage %d has a negative duration or rate", i+1)
+		}
+		total += (from + stage.Rate) / 2 * stage.Duration.Seconds()
+		from = stage.Rate
+	}
+
+	if total <= 0 {
+		return fmt.Errorf("arrival rate schedule starts no iterations")
+	}
+	return nil
+}
+
+// Peak returns the highest rate in the schedule
+func (s Schedule) Peak() float64 {
+	peak := s.StartRate
+	for _, stage := range s.Stages {
+		peak = math.Max(peak, stage.Rate)
+	}
+	return peak
+}
+
+// Offset returns when the n-th (0-based) iteration starts, relative to the
+// start of the schedule, and false once the schedule has ended.
+func (s Schedule) Offset(n int) (time.Duration, bool) {
+	remaining := float64(n)
+	var elapsed time.Duration
+	from := s.StartRate
+
+	for _, stage := range s.Stages {
+		seconds := stage.Duration.Seconds()
+		count := (from + stage.Rate) / 2 * seconds
+
+		if remaining < count {
+			t := solveArrival(from, stage.Rate, seconds, remaining)
+			return elapsed + time.Duration(t*float64(time.Second)), true
+		}
+
+		remaining -= count
+		elapsed += stage.Duration
+		from = stage.Rate
+	}
+
+	return 0, false
+}
+
+// solveArrival returns the time t within a stage ramping from r0 to r1 over
+// d seconds at which m arrivals have occurred: r0*t + (r1-r0)*t²/(2d) = m
+func solveArrival(r0, r1, d, m float64) float64 {
+	a := (r1 - r0) / (2 * d)
+	if math.Abs(a) < 1e-12 {
+		return m / r0
+	}
+	return (-r0 + math.Sqrt(r0*r0+4*a*m)) / (2 * a)
+}
+
+// Pool bounds the VUs available to run iterations
+type Pool struct {
+	PreAllocated int // VUs started up front
+	Max          int // Upper bound when pre-allocated VUs are all busy; 0 for PreAllocated
+}
+
+// Result summarises an arrival-rate run
+type Result struct {
+	Started int // Iterations started
+	Dropped int // Iterations skipped because every VU was busy
+	VUs     int // VUs allocated
+}
+
+// Run starts iterations on schedule until it ends or ctx is cancelled, then
+// waits for iterations in flight. iterate is called with the VU ID (from 1)
+// and that VU's iteration number (from 1); a VU runs one iteration at a time.
+// An iteration is dropped only when every VU is busy and the pool is at Max.
+func Run(ctx context.Context, schedule Schedule, pool Pool, iterate func(ctx context.Context, vu, iter int)) (Result, error) {
+	var result Result
+	var wg sync.WaitGroup
+
+	if pool.PreAllocated < 1 {
+		pool.PreAllocated = 1
+	}
+	if pool.Max == 0 {
+		pool.Max = pool.PreAllocated
+	}
+	if pool.Max < pool.PreAllocated {
+		return result, fmt.Errorf("%d pre-allocated VUs exceed the maximum of %d", pool.PreAllocated, pool.Max)
+	}
+
+	// Each idle VU holds a token in idle, so taking one guarantees a VU
+	// will receive the job even if its goroutine has not reached jobs yet
+	idle := make(chan struct{}, pool.Max)
+	jobs := make(chan struct{}, pool.Max)
+
+	startVU := func() {
+		result.VUs++
+		wg.Add(1)
+		go func(vu int) {
+			defer wg.Done()
+			iter := 0
+			for range jobs {
+				iter++
+				iterate(ctx, vu, iter)
+				idle <- struct{}{}
+			}
+		}(result.VUs)
+	}
+
+	for result.VUs < pool.PreAllocated {
+		startVU()
+		idle <- struct{}{}
+	}
+
+	start := time.Now()
+	timer := time.NewTimer(0)
+	<-timer.C
+	defer timer.Stop()
+
+schedule:
+	for n := 0; ; n++ {
+		offset, ok := schedule.Offset(n)
+		if !ok {
+			break
+		}
+
+		if wait := time.Until(start.Add(offset)); wait > 0 {
+			timer.Reset(wait)
+			select {
+			case <-ctx.Done():
+				break schedule
+			case <-timer.C:
+			}
+		} else if ctx.Err() != nil {
+			break
+		}
+
+		select {
+		case <-idle:
+			jobs <- struct{}{}
+			result.Started++
+		default:
+			if result.VUs < pool.Max {
+				startVU()
+				jobs <- struct{}{}
+				result.Started++
+			} else {
+				result.Dropped++
+			}
+		}
+	}
+
+	close(jobs)
+	wg.Wait()
+
+	return result, nil
+}
@@ -0,0 +1,111 @@
+package arrival
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSchedule_OffsetConstant(t *testing.T) {
+	s := Constant(10, time.Second)
+
+	tests := []struct {
+		n    int
+		want time.Duration
+	}{
+		{0, 0},
+		{1, 100 * time.Millisecond},
+		{5, 500 * time.Millisecond},
+		{9, 900 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		got, ok := s.Offset(tt.n)
+		if !ok || (got-tt.want).Abs() > time.Microsecond {
+			t.Errorf("Offset(%d) = %s, %v; want %s", tt.n, got, ok, tt.want)
+		}
+	}
+
+	if _, ok := s.Offset(10); ok {
+		t.Error("Offset(10) should be past the end of a 10 rps, 1s schedule")
+	}
+}
+
+func TestSchedule_OffsetRamp(t *testing.T) {
+	// 0 -> 20 rps over 2s starts 20 iterations; half of them by ~1.41s
+	s := Schedule{Stages: []Stage{{Duration: 2 * time.Second, Rate: 20}}}
+
+	got, ok := s.Offset(10)
+	want := time.Duration(1.41421356 * float64(time.Second))
+	if !ok || (got-want).Abs() > time.Millisecond {
+		t.Errorf("Offset(10) = %s, %v; want %s", got, ok, want)
+	}
+
+	if _, ok := s.Offset(20); ok {
+		t.Error("ramp should start exactly 20 iterations")
+	}
+}
+
+func TestSchedule_Validate(t *testing.T) {
+	if err := Constant(0, time.Second).Validate(); err == nil {
+		t.Error("zero rate should be rejected")
+	}
+	if err := Constant(5, 0).Validate(); err == nil {
+		t.Error("zero duration should be rejected")
+	}
+}
+
+func TestRun_DropsWhenPoolExhausted(t *testing.T) {
+	var running, peak atomic.Int32
+
+	result, err := Run(context.Background(), Constant(100, 200*time.Millisecond), Pool{PreAllocated: 1, Max: 2},
+		func(ctx context.Context, vu, iter int) {
+			if n := running.Add(1); n > peak.Load() {
+				peak.Store(n)
+			}
+			time.Sleep(50 * time.Millisecond)
+			running.Add(-1)
+		})
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	if result.VUs != 2 || peak.Load() > 2 {
+		t.Errorf("pool grew to %d VUs with %d concurrent iterations; max is 2", result.VUs, peak.Load())
+	}
+	if result.Started+result.Dropped != 20 {
+		t.Errorf("started %d + dropped %d, want 20 scheduled", result.Started, result.Dropped)
+	}
+	if result.Dropped == 0 {
+		t.Error("expected dropped iterations with slow iterations and a small pool")
+	}
+}
+
+func TestRun_NoDropsWhenVUsIdle(t *testing.T) {
+	result, err := Run(context.Background(), Constant(100, 200*time.Millisecond), Pool{PreAllocated: 2},
+		func(ctx context.Context, vu, iter int) {})
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	if result.Dropped != 0 || result.Started != 20 {
+		t.Errorf("started %d, dropped %d; want all 20 started on idle VUs", result.Started, result.Dropped)
+	}
+	if result.VUs != 2 {
+		t.Errorf("pool has %d VUs, want the 2 pre-allocated", result.VUs)
+	}
+}
+
+func TestRun_RejectsPreAllocatedOverMax(t *testing.T) {
+	var calls atomic.Int32
+
+	_, err := Run(context.Background(), Constant(100, 100*time.Millisecond), Pool{PreAllocated: 4, Max: 2},
+		func(ctx context.Context, vu, iter int) { calls.Add(1) })
+	if err == nil {
+		t.Fatal("expected an error for more pre-allocated VUs than max")
+	}
+	if calls.Load() != 0 {
+		t.Errorf("ran %d iterations after rejecting the pool", calls.Load())
+	}
+}
@@ -0,0 +1,344 @@
+// Package contract checks live responses against an OpenAPI 3 spec. Each
+// executed request is matched to an operation by method and path, and the
+// response's status code, required headers, content type and body are
+// compared with what the operation documents.
+package contract
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/vikasavnish/httptool/pkg/ir"
+	"github.com/vikasavnish/httptool/pkg/jsonschema"
+	"github.com/vikasavnish/httptool/pkg/openapi"
+)
+
+// Violation is one way a response broke the contract
+type Violation struct {
+	Kind    string `json:"kind"` // operation, status, header, content_type or body
+	Message string `json:"message"`
+}
+
+func (v Violation) String() string {
+	return v.Message
+}
+
+// Checker validates responses against a spec and keeps the results for a
+// Report. It is safe for concurrent use, e.g. from scenario VUs.
+type Checker struct {
+	spec     *openapi.Spec
+	ops      []*openapi.Operation
+	routes   []*route
+	prefixes []string // Base paths of the spec's servers, longest first
+
+	mu       sync.Mutex
+	schemas  map[string]*jsonschema.Schema // By operation, status and media type
+	requests map[string]*RequestReport
+	order    []string
+	calls    map[*openapi.Operation]int
+}
+
+// route matches request paths to an operation's path template
+type route struct {
+	op      *openapi.Operation
+	pattern *regexp.Regexp
+	params  int
+}
+
+// templateParam matches an escaped {name} in a quoted path template
+var templateParam = regexp.MustCompile(`\\\{[^{}/]+\\\}`)
+
+// Load reads a spec and creates a checker for it
+func Load(path string) (*Checker, error) {
+	spec, err := openapi.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	return New(spec)
+}
+
+// New creates a checker for a spec
+func New(spec *openapi.Spec) (*Checker, error) {
+	ops, err := spec.Operations()
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Checker{
+		spec:     spec,
+		ops:      ops,
+		schemas:  make(map[string]*jsonschema.Schema),
+		requests: make(map[string]*RequestReport),
+		calls:    make(map[*openapi.Operation]int),
+	}
+
+	for _, op := range ops {
+		pattern := templateParam.ReplaceAllString(regexp.QuoteMeta(op.Path), `[^/]+`)
+		c.routes = append(c.routes, &route{
+			op:      op,
+			pattern: regexp.MustCompile("^" + pattern + "$"),
+			params:  strings.Count(op.Path, "{"),
+		})
+	}
+	// Literal paths win over templates, so /users/me is not /users/{id}
+	sort.SliceStable(c.routes, func(i, j int) bool { return c.routes[i].params < c.routes[j].params })
+
+	for _, server := range spec.Servers {
+		base := server.URL
+		for name, v := range server.Variables {
+			base = strings.ReplaceAll(base, "{"+name+"}", v.Default)
+		}
+		if u, err := url.Parse(base); err == nil {
+			if prefix := strings.TrimRight(u.Path, "/"); prefix != "" {
+				c.prefixes = append(c.prefixes, prefix)
+			}
+		}
+	}
+	sort.Slice(c.prefixes, func(i, j int) bool { return len(c.prefixes[i]) > len(c.prefixes[j]) })
+
+	return c, nil
+}
+
+// Spec returns the spec requests are checked against
+func (c *Checker) Spec() *openapi.Spec {
+	return c.spec
+}
+
+// Check matches an executed request to an operation, validates the response
+// and records the result. name identifies the request in the report; the
+// method and path are used when it is empty. Requests that got no response,
+// e.g. because the connection was refused, are not checked.
+func (c *Checker) Check(name string, ctx *ir.EvaluationContext) []Violation {
+	if ctx == nil || ctx.Response == nil || ctx.Response.Status == 0 {
+		return nil
+	}
+
+	method := strings.ToUpper(ctx.Request.Method)
+	path := "/"
+	if u, err := url.Parse(ctx.Request.URL); err == nil && u.Path != "" {
+		path = u.Path
+	}
+	if name == "" {
+		name = method + " " + path
+	}
+
+	var violations []Violation
+	op := c.match(method, path)
+	if op == nil {
+		violations = []Violation{{Kind: "operation", Message: fmt.Sprintf("no operation in the spec matches %s %s", method, path)}}
+	} else {
+		violations = c.validate(op, ctx.Response)
+	}
+
+	c.record(name, op, violations)
+	return violations
+}
+
+// match finds the operation of a request, trying the path with each server's
+// base path removed and then as it is
+func (c *Checker) match(method, path string) *openapi.Operation {
+	candidates := make([]string, 0, len(c.prefixes)+1)
+	for _, prefix := range c.prefixes {
+		if rest, ok := strings.CutPrefix(path, prefix); ok && (rest == "" || rest[0] == '/') {
+			if rest == "" {
+				rest = "/"
+			}
+			candidates = append(candidates, rest)
+		}
+	}
+	candidates = append(candidates, path)
+
+	for _, candidate := range candidates {
+		for _, r := range c.routes {
+			if r.op.Method == method && r.pattern.MatchString(candidate) {
+				return r.op
+			}
+		}
+	}
+	return nil
+}
+
+// validate compares a response with what the operation documents for its
+// status code
+func (c *Checker) validate(op *openapi.Operation, resp *ir.Response) []Violation {
+	code, documented := responseFor(op, resp.Status)
+	if documented == nil {
+		return []Violation{{Kind: "status", Message: fmt.Sprintf("status %d is not documented (expected %s)", resp.Status, strings.Join(sortedKeys(op.Responses), ", "))}}
+	}
+
+	var violations []Violation
+	for _, name := range sortedKeys(documented.Headers) {
+		h := documented.Headers[name]
+		if h.Ref != "" {
+			h = &openapi.Header{}
+			if err := c.spec.Resolve(documented.Headers[name].Ref, h); err != nil {
+				violations = append(violations, Violation{Kind: "header", Message: fmt.Sprintf("header %s: %v", name, err)})
+				continue
+			}
+		}
+		// Content-Type is described by the content, not as a header
+		if h.Required && !strings.EqualFold(name, "Content-Type") && headerValue(resp.Headers, name) == "" {
+			violations = append(violations, Violation{Kind: "header", Message: fmt.Sprintf("missing required header %s", name)})
+		}
+	}
+
+	contentType := headerValue(resp.Headers, "Content-Type")
+	if len(documented.Content) == 0 {
+		if resp.SizeBytes > 0 {
+			violations = append(violations, Violation{Kind: "content_type", Message: fmt.Sprintf("status %d documents no body, got %d bytes of %s", resp.Status, resp.SizeBytes, orNone(contentType))})
+		}
+		return violations
+	}
+	if resp.SizeBytes == 0 {
+		return append(violations, Violation{Kind: "content_type", Message: fmt.Sprintf("empty body, expected %s", strings.Join(sortedKeys(documented.Content), " or "))})
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	key := matchMedia(documented.Content, mediaType)
+	if key == "" {
+		return append(violations, Violation{Kind: "content_type", Message: fmt.Sprintf("content type %s is not documented (expected %s)", orNone(contentType), strings.Join(sortedKeys(documented.Content), " or "))})
+	}
+
+	mt := documented.Content[key]
+	if mt.Schema == nil || !isJSON(mediaType) {
+		return violations
+	}
+	schema, err := c.schema(op, code, key, mt.Schema)
+	if err != nil {
+		return append(violations, Violation{Kind: "body", Message: fmt.Sprintf("schema of %s response: %v", code, err)})
+	}
+	if err := schema.Validate(resp.Body); err != nil {
+		var errs jsonschema.Errors
+		if !errors.As(err, &errs) {
+			return append(violations, Violation{Kind: "body", Message: err.Error()})
+		}
+		violations = append(violations, Violation{Kind: "body", Message: fmt.Sprintf("body does not match the schema: %v", errs)})
+	}
+
+	return violations
+}
+
+// responseFor picks the documented response of a status code: the code
+// itself, its range such as 4XX, or default
+func responseFor(op *openapi.Operation, status int) (string, *openapi.Response) {
+	code := strconv.Itoa(status)
+	for _, key := range []string{code, code[:1] + "XX", code[:1] + "xx", "default"} {
+		if resp, ok := op.Responses[key]; ok {
+			return key, resp
+		}
+	}
+	return "", nil
+}
+
+// matchMedia picks the documented media type of a content type: the type
+// itself, a wildcard such as application/*, or */*
+func matchMedia(content map[string]openapi.MediaType, mediaType string) string {
+	if mediaType == "" {
+		return ""
+	}
+	major, _, _ := strings.Cut(mediaType, "/")
+	for _, want := range []string{mediaType, major + "/*", "*/*"} {
+		for key := range content {
+			documented, _, err := mime.ParseMediaType(key)
+			if err == nil && documented == want {
+				return key
+			}
+		}
+	}
+	return ""
+}
+
+// schema compiles the JSON Schema of a response's media type, once
+func (c *Checker) schema(op *openapi.Operation, code, mediaType string, schema map[string]any) (*jsonschema.Schema, error) {
+	key := op.Method + " " + op.Path + " " + code + " " + mediaType
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if compiled, ok := c.schemas[key]; ok {
+		return compiled, nil
+	}
+
+	// $refs into the components resolve against the root of the schema
+	root := toJSONSchema(schema).(map[string]any)
+	if _, ok := root["components"]; !ok {
+		root["components"] = toJSONSchema(c.spec.Document()["components"])
+	}
+	data, err := json.Marshal(root)
+	if err != nil {
+		return nil, err
+	}
+	compiled, err := jsonschema.Compile(data)
+	if err != nil {
+		return nil, err
+	}
+	c.schemas[key] = compiled
+	return compiled, nil
+}
+
+// record adds a checked request to the report
+func (c *Checker) record(name string, op *openapi.Operation, violations []Violation) {
+	operation := ""
+	if op != nil {
+		operation = op.Method + " " + op.Path
+	}
+	key := name + "\x00" + operation
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	req, ok := c.requests[key]
+	if !ok {
+		req = &RequestReport{Name: name, Operation: operation}
+		c.requests[key] = req
+		c.order = append(c.order, key)
+	}
+	req.Calls++
+	if len(violations) > 0 {
+		req.Failed++
+	}
+	for _, v := range violations {
+		req.add(v)
+	}
+
+	if op != nil {
+		c.calls[op]++
+	}
+}
+
+// headerValue looks a header up by name, ignoring case
+func headerValue(headers map[string]string, name string) string {
+	for k, v := range headers {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return ""
+}
+
+func isJSON(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+func orNone(contentType string) string {
+	if contentType == "" {
+		return "no content type"
+	}
+	return contentType
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
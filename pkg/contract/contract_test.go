@@ -0,0 +1,245 @@
+package contract
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/vikasavnish/httptool/pkg/ir"
+	"github.com/vikasavnish/httptool/pkg/openapi"
+)
+
+// exchange builds the context of an executed request the way the executor
+// does, decoding JSON bodies
+func exchange(method, url string, status int, headers map[string]string, body string) *ir.EvaluationContext {
+	resp := &ir.Response{Status: status, Headers: headers, SizeBytes: int64(len(body))}
+	var decoded any
+	if err := json.Unmarshal([]byte(body), &decoded); err == nil {
+		resp.Body = decoded
+	} else if body != "" {
+		resp.Body = body
+	}
+	return &ir.EvaluationContext{
+		Request:  &ir.ExecutedRequest{Method: method, URL: url},
+		Response: resp,
+	}
+}
+
+var jsonType = map[string]string{"Content-Type": "application/json; charset=utf-8"}
+
+func TestChecker_Check(t *testing.T) {
+	checker, err := Load("../../examples/openapi-example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		ctx  *ir.EvaluationContext
+		want []string
+	}{
+		{
+			"valid",
+			exchange("GET", "http://localhost/v1/users/42", 200, jsonType, `{"id": 42, "name": "Ada", "email": "ada@example.com"}`),
+			nil,
+		},
+		{
+			"without the server's base path",
+			exchange("GET", "http://localhost/users/42?fields=name", 200, jsonType, `{"id": 42, "name": "Ada", "email": "ada@example.com", "role": "admin"}`),
+			nil,
+		},
+		{
+			"body",
+			exchange("GET", "http://localhost/v1/users/42", 200, jsonType, `{"id": "42", "name": "Ada", "role": "owner"}`),
+			[]string{"body does not match the schema: /email: required property is missing; /id: expected integer, got string; /role: must be one of [\"member\",\"admin\"]"},
+		},
+		{
+			"undocumented status",
+			exchange("GET", "http://localhost/v1/users/42", 500, jsonType, `{"message": "boom"}`),
+			[]string{"status 500 is not documented (expected 200, 404)"},
+		},
+		{
+			"required header",
+			exchange("GET", "http://localhost/v1/users", 200, jsonType, `[]`),
+			[]string{"missing required header X-Total-Count"},
+		},
+		{
+			"content type",
+			exchange("GET", "http://localhost/v1/users/42", 404, map[string]string{"Content-Type": "text/html"}, `<p>Not found</p>`),
+			[]string{"content type text/html is not documented (expected application/json)"},
+		},
+		{
+			"empty body",
+			exchange("POST", "http://localhost/v1/users", 201, nil, ``),
+			[]string{"empty body, expected application/json"},
+		},
+		{
+			"no content documented",
+			exchange("DELETE", "http://localhost/v1/users/42", 204, nil, ``),
+			nil,
+		},
+		{
+			"default response",
+			exchange("GET", "http://localhost/v1/health", 503, map[string]string{"Content-Type": "text/plain"}, `down`),
+			[]string{"status 503 documents no body, got 4 bytes of text/plain"},
+		},
+		{
+			"range response",
+			exchange("PUT", "http://localhost/v1/users/42/avatar", 202, nil, ``),
+			nil,
+		},
+		{
+			"no operation",
+			exchange("GET", "http://localhost/v1/stats", 200, jsonType, `{}`),
+			[]string{"no operation in the spec matches GET /v1/stats"},
+		},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, v := range checker.Check(tt.name, tt.ctx) {
+			got = append(got, v.Message)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: violations = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	// Connection errors are not checked
+	if v := checker.Check("refused", exchange("GET", "http://localhost/v1/health", 0, nil, "")); v != nil {
+		t.Errorf("refused: violations = %v", v)
+	}
+
+	report := checker.Report()
+	if len(report.Requests) != len(tests) || report.Passed() {
+		t.Errorf("requests = %+v", report.Requests)
+	}
+	if req := report.Requests[2]; req.Operation != "GET /users/{userId}" || req.Calls != 1 || req.Failed != 1 || req.Violations[0].Kind != "body" {
+		t.Errorf("body request = %+v", req)
+	}
+	if req := report.Requests[10]; req.Operation != "" || req.Violations[0].Kind != "operation" {
+		t.Errorf("unmatched request = %+v", req)
+	}
+
+	calls := make(map[string]int)
+	for _, op := range report.Operations {
+		calls[op.Operation] = op.Calls
+	}
+	wantCalls := map[string]int{
+		"GET /health":                1,
+		"POST /login":                0,
+		"GET /users":                 1,
+		"POST /users":                1,
+		"GET /users/{userId}":        5,
+		"PATCH /users/{userId}":      0,
+		"DELETE /users/{userId}":     1,
+		"PUT /users/{userId}/avatar": 1,
+	}
+	if !reflect.DeepEqual(calls, wantCalls) || report.Covered() != 6 {
+		t.Errorf("coverage = %v (%d covered), want %v", calls, report.Covered(), wantCalls)
+	}
+}
+
+func TestChecker_Report(t *testing.T) {
+	checker, err := Load("../../examples/openapi-example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		checker.Check("get_user", exchange("GET", "http://localhost/v1/users/"+strconv.Itoa(i), 500, nil, ""))
+	}
+	checker.Check("get_user", exchange("GET", "http://localhost/v1/users/4", 404, jsonType, `{"message": "not found"}`))
+
+	report := checker.Report()
+	want := []RequestReport{{
+		Name:      "get_user",
+		Operation: "GET /users/{userId}",
+		Calls:     4,
+		Failed:    3,
+		Violations: []ViolationCount{{
+			Violation: Violation{Kind: "status", Message: "status 500 is not documented (expected 200, 404)"},
+			Count:     3,
+		}},
+	}}
+	if !reflect.DeepEqual(report.Requests, want) {
+		t.Errorf("requests = %+v, want %+v", report.Requests, want)
+	}
+	if report.Title != "Users API" || report.Covered() != 1 {
+		t.Errorf("report = %+v", report)
+	}
+}
+
+// The 3.0 schema keywords that differ from JSON Schema
+const legacySpec = `
+openapi: 3.0.3
+info: {title: Legacy, version: "1"}
+paths:
+  /accounts/me:
+    get:
+      responses:
+        "200":
+          description: The account
+          content:
+            application/vnd.api+json:
+              schema: {$ref: "#/components/schemas/Account"}
+  /accounts/{id}:
+    get:
+      responses:
+        "200":
+          description: Any account
+          content:
+            "*/*": {}
+components:
+  schemas:
+    Account:
+      type: object
+      required: [id, secret, manager]
+      properties:
+        id: {type: integer, minimum: 0, exclusiveMinimum: true}
+        secret: {type: string, writeOnly: true}
+        nickname: {type: string, nullable: true}
+        manager:
+          nullable: true
+          allOf: [{$ref: "#/components/schemas/Manager"}]
+    Manager:
+      type: object
+      required: [name]
+      properties:
+        name: {type: string}
+`
+
+func TestChecker_OpenAPI30(t *testing.T) {
+	spec, err := openapi.Parse([]byte(legacySpec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checker, err := New(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	apiJSON := map[string]string{"Content-Type": "application/vnd.api+json"}
+	tests := []struct {
+		body string
+		want []string
+	}{
+		{`{"id": 1, "nickname": null, "manager": null}`, nil},
+		{`{"id": 1, "nickname": "A", "manager": {"name": "Grace"}}`, nil},
+		{`{"id": 0, "manager": {}}`, []string{"body does not match the schema: /id: must be > 0; /manager: must match at least one schema in anyOf"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		// /accounts/me is a literal path, so it wins over /accounts/{id}
+		for _, v := range checker.Check("", exchange("GET", "http://localhost/accounts/me", 200, apiJSON, tt.body)) {
+			got = append(got, v.Message)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: violations = %q, want %q", tt.body, got, tt.want)
+		}
+	}
+
+	if v := checker.Check("", exchange("GET", "http://localhost/accounts/7", 200, map[string]string{"Content-Type": "image/png"}, "PNG")); v != nil {
+		t.Errorf("wildcard content: violations = %v", v)
+	}
+}
@@ -0,0 +1,91 @@
+package contract
+
+// Report summarizes a run: the violations of each request, and which of the
+// spec's operations were called
+type Report struct {
+	Title      string              `json:"title"`
+	Version    string              `json:"version"`
+	Requests   []RequestReport     `json:"requests"`
+	Operations []OperationCoverage `json:"operations"`
+}
+
+// RequestReport is the result of the calls of one request to one operation
+type RequestReport struct {
+	Name       string           `json:"name"`
+	Operation  string           `json:"operation,omitempty"` // e.g. GET /users/{id}; empty when none matched
+	Calls      int              `json:"calls"`
+	Failed     int              `json:"failed"` // Calls with violations
+	Violations []ViolationCount `json:"violations,omitempty"`
+}
+
+// ViolationCount is a violation and how many calls had it
+type ViolationCount struct {
+	Violation
+	Count int `json:"count"`
+}
+
+// OperationCoverage is how often an operation was called
+type OperationCoverage struct {
+	Operation   string `json:"operation"`
+	OperationID string `json:"operation_id,omitempty"`
+	Calls       int    `json:"calls"`
+}
+
+// add counts a violation, keeping the order they were first seen in
+func (r *RequestReport) add(v Violation) {
+	for i := range r.Violations {
+		if r.Violations[i].Violation == v {
+			r.Violations[i].Count++
+			return
+		}
+	}
+	r.Violations = append(r.Violations, ViolationCount{Violation: v, Count: 1})
+}
+
+// Report returns the results so far, requests in the order they were first
+// checked and operations in the order of the spec
+func (c *Checker) Report() *Report {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	report := &Report{
+		Title:      c.spec.Info.Title,
+		Version:    c.spec.Info.Version,
+		Requests:   make([]RequestReport, 0, len(c.order)),
+		Operations: make([]OperationCoverage, 0, len(c.ops)),
+	}
+	for _, key := range c.order {
+		req := *c.requests[key]
+		req.Violations = append([]ViolationCount(nil), req.Violations...)
+		report.Requests = append(report.Requests, req)
+	}
+	for _, op := range c.ops {
+		report.Operations = append(report.Operations, OperationCoverage{
+			Operation:   op.Method + " " + op.Path,
+			OperationID: op.OperationID,
+			Calls:       c.calls[op],
+		})
+	}
+	return report
+}
+
+// Passed reports whether every checked request kept to the contract
+func (r *Report) Passed() bool {
+	for _, req := range r.Requests {
+		if req.Failed > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Covered returns the number of operations that were called
+func (r *Report) Covered() int {
+	covered := 0
+	for _, op := range r.Operations {
+		if op.Calls > 0 {
+			covered++
+		}
+	}
+	return covered
+}
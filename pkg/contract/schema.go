@@ -0,0 +1,72 @@
+package contract
+
+// toJSONSchema returns a copy of an OpenAPI schema as JSON Schema 2020-12.
+// OpenAPI 3.1 schemas already are; 3.0 ones differ in a few keywords:
+//
+//   - nullable: true allows null
+//   - exclusiveMinimum and exclusiveMaximum are booleans that modify
+//     minimum and maximum
+//
+// writeOnly properties are also no longer required, as responses leave them
+// out.
+func toJSONSchema(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		m := make(map[string]any, len(v))
+		for k, item := range v {
+			m[k] = toJSONSchema(item)
+		}
+		return convertKeywords(m)
+	case []any:
+		list := make([]any, len(v))
+		for i, item := range v {
+			list[i] = toJSONSchema(item)
+		}
+		return list
+	default:
+		return v
+	}
+}
+
+func convertKeywords(m map[string]any) map[string]any {
+	for _, bound := range [][2]string{{"exclusiveMinimum", "minimum"}, {"exclusiveMaximum", "maximum"}} {
+		exclusive, ok := m[bound[0]].(bool)
+		if !ok {
+			continue
+		}
+		delete(m, bound[0])
+		if limit, ok := m[bound[1]]; ok && exclusive {
+			m[bound[0]] = limit
+			delete(m, bound[1])
+		}
+	}
+
+	if required, ok := m["required"].([]any); ok {
+		props, _ := m["properties"].(map[string]any)
+		kept := make([]any, 0, len(required))
+		for _, name := range required {
+			key, _ := name.(string)
+			prop, _ := props[key].(map[string]any)
+			if prop["writeOnly"] != true {
+				kept = append(kept, name)
+			}
+		}
+		m["required"] = kept
+	}
+
+	if m["nullable"] != true {
+		return m
+	}
+	delete(m, "nullable")
+	if enum, ok := m["enum"].([]any); ok {
+		m["enum"] = append(enum, nil)
+	}
+	switch t := m["type"].(type) {
+	case string:
+		m["type"] = []any{t, "null"}
+	case nil:
+		// A $ref or composition: null or whatever it allows
+		return map[string]any{"anyOf": []any{map[string]any{"type": "null"}, m}}
+	}
+	return m
+}
@@ -6,14 +6,23 @@ import (
 	"encoding/json"
 	"fmt"
 	"os/exec"
+	"sync"
 	"time"
 
 	"github.com/vikasavnish/httptool/pkg/ir"
 )
 
 // Manager handles evaluator execution with safety controls
+//
+// By default every evaluation starts a fresh evaluator process (one-shot
+// mode). A pooled manager instead keeps long-lived workers per evaluator,
+// which is what load tests want.
 type Manager struct {
 	timeout time.Duration
+	pool    *PoolConfig // nil for one-shot mode
+
+	mu    sync.Mutex
+	pools map[string]*Pool
 }
 
 // NewManager creates a new evaluator manager
@@ -23,27 +32,54 @@ func NewManager(timeout time.Duration) *Manager {
 	}
 }
 
+// NewPooledManager creates a manager that runs evaluators as persistent
+// workers. Call Close to stop them.
+func NewPooledManager(timeout time.Duration, config PoolConfig) *Manager {
+	return &Manager{
+		timeout: timeout,
+		pool:    &config,
+		pools:   make(map[string]*Pool),
+	}
+}
+
+// Close stops any persistent workers
+func (m *Manager) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, pool := range m.pools {
+		pool.Close()
+		delete(m.pools, key)
+	}
+	return nil
+}
+
 // Evaluate runs an evaluator and returns its decision
 func (m *Manager) Evaluate(ctx context.Context, evalCtx *ir.EvaluationContext, evaluatorType string, evaluatorPath string) (*ir.EvaluatorDecision, error) {
+	// Select evaluator
+	cmd, err := m.command(evaluatorType, evaluatorPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Persistent workers
+	if m.pool != nil {
+		decision, err := m.poolFor(evaluatorType, evaluatorPath).Evaluate(ctx, evalCtx)
+		if err != nil {
+			return nil, err
+		}
+		if err := m.validateDecision(decision); err != nil {
+			return nil, fmt.Errorf("invalid evaluator decision: %w", err)
+		}
+		return decision, nil
+	}
+
 	// Serialize context to JSON
 	contextJSON, err := json.Marshal(evalCtx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal evaluation context: %w", err)
 	}
 
-	// Select evaluator
-	var cmd *exec.Cmd
-	switch evaluatorType {
-	case "bun":
-		cmd = m.createBunCommand(evaluatorPath, contextJSON)
-	case "python":
-		cmd = m.createPythonCommand(evaluatorPath, contextJSON)
-	case "go":
-		cmd = m.createGoCommand(evaluatorPath, contextJSON)
-	default:
-		return nil, fmt.Errorf("unsupported evaluator type: %s", evaluatorType)
-	}
-
 	// Execute with timeout
 	execCtx, cancel := context.WithTimeout(ctx, m.timeout)
 	defer cancel()
@@ -78,14 +114,44 @@ func (m *Manager) Evaluate(ctx context.Context, evalCtx *ir.EvaluationContext, e
 	return &decision, nil
 }
 
-func (m *Manager) createBunCommand(evaluatorPath string, contextJSON []byte) *exec.Cmd {
+// command builds the process for an evaluator type
+func (m *Manager) command(evaluatorType string, evaluatorPath string) (*exec.Cmd, error) {
+	switch evaluatorType {
+	case "bun":
+		return m.createBunCommand(evaluatorPath), nil
+	case "python":
+		return m.createPythonCommand(evaluatorPath), nil
+	case "go":
+		return m.createGoCommand(evaluatorPath), nil
+	}
+	return nil, fmt.Errorf("unsupported evaluator type: %s", evaluatorType)
+}
+
+// poolFor returns the worker pool of an evaluator, creating it on first use
+func (m *Manager) poolFor(evaluatorType string, evaluatorPath string) *Pool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := evaluatorType + ":" + evaluatorPath
+	pool, ok := m.pools[key]
+	if !ok {
+		pool = NewPool(func() *exec.Cmd {
+			cmd, _ := m.command(evaluatorType, evaluatorPath)
+			return exec.Command(cmd.Args[0], append(cmd.Args[1:], PersistentFlag)...)
+		}, m.timeout, *m.pool)
+		m.pools[key] = pool
+	}
+	return pool
+}
+
+func (m *Manager) createBunCommand(evaluatorPath string) *exec.Cmd {
 	if evaluatorPath == "" {
 		evaluatorPath = "evaluator.js" // default
 	}
 	return exec.Command("bun", "run", evaluatorPath)
 }
 
-func (m *Manager) createPythonCommand(evaluatorPath string, contextJSON []byte) *exec.Cmd {
+func (m *Manager) createPythonCommand(evaluatorPath string) *exec.Cmd {
 	if evaluatorPath == "" {
 		evaluatorPath = "evaluator.py" // default
 	}
@@ -96,7 +162,7 @@ func (m *Manager) createPythonCommand(evaluatorPath string, contextJSON []byte)
 	return exec.Command("python3", evaluatorPath)
 }
 
-func (m *Manager) createGoCommand(evaluatorPath string, contextJSON []byte) *exec.Cmd {
+func (m *Manager) createGoCommand(evaluatorPath string) *exec.Cmd {
 	if evaluatorPath == "" {
 		evaluatorPath = "./evaluator" // default binary
 	}
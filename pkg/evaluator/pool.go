@@ -0,0 +1,303 @@
+package evaluator
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/vikasavnish/httptool/pkg/ir"
+)
+
+// PoolConfig configures persistent evaluator workers
+type PoolConfig struct {
+	Workers        int           // Concurrent calls per evaluator (default 4)
+	HealthInterval time.Duration // How often idle workers are pinged (default 30s, negative disables)
+}
+
+// PersistentFlag is passed to evaluators started as long-lived workers. They
+// then read one request per line from stdin and answer each with one line on
+// stdout until stdin closes.
+const PersistentFlag = "--persistent"
+
+// maxFrame bounds a single response line from a worker
+const maxFrame = 16 << 20
+
+// workerRequest is one line sent to a persistent worker
+type workerRequest struct {
+	ID      uint64                `json:"id"`
+	Type    string                `json:"type"` // "evaluate" or "ping"
+	Context *ir.EvaluationContext `json:"context,omitempty"`
+}
+
+// workerResponse is one line received from a persistent worker
+type workerResponse struct {
+	ID       uint64                `json:"id"`
+	Decision *ir.EvaluatorDecision `json:"decision,omitempty"`
+	Error    string                `json:"error,omitempty"`
+}
+
+// Pool runs calls for one evaluator on up to Workers long-lived processes.
+// Workers start on first use, and a worker that crashes, times out or breaks
+// the protocol is killed and replaced on the next call.
+type Pool struct {
+	command func() *exec.Cmd
+	timeout time.Duration
+	slots   chan *worker // One entry per worker; nil means not running
+	nextID  atomic.Uint64
+	done    chan struct{}
+	once    sync.Once
+}
+
+// NewPool creates a pool that starts workers with command
+func NewPool(command func() *exec.Cmd, timeout time.Duration, config PoolConfig) *Pool {
+	if config.Workers <= 0 {
+		config.Workers = 4
+	}
+	if config.HealthInterval == 0 {
+		config.HealthInterval = 30 * time.Second
+	}
+
+	p := &Pool{
+		command: command,
+		timeout: timeout,
+		slots:   make(chan *worker, config.Workers),
+		done:    make(chan struct{}),
+	}
+	for i := 0; i < config.Workers; i++ {
+		p.slots <- nil
+	}
+
+	if config.HealthInterval > 0 {
+		go p.healthLoop(config.HealthInterval)
+	}
+
+	return p
+}
+
+// Evaluate sends the context to a worker and waits for its decision
+func (p *Pool) Evaluate(ctx context.Context, evalCtx *ir.EvaluationContext) (*ir.EvaluatorDecision, error) {
+	var w *worker
+	select {
+	case w = <-p.slots:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-p.done:
+		return nil, fmt.Errorf("evaluator pool closed")
+	}
+
+	if w == nil || w.exited.Load() {
+		var err error
+		if w, err = startWorker(p.command()); err != nil {
+			p.slots <- nil
+			return nil, err
+		}
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	resp, err := w.call(callCtx, workerRequest{
+		ID:      p.nextID.Add(1),
+		Type:    "evaluate",
+		Context: evalCtx,
+	})
+	if err != nil {
+		// The worker may still be busy with the request or half way through a
+		// line, so it cannot be reused
+		w.kill()
+		p.slots <- nil
+		if callCtx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("evaluator timeout after %v", p.timeout)
+		}
+		return nil, err
+	}
+	p.slots <- w
+
+	if resp.Error != "" {
+		return nil, fmt.Errorf("evaluator failed: %s", resp.Error)
+	}
+	if resp.Decision == nil {
+		return nil, fmt.Errorf("evaluator returned no decision")
+	}
+	return resp.Decision, nil
+}
+
+// Close stops every worker. Calls waiting for a worker fail.
+func (p *Pool) Close() {
+	p.once.Do(func() {
+		close(p.done)
+		for i := 0; i < cap(p.slots); i++ {
+			if w := <-p.slots; w != nil {
+				w.kill()
+			}
+		}
+	})
+}
+
+// healthLoop pings idle workers and replaces those that do not answer
+func (p *Pool) healthLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+		}
+
+		// Only check workers that are idle right now; busy ones prove
+		// themselves by answering
+		for i := 0; i < cap(p.slots); i++ {
+			var w *worker
+			select {
+			case w = <-p.slots:
+			default:
+				continue
+			}
+
+			if w != nil {
+				ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+				_, err := w.call(ctx, workerRequest{ID: p.nextID.Add(1), Type: "ping"})
+				cancel()
+				if err != nil {
+					w.kill()
+					w = nil
+				}
+			}
+			p.slots <- w
+		}
+	}
+}
+
+// worker is one long-lived evaluator process
+type worker struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	lines  chan []byte // Response lines; closed when stdout ends
+	stderr *tailBuffer
+	exited atomic.Bool
+}
+
+func startWorker(cmd *exec.Cmd) (*worker, error) {
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	w := &worker{
+		cmd:    cmd,
+		stdin:  stdin,
+		lines:  make(chan []byte, 1),
+		stderr: &tailBuffer{limit: 4096},
+	}
+	cmd.Stderr = w.stderr
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start evaluator: %w", err)
+	}
+
+	go func() {
+		scanner := bufio.NewScanner(stdout)
+		scanner.Buffer(make([]byte, 64*1024), maxFrame)
+		for scanner.Scan() {
+			w.lines <- bytes.Clone(scanner.Bytes())
+		}
+		w.exited.Store(true)
+		close(w.lines)
+		cmd.Wait()
+	}()
+
+	return w, nil
+}
+
+// call writes one request and waits for the matching response line
+func (w *worker) call(ctx context.Context, req workerRequest) (*workerResponse, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal evaluator request: %w", err)
+	}
+	data = append(data, '\n')
+
+	// A worker that stops reading fills the pipe, so write without blocking
+	// the timeout
+	written := make(chan error, 1)
+	go func() {
+		_, err := w.stdin.Write(data)
+		written <- err
+	}()
+
+	select {
+	case err := <-written:
+		if err != nil {
+			return nil, fmt.Errorf("evaluator exited: %w (stderr: %s)", err, w.stderr)
+		}
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	select {
+	case line, ok := <-w.lines:
+		if !ok {
+			return nil, fmt.Errorf("evaluator exited (stderr: %s)", w.stderr)
+		}
+		var resp workerResponse
+		if err := json.Unmarshal(line, &resp); err != nil {
+			return nil, fmt.Errorf("failed to parse evaluator output: %w (output: %s)", err, line)
+		}
+		if resp.ID != req.ID {
+			return nil, fmt.Errorf("evaluator answered request %d, expected %d", resp.ID, req.ID)
+		}
+		return &resp, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (w *worker) kill() {
+	w.stdin.Close()
+	if w.cmd.Process != nil {
+		w.cmd.Process.Kill()
+	}
+
+	// Unblock the reader so it can reap the process
+	go func() {
+		for range w.lines {
+		}
+	}()
+}
+
+// tailBuffer keeps the last limit bytes written to it
+type tailBuffer struct {
+	mu    sync.Mutex
+	buf   []byte
+	limit int
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.limit {
+		b.buf = b.buf[len(b.buf)-b.limit:]
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.buf)
+}
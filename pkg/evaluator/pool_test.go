@@ -0,0 +1,161 @@
+package evaluator
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vikasavnish/httptool/pkg/ir"
+)
+
+// The test binary doubles as an evaluator: with EVALUATOR_HELPER set it
+// behaves as a worker instead of running tests.
+func TestMain(m *testing.M) {
+	if mode := os.Getenv("EVALUATOR_HELPER"); mode != "" {
+		runHelper(mode)
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func runHelper(mode string) {
+	persistent := len(os.Args) > 1 && os.Args[len(os.Args)-1] == PersistentFlag
+
+	if !persistent {
+		var ctx ir.EvaluationContext
+		json.NewDecoder(os.Stdin).Decode(&ctx)
+		json.NewEncoder(os.Stdout).Encode(ir.EvaluatorDecision{Decision: "pass", Reason: "one-shot"})
+		return
+	}
+
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Buffer(make([]byte, 64*1024), maxFrame)
+	for served := 0; scanner.Scan(); served++ {
+		var req workerRequest
+		json.Unmarshal(scanner.Bytes(), &req)
+
+		if mode == "crash" && req.Type == "evaluate" && served > 0 {
+			os.Exit(1)
+		}
+		if mode == "hang" && req.Type == "evaluate" {
+			time.Sleep(time.Hour)
+		}
+
+		resp := workerResponse{ID: req.ID}
+		if req.Type == "evaluate" {
+			resp.Decision = &ir.EvaluatorDecision{
+				Decision: "pass",
+				Reason:   fmt.Sprintf("pid %d status %d", os.Getpid(), req.Context.Response.Status),
+			}
+		}
+		json.NewEncoder(os.Stdout).Encode(resp)
+	}
+}
+
+func evalContext(status int) *ir.EvaluationContext {
+	return &ir.EvaluationContext{Response: &ir.Response{Status: status}}
+}
+
+func TestPooledManager_ReusesWorkers(t *testing.T) {
+	t.Setenv("EVALUATOR_HELPER", "echo")
+
+	m := NewPooledManager(5*time.Second, PoolConfig{Workers: 2})
+	defer m.Close()
+
+	pids := make(map[string]bool)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(status int) {
+			defer wg.Done()
+			decision, err := m.Evaluate(context.Background(), evalContext(status), "go", os.Args[0])
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if !strings.HasSuffix(decision.Reason, fmt.Sprintf("status %d", status)) {
+				t.Errorf("decision for %d: %s", status, decision.Reason)
+			}
+			mu.Lock()
+			pids[strings.Fields(decision.Reason)[1]] = true
+			mu.Unlock()
+		}(200 + i)
+	}
+	wg.Wait()
+
+	if len(pids) == 0 || len(pids) > 2 {
+		t.Errorf("20 calls used %d processes, want at most 2", len(pids))
+	}
+}
+
+func TestPooledManager_RestartsCrashedWorker(t *testing.T) {
+	t.Setenv("EVALUATOR_HELPER", "crash")
+
+	m := NewPooledManager(5*time.Second, PoolConfig{Workers: 1})
+	defer m.Close()
+
+	ctx := context.Background()
+	if _, err := m.Evaluate(ctx, evalContext(200), "go", os.Args[0]); err != nil {
+		t.Fatalf("first call: %v", err)
+	}
+	if _, err := m.Evaluate(ctx, evalContext(200), "go", os.Args[0]); err == nil {
+		t.Fatal("expected the call that crashed the worker to fail")
+	}
+	if _, err := m.Evaluate(ctx, evalContext(200), "go", os.Args[0]); err != nil {
+		t.Fatalf("call after crash: %v", err)
+	}
+}
+
+func TestPooledManager_Timeout(t *testing.T) {
+	t.Setenv("EVALUATOR_HELPER", "hang")
+
+	m := NewPooledManager(200*time.Millisecond, PoolConfig{Workers: 1})
+	defer m.Close()
+
+	_, err := m.Evaluate(context.Background(), evalContext(200), "go", os.Args[0])
+	if err == nil || !strings.Contains(err.Error(), "timeout") {
+		t.Errorf("expected timeout, got %v", err)
+	}
+}
+
+func TestPool_HealthCheck(t *testing.T) {
+	t.Setenv("EVALUATOR_HELPER", "echo")
+
+	m := NewPooledManager(time.Second, PoolConfig{Workers: 1, HealthInterval: 20 * time.Millisecond})
+	defer m.Close()
+
+	first, err := m.Evaluate(context.Background(), evalContext(200), "go", os.Args[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Pings keep a healthy worker alive
+	time.Sleep(100 * time.Millisecond)
+
+	second, err := m.Evaluate(context.Background(), evalContext(200), "go", os.Args[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Reason != second.Reason {
+		t.Errorf("worker was replaced: %q then %q", first.Reason, second.Reason)
+	}
+}
+
+func TestManager_OneShot(t *testing.T) {
+	t.Setenv("EVALUATOR_HELPER", "echo")
+
+	decision, err := NewManager(5*time.Second).Evaluate(context.Background(), evalContext(200), "go", os.Args[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decision.Reason != "one-shot" {
+		t.Errorf("reason = %q", decision.Reason)
+	}
+}
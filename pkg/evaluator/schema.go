@@ -0,0 +1,52 @@
+package evaluator
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/vikasavnish/httptool/pkg/ir"
+	"github.com/vikasavnish/httptool/pkg/jsonschema"
+)
+
+// schemas caches compiled response schemas by path; load tests validate
+// every response against the same few files
+var schemas sync.Map
+
+// SchemaDecision validates the response body against the JSON Schema file at
+// path, as set by Evaluation.Schema. It returns a fail decision naming the
+// failing JSON pointers, or nil when the body matches.
+func SchemaDecision(ctx *ir.EvaluationContext, path string) (*ir.EvaluatorDecision, error) {
+	schema, err := loadSchema(path)
+	if err != nil {
+		return nil, err
+	}
+
+	err = schema.Validate(ctx.Response.Body)
+	if err == nil {
+		return nil, nil
+	}
+
+	decision := &ir.EvaluatorDecision{
+		Decision: "fail",
+		Reason:   fmt.Sprintf("response does not match schema: %v", err),
+		Metadata: map[string]any{"schema": path},
+	}
+	var errs jsonschema.Errors
+	if errors.As(err, &errs) {
+		decision.Metadata["schema_errors"] = errs.Paths()
+	}
+	return decision, nil
+}
+
+func loadSchema(path string) (*jsonschema.Schema, error) {
+	if cached, ok := schemas.Load(path); ok {
+		return cached.(*jsonschema.Schema), nil
+	}
+	schema, err := jsonschema.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	schemas.Store(path, schema)
+	return schema, nil
+}
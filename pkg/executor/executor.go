@@ -6,28 +6,31 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/vikasavnish/httptool/pkg/ir"
 )
 
 // Executor executes HTTP requests from IR (no business logic)
+//
+// An executor is safe for concurrent use. Requests made through it share its
+// cookie jar and its connections, so a load test gives each virtual user its
+// own executor.
 type Executor struct {
-	client    *http.Client
-	cookieJar *CookieJar
+	cookieJar  *CookieJar
+	transports map[ir.Transport]*http.Transport // By transportKey
+	mu         sync.Mutex
 }
 
 // NewExecutor creates a new HTTP executor
 func NewExecutor() *Executor {
 	return &Executor{
-		client: &http.Client{
-			CheckRedirect: func(req *http.Request, via []*http.Request) error {
-				return http.ErrUseLastResponse // Let IR control redirects
-			},
-		},
 		cookieJar: NewCookieJar(),
 	}
 }
@@ -35,31 +38,15 @@ func NewExecutor() *Executor {
 // NewExecutorWithCookieJar creates an executor with a specific cookie jar
 func NewExecutorWithCookieJar(jar *CookieJar) *Executor {
 	return &Executor{
-		client: &http.Client{
-			CheckRedirect: func(req *http.Request, via []*http.Request) error {
-				return http.ErrUseLastResponse // Let IR control redirects
-			},
-		},
 		cookieJar: jar,
 	}
 }
 
 // Execute runs an HTTP request and returns evaluation context
 func (e *Executor) Execute(irSpec *ir.IR) (*ir.EvaluationContext, error) {
-	// Configure transport
-	transport := e.buildTransport(irSpec.Transport)
-	e.client.Transport = transport
-	e.client.Timeout = time.Duration(irSpec.Transport.TimeoutMs) * time.Millisecond
-
-	// Handle redirects
-	if irSpec.Transport.FollowRedirects {
-		maxRedirects := irSpec.Transport.MaxRedirects
-		e.client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
-			if len(via) >= maxRedirects {
-				return fmt.Errorf("stopped after %d redirects", maxRedirects)
-			}
-			return nil
-		}
+	client, err := e.buildClient(irSpec.Transport)
+	if err != nil {
+		return nil, err
 	}
 
 	// Build HTTP request
@@ -76,9 +63,11 @@ func (e *Executor) Execute(irSpec *ir.IR) (*ir.EvaluationContext, error) {
 		}
 	}
 
-	// Execute request
+	// Execute request, tracing its phases
+	tr := newTrace()
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), tr.clientTrace()))
 	start := time.Now()
-	resp, err := e.client.Do(req)
+	resp, err := client.Do(req)
 	latencyMs := float64(time.Since(start).Microseconds()) / 1000.0
 
 	// Build evaluation context
@@ -111,6 +100,7 @@ func (e *Executor) Execute(irSpec *ir.IR) (*ir.EvaluationContext, error) {
 	if err != nil {
 		ctx.Response.Error = err.Error()
 		ctx.Response.Status = 0
+		ctx.Response.Timing = tr.timing(time.Now())
 		return ctx, nil // Return context even on error
 	}
 	defer resp.Body.Close()
@@ -118,6 +108,12 @@ func (e *Executor) Execute(irSpec *ir.IR) (*ir.EvaluationContext, error) {
 	// Parse response
 	ctx.Response.Status = resp.StatusCode
 	ctx.Response.Headers = flattenHeaders(resp.Header)
+	ctx.Response.SetCookies = resp.Header.Values("Set-Cookie")
+	ctx.Response.Protocol = resp.Proto
+	if resp.TLS != nil {
+		ctx.Response.TLSVersion = tls.VersionName(resp.TLS.Version)
+		ctx.Response.TLSCipher = tls.CipherSuiteName(resp.TLS.CipherSuite)
+	}
 
 	// Extract and store cookies from response
 	if e.cookieJar != nil {
@@ -129,6 +125,7 @@ func (e *Executor) Execute(irSpec *ir.IR) (*ir.EvaluationContext, error) {
 
 	// Read body
 	bodyBytes, err := io.ReadAll(resp.Body)
+	ctx.Response.Timing = tr.timing(time.Now())
 	if err != nil {
 		ctx.Response.Error = fmt.Sprintf("failed to read response body: %v", err)
 		return ctx, nil
@@ -152,11 +149,111 @@ func (e *Executor) GetCookieJar() *CookieJar {
 	return e.cookieJar
 }
 
-func (e *Executor) buildTransport(transport *ir.Transport) *http.Transport {
+// buildClient builds the client for one request. Each request gets its own,
+// so concurrent requests never share redirect or timeout settings.
+func (e *Executor) buildClient(transport *ir.Transport) (*http.Client, error) {
+	// The transport block is optional in IR files
+	if transport == nil {
+		transport = ir.DefaultTransport()
+	}
+
+	t, err := e.transport(transport)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{
+		Transport: t,
+		Timeout:   time.Duration(transport.TimeoutMs) * time.Millisecond,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse // Let IR control redirects
+		},
+	}
+
+	// Handle redirects
+	if transport.FollowRedirects {
+		maxRedirects := transport.MaxRedirects
+		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			return nil
+		}
+	}
+
+	return client, nil
+}
+
+// transport returns the transport for a configuration, creating it on first
+// use. Requests with the same settings share it and so reuse connections.
+func (e *Executor) transport(config *ir.Transport) (*http.Transport, error) {
+	key := transportKey(config)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if t, ok := e.transports[key]; ok {
+		return t, nil
+	}
+	t, err := e.buildTransport(config)
+	if err != nil {
+		return nil, err
+	}
+	if e.transports == nil {
+		e.transports = make(map[ir.Transport]*http.Transport)
+	}
+	e.transports[key] = t
+	return t, nil
+}
+
+// transportKey drops the settings the client applies rather than the
+// transport, so requests differing only in those share connections
+func transportKey(config *ir.Transport) ir.Transport {
+	key := *config
+	key.TimeoutMs = 0
+	key.FollowRedirects = false
+	key.MaxRedirects = 0
+	return key
+}
+
+// CloseIdleConnections closes connections that are not carrying a request.
+// The executor can still be used afterwards.
+func (e *Executor) CloseIdleConnections() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, t := range e.transports {
+		t.CloseIdleConnections()
+	}
+}
+
+func (e *Executor) buildTransport(transport *ir.Transport) (*http.Transport, error) {
+	protocols, err := protocols(transport.Protocol)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: millis(transport.KeepAliveMs, 30*time.Second),
+	}
+
+	maxIdle := transport.MaxIdleConnsPerHost
+	if maxIdle == 0 {
+		maxIdle = 100
+	}
+
 	t := &http.Transport{
+		DialContext: dialer.DialContext,
 		TLSClientConfig: &tls.Config{
 			InsecureSkipVerify: !transport.TLSVerify,
 		},
+		TLSHandshakeTimeout: 10 * time.Second,
+		DisableKeepAlives:   transport.DisableKeepAlive,
+		MaxIdleConnsPerHost: maxIdle,
+		MaxConnsPerHost:     transport.MaxConnsPerHost,
+		IdleConnTimeout:     millis(transport.IdleTimeoutMs, 90*time.Second),
+		Protocols:           protocols,
 	}
 
 	if transport.Proxy != "" {
@@ -166,7 +263,40 @@ func (e *Executor) buildTransport(transport *ir.Transport) *http.Transport {
 		}
 	}
 
-	return t
+	return t, nil
+}
+
+// protocols maps a transport's protocol setting to the HTTP versions its
+// connections may speak
+func protocols(name string) (*http.Protocols, error) {
+	p := new(http.Protocols)
+	switch name {
+	case "", "auto":
+		p.SetHTTP1(true)
+		p.SetHTTP2(true)
+	case "http1":
+		p.SetHTTP1(true)
+	case "http2":
+		// TLS offers only h2. Like curl --http2, cleartext requests still
+		// use HTTP/1.1.
+		p.SetHTTP2(true)
+	case "h2c":
+		// Prior knowledge: cleartext requests start HTTP/2 straight away.
+		// https URLs still negotiate HTTP/2 over TLS, as curl does.
+		p.SetUnencryptedHTTP2(true)
+		p.SetHTTP2(true)
+	default:
+		return nil, fmt.Errorf("unknown protocol '%s' (use auto, http1, http2 or h2c)", name)
+	}
+	return p, nil
+}
+
+// millis converts a millisecond setting, using def when it is zero
+func millis(ms int, def time.Duration) time.Duration {
+	if ms == 0 {
+		return def
+	}
+	return time.Duration(ms) * time.Millisecond
 }
 
 func (e *Executor) buildRequest(irSpec *ir.IR) (*http.Request, error) {
@@ -0,0 +1,182 @@
+package executor
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vikasavnish/httptool/pkg/ir"
+)
+
+func TestExecutor_ReusesConnections(t *testing.T) {
+	var mu sync.Mutex
+	conns := 0
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"ok": true}`)
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			mu.Lock()
+			conns++
+			mu.Unlock()
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	tests := []struct {
+		name      string
+		transport func(i int, t *ir.Transport)
+		want      int
+	}{
+		{"keep-alive", func(i int, t *ir.Transport) {}, 1},
+		{"timeouts differ", func(i int, t *ir.Transport) { t.TimeoutMs = 1000 * (i + 1) }, 1},
+		{"disable_keepalive", func(i int, t *ir.Transport) { t.DisableKeepAlive = true }, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mu.Lock()
+			conns = 0
+			mu.Unlock()
+
+			e := NewExecutor()
+			defer e.CloseIdleConnections()
+
+			for i := 0; i < 5; i++ {
+				transport := ir.DefaultTransport()
+				tt.transport(i, transport)
+				spec := &ir.IR{
+					Request:   ir.Request{Method: "GET", URL: server.URL},
+					Transport: transport,
+				}
+				ctx, err := e.Execute(spec)
+				if err != nil || ctx.Response.Status != http.StatusOK {
+					t.Fatalf("request %d: %v %+v", i, err, ctx.Response)
+				}
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if conns != tt.want {
+				t.Errorf("opened %d connections, want %d", conns, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecutor_Timing(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		fmt.Fprint(w, `{"ok": true}`)
+	}))
+	defer server.Close()
+
+	e := NewExecutor()
+	defer e.CloseIdleConnections()
+
+	var timings []*ir.Timing
+	for i := 0; i < 2; i++ {
+		ctx, err := e.Execute(&ir.IR{
+			Request:   ir.Request{Method: "GET", URL: server.URL},
+			Transport: ir.DefaultTransport(),
+		})
+		if err != nil || ctx.Response.Timing == nil {
+			t.Fatalf("request %d: %v, timing %v", i, err, ctx.Response.Timing)
+		}
+		timings = append(timings, ctx.Response.Timing)
+	}
+
+	first, second := timings[0], timings[1]
+	if first.ConnReused || first.ConnectMs <= 0 {
+		t.Errorf("first request: %+v, want a new connection", first)
+	}
+	if !second.ConnReused || second.ConnectMs != 0 || second.DNSMs != 0 {
+		t.Errorf("second request: %+v, want a reused connection", second)
+	}
+	for _, timing := range timings {
+		if timing.WaitMs < 20 || timing.TTFBMs < timing.WaitMs {
+			t.Errorf("wait %.2fms, ttfb %.2fms, want the server's 20ms in both", timing.WaitMs, timing.TTFBMs)
+		}
+	}
+}
+
+func TestExecutor_Protocol(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.Proto)
+	})
+
+	tlsServer := httptest.NewUnstartedServer(handler)
+	tlsServer.EnableHTTP2 = true
+	tlsServer.StartTLS()
+	defer tlsServer.Close()
+
+	h2cServer := httptest.NewUnstartedServer(handler)
+	h2cServer.Config.Protocols = new(http.Protocols)
+	h2cServer.Config.Protocols.SetHTTP1(true)
+	h2cServer.Config.Protocols.SetUnencryptedHTTP2(true)
+	h2cServer.Start()
+	defer h2cServer.Close()
+
+	tests := []struct {
+		protocol string
+		url      string
+		want     string
+		tls      bool
+	}{
+		{"", tlsServer.URL, "HTTP/2.0", true},
+		{"auto", h2cServer.URL, "HTTP/1.1", false},
+		{"http1", tlsServer.URL, "HTTP/1.1", true},
+		{"http2", tlsServer.URL, "HTTP/2.0", true},
+		{"http2", h2cServer.URL, "HTTP/1.1", false}, // Like curl --http2, cleartext stays on HTTP/1.1
+		{"h2c", h2cServer.URL, "HTTP/2.0", false},
+		{"h2c", tlsServer.URL, "HTTP/2.0", true},
+	}
+
+	e := NewExecutor()
+	defer e.CloseIdleConnections()
+
+	for _, tt := range tests {
+		transport := ir.DefaultTransport()
+		transport.TLSVerify = false
+		transport.Protocol = tt.protocol
+		ctx, err := e.Execute(&ir.IR{
+			Request:   ir.Request{Method: "GET", URL: tt.url},
+			Transport: transport,
+		})
+		if err != nil {
+			t.Fatalf("%s %s: %v", tt.protocol, tt.url, err)
+		}
+		resp := ctx.Response
+		if resp.Protocol != tt.want || resp.Body != tt.want {
+			t.Errorf("%s %s: negotiated %q, server saw %v, want %s", tt.protocol, tt.url, resp.Protocol, resp.Body, tt.want)
+		}
+		if tt.tls != (resp.TLSVersion != "" && resp.TLSCipher != "") {
+			t.Errorf("%s %s: TLS %q %q", tt.protocol, tt.url, resp.TLSVersion, resp.TLSCipher)
+		}
+	}
+
+	transport := ir.DefaultTransport()
+	transport.Protocol = "http3"
+	if _, err := e.Execute(&ir.IR{Request: ir.Request{Method: "GET", URL: h2cServer.URL}, Transport: transport}); err == nil {
+		t.Error("unknown protocol accepted")
+	}
+}
+
+func TestExecutor_DefaultTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"ok": true}`)
+	}))
+	defer server.Close()
+
+	// The IR schema only requires version and request
+	spec := &ir.IR{Version: ir.Version, Request: ir.Request{Method: "GET", URL: server.URL}}
+	ctx, err := NewExecutor().Execute(spec)
+	if err != nil || ctx.Response.Status != http.StatusOK {
+		t.Fatalf("%v %+v", err, ctx.Response)
+	}
+}
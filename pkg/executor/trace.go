@@ -0,0 +1,82 @@
+package executor
+
+import (
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/vikasavnish/httptool/pkg/ir"
+)
+
+// trace records when the phases of a request start and end. Hooks can fire
+// from the transport's dialing goroutines, so every field is guarded.
+type trace struct {
+	mu sync.Mutex
+
+	start        time.Time
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	wrote        time.Time
+	firstByte    time.Time
+	reused       bool
+}
+
+func newTrace() *trace {
+	return &trace{start: time.Now()}
+}
+
+// mark sets a phase boundary to now. Later hops of a redirect overwrite
+// earlier ones.
+func (t *trace) mark(at *time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	*at = time.Now()
+}
+
+func (t *trace) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart:             func(httptrace.DNSStartInfo) { t.mark(&t.dnsStart) },
+		DNSDone:              func(httptrace.DNSDoneInfo) { t.mark(&t.dnsDone) },
+		ConnectStart:         func(string, string) { t.mark(&t.connectStart) },
+		ConnectDone:          func(string, string, error) { t.mark(&t.connectDone) },
+		TLSHandshakeStart:    func() { t.mark(&t.tlsStart) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { t.mark(&t.tlsDone) },
+		WroteRequest:         func(httptrace.WroteRequestInfo) { t.mark(&t.wrote) },
+		GotFirstResponseByte: func() { t.mark(&t.firstByte) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.reused = info.Reused
+		},
+	}
+}
+
+// timing returns the phases of a request whose body was read by end
+func (t *trace) timing(end time.Time) *ir.Timing {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return &ir.Timing{
+		DNSMs:      between(t.dnsStart, t.dnsDone),
+		ConnectMs:  between(t.connectStart, t.connectDone),
+		TLSMs:      between(t.tlsStart, t.tlsDone),
+		WaitMs:     between(t.wrote, t.firstByte),
+		TTFBMs:     between(t.start, t.firstByte),
+		TransferMs: between(t.firstByte, end),
+		ConnReused: t.reused,
+	}
+}
+
+// between returns the milliseconds from start to end, or 0 when the phase
+// did not complete
+func between(start, end time.Time) float64 {
+	if start.IsZero() || end.IsZero() || end.Before(start) {
+		return 0
+	}
+	return float64(end.Sub(start).Microseconds()) / 1000.0
+}
@@ -0,0 +1,613 @@
+package expr
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+type node interface {
+	eval(env Env) (any, error)
+}
+
+type literal struct{ value any }
+
+func (n *literal) eval(Env) (any, error) { return n.value, nil }
+
+type variable struct{ name string }
+
+func (n *variable) eval(env Env) (any, error) {
+	v, _ := env.Var(n.name)
+	return v, nil
+}
+
+type field struct{ name string }
+
+func (n *field) eval(env Env) (any, error) {
+	v, ok := env.Field(n.name)
+	if !ok {
+		return nil, fmt.Errorf("%s is not available", n.name)
+	}
+	return v, nil
+}
+
+type list struct{ items []node }
+
+func (n *list) eval(env Env) (any, error) {
+	items := make([]any, 0, len(n.items))
+	for _, item := range n.items {
+		v, err := item.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, v)
+	}
+	return items, nil
+}
+
+type member struct {
+	operand node
+	name    string
+}
+
+func (n *member) eval(env Env) (any, error) {
+	v, err := n.operand.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	if object, ok := v.(map[string]any); ok {
+		return object[n.name], nil
+	}
+	return nil, nil
+}
+
+type indexed struct {
+	operand, index node
+}
+
+func (n *indexed) eval(env Env) (any, error) {
+	v, err := n.operand.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	index, err := n.index.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	switch container := v.(type) {
+	case []any:
+		i, ok := index.(float64)
+		if !ok || i != math.Trunc(i) {
+			return nil, fmt.Errorf("list index must be a whole number, got %s", Format(index))
+		}
+		if i < 0 {
+			i += float64(len(container))
+		}
+		if i >= 0 && int(i) < len(container) {
+			return container[int(i)], nil
+		}
+	case map[string]any:
+		return container[Format(index)], nil
+	}
+	return nil, nil
+}
+
+type call struct {
+	name    string
+	builtin func([]any) (any, error) // nil for functions provided as fields
+	args    []node
+}
+
+func (n *call) eval(env Env) (any, error) {
+	args := make([]any, 0, len(n.args))
+	for _, arg := range n.args {
+		v, err := arg.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, v)
+	}
+
+	if n.builtin != nil {
+		v, err := n.builtin(args)
+		if err != nil {
+			return nil, fmt.Errorf("%s(): %w", n.name, err)
+		}
+		return v, nil
+	}
+
+	v, ok := env.Field(n.name)
+	if !ok {
+		return nil, fmt.Errorf("%s is not available", n.name)
+	}
+	fn, ok := v.(Func)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a function", n.name)
+	}
+	return fn(args...)
+}
+
+type unary struct {
+	op      string
+	operand node
+}
+
+func (n *unary) eval(env Env) (any, error) {
+	v, err := n.operand.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	switch x := v.(type) {
+	case bool:
+		if n.op == "!" {
+			return !x, nil
+		}
+	case float64:
+		if n.op == "-" {
+			return -x, nil
+		}
+	case time.Duration:
+		if n.op == "-" {
+			return -x, nil
+		}
+	}
+	if n.op == "!" {
+		return nil, fmt.Errorf("'!' needs a boolean, got %s", TypeOf(v))
+	}
+	return nil, fmt.Errorf("cannot negate %s", TypeOf(v))
+}
+
+type logical struct {
+	and         bool
+	left, right node
+}
+
+func (n *logical) eval(env Env) (any, error) {
+	left, err := n.operand(n.left, env)
+	if err != nil || left != n.and {
+		// false && ... and true || ... are decided by the left side
+		return left, err
+	}
+	return n.operand(n.right, env)
+}
+
+func (n *logical) operand(operand node, env Env) (bool, error) {
+	v, err := operand.eval(env)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		op := "||"
+		if n.and {
+			op = "&&"
+		}
+		return false, fmt.Errorf("'%s' needs booleans, got %s %s", op, TypeOf(v), Format(v))
+	}
+	return b, nil
+}
+
+type binary struct {
+	op          string
+	left, right node
+}
+
+func (n *binary) eval(env Env) (any, error) {
+	left, err := n.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "+", "-", "*", "/", "%":
+		return arithmetic(n.op, left, right)
+	}
+	return Compare(n.op, left, right)
+}
+
+func arithmetic(op string, a, b any) (any, error) {
+	if op == "+" {
+		_, as := a.(string)
+		_, bs := b.(string)
+		if as || bs {
+			return Format(a) + Format(b), nil
+		}
+	}
+
+	x, xNum := number(a)
+	y, yNum := number(b)
+	dx, xDur := a.(time.Duration)
+	dy, yDur := b.(time.Duration)
+
+	switch {
+	case xNum && yNum:
+		switch op {
+		case "+":
+			return x + y, nil
+		case "-":
+			return x - y, nil
+		case "*":
+			return x * y, nil
+		case "/", "%":
+			if y == 0 {
+				return nil, fmt.Errorf("division by zero")
+			}
+			if op == "%" {
+				return math.Mod(x, y), nil
+			}
+			return x / y, nil
+		}
+	case xDur && yDur:
+		switch op {
+		case "+":
+			return dx + dy, nil
+		case "-":
+			return dx - dy, nil
+		case "/":
+			if dy == 0 {
+				return nil, fmt.Errorf("division by zero")
+			}
+			return float64(dx) / float64(dy), nil
+		}
+	case xDur && yNum:
+		switch op {
+		case "*":
+			return time.Duration(float64(dx) * y), nil
+		case "/":
+			if y == 0 {
+				return nil, fmt.Errorf("division by zero")
+			}
+			return time.Duration(float64(dx) / y), nil
+		}
+	case xNum && yDur:
+		if op == "*" {
+			return time.Duration(x * float64(dy)), nil
+		}
+	}
+	return nil, fmt.Errorf("cannot apply '%s' to %s and %s", op, TypeOf(a), TypeOf(b))
+}
+
+// typeOf works out the type a node always yields without evaluating it, or
+// "" when that depends on fields, variables or data
+func typeOf(n node, fields map[string]string, bound map[string]any) string {
+	switch n := n.(type) {
+	case *literal:
+		return TypeOf(n.value)
+	case *variable:
+		if v, ok := bound[n.name]; ok {
+			return TypeOf(v)
+		}
+	case *field:
+		return fields[n.name]
+	case *list:
+		return "array"
+	case *call:
+		if n.builtin != nil {
+			return builtins[n.name].result
+		}
+	case *unary:
+		if n.op == "!" {
+			return "boolean"
+		}
+		if t := typeOf(n.operand, fields, bound); t == "number" || t == "duration" {
+			return t
+		}
+	case *logical:
+		return "boolean"
+	case *binary:
+		left, right := typeOf(n.left, fields, bound), typeOf(n.right, fields, bound)
+		switch n.op {
+		case "+":
+			if left == "string" || right == "string" {
+				return "string"
+			}
+			fallthrough
+		case "-", "*", "/", "%":
+			if left == "number" && right == "number" {
+				return "number"
+			}
+		default:
+			return "boolean"
+		}
+	}
+	return ""
+}
+
+// Comparison
+
+// Compare applies a comparison operator: ==, !=, <, <=, >, >=, in, contains
+// or matches, the last three optionally negated as in "not in". It fails for
+// values that cannot be ordered and for matches without a pattern.
+func Compare(op string, a, b any) (bool, error) {
+	if positive, ok := strings.CutPrefix(op, "not "); ok {
+		result, err := Compare(positive, a, b)
+		return !result, err
+	}
+
+	switch op {
+	case "==":
+		return Equal(a, b), nil
+	case "!=":
+		return !Equal(a, b), nil
+	case "<", "<=", ">", ">=":
+		c, ok := Order(a, b)
+		if !ok {
+			return false, fmt.Errorf("cannot compare %s and %s with '%s'", TypeOf(a), TypeOf(b), op)
+		}
+		switch op {
+		case "<":
+			return c < 0, nil
+		case "<=":
+			return c <= 0, nil
+		case ">":
+			return c > 0, nil
+		default:
+			return c >= 0, nil
+		}
+	case "in":
+		return Contains(b, a), nil
+	case "contains":
+		return Contains(a, b), nil
+	case "matches":
+		re, ok := b.(*regexp.Regexp)
+		if !ok {
+			pattern, isString := b.(string)
+			if !isString {
+				return false, fmt.Errorf("'matches' needs a /regex/ or string, got %s", TypeOf(b))
+			}
+			var err error
+			if re, err = regexp.Compile(pattern); err != nil {
+				return false, fmt.Errorf("invalid regex: %w", err)
+			}
+		}
+		return re.MatchString(Format(a)), nil
+	}
+	return false, fmt.Errorf("unknown operator '%s'", op)
+}
+
+// Equal compares two values. Numbers equal numeric strings, booleans equal
+// "true" and "false", and plain numbers equal durations of that many
+// milliseconds.
+func Equal(a, b any) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+
+	_, aDur := a.(time.Duration)
+	_, bDur := b.(time.Duration)
+	_, aNum := number(a)
+	_, bNum := number(b)
+	_, aBool := a.(bool)
+	_, bBool := b.(bool)
+	_, aStr := a.(string)
+	_, bStr := b.(string)
+
+	switch {
+	case aDur || bDur:
+		c, ok := Order(a, b)
+		return ok && c == 0
+	case aNum || bNum:
+		x, xOk := toNumber(a)
+		y, yOk := toNumber(b)
+		return xOk && yOk && x == y
+	case aBool || bBool, aStr || bStr:
+		return Format(a) == Format(b)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// Order compares numbers, numeric strings, durations and strings, returning
+// -1, 0 or 1. Plain numbers compared with durations count as milliseconds.
+// It reports false for values that cannot be ordered.
+func Order(a, b any) (int, bool) {
+	var x, y float64
+
+	_, aDur := a.(time.Duration)
+	_, bDur := b.(time.Duration)
+	if aDur || bDur {
+		var ok bool
+		if x, ok = milliseconds(a); !ok {
+			return 0, false
+		}
+		if y, ok = milliseconds(b); !ok {
+			return 0, false
+		}
+	} else if xn, ok := toNumber(a); ok {
+		if y, ok = toNumber(b); !ok {
+			return 0, false
+		}
+		x = xn
+	} else {
+		s, ok := a.(string)
+		t, ok2 := b.(string)
+		if !ok || !ok2 {
+			return 0, false
+		}
+		return strings.Compare(s, t), true
+	}
+
+	switch {
+	case x < y:
+		return -1, true
+	case x > y:
+		return 1, true
+	}
+	return 0, true
+}
+
+// Contains reports whether a list has an element equal to item, an object has
+// the key item, or the text of container contains the text of item
+func Contains(container, item any) bool {
+	switch v := container.(type) {
+	case []any:
+		for _, element := range v {
+			if Equal(element, item) {
+				return true
+			}
+		}
+		return false
+	case map[string]any:
+		_, ok := v[Format(item)]
+		return ok
+	case nil:
+		return false
+	}
+	return strings.Contains(Format(container), Format(item))
+}
+
+// number reads numeric values, but not numeric strings
+func number(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// toNumber reads numbers and numeric strings, such as header values
+func toNumber(v any) (float64, bool) {
+	if s, ok := v.(string); ok {
+		f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		return f, err == nil
+	}
+	return number(v)
+}
+
+func milliseconds(v any) (float64, bool) {
+	if d, ok := v.(time.Duration); ok {
+		return float64(d) / float64(time.Millisecond), true
+	}
+	return number(v)
+}
+
+// TypeOf names the type of a value: null, boolean, number, string, array,
+// object or duration
+func TypeOf(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64, int, int64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	case time.Duration:
+		return "duration"
+	case *regexp.Regexp:
+		return "regex"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// Format renders a value the way it would be written: strings as they are,
+// everything else as JSON, durations as 1.5s
+func Format(v any) string {
+	switch x := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return x
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(x)
+	case time.Duration:
+		return x.String()
+	case *regexp.Regexp:
+		return "/" + x.String() + "/"
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(data)
+}
+
+// Builtin functions
+
+type builtin struct {
+	arity  int
+	fn     func(args []any) (any, error)
+	result string // Type of the value returned
+}
+
+var builtins = map[string]builtin{
+	"len":         {1, length, "number"},
+	"lower":       {1, stringFunc(strings.ToLower), "string"},
+	"upper":       {1, stringFunc(strings.ToUpper), "string"},
+	"trim":        {1, stringFunc(strings.TrimSpace), "string"},
+	"starts_with": {2, stringTest(strings.HasPrefix), "boolean"},
+	"ends_with":   {2, stringTest(strings.HasSuffix), "boolean"},
+	"number":      {1, toNumberFunc, "number"},
+	"string":      {1, func(args []any) (any, error) { return Format(args[0]), nil }, "string"},
+	"type":        {1, func(args []any) (any, error) { return TypeOf(args[0]), nil }, "string"},
+}
+
+func length(args []any) (any, error) {
+	switch v := args[0].(type) {
+	case nil:
+		return 0.0, nil
+	case string:
+		return float64(utf8.RuneCountInString(v)), nil
+	case []any:
+		return float64(len(v)), nil
+	case map[string]any:
+		return float64(len(v)), nil
+	}
+	return nil, fmt.Errorf("expected a string, array or object, got %s", TypeOf(args[0]))
+}
+
+func stringFunc(f func(string) string) func([]any) (any, error) {
+	return func(args []any) (any, error) {
+		s, ok := args[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("expected a string, got %s", TypeOf(args[0]))
+		}
+		return f(s), nil
+	}
+}
+
+func stringTest(f func(string, string) bool) func([]any) (any, error) {
+	return func(args []any) (any, error) {
+		s, ok := args[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("expected a string, got %s", TypeOf(args[0]))
+		}
+		return f(s, Format(args[1])), nil
+	}
+}
+
+func toNumberFunc(args []any) (any, error) {
+	if b, ok := args[0].(bool); ok {
+		if b {
+			return 1.0, nil
+		}
+		return 0.0, nil
+	}
+	n, ok := toNumber(args[0])
+	if !ok {
+		return nil, fmt.Errorf("%s is not a number", Format(args[0]))
+	}
+	return n, nil
+}
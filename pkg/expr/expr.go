@@ -0,0 +1,700 @@
+// Package expr implements the expression language of .httpx conditions,
+// assertions and thresholds, e.g.
+//
+//	status == 200 && body.items[0].id in [1, 2, 3]
+//	!(latency > 500ms) || header("X-Cache") == "HIT"
+//	len(body.name) > 3 && lower(${role}) != "guest"
+//
+// Values are those of decoded JSON (null, booleans, numbers, strings, lists
+// and objects) plus durations. The syntax, loosest binding first:
+//
+//	a || b                      logical or; both sides must be booleans
+//	a && b                      logical and
+//	== != < <= > >=             comparison
+//	in, contains, matches       membership, containment, regex match; each
+//	                            can be negated with not, as in 'not in'
+//	+ -                         arithmetic; + joins strings
+//	* / %                       arithmetic
+//	!a -a                       negation
+//	a.b a[0] a["k"]             member and element access; null when missing
+//	f(a, b)                     functions
+//
+// Operands are literals ("text", 'text', 12, 1.5, 500ms, 1m30s, 10KB, 5%,
+// true, false, null, [1, 2], /regex/i), variables (${name}), fields the
+// caller provides (status, body, ...) and parenthesised expressions.
+//
+// Comparisons follow the assertions: numbers equal numeric strings, so a
+// header "7" equals 7, and plain numbers compared with durations count as
+// milliseconds.
+package expr
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Expr is a compiled expression
+type Expr struct {
+	src   string
+	root  node
+	vars  []string       // Names of the ${...} variables it refers to
+	bound map[string]any // Variables fixed with Bind
+}
+
+// Env supplies the fields and variables an expression refers to
+type Env interface {
+	// Field returns the value of a bare name such as status
+	Field(name string) (any, bool)
+	// Var returns the value of ${name}
+	Var(name string) (any, bool)
+}
+
+// Func is a function provided as a field, e.g. header("X-Request-Id")
+type Func func(args ...any) (any, error)
+
+// SyntaxError reports where an expression failed to compile
+type SyntaxError struct {
+	Offset int // Byte offset into the source
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s at offset %d", e.Msg, e.Offset)
+}
+
+// Compile parses an expression. Bare names, and functions other than the
+// builtins, must be among fields.
+func Compile(src string, fields ...string) (*Expr, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens, fields: fields}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, p.errorf(tok, "unexpected %s", tok)
+	}
+
+	return &Expr{src: src, root: root, vars: p.vars}, nil
+}
+
+// MustCompile is like Compile but panics on an invalid expression
+func MustCompile(src string, fields ...string) *Expr {
+	e, err := Compile(src, fields...)
+	if err != nil {
+		panic(err)
+	}
+	return e
+}
+
+// String returns the source the expression was compiled from
+func (e *Expr) String() string {
+	return e.src
+}
+
+// Bind returns a copy of the expression with the variables lookup knows
+// fixed to their current values. Bound variables take precedence over the
+// environment's.
+func (e *Expr) Bind(lookup func(name string) (any, bool)) *Expr {
+	bound := maps.Clone(e.bound)
+	for _, name := range e.vars {
+		if v, ok := lookup(name); ok {
+			if bound == nil {
+				bound = make(map[string]any)
+			}
+			bound[name] = v
+		}
+	}
+	copied := *e
+	copied.bound = bound
+	return &copied
+}
+
+// Eval evaluates the expression. env may be nil when it refers to no fields.
+// Variables that are not set evaluate to null.
+func (e *Expr) Eval(env Env) (any, error) {
+	if env == nil {
+		env = emptyEnv{}
+	}
+	if len(e.bound) > 0 {
+		env = boundEnv{env, e.bound}
+	}
+	return e.root.eval(env)
+}
+
+// Bool evaluates an expression that must yield a boolean, such as a condition
+func (e *Expr) Bool(env Env) (bool, error) {
+	v, err := e.Eval(env)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("expected a boolean, got %s %s", TypeOf(v), Format(v))
+	}
+	return b, nil
+}
+
+// Type returns the type the expression always yields, named as TypeOf names
+// them, or "" when that depends on the values it reads. fields gives the
+// types of fields that always have one, e.g. "number" for status.
+func (e *Expr) Type(fields map[string]string) string {
+	return typeOf(e.root, fields, e.bound)
+}
+
+type emptyEnv struct{}
+
+func (emptyEnv) Field(string) (any, bool) { return nil, false }
+func (emptyEnv) Var(string) (any, bool)   { return nil, false }
+
+type boundEnv struct {
+	Env
+	vars map[string]any
+}
+
+func (e boundEnv) Var(name string) (any, bool) {
+	if v, ok := e.vars[name]; ok {
+		return v, true
+	}
+	return e.Env.Var(name)
+}
+
+// Lexer
+
+type tokenKind int
+
+const (
+	tokEOF    tokenKind = iota
+	tokNumber           // value is a float64 or time.Duration
+	tokString           // value is the unquoted string
+	tokRegex            // value is a *regexp.Regexp
+	tokVar              // value is the variable name
+	tokIdent
+	tokPunct // Operators and brackets
+)
+
+type token struct {
+	kind  tokenKind
+	text  string // As written
+	value any
+	pos   int
+}
+
+func (t token) String() string {
+	if t.kind == tokEOF {
+		return "end of expression"
+	}
+	return "'" + t.text + "'"
+}
+
+// is reports whether the token is the given operator, bracket or keyword
+func (t token) is(text string) bool {
+	return (t.kind == tokPunct || t.kind == tokIdent) && t.text == text
+}
+
+// keywords are the identifiers that are not names
+var keywords = map[string]bool{
+	"true": true, "false": true, "null": true, "in": true, "not": true, "contains": true, "matches": true,
+}
+
+// punctuation lists two-character operators before their prefixes
+var punctuation = []string{"||", "&&", "==", "!=", "<=", ">=", "!", "<", ">", "+", "-", "*", "/", "%", "(", ")", "[", "]", ",", "."}
+
+// sizeUnits are the byte multiples accepted after numbers
+var sizeUnits = map[string]float64{"b": 1, "kb": 1 << 10, "mb": 1 << 20, "gb": 1 << 30}
+
+func lex(src string) ([]token, error) {
+	var tokens []token
+	i := 0
+
+	for {
+		for i < len(src) && isSpace(src[i]) {
+			i++
+		}
+		if i == len(src) {
+			return append(tokens, token{kind: tokEOF, pos: i}), nil
+		}
+
+		start := i
+		c := src[i]
+		var tok token
+		var err error
+
+		switch {
+		case isDigit(c):
+			tok, i, err = lexNumber(src, i)
+		case isLetter(c):
+			for i < len(src) && (isLetter(src[i]) || isDigit(src[i])) {
+				i++
+			}
+			tok = token{kind: tokIdent}
+		case c == '"' || c == '\'':
+			tok, i, err = lexString(src, i)
+		case strings.HasPrefix(src[i:], "${"):
+			end := strings.IndexByte(src[i:], '}')
+			if end < 0 {
+				return nil, &SyntaxError{Offset: i, Msg: "unterminated variable"}
+			}
+			name := strings.TrimSpace(src[i+2 : i+end])
+			if name == "" {
+				return nil, &SyntaxError{Offset: i, Msg: "empty variable name"}
+			}
+			i += end + 1
+			tok = token{kind: tokVar, value: name}
+		case c == '/' && !endsOperand(tokens):
+			tok, i, err = lexRegex(src, i)
+		default:
+			for _, op := range punctuation {
+				if strings.HasPrefix(src[i:], op) {
+					tok = token{kind: tokPunct}
+					i += len(op)
+					break
+				}
+			}
+			if tok.kind != tokPunct {
+				msg := fmt.Sprintf("unexpected character '%c'", c)
+				switch c {
+				case '=':
+					msg = "unexpected '=' (use == to compare)"
+				case '&', '|':
+					msg = fmt.Sprintf("unexpected '%c' (use %c%c)", c, c, c)
+				}
+				return nil, &SyntaxError{Offset: i, Msg: msg}
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		tok.text = src[start:i]
+		tok.pos = start
+		tokens = append(tokens, tok)
+	}
+}
+
+// endsOperand reports whether the last token closes an operand, in which case
+// a / divides rather than starting a regex
+func endsOperand(tokens []token) bool {
+	if len(tokens) == 0 {
+		return false
+	}
+	last := tokens[len(tokens)-1]
+	switch last.kind {
+	case tokNumber, tokString, tokRegex, tokVar:
+		return true
+	case tokIdent:
+		return !keywords[last.text] || last.text == "true" || last.text == "false" || last.text == "null"
+	}
+	return last.text == ")" || last.text == "]"
+}
+
+// lexNumber reads 12, 1.5, a duration such as 500ms or 1m30s, a size such as
+// 10KB, or a percentage such as 5%
+func lexNumber(src string, i int) (token, int, error) {
+	start := i
+	for i < len(src) && isDigit(src[i]) {
+		i++
+	}
+	if i+1 < len(src) && src[i] == '.' && isDigit(src[i+1]) {
+		i++
+		for i < len(src) && isDigit(src[i]) {
+			i++
+		}
+	}
+	number := src[start:i]
+
+	unitStart := i
+	for i < len(src) && (isLetter(src[i]) || isDigit(src[i]) || src[i] == '.') {
+		i++
+	}
+	unit := src[unitStart:i]
+
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return token{}, i, &SyntaxError{Offset: start, Msg: fmt.Sprintf("invalid number '%s'", src[start:i])}
+	}
+
+	switch {
+	case unit == "":
+		// 5% is a percentage, 5 % 2 and 5%2 are remainders
+		if i < len(src) && src[i] == '%' {
+			rest := strings.TrimLeft(src[i+1:], " \t")
+			if rest == "" || !startsOperand(rest[0]) {
+				return token{kind: tokNumber, value: n / 100}, i + 1, nil
+			}
+		}
+		return token{kind: tokNumber, value: n}, i, nil
+	case sizeUnits[strings.ToLower(unit)] != 0:
+		return token{kind: tokNumber, value: n * sizeUnits[strings.ToLower(unit)]}, i, nil
+	}
+
+	d, err := time.ParseDuration(src[start:i])
+	if err != nil {
+		return token{}, i, &SyntaxError{Offset: start, Msg: fmt.Sprintf("invalid number '%s'", src[start:i])}
+	}
+	return token{kind: tokNumber, value: d}, i, nil
+}
+
+func startsOperand(c byte) bool {
+	return isDigit(c) || isLetter(c) || c == '(' || c == '$' || c == '"' || c == '\'' || c == '['
+}
+
+// lexString reads a quoted string. Double-quoted strings take Go escapes;
+// in single-quoted ones only \' and \\ are escapes.
+func lexString(src string, i int) (token, int, error) {
+	start, quote := i, src[i]
+	i++
+	for i < len(src) && src[i] != quote {
+		if src[i] == '\\' {
+			i++
+		}
+		i++
+	}
+	if i >= len(src) {
+		return token{}, i, &SyntaxError{Offset: start, Msg: "unterminated string"}
+	}
+	i++
+
+	raw := src[start:i]
+	if quote == '"' {
+		s, err := strconv.Unquote(raw)
+		if err != nil {
+			return token{}, i, &SyntaxError{Offset: start, Msg: fmt.Sprintf("invalid string %s", raw)}
+		}
+		return token{kind: tokString, value: s}, i, nil
+	}
+	s := strings.NewReplacer(`\'`, `'`, `\\`, `\`).Replace(raw[1 : len(raw)-1])
+	return token{kind: tokString, value: s}, i, nil
+}
+
+// lexRegex reads /pattern/flags with flags among i, m and s
+func lexRegex(src string, i int) (token, int, error) {
+	start := i
+	i++
+	for i < len(src) && src[i] != '/' {
+		if src[i] == '\\' {
+			i++
+		}
+		i++
+	}
+	if i >= len(src) {
+		return token{}, i, &SyntaxError{Offset: start, Msg: "unterminated regex"}
+	}
+	pattern := src[start+1 : i]
+	i++
+
+	flagStart := i
+	for i < len(src) && isLetter(src[i]) {
+		i++
+	}
+	if flags := src[flagStart:i]; flags != "" {
+		if strings.Trim(flags, "ims") != "" {
+			return token{}, i, &SyntaxError{Offset: flagStart, Msg: fmt.Sprintf("unknown regex flags '%s'", flags)}
+		}
+		pattern = "(?" + flags + ")" + pattern
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return token{}, i, &SyntaxError{Offset: start, Msg: fmt.Sprintf("invalid regex: %v", err)}
+	}
+	return token{kind: tokRegex, value: re}, i, nil
+}
+
+func isSpace(c byte) bool  { return c == ' ' || c == '\t' || c == '\n' || c == '\r' }
+func isDigit(c byte) bool  { return c >= '0' && c <= '9' }
+func isLetter(c byte) bool { return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' }
+
+// Parser
+
+type parser struct {
+	tokens []token
+	pos    int
+	fields []string
+	vars   []string
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) expect(text string) error {
+	if tok := p.next(); !tok.is(text) {
+		return p.errorf(tok, "expected '%s', got %s", text, tok)
+	}
+	return nil
+}
+
+func (p *parser) errorf(tok token, format string, args ...any) error {
+	return &SyntaxError{Offset: tok.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	for err == nil && p.peek().is("||") {
+		p.next()
+		var right node
+		if right, err = p.parseAnd(); err == nil {
+			left = &logical{and: false, left: left, right: right}
+		}
+	}
+	return left, err
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseComparison()
+	for err == nil && p.peek().is("&&") {
+		p.next()
+		var right node
+		if right, err = p.parseComparison(); err == nil {
+			left = &logical{and: true, left: left, right: right}
+		}
+	}
+	return left, err
+}
+
+// comparisonOp reads a comparison operator, if one comes next
+func (p *parser) comparisonOp() (string, bool, error) {
+	tok := p.peek()
+	switch {
+	case !startsComparison(tok):
+		return "", false, nil
+	case tok.is("not"):
+		p.next()
+		op := p.next()
+		if !op.is("in") && !op.is("contains") && !op.is("matches") {
+			return "", false, p.errorf(op, "expected in, contains or matches after 'not', got %s", op)
+		}
+		return "not " + op.text, true, nil
+	}
+	p.next()
+	return tok.text, true, nil
+}
+
+func startsComparison(tok token) bool {
+	if tok.kind == tokPunct {
+		return slices.Contains([]string{"==", "!=", "<", "<=", ">", ">="}, tok.text)
+	}
+	return tok.is("in") || tok.is("contains") || tok.is("matches") || tok.is("not")
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+
+	opTok := p.peek()
+	op, ok, err := p.comparisonOp()
+	if err != nil || !ok {
+		return left, err
+	}
+
+	right, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+
+	// A pattern given as a string is compiled once, here
+	if strings.HasSuffix(op, "matches") {
+		if lit, ok := right.(*literal); ok {
+			if s, ok := lit.value.(string); ok {
+				re, err := regexp.Compile(s)
+				if err != nil {
+					return nil, p.errorf(opTok, "invalid regex: %v", err)
+				}
+				right = &literal{re}
+			}
+		}
+	}
+
+	if tok := p.peek(); startsComparison(tok) {
+		return nil, p.errorf(tok, "comparisons cannot be chained, use &&")
+	}
+
+	return &binary{op: op, left: left, right: right}, nil
+}
+
+func (p *parser) parseAdditive() (node, error) {
+	left, err := p.parseMultiplicative()
+	for err == nil && (p.peek().is("+") || p.peek().is("-")) {
+		op := p.next().text
+		var right node
+		if right, err = p.parseMultiplicative(); err == nil {
+			left = &binary{op: op, left: left, right: right}
+		}
+	}
+	return left, err
+}
+
+func (p *parser) parseMultiplicative() (node, error) {
+	left, err := p.parseUnary()
+	for err == nil && (p.peek().is("*") || p.peek().is("/") || p.peek().is("%")) {
+		op := p.next().text
+		var right node
+		if right, err = p.parseUnary(); err == nil {
+			left = &binary{op: op, left: left, right: right}
+		}
+	}
+	return left, err
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if tok := p.peek(); tok.is("!") || tok.is("-") {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unary{op: tok.text, operand: operand}, nil
+	}
+	return p.parsePostfix()
+}
+
+func (p *parser) parsePostfix() (node, error) {
+	n, err := p.parsePrimary()
+	for err == nil {
+		switch {
+		case p.peek().is("."):
+			p.next()
+			name := p.next()
+			if name.kind != tokIdent {
+				return nil, p.errorf(name, "expected a member name after '.', got %s", name)
+			}
+			n = &member{operand: n, name: name.text}
+		case p.peek().is("["):
+			p.next()
+			var index node
+			if index, err = p.parseOr(); err == nil {
+				err = p.expect("]")
+			}
+			n = &indexed{operand: n, index: index}
+		default:
+			return n, nil
+		}
+	}
+	return nil, err
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	tok := p.next()
+
+	switch tok.kind {
+	case tokNumber, tokString, tokRegex:
+		return &literal{tok.value}, nil
+
+	case tokVar:
+		name := tok.value.(string)
+		if !slices.Contains(p.vars, name) {
+			p.vars = append(p.vars, name)
+		}
+		return &variable{name: name}, nil
+
+	case tokIdent:
+		switch tok.text {
+		case "true", "false":
+			return &literal{tok.text == "true"}, nil
+		case "null":
+			return &literal{nil}, nil
+		}
+		if keywords[tok.text] {
+			break
+		}
+		if p.peek().is("(") {
+			return p.parseCall(tok)
+		}
+		if !slices.Contains(p.fields, tok.text) {
+			return nil, p.errorf(tok, "unknown name '%s'%s", tok.text, p.expected())
+		}
+		return &field{name: tok.text}, nil
+
+	case tokPunct:
+		switch tok.text {
+		case "(":
+			n, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			return n, p.expect(")")
+		case "[":
+			items := []node{}
+			for !p.peek().is("]") {
+				item, err := p.parseOr()
+				if err != nil {
+					return nil, err
+				}
+				items = append(items, item)
+				if !p.peek().is(",") {
+					break
+				}
+				p.next()
+			}
+			return &list{items: items}, p.expect("]")
+		}
+	}
+
+	return nil, p.errorf(tok, "expected a value, got %s", tok)
+}
+
+// expected lists the fields for unknown name errors
+func (p *parser) expected() string {
+	if len(p.fields) == 0 {
+		return ""
+	}
+	return " (expected " + strings.Join(p.fields, ", ") + " or ${variable})"
+}
+
+func (p *parser) parseCall(name token) (node, error) {
+	p.next() // consume '('
+
+	var args []node
+	for !p.peek().is(")") {
+		arg, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		if !p.peek().is(",") {
+			break
+		}
+		p.next()
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+
+	if fn, ok := builtins[name.text]; ok {
+		if len(args) != fn.arity {
+			return nil, p.errorf(name, "%s() takes %d argument%s, got %d", name.text, fn.arity, plural(fn.arity), len(args))
+		}
+		return &call{name: name.text, builtin: fn.fn, args: args}, nil
+	}
+	if !slices.Contains(p.fields, name.text) {
+		return nil, p.errorf(name, "unknown function '%s'", name.text)
+	}
+	return &call{name: name.text, args: args}, nil
+}
+
+func plural(n int) string {
+	if n == 1 {
+		return ""
+	}
+	return "s"
+}
@@ -0,0 +1,267 @@
+package expr
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testEnv struct {
+	fields map[string]any
+	vars   map[string]any
+}
+
+func (e testEnv) Field(name string) (any, bool) {
+	v, ok := e.fields[name]
+	return v, ok
+}
+
+func (e testEnv) Var(name string) (any, bool) {
+	v, ok := e.vars[name]
+	return v, ok
+}
+
+var fields = []string{"status", "latency", "body", "header"}
+
+func newEnv() testEnv {
+	headers := map[string]string{"Content-Type": "application/json", "X-Remaining": "97"}
+	return testEnv{
+		fields: map[string]any{
+			"status":  200.0,
+			"latency": 120 * time.Millisecond,
+			"body": map[string]any{
+				"id":    7.0,
+				"name":  "Ada Lovelace",
+				"admin": true,
+				"roles": []any{"admin", "ops"},
+				"meta":  map[string]any{"v": 2.0},
+			},
+			"header": Func(func(args ...any) (any, error) {
+				for k, v := range headers {
+					if strings.EqualFold(k, Format(args[0])) {
+						return v, nil
+					}
+				}
+				return nil, nil
+			}),
+		},
+		vars: map[string]any{"role": "Admin", "user_id": "7", "limit": 100.0},
+	}
+}
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		src  string
+		want any
+	}{
+		{`status == 200`, true},
+		{`status == 200 && body.admin`, true},
+		{`status != 200 || body.id == 7`, true},
+		{`!(status >= 500)`, true},
+		{`!body.admin`, false},
+		{`status in [200, 201, 204]`, true},
+		{`status not in [500, 503]`, true},
+		{`"ops" in body.roles`, true},
+		{`body.roles contains "root"`, false},
+		{`body.meta contains "v"`, true},
+		{`body.name contains "Love"`, true},
+		{`body.name matches /^ada/i`, true},
+		{`body.name not matches "^Grace"`, true},
+		{`body.roles[0]`, "admin"},
+		{`body.roles[-1]`, "ops"},
+		{`body.roles[5]`, nil},
+		{`body["name"]`, "Ada Lovelace"},
+		{`body.missing.deeper`, nil},
+		{`body.missing == null`, true},
+		{`body.id == ${user_id}`, true},
+		{`${user_id} + 1`, "71"},
+		{`number(${user_id}) + 1`, 8.0},
+		{`${unset} == null`, true},
+		{`lower(${role}) == "admin"`, true},
+		{`upper("ab") + trim("  c ")`, "ABc"},
+		{`starts_with(body.name, "Ada") && ends_with(body.name, "lace")`, true},
+		{`len(body.roles) == 2 && len(body.name) > 3`, true},
+		{`type(body.meta)`, "object"},
+		{`string(body.id) == "7"`, true},
+		{`1 + 2 * 3 - 4 / 2`, 5.0},
+		{`(1 + 2) * 3 % 4`, 1.0},
+		{`-body.id`, -7.0},
+		{`10%3 == 1`, true},
+		{`5%`, 0.05},
+		{`latency < 500ms`, true},
+		{`latency > 100`, true},
+		{`latency == 120`, true},
+		{`latency * 2 > 200ms`, true},
+		{`1m30s / 1s`, 90.0},
+		{`10KB`, 10240.0},
+		{`header("x-remaining") > 50`, true},
+		{`header("X-Missing") == null`, true},
+		{`header("content-type") contains "json"`, true},
+		{`"a" < "b"`, true},
+		{`'it\'s' == "it's"`, true},
+		{`[1, "two", ${limit}]`, []any{1.0, "two", 100.0}},
+	}
+
+	env := newEnv()
+	for _, tt := range tests {
+		e, err := Compile(tt.src, fields...)
+		if err != nil {
+			t.Errorf("%s: %v", tt.src, err)
+			continue
+		}
+		got, err := e.Eval(env)
+		if err != nil {
+			t.Errorf("%s: %v", tt.src, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s = %#v, want %#v", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestEval_Errors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{`status && true`, "'&&' needs booleans, got number 200"},
+		{`!status`, "'!' needs a boolean"},
+		{`body.name < 3`, "cannot compare string and number with '<'"},
+		{`body.roles - 1`, "cannot apply '-' to array and number"},
+		{`status / 0`, "division by zero"},
+		{`len(status)`, "len(): expected a string, array or object, got number"},
+		{`body.roles[0.5]`, "whole number"},
+		{`number("abc")`, "abc is not a number"},
+	}
+
+	env := newEnv()
+	for _, tt := range tests {
+		_, err := MustCompile(tt.src, fields...).Eval(env)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: error %v, want %q", tt.src, err, tt.want)
+		}
+	}
+
+	// Short-circuiting skips the side that would fail
+	if ok, err := MustCompile(`false && status.x > 1`, fields...).Bool(env); ok || err != nil {
+		t.Errorf("short-circuit: %v, %v", ok, err)
+	}
+	if _, err := MustCompile(`status`, fields...).Bool(env); err == nil {
+		t.Error("expected an error for a non-boolean condition")
+	}
+	if _, err := MustCompile(`status == 200`, fields...).Eval(nil); err == nil || err.Error() != "status is not available" {
+		t.Errorf("error = %v", err)
+	}
+}
+
+func TestCompile_Errors(t *testing.T) {
+	tests := []struct {
+		src    string
+		offset int
+		msg    string
+	}{
+		{`status = 200`, 7, "use =="},
+		{`status == 200 & body.admin`, 14, "use &&"},
+		{`status == `, 10, "expected a value, got end of expression"},
+		{`(status == 200`, 14, "expected ')'"},
+		{`stauts == 200`, 0, "unknown name 'stauts'"},
+		{`size(body) > 1`, 0, "unknown function 'size'"},
+		{`len(body, 1) > 1`, 0, "len() takes 1 argument, got 2"},
+		{`1 < status < 3`, 11, "comparisons cannot be chained"},
+		{`status not == 200`, 11, "expected in, contains or matches after 'not'"},
+		{`body.name matches "["`, 10, "invalid regex"},
+		{`body.name matches /a/x`, 21, "unknown regex flags 'x'"},
+		{`"open`, 0, "unterminated string"},
+		{`${name`, 0, "unterminated variable"},
+		{`5 parsecs`, 2, "unexpected 'parsecs'"},
+		{`12xb == 1`, 0, "invalid number '12xb'"},
+		{`body.`, 5, "expected a member name"},
+	}
+
+	for _, tt := range tests {
+		_, err := Compile(tt.src, fields...)
+		var syntax *SyntaxError
+		if !errors.As(err, &syntax) {
+			t.Errorf("%s: expected a syntax error, got %v", tt.src, err)
+			continue
+		}
+		if syntax.Offset != tt.offset || !strings.Contains(syntax.Msg, tt.msg) {
+			t.Errorf("%s: %q at %d, want %q at %d", tt.src, syntax.Msg, syntax.Offset, tt.msg, tt.offset)
+		}
+	}
+}
+
+func TestType(t *testing.T) {
+	types := map[string]string{"status": "number", "latency": "duration"}
+	tests := map[string]string{
+		`status`:                      "number",
+		`latency`:                     "duration",
+		`-latency`:                    "duration",
+		`status * 2 + 1`:              "number",
+		`"v" + status`:                "string",
+		`[1, 2]`:                      "array",
+		`len(body.items)`:             "number",
+		`lower(header("X-Env"))`:      "string",
+		`null`:                        "null",
+		`status == 200`:               "boolean",
+		`!body.admin`:                 "boolean",
+		`body.admin && status < 300`:  "boolean",
+		`starts_with(body.name, "A")`: "boolean",
+		`body.admin`:                  "",
+		`header("X-Flag")`:            "",
+		`${enabled}`:                  "",
+		`latency * 2`:                 "",
+	}
+	for src, want := range tests {
+		if got := MustCompile(src, fields...).Type(types); got != want {
+			t.Errorf("%s: type %q, want %q", src, got, want)
+		}
+	}
+}
+
+func TestBind(t *testing.T) {
+	e := MustCompile(`${env} == "prod" && ${limit} > 10`)
+	bound := e.Bind(func(name string) (any, bool) {
+		if name == "env" {
+			return "prod", true
+		}
+		return nil, false
+	})
+
+	env := testEnv{vars: map[string]any{"env": "dev", "limit": 20.0}}
+	for _, tt := range []struct {
+		e    *Expr
+		want bool
+	}{{e, false}, {bound, true}} {
+		got, err := tt.e.Bool(env)
+		if err != nil || got != tt.want {
+			t.Errorf("%s = %v (%v), want %v", tt.e, got, err, tt.want)
+		}
+	}
+}
+
+func TestEqual(t *testing.T) {
+	tests := []struct {
+		a, b any
+		want bool
+	}{
+		{7.0, "7", true},
+		{"7", 7.0, true},
+		{7.0, "seven", false},
+		{true, "true", true},
+		{nil, nil, true},
+		{nil, "null", false},
+		{100 * time.Millisecond, 100.0, true},
+		{[]any{1.0, "a"}, []any{1.0, "a"}, true},
+		{map[string]any{"a": 1.0}, map[string]any{"a": 2.0}, false},
+	}
+	for _, tt := range tests {
+		if got := Equal(tt.a, tt.b); got != tt.want {
+			t.Errorf("Equal(%s, %s) = %v", fmt.Sprint(tt.a), fmt.Sprint(tt.b), got)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+// Package extract pulls values out of responses. It backs both the DSL
+// extract blocks and the extract actions returned by evaluators, so a rule
+// behaves the same wherever it is written.
+package extract
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/vikasavnish/httptool/pkg/ir"
+	"github.com/vikasavnish/httptool/pkg/jsonpath"
+)
+
+// Apply runs every rule against the response. Rules that match nothing and
+// have no default are left out of the result.
+func Apply(resp *ir.Response, rules map[string]ir.ExtractRule) (map[string]any, error) {
+	extracted := make(map[string]any, len(rules))
+	for name, rule := range rules {
+		value, ok, err := Value(resp, rule)
+		if err != nil {
+			return extracted, fmt.Errorf("extract '%s': %w", name, err)
+		}
+		if ok {
+			extracted[name] = value
+		}
+	}
+	return extracted, nil
+}
+
+// Value runs one rule against the response
+func Value(resp *ir.Response, rule ir.ExtractRule) (any, bool, error) {
+	var value any
+	var found bool
+
+	if resp != nil {
+		switch {
+		case rule.JSONPath != "":
+			var err error
+			if value, found, err = JSONPath(resp.Body, rule.JSONPath); err != nil {
+				return nil, false, err
+			}
+		case rule.Regex != "":
+			re, err := regexp.Compile(rule.Regex)
+			if err != nil {
+				return nil, false, fmt.Errorf("invalid regex: %w", err)
+			}
+			value, found = matchRegex(re, bodyText(resp.Body))
+		case rule.Header != "":
+			value, found = header(resp.Headers, rule.Header)
+		case rule.Cookie != "":
+			value, found = cookie(resp, rule.Cookie)
+		}
+	}
+
+	if !found && rule.Default != "" {
+		return rule.Default, true, nil
+	}
+	return value, found, nil
+}
+
+// paths caches compiled JSONPath expressions, which repeat across requests
+var paths sync.Map
+
+// JSONPath evaluates a JSONPath expression against a decoded JSON body. Paths
+// that can match several values, such as $.items[*].id, return a list.
+func JSONPath(body any, expr string) (any, bool, error) {
+	path, err := compilePath(expr)
+	if err != nil {
+		return nil, false, err
+	}
+	value, ok := path.Lookup(body)
+	return value, ok, nil
+}
+
+func compilePath(expr string) (*jsonpath.Path, error) {
+	if cached, ok := paths.Load(expr); ok {
+		return cached.(*jsonpath.Path), nil
+	}
+	path, err := jsonpath.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	paths.Store(expr, path)
+	return path, nil
+}
+
+// matchRegex returns the first capture group, or the whole match when the
+// pattern has no groups
+func matchRegex(re *regexp.Regexp, text string) (string, bool) {
+	matches := re.FindStringSubmatch(text)
+	switch {
+	case matches == nil:
+		return "", false
+	case len(matches) > 1:
+		return matches[1], true
+	default:
+		return matches[0], true
+	}
+}
+
+// bodyText renders a body for regex matching: strings as they are, decoded
+// JSON re-encoded
+func bodyText(body any) string {
+	if s, ok := body.(string); ok {
+		return s
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Sprintf("%v", body)
+	}
+	return string(data)
+}
+
+func header(headers map[string]string, name string) (string, bool) {
+	if value, ok := headers[name]; ok {
+		return value, true
+	}
+	for key, value := range headers {
+		if strings.EqualFold(key, name) {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// cookie finds the value a response sets a cookie to, e.g. "abc" for sid in
+// "sid=abc; Path=/". When several Set-Cookie headers set it the last wins.
+func cookie(resp *ir.Response, name string) (string, bool) {
+	value, found := "", false
+	for _, c := range Cookies(resp) {
+		if c.Name == name {
+			value, found = c.Value, true
+		}
+	}
+	return value, found
+}
+
+// Cookies parses the cookies a response sets, in order. Responses built
+// without SetCookies, e.g. by hand, fall back to their Set-Cookie header.
+func Cookies(resp *ir.Response) []*http.Cookie {
+	setCookies := resp.SetCookies
+	if len(setCookies) == 0 {
+		if value, ok := header(resp.Headers, "Set-Cookie"); ok {
+			setCookies = []string{value}
+		}
+	}
+	return (&http.Response{Header: http.Header{"Set-Cookie": setCookies}}).Cookies()
+}
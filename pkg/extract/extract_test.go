@@ -0,0 +1,96 @@
+package extract
+
+import (
+	"testing"
+
+	"github.com/vikasavnish/httptool/pkg/ir"
+)
+
+func TestApply(t *testing.T) {
+	resp := &ir.Response{
+		Status: 200,
+		Headers: map[string]string{
+			"X-Request-Id": "req-1",
+			"Set-Cookie":   "session=abc123; Path=/; HttpOnly",
+		},
+		Body: map[string]any{
+			"user": map[string]any{"id": float64(42), "name": "ada"},
+			"note": "order #1234 created",
+		},
+	}
+
+	extracted, err := Apply(resp, map[string]ir.ExtractRule{
+		"id":       {JSONPath: "$.user.id"},
+		"order":    {Regex: `order #(\d+)`},
+		"request":  {Header: "x-request-id"},
+		"session":  {Cookie: "session"},
+		"fallback": {JSONPath: "$.user.email", Default: "none"},
+		"missing":  {JSONPath: "$.user.email"},
+		"keys":     {JSONPath: "$.user.*"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]any{
+		"id":       float64(42),
+		"order":    "1234",
+		"request":  "req-1",
+		"session":  "abc123",
+		"fallback": "none",
+	}
+	if keys, ok := extracted["keys"].([]any); !ok || len(keys) != 2 {
+		t.Errorf("keys = %v, want a list of 2", extracted["keys"])
+	}
+	delete(extracted, "keys")
+	if len(extracted) != len(want) {
+		t.Errorf("extracted %v, want %v", extracted, want)
+	}
+	for k, v := range want {
+		if extracted[k] != v {
+			t.Errorf("%s = %v, want %v", k, extracted[k], v)
+		}
+	}
+}
+
+func TestValue_Cookies(t *testing.T) {
+	resp := &ir.Response{
+		Headers: map[string]string{"Set-Cookie": "theme=light; Expires=Wed, 21 Oct 2026 07:28:00 GMT"},
+		SetCookies: []string{
+			"theme=light; Expires=Wed, 21 Oct 2026 07:28:00 GMT",
+			"sid=abc; Path=/; HttpOnly",
+			"theme=dark",
+		},
+	}
+
+	for name, want := range map[string]string{"sid": "abc", "theme": "dark"} {
+		if value, found, _ := Value(resp, ir.ExtractRule{Cookie: name}); !found || value != want {
+			t.Errorf("%s = %v, want %s", name, value, want)
+		}
+	}
+	// An Expires date is not a cookie
+	if _, found, _ := Value(resp, ir.ExtractRule{Cookie: "21 Oct 2026 07:28:00 GMT"}); found {
+		t.Error("found a cookie in the Expires date")
+	}
+}
+
+func TestApply_InvalidRegex(t *testing.T) {
+	_, err := Apply(&ir.Response{Body: "text"}, map[string]ir.ExtractRule{"x": {Regex: "("}})
+	if err == nil {
+		t.Fatal("expected an error for an invalid regex")
+	}
+}
+
+func TestApply_InvalidJSONPath(t *testing.T) {
+	_, err := Apply(&ir.Response{Body: map[string]any{}}, map[string]ir.ExtractRule{"x": {JSONPath: "$.items["}})
+	if err == nil {
+		t.Fatal("expected an error for an invalid JSONPath")
+	}
+}
+
+func TestValue_RegexWholeMatch(t *testing.T) {
+	value, ok, err := Value(&ir.Response{Body: "token=abc"}, ir.ExtractRule{Regex: `abc`})
+	if err != nil || !ok || value != "abc" {
+		t.Errorf("got %v, %v, %v", value, ok, err)
+	}
+}
@@ -0,0 +1,223 @@
+package har
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vikasavnish/httptool/pkg/extract"
+	"github.com/vikasavnish/httptool/pkg/ir"
+)
+
+// Recorder collects executed requests as HAR entries. It is safe for
+// concurrent use, so it can record every request of a load test, though it
+// keeps all their bodies in memory.
+type Recorder struct {
+	mu      sync.Mutex
+	entries []Entry
+}
+
+// NewRecorder creates an empty recorder
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// Add records a request that started at start
+func (r *Recorder) Add(start time.Time, ctx *ir.EvaluationContext) {
+	entry := toEntry(start, ctx)
+
+	r.mu.Lock()
+	r.entries = append(r.entries, entry)
+	r.mu.Unlock()
+}
+
+// HAR returns the recorded entries in the order they started
+func (r *Recorder) HAR() *HAR {
+	r.mu.Lock()
+	entries := make([]Entry, len(r.entries))
+	copy(entries, r.entries)
+	r.mu.Unlock()
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].StartedDateTime.Before(entries[j].StartedDateTime)
+	})
+
+	return &HAR{Log: Log{
+		Version: Version,
+		Creator: Creator{Name: "httptool", Version: ir.Version},
+		Entries: entries,
+	}}
+}
+
+// Write writes the recorded entries as a HAR document
+func (r *Recorder) Write(w io.Writer) error {
+	return Write(w, r.HAR())
+}
+
+// toEntry converts an executed request and its response
+func toEntry(start time.Time, ctx *ir.EvaluationContext) Entry {
+	resp := ctx.Response
+
+	entry := Entry{
+		StartedDateTime: start,
+		Time:            resp.LatencyMs,
+		Request: Request{
+			Method:      ctx.Request.Method,
+			URL:         ctx.Request.URL,
+			HTTPVersion: httpVersion(resp.Protocol),
+			Cookies:     []Cookie{},
+			Headers:     nameValues(ctx.Request.Headers),
+			QueryString: []NameValue{},
+			HeadersSize: -1,
+			BodySize:    0,
+		},
+		Response: Response{
+			Status:      resp.Status,
+			StatusText:  http.StatusText(resp.Status),
+			HTTPVersion: httpVersion(resp.Protocol),
+			Cookies:     responseCookies(resp),
+			Headers:     responseHeaders(resp),
+			Content: Content{
+				Size:     resp.SizeBytes,
+				MimeType: headerText(resp.Headers, "Content-Type"),
+				Text:     bodyText(resp.Body),
+			},
+			RedirectURL: headerText(resp.Headers, "Location"),
+			HeadersSize: -1,
+			BodySize:    resp.SizeBytes,
+			Error:       resp.Error,
+		},
+		Timings: Timings{Blocked: -1, DNS: -1, Connect: -1, Send: 0, Wait: resp.LatencyMs, Receive: 0, SSL: -1},
+	}
+
+	if u, err := url.Parse(ctx.Request.URL); err == nil {
+		for key, values := range u.Query() {
+			for _, value := range values {
+				entry.Request.QueryString = append(entry.Request.QueryString, NameValue{Name: key, Value: value})
+			}
+		}
+		sort.Slice(entry.Request.QueryString, func(i, j int) bool {
+			return entry.Request.QueryString[i].Name < entry.Request.QueryString[j].Name
+		})
+	}
+
+	if ctx.Request.Body != nil {
+		mimeType := headerText(ctx.Request.Headers, "Content-Type")
+		text := bodyText(ctx.Request.Body)
+		if form, ok := ctx.Request.Body.(map[string]any); ok && mimeType == "application/x-www-form-urlencoded" {
+			values := url.Values{}
+			for k, v := range form {
+				values.Set(k, fmt.Sprintf("%v", v))
+			}
+			text = values.Encode()
+		}
+		entry.Request.PostData = &PostData{MimeType: mimeType, Text: text}
+		entry.Request.BodySize = int64(len(text))
+	}
+
+	if t := resp.Timing; t != nil {
+		entry.Timings = Timings{
+			Blocked: -1,
+			DNS:     phase(t.DNSMs, t.ConnReused),
+			Connect: phase(t.ConnectMs+t.TLSMs, t.ConnReused),
+			SSL:     phase(t.TLSMs, t.ConnReused || resp.TLSVersion == ""),
+			Send:    0,
+			Wait:    t.WaitMs,
+			Receive: t.TransferMs,
+		}
+		entry.Time = t.TTFBMs + t.TransferMs
+		if t.ConnReused {
+			entry.Connection = "reused"
+		}
+	}
+
+	return entry
+}
+
+// phase is a timing, or -1 when the phase did not apply
+func phase(ms float64, skipped bool) float64 {
+	if skipped {
+		return -1
+	}
+	return ms
+}
+
+// httpVersion names a protocol the way browsers write it in HAR files
+func httpVersion(proto string) string {
+	if proto == "" {
+		return "HTTP/1.1"
+	}
+	return proto
+}
+
+// bodyText renders a decoded body: strings as they are, JSON re-encoded
+func bodyText(body any) string {
+	switch b := body.(type) {
+	case nil:
+		return ""
+	case string:
+		return b
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			return ""
+		}
+		return string(data)
+	}
+}
+
+// responseHeaders lists a response's headers with every Set-Cookie header
+// instead of only the first
+func responseHeaders(resp *ir.Response) []NameValue {
+	if len(resp.SetCookies) == 0 {
+		return nameValues(resp.Headers)
+	}
+	list := make([]NameValue, 0, len(resp.Headers)+len(resp.SetCookies))
+	for name, value := range resp.Headers {
+		if !strings.EqualFold(name, "Set-Cookie") {
+			list = append(list, NameValue{Name: name, Value: value})
+		}
+	}
+	for _, value := range resp.SetCookies {
+		list = append(list, NameValue{Name: "Set-Cookie", Value: value})
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// responseCookies lists the cookies a response sets
+func responseCookies(resp *ir.Response) []Cookie {
+	cookies := []Cookie{}
+	for _, c := range extract.Cookies(resp) {
+		cookies = append(cookies, Cookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Path:     c.Path,
+			Domain:   c.Domain,
+			HTTPOnly: c.HttpOnly,
+			Secure:   c.Secure,
+		})
+	}
+	return cookies
+}
+
+func nameValues(headers map[string]string) []NameValue {
+	list := make([]NameValue, 0, len(headers))
+	for name, value := range headers {
+		list = append(list, NameValue{Name: name, Value: value})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// headerText returns a header's value, or "" when it is not set
+func headerText(headers map[string]string, name string) string {
+	value, _ := header(headers, name)
+	return value
+}
@@ -0,0 +1,151 @@
+// Package har reads and writes HTTP Archive (HAR) 1.2 files, the format
+// browser devtools export and import network captures in.
+package har
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Version is the HAR version written by this package
+const Version = "1.2"
+
+// HAR is an HTTP Archive document
+type HAR struct {
+	Log Log `json:"log"`
+}
+
+// Log is the root of a HAR document
+type Log struct {
+	Version string  `json:"version"`
+	Creator Creator `json:"creator"`
+	Entries []Entry `json:"entries"`
+	Comment string  `json:"comment,omitempty"`
+}
+
+// Creator names the application that wrote the file
+type Creator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// Entry is one request and its response
+type Entry struct {
+	StartedDateTime time.Time `json:"startedDateTime"`
+	Time            float64   `json:"time"` // Total milliseconds, the sum of the timings
+	Request         Request   `json:"request"`
+	Response        Response  `json:"response"`
+	Cache           struct{}  `json:"cache"`
+	Timings         Timings   `json:"timings"`
+	ServerIPAddress string    `json:"serverIPAddress,omitempty"`
+	Connection      string    `json:"connection,omitempty"`
+	Comment         string    `json:"comment,omitempty"`
+}
+
+// Request is the request of an entry
+type Request struct {
+	Method      string      `json:"method"`
+	URL         string      `json:"url"`
+	HTTPVersion string      `json:"httpVersion"`
+	Cookies     []Cookie    `json:"cookies"`
+	Headers     []NameValue `json:"headers"`
+	QueryString []NameValue `json:"queryString"`
+	PostData    *PostData   `json:"postData,omitempty"`
+	HeadersSize int64       `json:"headersSize"` // -1 when unknown
+	BodySize    int64       `json:"bodySize"`    // -1 when unknown
+}
+
+// Response is the response of an entry. A request that got no response has
+// status 0 and the reason in Error.
+type Response struct {
+	Status      int         `json:"status"`
+	StatusText  string      `json:"statusText"`
+	HTTPVersion string      `json:"httpVersion"`
+	Cookies     []Cookie    `json:"cookies"`
+	Headers     []NameValue `json:"headers"`
+	Content     Content     `json:"content"`
+	RedirectURL string      `json:"redirectURL"`
+	HeadersSize int64       `json:"headersSize"`
+	BodySize    int64       `json:"bodySize"`
+	Error       string      `json:"_error,omitempty"`
+}
+
+// NameValue is a header or query parameter
+type NameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Cookie is a request or response cookie
+type Cookie struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Path     string `json:"path,omitempty"`
+	Domain   string `json:"domain,omitempty"`
+	HTTPOnly bool   `json:"httpOnly,omitempty"`
+	Secure   bool   `json:"secure,omitempty"`
+}
+
+// PostData is a request body. Form bodies may come as Params instead of Text.
+type PostData struct {
+	MimeType string  `json:"mimeType"`
+	Text     string  `json:"text"`
+	Params   []Param `json:"params,omitempty"`
+}
+
+// Param is a form field of a request body
+type Param struct {
+	Name        string `json:"name"`
+	Value       string `json:"value,omitempty"`
+	FileName    string `json:"fileName,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
+}
+
+// Content is a response body. Encoding is "base64" for binary bodies.
+type Content struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// Timings are the phases of an entry in milliseconds, -1 when they do not
+// apply. Connect includes SSL.
+type Timings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	SSL     float64 `json:"ssl"`
+}
+
+// Load reads a HAR file
+func Load(path string) (*HAR, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+// Read decodes a HAR document
+func Read(r io.Reader) (*HAR, error) {
+	var h HAR
+	if err := json.NewDecoder(r).Decode(&h); err != nil {
+		return nil, fmt.Errorf("invalid HAR: %w", err)
+	}
+	return &h, nil
+}
+
+// Write encodes a HAR document, indented like devtools exports
+func Write(w io.Writer, h *HAR) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(h)
+}
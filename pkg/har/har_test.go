@@ -0,0 +1,245 @@
+package har
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vikasavnish/httptool/pkg/executor"
+	"github.com/vikasavnish/httptool/pkg/ir"
+	"github.com/vikasavnish/httptool/pkg/scenario"
+)
+
+const capture = `{
+  "log": {
+    "version": "1.2",
+    "creator": {"name": "WebInspector", "version": "537.36"},
+    "entries": [
+      {
+        "startedDateTime": "2024-05-01T10:00:01.000Z",
+        "time": 40,
+        "request": {
+          "method": "POST", "url": "https://api.example.com/login", "httpVersion": "h2",
+          "headers": [
+            {"name": ":authority", "value": "api.example.com"},
+            {"name": "content-type", "value": "application/json"},
+            {"name": "accept-encoding", "value": "gzip"},
+            {"name": "x-client", "value": "it's \"web\""}
+          ],
+          "cookies": [{"name": "sid", "value": "abc"}],
+          "postData": {"mimeType": "application/json", "text": "{\"user\": \"ada\", \"note\": \"a\\\\b\"}"}
+        },
+        "response": {"status": 200, "content": {"size": 10, "mimeType": "application/json; charset=utf-8"}},
+        "timings": {"wait": 30, "receive": 10}
+      },
+      {
+        "startedDateTime": "2024-05-01T10:00:00.000Z",
+        "time": 50,
+        "request": {"method": "GET", "url": "https://api.example.com/users?page=2&tag=a&tag=b", "headers": []},
+        "response": {"status": 200, "content": {"size": 10, "mimeType": "application/json"}},
+        "timings": {"wait": 50}
+      },
+      {
+        "startedDateTime": "2024-05-01T10:00:00.010Z",
+        "time": 5,
+        "request": {"method": "GET", "url": "https://cdn.example.net/app.js", "headers": []},
+        "response": {"status": 200, "content": {"size": 10, "mimeType": "application/javascript"}},
+        "timings": {"wait": 5}
+      },
+      {
+        "startedDateTime": "2024-05-01T10:00:01.050Z",
+        "time": 20,
+        "request": {"method": "GET", "url": "https://www.example.com/", "headers": []},
+        "response": {"status": 200, "content": {"size": 10, "mimeType": "text/html"}},
+        "timings": {"wait": 20}
+      },
+      {
+        "startedDateTime": "2024-05-01T10:00:01.060Z",
+        "time": 20,
+        "request": {
+          "method": "POST", "url": "https://api.example.com/users", "headers": [],
+          "postData": {"mimeType": "application/x-www-form-urlencoded", "params": [{"name": "name", "value": "Ada Lovelace"}]}
+        },
+        "response": {"status": 201, "content": {"size": 10, "mimeType": "application/json"}},
+        "timings": {"wait": 20}
+      },
+      {
+        "startedDateTime": "2024-05-01T10:00:03.000Z",
+        "time": 20,
+        "request": {"method": "GET", "url": "https://api.example.com/users#top", "headers": []},
+        "response": {"status": 200, "content": {"size": 10, "mimeType": "application/json"}},
+        "timings": {"wait": 20}
+      }
+    ]
+  }
+}`
+
+func TestImport(t *testing.T) {
+	h, err := Read(strings.NewReader(capture))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requests, err := Import(h, ImportOptions{Domains: []string{"example.com"}, ContentTypes: []string{"json"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, req := range requests {
+		got = append(got, fmt.Sprintf("%s %v", req.Name, req.Think))
+	}
+	want := []string{"get_users 0s", "post_login 950ms", "post_users 0s", "get_users_2 1.92s"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("imported %v, want %v", got, want)
+	}
+
+	login := requests[1].IR
+	if login.Metadata.Source != "har" || login.Request.Cookies["sid"] != "abc" {
+		t.Errorf("login = %+v", login)
+	}
+	if _, ok := login.Request.Headers[":authority"]; ok || login.Request.Headers["accept-encoding"] != "" {
+		t.Errorf("client headers were kept: %v", login.Request.Headers)
+	}
+	if body := login.Request.Body; body.Type != "json" || body.Content.(map[string]any)["user"] != "ada" {
+		t.Errorf("login body = %+v", body)
+	}
+	if users := requests[0].IR.Request; users.URL != "https://api.example.com/users" || users.Query["page"] != "2" {
+		t.Errorf("users request = %+v", users)
+	}
+}
+
+func TestWriteScenario(t *testing.T) {
+	h, _ := Read(strings.NewReader(capture))
+	requests, err := Import(h, ImportOptions{Domains: []string{"api.example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := WriteScenario(&out, "session", requests); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := scenario.NewParser(out.String()).Parse()
+	if err != nil {
+		t.Fatalf("%v\n%s", err, out.String())
+	}
+	compiled, err := scenario.NewCompiler().Compile(s, "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var think []string
+	var compiledRequests []*ir.Request
+	for _, node := range compiled.Main {
+		if node.ThinkTime != nil {
+			think = append(think, node.ThinkTime.Duration)
+		}
+		if node.IR != nil {
+			compiledRequests = append(compiledRequests, &node.IR.Request)
+		}
+	}
+	if !reflect.DeepEqual(think, []string{"950ms", "1920ms"}) {
+		t.Errorf("think times %v", think)
+	}
+
+	if len(compiledRequests) != len(requests) {
+		t.Fatalf("compiled %d requests, want %d", len(compiledRequests), len(requests))
+	}
+	for i, req := range requests {
+		want, got := req.IR.Request, compiledRequests[i]
+		if got.Method != want.Method || got.URL != want.URL || !reflect.DeepEqual(got.Cookies, want.Cookies) {
+			t.Errorf("%s: compiled %s %s %v, want %s %s %v", req.Name, got.Method, got.URL, got.Cookies, want.Method, want.URL, want.Cookies)
+		}
+		if fmt.Sprint(got.Query) != fmt.Sprint(want.Query) {
+			t.Errorf("%s: query %v, want %v", req.Name, got.Query, want.Query)
+		}
+		for k, v := range want.Headers {
+			if got.Headers[k] != v {
+				t.Errorf("%s: header %s = %q, want %q", req.Name, k, got.Headers[k], v)
+			}
+		}
+		if want.Body != nil && (got.Body == nil || fmt.Sprint(got.Body.Content) != fmt.Sprint(want.Body.Content)) {
+			t.Errorf("%s: body %+v, want %+v", req.Name, got.Body, want.Body)
+		}
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		http.SetCookie(w, &http.Cookie{Name: "sid", Value: "abc", Path: "/", HttpOnly: true})
+		http.SetCookie(w, &http.Cookie{Name: "theme", Value: "dark", Expires: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)})
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"id": 7}`)
+	}))
+	defer server.Close()
+
+	spec := &ir.IR{
+		Request: ir.Request{
+			Method:  "POST",
+			URL:     server.URL + "/users?dry=1",
+			Headers: map[string]string{"Content-Type": "application/json"},
+			Body:    &ir.Body{Type: "json", Content: map[string]any{"name": "ada"}},
+		},
+		Transport: ir.DefaultTransport(),
+	}
+
+	recorder := NewRecorder()
+	start := time.Now()
+	ctx, err := executor.NewExecutor().Execute(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder.Add(start, ctx)
+
+	var out bytes.Buffer
+	if err := recorder.Write(&out); err != nil {
+		t.Fatal(err)
+	}
+	h, err := Read(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(h.Log.Entries) != 1 || h.Log.Version != "1.2" {
+		t.Fatalf("log = %+v", h.Log)
+	}
+	entry := h.Log.Entries[0]
+	if entry.Response.Status != 201 || entry.Response.Content.Text != `{"id":7}` || entry.Response.Content.MimeType != "application/json" {
+		t.Errorf("response = %+v", entry.Response)
+	}
+	var setCookies []string
+	for _, h := range entry.Response.Headers {
+		if h.Name == "Set-Cookie" {
+			setCookies = append(setCookies, h.Value)
+		}
+	}
+	if len(setCookies) != 2 || len(entry.Response.Cookies) != 2 || entry.Response.Cookies[0] != (Cookie{Name: "sid", Value: "abc", Path: "/", HTTPOnly: true}) || entry.Response.Cookies[1].Value != "dark" {
+		t.Errorf("set cookies %q, cookies %+v", setCookies, entry.Response.Cookies)
+	}
+	if entry.Request.PostData == nil || entry.Request.PostData.Text != `{"name":"ada"}` {
+		t.Errorf("request body = %+v", entry.Request.PostData)
+	}
+	if entry.Request.HTTPVersion != "HTTP/1.1" || len(entry.Request.QueryString) != 1 {
+		t.Errorf("request = %+v", entry.Request)
+	}
+	if entry.Timings.Wait <= 0 || entry.Timings.Connect <= 0 || entry.Timings.SSL != -1 {
+		t.Errorf("timings = %+v", entry.Timings)
+	}
+
+	// A recorded session imports back to the same request
+	requests, err := Import(h, ImportOptions{})
+	if err != nil || len(requests) != 1 {
+		t.Fatalf("import: %v %v", requests, err)
+	}
+	if got := requests[0].IR.Request; got.Method != "POST" || got.Query["dry"] != "1" || got.Body.Type != "json" {
+		t.Errorf("re-imported %+v", got)
+	}
+}
@@ -0,0 +1,277 @@
+package har
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vikasavnish/httptool/pkg/ir"
+	"github.com/vikasavnish/httptool/pkg/parser"
+)
+
+// ImportOptions select the entries that become requests
+type ImportOptions struct {
+	Domains      []string      // Hosts to keep, subdomains included; empty keeps all
+	ContentTypes []string      // Response MIME types to keep, e.g. application/json, or parts such as json; empty keeps all
+	MinThink     time.Duration // Shorter gaps between requests are not think time: 100ms
+}
+
+// Imported is an entry converted to IR
+type Imported struct {
+	Name  string // Unique request name derived from the method and path
+	IR    *ir.IR
+	Think time.Duration // Pause between the previous request ending and this one starting
+}
+
+// skippedHeaders are set by the HTTP client, or are HTTP/2 pseudo-headers
+var skippedHeaders = map[string]bool{
+	"host":              true,
+	"content-length":    true,
+	"connection":        true,
+	"accept-encoding":   true,
+	"transfer-encoding": true,
+	"cookie":            true, // From the entry's cookies instead
+}
+
+// Import converts the entries that pass the filters to IR, in the order they
+// started
+func Import(h *HAR, opts ImportOptions) ([]Imported, error) {
+	minThink := opts.MinThink
+	if minThink == 0 {
+		minThink = 100 * time.Millisecond
+	}
+
+	entries := make([]Entry, len(h.Log.Entries))
+	copy(entries, h.Log.Entries)
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].StartedDateTime.Before(entries[j].StartedDateTime)
+	})
+
+	var imported []Imported
+	names := make(map[string]bool)
+	var lastEnd time.Time
+
+	for i := range entries {
+		entry := &entries[i]
+		if !keep(entry, opts) {
+			continue
+		}
+
+		irSpec, err := ToIR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("entry %d (%s %s): %w", i+1, entry.Request.Method, entry.Request.URL, err)
+		}
+
+		req := Imported{Name: uniqueName(requestName(irSpec), names), IR: irSpec}
+		if !lastEnd.IsZero() {
+			if gap := entry.StartedDateTime.Sub(lastEnd); gap >= minThink {
+				req.Think = gap.Round(time.Millisecond)
+			}
+		}
+		end := entry.StartedDateTime.Add(time.Duration(entry.Time * float64(time.Millisecond)))
+		if end.After(lastEnd) {
+			lastEnd = end
+		}
+
+		imported = append(imported, req)
+	}
+
+	return imported, nil
+}
+
+// keep reports whether an entry passes the domain and content type filters
+func keep(entry *Entry, opts ImportOptions) bool {
+	if len(opts.Domains) > 0 {
+		u, err := url.Parse(entry.Request.URL)
+		if err != nil {
+			return false
+		}
+		host := strings.ToLower(u.Hostname())
+		found := false
+		for _, domain := range opts.Domains {
+			domain = strings.ToLower(strings.TrimPrefix(domain, "."))
+			if host == domain || strings.HasSuffix(host, "."+domain) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(opts.ContentTypes) > 0 {
+		mimeType, _, _ := mime.ParseMediaType(entry.Response.Content.MimeType)
+		found := false
+		for _, want := range opts.ContentTypes {
+			if mimeType != "" && strings.Contains(mimeType, strings.ToLower(want)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ToIR converts the request of an entry to IR
+func ToIR(entry *Entry) (*ir.IR, error) {
+	u, err := url.Parse(entry.Request.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+
+	started := entry.StartedDateTime
+	result := &ir.IR{
+		Version: ir.Version,
+		Metadata: &ir.Metadata{
+			ID:        uuid.New().String(),
+			Source:    "har",
+			CreatedAt: &started,
+		},
+		Request: ir.Request{
+			Method:  strings.ToUpper(entry.Request.Method),
+			Headers: make(map[string]string),
+		},
+		Transport:  ir.DefaultTransport(),
+		Evaluation: ir.DefaultEvaluation(),
+	}
+
+	// Query parameters come from the URL, which is what was sent
+	if query := u.Query(); len(query) > 0 {
+		result.Request.Query = make(map[string]any)
+		for key, values := range query {
+			if len(values) == 1 {
+				result.Request.Query[key] = values[0]
+			} else {
+				result.Request.Query[key] = values
+			}
+		}
+		u.RawQuery = ""
+	}
+	u.Fragment = ""
+	result.Request.URL = u.String()
+
+	for _, h := range entry.Request.Headers {
+		if strings.HasPrefix(h.Name, ":") || skippedHeaders[strings.ToLower(h.Name)] {
+			continue
+		}
+		result.Request.Headers[h.Name] = h.Value
+	}
+
+	if len(entry.Request.Cookies) > 0 {
+		result.Request.Cookies = make(map[string]string)
+		for _, c := range entry.Request.Cookies {
+			result.Request.Cookies[c.Name] = c.Value
+		}
+	}
+
+	if entry.Request.PostData != nil {
+		result.Request.Body = postDataBody(entry.Request.PostData)
+		if _, ok := header(result.Request.Headers, "Content-Type"); !ok && entry.Request.PostData.MimeType != "" {
+			result.Request.Headers["Content-Type"] = entry.Request.PostData.MimeType
+		}
+	}
+
+	return result, nil
+}
+
+// postDataBody converts a request body, keeping JSON and form fields
+// structured
+func postDataBody(data *PostData) *ir.Body {
+	mimeType, _, _ := mime.ParseMediaType(data.MimeType)
+
+	switch {
+	case strings.Contains(mimeType, "json"):
+		var content any
+		if err := json.Unmarshal([]byte(data.Text), &content); err == nil {
+			return &ir.Body{Type: "json", Content: content}
+		}
+	case mimeType == "application/x-www-form-urlencoded":
+		form := make(map[string]any)
+		if len(data.Params) > 0 {
+			for _, p := range data.Params {
+				form[p.Name] = p.Value
+			}
+			return &ir.Body{Type: "form", Content: form}
+		}
+		if values, err := url.ParseQuery(data.Text); err == nil {
+			for key := range values {
+				form[key] = values.Get(key)
+			}
+			return &ir.Body{Type: "form", Content: form}
+		}
+	}
+
+	return &ir.Body{Type: "text", Content: data.Text}
+}
+
+// header looks a header up case-insensitively
+func header(headers map[string]string, name string) (string, bool) {
+	for k, v := range headers {
+		if strings.EqualFold(k, name) {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+var nonWord = regexp.MustCompile(`[^a-z0-9]+`)
+
+// requestName names a request after its method and path, e.g.
+// get_api_users_42
+func requestName(irSpec *ir.IR) string {
+	name := strings.ToLower(irSpec.Request.Method)
+	if u, err := url.Parse(irSpec.Request.URL); err == nil {
+		if path := strings.Trim(nonWord.ReplaceAllString(strings.ToLower(u.Path), "_"), "_"); path != "" {
+			name += "_" + path
+		}
+	}
+	if len(name) > 48 {
+		name = strings.TrimRight(name[:48], "_")
+	}
+	return name
+}
+
+// uniqueName numbers repeated names: get_users, get_users_2, ...
+func uniqueName(name string, seen map[string]bool) string {
+	unique := name
+	for n := 2; seen[unique]; n++ {
+		unique = fmt.Sprintf("%s_%d", name, n)
+	}
+	seen[unique] = true
+	return unique
+}
+
+// WriteScenario writes requests as a .httpx file: one request block each and
+// a scenario that runs them once, in order, pausing for their think times
+func WriteScenario(w io.Writer, name string, requests []Imported) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# Imported from a HAR capture by httptool import har\n\n")
+	for _, req := range requests {
+		fmt.Fprintf(&b, "request %s {\n  %s\n}\n\n", req.Name, strings.ReplaceAll(parser.FormatCurl(req.IR), "\\\n", "\\\n  "))
+	}
+
+	fmt.Fprintf(&b, "scenario %s {\n  load 1 iterations with 1 vus\n", name)
+	for _, req := range requests {
+		if req.Think > 0 {
+			fmt.Fprintf(&b, "  think %dms\n", req.Think.Milliseconds())
+		}
+		fmt.Fprintf(&b, "  run %s\n", req.Name)
+	}
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,71 @@
+package histogram
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestHistogram_Quantiles(t *testing.T) {
+	h := New()
+	for i := 1; i <= 10000; i++ {
+		h.Record(time.Duration(i) * 100 * time.Microsecond) // 0.1ms .. 1000ms
+	}
+
+	tests := []struct {
+		q    float64
+		want float64 // ms
+	}{
+		{0.50, 500},
+		{0.90, 900},
+		{0.99, 990},
+		{0.999, 999},
+		{1, 1000},
+	}
+	for _, tt := range tests {
+		got := Millis(h.Quantile(tt.q))
+		if math.Abs(got-tt.want)/tt.want > 0.01 {
+			t.Errorf("q%v = %.3fms, want %.3fms within 1%%", tt.q, got, tt.want)
+		}
+	}
+
+	if h.Count() != 10000 || h.Min() != 100*time.Microsecond || h.Max() != time.Second {
+		t.Errorf("count=%d min=%v max=%v", h.Count(), h.Min(), h.Max())
+	}
+	if h.Mean() != 500050*time.Microsecond {
+		t.Errorf("mean = %v, want exact 500.05ms", h.Mean())
+	}
+}
+
+func TestHistogram_Merge(t *testing.T) {
+	a, b := New(), New()
+	a.Record(2 * time.Millisecond)
+	b.Record(time.Millisecond)
+	b.Record(3 * time.Second)
+
+	a.Merge(b)
+	a.Merge(New())
+
+	if a.Count() != 3 || a.Min() != time.Millisecond || a.Max() != 3*time.Second {
+		t.Errorf("merged count=%d min=%v max=%v", a.Count(), a.Min(), a.Max())
+	}
+	if got := a.Quantile(0.5); got < 1980*time.Microsecond || got > 2020*time.Microsecond {
+		t.Errorf("merged p50 = %v, want ~2ms", got)
+	}
+}
+
+func TestBucketIndex_RoundTrip(t *testing.T) {
+	for _, v := range []uint64{0, 1, 255, 256, 257, 1000, 65535, 1 << 40} {
+		mid := uint64(bucketMidpoint(bucketIndex(v)))
+		if diff := math.Abs(float64(mid) - float64(v)); diff > float64(v)/128+1 {
+			t.Errorf("value %d maps back to %d", v, mid)
+		}
+	}
+}
+
+func TestHistogram_Empty(t *testing.T) {
+	s := New().Summary()
+	if s.Count != 0 || s.P99 != 0 || s.Mean != 0 {
+		t.Errorf("empty summary = %+v", s)
+	}
+}
@@ -24,6 +24,27 @@ type Response struct {
 	LatencyMs float64           `json:"latency_ms"`
 	SizeBytes int64             `json:"size_bytes,omitempty"`
 	Error     string            `json:"error,omitempty"`
+	Timing    *Timing           `json:"timing,omitempty"`
+
+	// Every Set-Cookie header, in order; Headers only holds the first
+	SetCookies []string `json:"set_cookies,omitempty"`
+
+	Protocol   string `json:"protocol,omitempty"`    // As negotiated, e.g. HTTP/1.1 or HTTP/2.0
+	TLSVersion string `json:"tls_version,omitempty"` // e.g. TLS 1.3; empty without TLS
+	TLSCipher  string `json:"tls_cipher,omitempty"`  // e.g. TLS_AES_128_GCM_SHA256
+}
+
+// Timing breaks a request down into phases, in milliseconds. Phases that did
+// not happen, such as DNS and connect on a reused connection, are 0. After
+// redirects the phases are those of the last hop.
+type Timing struct {
+	DNSMs      float64 `json:"dns_ms"`
+	ConnectMs  float64 `json:"connect_ms"`  // TCP connect
+	TLSMs      float64 `json:"tls_ms"`      // TLS handshake
+	WaitMs     float64 `json:"wait_ms"`     // Request sent to first response byte, i.e. server time
+	TTFBMs     float64 `json:"ttfb_ms"`     // Start of the request to first response byte
+	TransferMs float64 `json:"transfer_ms"` // First to last byte of the response body
+	ConnReused bool    `json:"conn_reused"` // An idle keep-alive connection was used
 }
 
 // EvaluatorDecision represents the decision output from an evaluator
@@ -51,9 +72,13 @@ type Actions struct {
 	Extract      map[string]ExtractRule    `json:"extract,omitempty"`
 }
 
-// ExtractRule defines how to extract data from response
+// ExtractRule defines how to extract data from response. Exactly one of
+// JSONPath, Regex, Header or Cookie is expected; Default is used when it
+// matches nothing.
 type ExtractRule struct {
 	JSONPath string `json:"jsonpath,omitempty"`
 	Regex    string `json:"regex,omitempty"`
+	Header   string `json:"header,omitempty"`
+	Cookie   string `json:"cookie,omitempty"`
 	Default  string `json:"default,omitempty"`
 }
@@ -51,6 +51,10 @@ type Auth struct {
 }
 
 // Transport represents transport layer configuration
+//
+// Connections are kept alive and reused between requests with the same
+// transport settings unless DisableKeepAlive is set. Zero values of the
+// connection pool settings pick the defaults noted below.
 type Transport struct {
 	TLSVerify      bool   `json:"tls_verify"`
 	FollowRedirects bool   `json:"follow_redirects"`
@@ -59,6 +63,14 @@ type Transport struct {
 	TimeoutMs      int    `json:"timeout_ms"`
 	ClientCert     string `json:"client_cert,omitempty"`
 	ClientKey      string `json:"client_key,omitempty"`
+
+	DisableKeepAlive    bool `json:"disable_keepalive,omitempty"`       // New connection for every request
+	KeepAliveMs         int  `json:"keep_alive_ms,omitempty"`           // TCP keep-alive probe interval: 30s, negative disables probes
+	IdleTimeoutMs       int  `json:"idle_timeout_ms,omitempty"`         // How long an idle connection is kept: 90s
+	MaxIdleConnsPerHost int  `json:"max_idle_conns_per_host,omitempty"` // Idle connections kept per host: 100
+	MaxConnsPerHost     int  `json:"max_conns_per_host,omitempty"`      // Connections per host, dialing or in use: no limit
+
+	Protocol string `json:"protocol,omitempty"` // auto (HTTP/2 when TLS negotiates it), http1, http2 or h2c (HTTP/2 without TLS)
 }
 
 // DefaultTransport returns transport with safe defaults
@@ -83,6 +95,7 @@ type Evaluation struct {
 	EvaluatorPath string         `json:"evaluator_path,omitempty"` // path to custom evaluator
 	TimeoutMs     int            `json:"timeout_ms"`
 	Vars          map[string]any `json:"vars,omitempty"`
+	Schema        string         `json:"schema,omitempty"` // JSON Schema the response body must match
 }
 
 // DefaultEvaluation returns evaluation config with safe defaults
@@ -0,0 +1,825 @@
+// Package jsonpath evaluates JSONPath expressions against decoded JSON, i.e.
+// values built from map[string]any, []any, string, float64, bool and nil.
+//
+// Supported syntax:
+//
+//	$                 the root value
+//	.name ['name']    object member
+//	.* [*]            every member or element
+//	[0] [-1]          array element, negative counts from the end
+//	[1:3] [::2]       array slice
+//	[0,2] ['a','b']   union
+//	..name ..*        recursive descent
+//	[?(@.price < 10)] filter with == != < <= > >= =~ /regex/, && || ! and ()
+//	.length()         length of an array, object or string, or the number
+//	                  of matches of a path that can match several values
+//
+// A bare path in a filter, as in [?(@.active)], keeps the elements where it
+// exists and is neither false nor null. On arrays and strings .length works
+// like .length().
+package jsonpath
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Path is a compiled JSONPath expression
+type Path struct {
+	raw      string
+	segments []segment
+}
+
+// segment is one step of a path: a set of selectors applied to every current
+// node, or to every node below it for descendant segments
+type segment struct {
+	descendant bool
+	selectors  []selector
+	length     bool // length() function; has no selectors
+}
+
+type selector interface {
+	selectFrom(value, root any, out []any) []any
+	definite() bool
+}
+
+// Compile parses a JSONPath expression. The leading $ may be omitted.
+func Compile(expr string) (*Path, error) {
+	src := strings.TrimSpace(expr)
+	if !strings.HasPrefix(src, "$") {
+		if !strings.HasPrefix(src, ".") && !strings.HasPrefix(src, "[") && src != "" {
+			src = "." + src
+		}
+		src = "$" + src
+	}
+
+	p := &parser{src: src, pos: 1}
+	segments, err := p.parseSegments(false)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSONPath %q: %w", expr, err)
+	}
+	if p.pos < len(p.src) {
+		return nil, fmt.Errorf("invalid JSONPath %q: unexpected %q at offset %d", expr, p.src[p.pos:], p.pos)
+	}
+	return &Path{raw: expr, segments: segments}, nil
+}
+
+// MustCompile is like Compile but panics on an invalid expression
+func MustCompile(expr string) *Path {
+	path, err := Compile(expr)
+	if err != nil {
+		panic(err)
+	}
+	return path
+}
+
+// String returns the expression the path was compiled from
+func (p *Path) String() string {
+	return p.raw
+}
+
+// Definite reports whether the path can match at most one value, i.e. it has
+// no wildcards, slices, unions, filters or recursive descent
+func (p *Path) Definite() bool {
+	return definite(p.segments)
+}
+
+// Get returns every value the path matches. Array elements come in order and
+// object members sorted by key.
+func (p *Path) Get(doc any) []any {
+	return evaluate(p.segments, doc, doc)
+}
+
+// Lookup returns the single value of a definite path, or the list of matches
+// of any other path. It reports false when nothing matches.
+func (p *Path) Lookup(doc any) (any, bool) {
+	matches := p.Get(doc)
+	if p.Definite() {
+		if len(matches) == 0 {
+			return nil, false
+		}
+		return matches[0], true
+	}
+	if len(matches) == 0 {
+		return nil, false
+	}
+	return matches, true
+}
+
+func definite(segments []segment) bool {
+	// length() folds whatever came before it into one value
+	for i := len(segments) - 1; i >= 0; i-- {
+		if segments[i].length {
+			segments = segments[i+1:]
+			break
+		}
+	}
+
+	for _, seg := range segments {
+		if seg.descendant || len(seg.selectors) > 1 {
+			return false
+		}
+		for _, sel := range seg.selectors {
+			if !sel.definite() {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func evaluate(segments []segment, current, root any) []any {
+	nodes := []any{current}
+	for i, seg := range segments {
+		// After a path that can match several values, length() counts the
+		// matches rather than measuring each one
+		if seg.length && !definite(segments[:i]) {
+			nodes = []any{float64(len(nodes))}
+			continue
+		}
+
+		var next []any
+		for _, node := range nodes {
+			switch {
+			case seg.length:
+				if n, ok := length(node); ok {
+					next = append(next, float64(n))
+				}
+			case seg.descendant:
+				for _, d := range descendants(node, nil) {
+					for _, sel := range seg.selectors {
+						next = sel.selectFrom(d, root, next)
+					}
+				}
+			default:
+				for _, sel := range seg.selectors {
+					next = sel.selectFrom(node, root, next)
+				}
+			}
+		}
+		nodes = next
+	}
+	return nodes
+}
+
+// descendants returns value and everything nested in it, depth first
+func descendants(value any, out []any) []any {
+	out = append(out, value)
+	switch v := value.(type) {
+	case map[string]any:
+		for _, key := range sortedKeys(v) {
+			out = descendants(v[key], out)
+		}
+	case []any:
+		for _, item := range v {
+			out = descendants(item, out)
+		}
+	}
+	return out
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func length(value any) (int, bool) {
+	switch v := value.(type) {
+	case []any:
+		return len(v), true
+	case map[string]any:
+		return len(v), true
+	case string:
+		return utf8.RuneCountInString(v), true
+	}
+	return 0, false
+}
+
+// Selectors
+
+type nameSelector struct{ name string }
+
+func (s nameSelector) selectFrom(value, _ any, out []any) []any {
+	switch v := value.(type) {
+	case map[string]any:
+		if member, ok := v[s.name]; ok {
+			out = append(out, member)
+		}
+	case []any, string:
+		if s.name == "length" {
+			n, _ := length(v)
+			out = append(out, float64(n))
+		}
+	}
+	return out
+}
+
+func (nameSelector) definite() bool { return true }
+
+type wildcardSelector struct{}
+
+func (wildcardSelector) selectFrom(value, _ any, out []any) []any {
+	switch v := value.(type) {
+	case map[string]any:
+		for _, key := range sortedKeys(v) {
+			out = append(out, v[key])
+		}
+	case []any:
+		out = append(out, v...)
+	}
+	return out
+}
+
+func (wildcardSelector) definite() bool { return false }
+
+type indexSelector struct{ index int }
+
+func (s indexSelector) selectFrom(value, _ any, out []any) []any {
+	if items, ok := value.([]any); ok {
+		i := s.index
+		if i < 0 {
+			i += len(items)
+		}
+		if i >= 0 && i < len(items) {
+			out = append(out, items[i])
+		}
+	}
+	return out
+}
+
+func (indexSelector) definite() bool { return true }
+
+type sliceSelector struct {
+	start, end *int
+	step       int
+}
+
+func (s sliceSelector) selectFrom(value, _ any, out []any) []any {
+	items, ok := value.([]any)
+	if !ok || s.step == 0 {
+		return out
+	}
+
+	n := len(items)
+	bound := func(i *int, def int) int {
+		if i == nil {
+			return def
+		}
+		v := *i
+		if v < 0 {
+			v += n
+		}
+		return v
+	}
+
+	if s.step > 0 {
+		start, end := max(bound(s.start, 0), 0), min(bound(s.end, n), n)
+		for i := start; i < end; i += s.step {
+			out = append(out, items[i])
+		}
+	} else {
+		start, end := min(bound(s.start, n-1), n-1), max(bound(s.end, -n-1), -1)
+		for i := start; i > end; i += s.step {
+			out = append(out, items[i])
+		}
+	}
+	return out
+}
+
+func (sliceSelector) definite() bool { return false }
+
+type filterSelector struct{ expr expr }
+
+func (s filterSelector) selectFrom(value, root any, out []any) []any {
+	switch v := value.(type) {
+	case map[string]any:
+		for _, key := range sortedKeys(v) {
+			if truthy(s.expr.eval(v[key], root)) {
+				out = append(out, v[key])
+			}
+		}
+	case []any:
+		for _, item := range v {
+			if truthy(s.expr.eval(item, root)) {
+				out = append(out, item)
+			}
+		}
+	}
+	return out
+}
+
+func (filterSelector) definite() bool { return false }
+
+// Filter expressions
+
+// nothing is the result of a path that matched no value
+type nothingType struct{}
+
+var nothing = nothingType{}
+
+type expr interface {
+	eval(current, root any) any
+}
+
+type literalExpr struct{ value any }
+
+func (e literalExpr) eval(_, _ any) any { return e.value }
+
+type pathExpr struct {
+	relative bool // @ rather than $
+	segments []segment
+}
+
+func (e pathExpr) eval(current, root any) any {
+	start := root
+	if e.relative {
+		start = current
+	}
+	matches := evaluate(e.segments, start, root)
+	if len(matches) == 1 {
+		return matches[0]
+	}
+	if len(matches) == 0 {
+		return nothing
+	}
+	return matches
+}
+
+type notExpr struct{ operand expr }
+
+func (e notExpr) eval(current, root any) any {
+	return !truthy(e.operand.eval(current, root))
+}
+
+type logicalExpr struct {
+	and         bool
+	left, right expr
+}
+
+func (e logicalExpr) eval(current, root any) any {
+	left := truthy(e.left.eval(current, root))
+	if e.and {
+		return left && truthy(e.right.eval(current, root))
+	}
+	return left || truthy(e.right.eval(current, root))
+}
+
+type compareExpr struct {
+	op          string
+	left, right expr
+}
+
+func (e compareExpr) eval(current, root any) any {
+	left := e.left.eval(current, root)
+	right := e.right.eval(current, root)
+
+	switch e.op {
+	case "==":
+		return equal(left, right)
+	case "!=":
+		return !equal(left, right)
+	}
+
+	if ln, ok := toNumber(left); ok {
+		if rn, ok := toNumber(right); ok {
+			return compareOrdered(e.op, ln, rn)
+		}
+	}
+	if ls, ok := left.(string); ok {
+		if rs, ok := right.(string); ok {
+			return compareOrdered(e.op, ls, rs)
+		}
+	}
+	return false
+}
+
+type matchExpr struct {
+	operand expr
+	re      *regexp.Regexp
+}
+
+func (e matchExpr) eval(current, root any) any {
+	s, ok := e.operand.eval(current, root).(string)
+	return ok && e.re.MatchString(s)
+}
+
+func compareOrdered[T float64 | string](op string, a, b T) bool {
+	switch op {
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	}
+	return false
+}
+
+func equal(a, b any) bool {
+	if an, ok := toNumber(a); ok {
+		bn, ok := toNumber(b)
+		return ok && an == bn
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func toNumber(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+func truthy(v any) bool {
+	switch b := v.(type) {
+	case nothingType:
+		return false
+	case nil:
+		return false
+	case bool:
+		return b
+	}
+	return true
+}
+
+// Parser
+
+type parser struct {
+	src string
+	pos int
+}
+
+func (p *parser) peek() byte {
+	if p.pos < len(p.src) {
+		return p.src[p.pos]
+	}
+	return 0
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func (p *parser) consume(s string) bool {
+	if strings.HasPrefix(p.src[p.pos:], s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+// parseSegments reads segments until the path ends. Inside filters a path
+// also ends at an operator, space or closing bracket.
+func (p *parser) parseSegments(inFilter bool) ([]segment, error) {
+	var segments []segment
+	for p.pos < len(p.src) {
+		switch {
+		case p.consume(".."):
+			seg := segment{descendant: true}
+			switch {
+			case p.consume("*"):
+				seg.selectors = []selector{wildcardSelector{}}
+			case p.peek() == '[':
+				selectors, err := p.parseBracket()
+				if err != nil {
+					return nil, err
+				}
+				seg.selectors = selectors
+			default:
+				name := p.readName()
+				if name == "" {
+					return nil, fmt.Errorf("expected name after '..' at offset %d", p.pos)
+				}
+				seg.selectors = []selector{nameSelector{name}}
+			}
+			segments = append(segments, seg)
+
+		case p.consume("."):
+			if p.consume("*") {
+				segments = append(segments, segment{selectors: []selector{wildcardSelector{}}})
+				continue
+			}
+			name := p.readName()
+			if name == "" {
+				return nil, fmt.Errorf("expected name after '.' at offset %d", p.pos)
+			}
+			if p.consume("()") {
+				if name != "length" {
+					return nil, fmt.Errorf("unknown function %s()", name)
+				}
+				segments = append(segments, segment{length: true})
+				continue
+			}
+			segments = append(segments, segment{selectors: []selector{nameSelector{name}}})
+
+		case p.peek() == '[':
+			selectors, err := p.parseBracket()
+			if err != nil {
+				return nil, err
+			}
+			segments = append(segments, segment{selectors: selectors})
+
+		default:
+			if inFilter {
+				return segments, nil
+			}
+			return nil, fmt.Errorf("unexpected %q at offset %d", p.src[p.pos:], p.pos)
+		}
+	}
+	return segments, nil
+}
+
+func (p *parser) readName() string {
+	start := p.pos
+	for p.pos < len(p.src) && !strings.ContainsRune(".[]()=!<>&|,'\" \t~", rune(p.src[p.pos])) {
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+// parseBracket reads a [...] selector list
+func (p *parser) parseBracket() ([]selector, error) {
+	p.pos++ // [
+	var selectors []selector
+
+	for {
+		p.skipSpace()
+		sel, err := p.parseSelector()
+		if err != nil {
+			return nil, err
+		}
+		selectors = append(selectors, sel)
+
+		p.skipSpace()
+		if p.consume("]") {
+			return selectors, nil
+		}
+		if !p.consume(",") {
+			return nil, fmt.Errorf("expected ',' or ']' at offset %d", p.pos)
+		}
+	}
+}
+
+func (p *parser) parseSelector() (selector, error) {
+	switch c := p.peek(); {
+	case c == '*':
+		p.pos++
+		return wildcardSelector{}, nil
+	case c == '\'' || c == '"':
+		name, err := p.readString()
+		if err != nil {
+			return nil, err
+		}
+		return nameSelector{name}, nil
+	case c == '?':
+		p.pos++
+		p.skipSpace()
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return filterSelector{e}, nil
+	case c == '-' || c == ':' || (c >= '0' && c <= '9'):
+		return p.parseIndexOrSlice()
+	case c == 0:
+		return nil, fmt.Errorf("unterminated '['")
+	}
+	return nil, fmt.Errorf("unexpected %q in brackets at offset %d", p.peek(), p.pos)
+}
+
+func (p *parser) parseIndexOrSlice() (selector, error) {
+	var parts [3]*int
+	n := 0
+	for {
+		p.skipSpace()
+		start := p.pos
+		if p.peek() == '-' {
+			p.pos++
+		}
+		for p.peek() >= '0' && p.peek() <= '9' {
+			p.pos++
+		}
+		if p.pos > start {
+			v, err := strconv.Atoi(p.src[start:p.pos])
+			if err != nil {
+				return nil, fmt.Errorf("invalid index %q", p.src[start:p.pos])
+			}
+			parts[n] = &v
+		}
+		p.skipSpace()
+		if n < 2 && p.consume(":") {
+			n++
+			continue
+		}
+		break
+	}
+
+	if n == 0 {
+		if parts[0] == nil {
+			return nil, fmt.Errorf("expected index at offset %d", p.pos)
+		}
+		return indexSelector{*parts[0]}, nil
+	}
+
+	step := 1
+	if parts[2] != nil {
+		step = *parts[2]
+	}
+	return sliceSelector{start: parts[0], end: parts[1], step: step}, nil
+}
+
+func (p *parser) readString() (string, error) {
+	quote := p.src[p.pos]
+	p.pos++
+
+	var b strings.Builder
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		p.pos++
+		switch c {
+		case quote:
+			return b.String(), nil
+		case '\\':
+			if p.pos < len(p.src) {
+				b.WriteByte(p.src[p.pos])
+				p.pos++
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", fmt.Errorf("unterminated string")
+}
+
+func (p *parser) parseOr() (expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		p.skipSpace()
+		if !p.consume("||") {
+			return left, nil
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalExpr{left: left, right: right}
+	}
+}
+
+func (p *parser) parseAnd() (expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		p.skipSpace()
+		if !p.consume("&&") {
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalExpr{and: true, left: left, right: right}
+	}
+}
+
+func (p *parser) parseUnary() (expr, error) {
+	p.skipSpace()
+	if p.peek() == '!' && !strings.HasPrefix(p.src[p.pos:], "!=") {
+		p.pos++
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{operand}, nil
+	}
+	if p.consume("(") {
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if !p.consume(")") {
+			return nil, fmt.Errorf("expected ')' at offset %d", p.pos)
+		}
+		return e, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (expr, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipSpace()
+	if p.consume("=~") {
+		p.skipSpace()
+		re, err := p.readRegex()
+		if err != nil {
+			return nil, err
+		}
+		return matchExpr{operand: left, re: re}, nil
+	}
+
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.consume(op) {
+			right, err := p.parseOperand()
+			if err != nil {
+				return nil, err
+			}
+			return compareExpr{op: op, left: left, right: right}, nil
+		}
+	}
+	return left, nil
+}
+
+func (p *parser) parseOperand() (expr, error) {
+	p.skipSpace()
+	switch c := p.peek(); {
+	case c == '@' || c == '$':
+		p.pos++
+		segments, err := p.parseSegments(true)
+		if err != nil {
+			return nil, err
+		}
+		return pathExpr{relative: c == '@', segments: segments}, nil
+	case c == '\'' || c == '"':
+		s, err := p.readString()
+		if err != nil {
+			return nil, err
+		}
+		return literalExpr{s}, nil
+	case c == '-' || (c >= '0' && c <= '9'):
+		start := p.pos
+		p.pos++
+		for p.pos < len(p.src) && strings.ContainsRune("0123456789.eE+-", rune(p.src[p.pos])) {
+			p.pos++
+		}
+		n, err := strconv.ParseFloat(p.src[start:p.pos], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", p.src[start:p.pos])
+		}
+		return literalExpr{n}, nil
+	}
+
+	for word, value := range map[string]any{"true": true, "false": false, "null": nil} {
+		if p.consume(word) {
+			return literalExpr{value}, nil
+		}
+	}
+	return nil, fmt.Errorf("expected value at offset %d", p.pos)
+}
+
+// readRegex reads /pattern/ with an optional i flag
+func (p *parser) readRegex() (*regexp.Regexp, error) {
+	if !p.consume("/") {
+		return nil, fmt.Errorf("expected /regex/ at offset %d", p.pos)
+	}
+
+	var b strings.Builder
+	for {
+		if p.pos >= len(p.src) {
+			return nil, fmt.Errorf("unterminated regex")
+		}
+		c := p.src[p.pos]
+		p.pos++
+		if c == '/' {
+			break
+		}
+		if c == '\\' && p.peek() == '/' {
+			c = '/'
+			p.pos++
+		}
+		b.WriteByte(c)
+	}
+
+	pattern := b.String()
+	if p.consume("i") {
+		pattern = "(?i)" + pattern
+	}
+	return regexp.Compile(pattern)
+}
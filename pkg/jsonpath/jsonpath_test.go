@@ -0,0 +1,130 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+const store = `{
+  "store": {
+    "book": [
+      {"category": "reference", "author": "Nigel Rees", "title": "Sayings of the Century", "price": 8.95},
+      {"category": "fiction", "author": "Evelyn Waugh", "title": "Sword of Honour", "price": 12.99},
+      {"category": "fiction", "author": "Herman Melville", "title": "Moby Dick", "isbn": "0-553-21311-3", "price": 8.99},
+      {"category": "fiction", "author": "J. R. R. Tolkien", "title": "The Lord of the Rings", "isbn": "0-395-19395-8", "price": 22.99}
+    ],
+    "bicycle": {"color": "red", "price": 19.95}
+  },
+  "users": [
+    {"name": "ada", "active": true, "tags": ["admin", "ops"]},
+    {"name": "bob", "active": false, "tags": []},
+    {"name": "cy", "tags": ["ops"]}
+  ]
+}`
+
+func decode(t *testing.T, s string) any {
+	t.Helper()
+	var v any
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestGet(t *testing.T) {
+	doc := decode(t, store)
+
+	tests := []struct {
+		path string
+		want []any
+	}{
+		{"$.store.bicycle.color", []any{"red"}},
+		{"store.bicycle.color", []any{"red"}},
+		{"$['store']['bicycle']['price']", []any{19.95}},
+		{"$.store.book[0].author", []any{"Nigel Rees"}},
+		{"$.store.book[-1].title", []any{"The Lord of the Rings"}},
+		{"$.store.book[*].price", []any{8.95, 12.99, 8.99, 22.99}},
+		{"$.store.book[1:3].price", []any{12.99, 8.99}},
+		{"$.store.book[::-2].price", []any{22.99, 12.99}},
+		{"$.store.book[0,2].price", []any{8.95, 8.99}},
+		{"$.store.bicycle['color','price']", []any{"red", 19.95}},
+		{"$..isbn", []any{"0-553-21311-3", "0-395-19395-8"}},
+		{"$.store..price", []any{19.95, 8.95, 12.99, 8.99, 22.99}},
+		{"$.store.book[?(@.price < 10)].title", []any{"Sayings of the Century", "Moby Dick"}},
+		{"$.store.book[?(@.isbn)].price", []any{8.99, 22.99}},
+		{"$.store.book[?(@.category == 'fiction' && @.price > 20)].author", []any{"J. R. R. Tolkien"}},
+		{"$.store.book[?(@.author =~ /tolkien/i || !(@.price >= 9))].price", []any{8.95, 8.99, 22.99}},
+		{"$.store.book[?(@.price > $.store.bicycle.price)].title", []any{"The Lord of the Rings"}},
+		{"$.users[?(@.active)].name", []any{"ada"}},
+		{"$.users[?(@.tags.length() > 0)].name", []any{"ada", "cy"}},
+		{"$.store.book.length()", []any{float64(4)}},
+		{"$..isbn.length()", []any{float64(2)}},
+		{"$.store.book[?(@.price > 100)].length()", []any{float64(0)}},
+		{"$.users[0].tags.length", []any{float64(2)}},
+		{"$.users[0].name.length()", []any{float64(3)}},
+		{"$.store.book[10]", nil},
+		{"$.missing.path", nil},
+	}
+
+	for _, tt := range tests {
+		path, err := Compile(tt.path)
+		if err != nil {
+			t.Errorf("%s: %v", tt.path, err)
+			continue
+		}
+		if got := path.Get(doc); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestGet_TopLevelArray(t *testing.T) {
+	doc := decode(t, `[{"id": 1}, {"id": 2}]`)
+
+	if got := MustCompile("$[1].id").Get(doc); !reflect.DeepEqual(got, []any{float64(2)}) {
+		t.Errorf("$[1].id = %v", got)
+	}
+	if got := MustCompile("$.length()").Get(doc); !reflect.DeepEqual(got, []any{float64(2)}) {
+		t.Errorf("$.length() = %v", got)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	doc := decode(t, store)
+
+	value, ok := MustCompile("$.store.book[0].price").Lookup(doc)
+	if !ok || value != 8.95 {
+		t.Errorf("definite path = %v, %v", value, ok)
+	}
+
+	value, ok = MustCompile("$.users[*].name").Lookup(doc)
+	if !ok || !reflect.DeepEqual(value, []any{"ada", "bob", "cy"}) {
+		t.Errorf("multi-match path = %v, %v", value, ok)
+	}
+
+	value, ok = MustCompile("$.users[*].tags.length()").Lookup(doc)
+	if !ok || value != float64(3) {
+		t.Errorf("length of matches = %v, %v", value, ok)
+	}
+
+	if _, ok := MustCompile("$.users[?(@.name == 'zed')]").Lookup(doc); ok {
+		t.Error("expected no match")
+	}
+}
+
+func TestCompile_Errors(t *testing.T) {
+	for _, path := range []string{
+		"$.",
+		"$.store[",
+		"$.store['book'",
+		"$.book[?(@.price <)]",
+		"$.book[?(@.title =~ tolkien)]",
+		"$.book.count()",
+		"$.store)",
+	} {
+		if _, err := Compile(path); err == nil {
+			t.Errorf("%s: expected an error", path)
+		}
+	}
+}
@@ -0,0 +1,902 @@
+// Package jsonschema validates decoded JSON, i.e. values built from
+// map[string]any, []any, string, float64, bool and nil, against JSON Schema
+// draft 2020-12.
+//
+// All validation and applicator keywords are supported, including
+// unevaluatedProperties and unevaluatedItems. $ref may point into the same
+// schema (#/$defs/user, #anchor), at another file relative to the schema
+// (common.json#/$defs/id), or at the $id of any loaded schema. $dynamicRef is
+// resolved like $ref. As the draft specifies by default, format is an
+// annotation and is not checked.
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxDepth bounds nested validation so a $ref cycle that never consumes the
+// instance fails instead of recursing forever
+const maxDepth = 256
+
+// Schema is a compiled schema, ready to validate values
+type Schema struct {
+	root *document
+	reg  *registry
+}
+
+// document is one schema resource: a file or a subschema with its own $id
+type document struct {
+	root    any
+	path    string // File it was loaded from, for relative $refs
+	id      string // Absolute $id, for relative $refs between remote ids
+	anchors map[string]any
+}
+
+// registry holds every document reachable from the root schema
+type registry struct {
+	files    map[string]*document // By absolute path
+	ids      map[string]*document // By $id without fragment
+	patterns map[string]*regexp.Regexp
+}
+
+// ValidationError is one failed keyword
+type ValidationError struct {
+	Path    string // JSON pointer into the value, e.g. /items/0/id
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	if e.Path == "" {
+		return "(root): " + e.Message
+	}
+	return e.Path + ": " + e.Message
+}
+
+// Errors is returned by Validate when the value does not match
+type Errors []ValidationError
+
+func (e Errors) Error() string {
+	const shown = 5
+
+	parts := make([]string, 0, shown+1)
+	for i, err := range e {
+		if i == shown {
+			parts = append(parts, fmt.Sprintf("and %d more", len(e)-shown))
+			break
+		}
+		parts = append(parts, err.Error())
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Paths returns the JSON pointers that failed, without duplicates
+func (e Errors) Paths() []string {
+	seen := make(map[string]bool)
+	var paths []string
+	for _, err := range e {
+		if !seen[err.Path] {
+			seen[err.Path] = true
+			paths = append(paths, err.Path)
+		}
+	}
+	return paths
+}
+
+// Load reads a schema file. Files it refers to with $ref are loaded too.
+func Load(path string) (*Schema, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	reg := newRegistry()
+	doc, err := reg.loadFile(abs)
+	if err != nil {
+		return nil, err
+	}
+	if err := reg.checkRefs(); err != nil {
+		return nil, err
+	}
+	return &Schema{root: doc, reg: reg}, nil
+}
+
+// Compile parses a schema. Relative $refs to files resolve against the
+// working directory.
+func Compile(data []byte) (*Schema, error) {
+	var root any
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+
+	wd, _ := os.Getwd()
+	reg := newRegistry()
+	doc, err := reg.add(root, filepath.Join(wd, "schema.json"))
+	if err != nil {
+		return nil, err
+	}
+	if err := reg.checkRefs(); err != nil {
+		return nil, err
+	}
+	return &Schema{root: doc, reg: reg}, nil
+}
+
+// MustCompile is like Compile but panics on error
+func MustCompile(data string) *Schema {
+	s, err := Compile([]byte(data))
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+func newRegistry() *registry {
+	return &registry{
+		files:    make(map[string]*document),
+		ids:      make(map[string]*document),
+		patterns: make(map[string]*regexp.Regexp),
+	}
+}
+
+func (r *registry) loadFile(path string) (*document, error) {
+	if doc, ok := r.files[path]; ok {
+		return doc, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema: %w", err)
+	}
+	var root any
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("invalid schema %s: %w", filepath.Base(path), err)
+	}
+	return r.add(root, path)
+}
+
+// add registers a schema file with its $ids and anchors, and compiles its
+// patterns
+func (r *registry) add(root any, path string) (*document, error) {
+	switch root.(type) {
+	case map[string]any, bool:
+	default:
+		return nil, fmt.Errorf("invalid schema %s: must be an object or a boolean", filepath.Base(path))
+	}
+
+	doc := &document{root: root, path: path, anchors: make(map[string]any)}
+	r.files[path] = doc
+	if err := r.index(doc, root, doc); err != nil {
+		return nil, fmt.Errorf("invalid schema %s: %w", filepath.Base(path), err)
+	}
+	return doc, nil
+}
+
+// index walks a schema, registering resources and anchors and compiling
+// patterns
+func (r *registry) index(file *document, schema any, doc *document) error {
+	m, ok := schema.(map[string]any)
+	if !ok {
+		return nil
+	}
+
+	if id, ok := m["$id"].(string); ok {
+		resolved := resolveURL(doc.id, id)
+		if isRoot(doc, m) {
+			doc.id = resolved
+		} else {
+			doc = &document{root: m, path: file.path, id: resolved, anchors: make(map[string]any)}
+		}
+		r.ids[resolved] = doc
+	}
+	for _, key := range []string{"$anchor", "$dynamicAnchor"} {
+		if anchor, ok := m[key].(string); ok {
+			doc.anchors[anchor] = m
+		}
+	}
+
+	if pattern, ok := m["pattern"].(string); ok {
+		if err := r.compile(pattern); err != nil {
+			return err
+		}
+	}
+	if props, ok := m["patternProperties"].(map[string]any); ok {
+		for pattern := range props {
+			if err := r.compile(pattern); err != nil {
+				return err
+			}
+		}
+	}
+
+	for key, value := range m {
+		switch key {
+		case "const", "enum", "default", "examples":
+			continue
+		}
+		switch v := value.(type) {
+		case map[string]any:
+			if key == "properties" || key == "patternProperties" || key == "$defs" || key == "definitions" || key == "dependentSchemas" {
+				for _, sub := range v {
+					if err := r.index(file, sub, doc); err != nil {
+						return err
+					}
+				}
+			} else if err := r.index(file, v, doc); err != nil {
+				return err
+			}
+		case []any:
+			for _, sub := range v {
+				if err := r.index(file, sub, doc); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (r *registry) compile(pattern string) error {
+	if _, ok := r.patterns[pattern]; ok {
+		return nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+	r.patterns[pattern] = re
+	return nil
+}
+
+// checkRefs resolves every $ref up front, loading the files they name, so
+// that validation never reads files or meets a broken reference
+func (r *registry) checkRefs() error {
+	for checked := make(map[*document]bool); ; {
+		var pending []*document
+		for _, doc := range r.files {
+			if !checked[doc] {
+				pending = append(pending, doc)
+			}
+		}
+		if len(pending) == 0 {
+			return nil
+		}
+		for _, doc := range pending {
+			checked[doc] = true
+			if err := r.checkRefsIn(doc, doc.root); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (r *registry) checkRefsIn(doc *document, schema any) error {
+	switch v := schema.(type) {
+	case map[string]any:
+		if id, ok := v["$id"].(string); ok && !isRoot(doc, v) {
+			if sub, ok := r.ids[resolveURL(doc.id, id)]; ok {
+				doc = sub
+			}
+		}
+		for _, key := range []string{"$ref", "$dynamicRef"} {
+			if ref, ok := v[key].(string); ok {
+				if _, _, err := r.resolve(doc, ref, true); err != nil {
+					return err
+				}
+			}
+		}
+		for key, sub := range v {
+			if key == "const" || key == "enum" || key == "default" || key == "examples" {
+				continue
+			}
+			if err := r.checkRefsIn(doc, sub); err != nil {
+				return err
+			}
+		}
+	case []any:
+		for _, sub := range v {
+			if err := r.checkRefsIn(doc, sub); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// resolve finds the schema a $ref points at. Only checkRefs may load files.
+func (r *registry) resolve(doc *document, ref string, load bool) (*document, any, error) {
+	resource, fragment, _ := strings.Cut(ref, "#")
+
+	target := doc
+	if resource != "" {
+		target = r.ids[resolveURL(doc.id, resource)]
+		if target == nil {
+			target = r.ids[resource]
+		}
+		if target == nil && !strings.Contains(resource, "://") {
+			path := resource
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(filepath.Dir(doc.path), path)
+			}
+			if target = r.files[path]; target == nil && load {
+				var err error
+				if target, err = r.loadFile(path); err != nil {
+					return nil, nil, fmt.Errorf("$ref %q: %w", ref, err)
+				}
+			}
+		}
+		if target == nil {
+			return nil, nil, fmt.Errorf("$ref %q: schema not found", ref)
+		}
+	}
+
+	fragment, err := url.PathUnescape(fragment)
+	if err != nil {
+		return nil, nil, fmt.Errorf("$ref %q: %w", ref, err)
+	}
+
+	switch {
+	case fragment == "":
+		return target, target.root, nil
+	case strings.HasPrefix(fragment, "/"):
+		node := target.root
+		for _, token := range strings.Split(fragment[1:], "/") {
+			token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+			var ok bool
+			if node, ok = step(node, token); !ok {
+				return nil, nil, fmt.Errorf("$ref %q: no %q in schema", ref, token)
+			}
+		}
+		return target, node, nil
+	default:
+		anchor, ok := target.anchors[fragment]
+		if !ok {
+			return nil, nil, fmt.Errorf("$ref %q: anchor not found", ref)
+		}
+		return target, anchor, nil
+	}
+}
+
+// step moves one JSON pointer token into a schema
+func step(node any, token string) (any, bool) {
+	switch v := node.(type) {
+	case map[string]any:
+		next, ok := v[token]
+		return next, ok
+	case []any:
+		i, err := strconv.Atoi(token)
+		if err != nil || i < 0 || i >= len(v) {
+			return nil, false
+		}
+		return v[i], true
+	}
+	return nil, false
+}
+
+// isRoot reports whether a schema object is the root of a document. Maps
+// cannot be compared with ==, so this compares their identity.
+func isRoot(doc *document, m map[string]any) bool {
+	root, ok := doc.root.(map[string]any)
+	return ok && reflect.ValueOf(root).UnsafePointer() == reflect.ValueOf(m).UnsafePointer()
+}
+
+// resolveURL resolves an $id or $ref against the base $id, if both are URLs
+func resolveURL(base, ref string) string {
+	ref, _, _ = strings.Cut(ref, "#")
+	if base == "" {
+		return ref
+	}
+	b, err := url.Parse(base)
+	if err != nil {
+		return ref
+	}
+	r, err := url.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return b.ResolveReference(r).String()
+}
+
+// evaluated records which properties and items the keywords of a schema
+// looked at, for unevaluatedProperties and unevaluatedItems
+type evaluated struct {
+	props    map[string]bool
+	items    map[int]bool
+	allItems bool
+}
+
+func (e *evaluated) merge(o *evaluated) {
+	for k := range o.props {
+		e.prop(k)
+	}
+	for i := range o.items {
+		e.item(i)
+	}
+	e.allItems = e.allItems || o.allItems
+}
+
+func (e *evaluated) prop(name string) {
+	if e.props == nil {
+		e.props = make(map[string]bool)
+	}
+	e.props[name] = true
+}
+
+func (e *evaluated) item(i int) {
+	if e.items == nil {
+		e.items = make(map[int]bool)
+	}
+	e.items[i] = true
+}
+
+// Validate checks a value against the schema. It returns Errors listing every
+// failed keyword, or nil.
+func (s *Schema) Validate(v any) error {
+	errs, _ := s.validate(s.root, s.root.root, v, "", 0)
+	if len(errs) == 0 {
+		return nil
+	}
+	return Errors(errs)
+}
+
+// frame is the validation of one value against one schema object
+type frame struct {
+	s     *Schema
+	doc   *document
+	ptr   string
+	depth int
+	errs  []ValidationError
+	ev    *evaluated
+}
+
+func (s *Schema) validate(doc *document, schema any, v any, ptr string, depth int) ([]ValidationError, *evaluated) {
+	ev := &evaluated{}
+
+	m, ok := schema.(map[string]any)
+	if !ok {
+		if schema == false {
+			return []ValidationError{{ptr, "not allowed"}}, ev
+		}
+		return nil, ev
+	}
+	if depth > maxDepth {
+		return []ValidationError{{ptr, "schema nests too deeply (recursive $ref?)"}}, ev
+	}
+
+	if id, ok := m["$id"].(string); ok && !isRoot(doc, m) {
+		if sub, ok := s.reg.ids[resolveURL(doc.id, id)]; ok {
+			doc = sub
+		}
+	}
+
+	f := &frame{s: s, doc: doc, ptr: ptr, depth: depth, ev: ev}
+	f.validate(m, v)
+	return f.errs, ev
+}
+
+func (f *frame) fail(format string, args ...any) {
+	f.failAt(f.ptr, format, args...)
+}
+
+func (f *frame) failAt(ptr string, format string, args ...any) {
+	f.errs = append(f.errs, ValidationError{ptr, fmt.Sprintf(format, args...)})
+}
+
+// inPlace applies a subschema to the same value, keeping its annotations
+func (f *frame) inPlace(doc *document, sub any, v any) bool {
+	errs, ev := f.s.validate(doc, sub, v, f.ptr, f.depth+1)
+	f.errs = append(f.errs, errs...)
+	if len(errs) == 0 {
+		f.ev.merge(ev)
+	}
+	return len(errs) == 0
+}
+
+// try applies a subschema to the same value, keeping its annotations if it
+// matches but not its errors if it does not
+func (f *frame) try(sub any, v any) bool {
+	errs, ev := f.s.validate(f.doc, sub, v, f.ptr, f.depth+1)
+	if len(errs) == 0 {
+		f.ev.merge(ev)
+	}
+	return len(errs) == 0
+}
+
+// child applies a subschema to a member or element
+func (f *frame) child(sub any, v any, ptr string) bool {
+	errs, _ := f.s.validate(f.doc, sub, v, ptr, f.depth+1)
+	f.errs = append(f.errs, errs...)
+	return len(errs) == 0
+}
+
+// matches reports whether a value matches a subschema, without errors
+func (f *frame) matches(sub any, v any) bool {
+	errs, _ := f.s.validate(f.doc, sub, v, f.ptr, f.depth+1)
+	return len(errs) == 0
+}
+
+func (f *frame) validate(m map[string]any, v any) {
+	for _, key := range []string{"$ref", "$dynamicRef"} {
+		if ref, ok := m[key].(string); ok {
+			target, sub, err := f.s.reg.resolve(f.doc, ref, false)
+			if err != nil {
+				f.fail("%v", err)
+			} else {
+				f.inPlace(target, sub, v)
+			}
+		}
+	}
+
+	if t, ok := m["type"]; ok {
+		var names []string
+		switch t := t.(type) {
+		case string:
+			names = []string{t}
+		case []any:
+			for _, name := range t {
+				if s, ok := name.(string); ok {
+					names = append(names, s)
+				}
+			}
+		}
+		if !hasType(v, names) {
+			f.fail("expected %s, got %s", strings.Join(names, " or "), typeOf(v))
+		}
+	}
+	if enum, ok := m["enum"].([]any); ok {
+		found := false
+		for _, e := range enum {
+			if equal(v, e) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			f.fail("must be one of %s", encode(enum))
+		}
+	}
+	if c, ok := m["const"]; ok && !equal(v, c) {
+		f.fail("must be %s", encode(c))
+	}
+
+	if all, ok := m["allOf"].([]any); ok {
+		for _, sub := range all {
+			f.inPlace(f.doc, sub, v)
+		}
+	}
+	if anyOf, ok := m["anyOf"].([]any); ok {
+		matched := false
+		for _, sub := range anyOf {
+			if f.try(sub, v) {
+				matched = true
+			}
+		}
+		if !matched {
+			f.fail("must match at least one schema in anyOf")
+		}
+	}
+	if oneOf, ok := m["oneOf"].([]any); ok {
+		matched := 0
+		for _, sub := range oneOf {
+			if f.try(sub, v) {
+				matched++
+			}
+		}
+		if matched != 1 {
+			f.fail("must match exactly one schema in oneOf, matched %d", matched)
+		}
+	}
+	if not, ok := m["not"]; ok && f.matches(not, v) {
+		f.fail("must not match the schema in not")
+	}
+	if cond, ok := m["if"]; ok {
+		if f.try(cond, v) {
+			if then, ok := m["then"]; ok {
+				f.inPlace(f.doc, then, v)
+			}
+		} else if els, ok := m["else"]; ok {
+			f.inPlace(f.doc, els, v)
+		}
+	}
+
+	switch v := v.(type) {
+	case float64:
+		f.validateNumber(m, v)
+	case string:
+		f.validateString(m, v)
+	case []any:
+		f.validateArray(m, v)
+	case map[string]any:
+		f.validateObject(m, v)
+	}
+}
+
+func (f *frame) validateNumber(m map[string]any, v float64) {
+	if n, ok := number(m, "multipleOf"); ok && n > 0 {
+		q := v / n
+		if math.Abs(q-math.Round(q)) > 1e-9 {
+			f.fail("must be a multiple of %s", format(n))
+		}
+	}
+	if n, ok := number(m, "maximum"); ok && v > n {
+		f.fail("must be <= %s", format(n))
+	}
+	if n, ok := number(m, "exclusiveMaximum"); ok && v >= n {
+		f.fail("must be < %s", format(n))
+	}
+	if n, ok := number(m, "minimum"); ok && v < n {
+		f.fail("must be >= %s", format(n))
+	}
+	if n, ok := number(m, "exclusiveMinimum"); ok && v <= n {
+		f.fail("must be > %s", format(n))
+	}
+}
+
+func (f *frame) validateString(m map[string]any, v string) {
+	length := float64(utf8.RuneCountInString(v))
+	if n, ok := number(m, "maxLength"); ok && length > n {
+		f.fail("must be at most %s characters long", format(n))
+	}
+	if n, ok := number(m, "minLength"); ok && length < n {
+		f.fail("must be at least %s characters long", format(n))
+	}
+	if pattern, ok := m["pattern"].(string); ok && !f.s.reg.patterns[pattern].MatchString(v) {
+		f.fail("must match pattern %q", pattern)
+	}
+}
+
+func (f *frame) validateArray(m map[string]any, v []any) {
+	itemPtr := func(i int) string { return f.ptr + "/" + strconv.Itoa(i) }
+
+	prefix, _ := m["prefixItems"].([]any)
+	for i, sub := range prefix {
+		if i < len(v) {
+			f.child(sub, v[i], itemPtr(i))
+			f.ev.item(i)
+		}
+	}
+	if items, ok := m["items"]; ok {
+		for i := len(prefix); i < len(v); i++ {
+			f.child(items, v[i], itemPtr(i))
+		}
+		f.ev.allItems = true
+	}
+
+	if contains, ok := m["contains"]; ok {
+		matched := 0
+		for i, item := range v {
+			if f.matches(contains, item) {
+				matched++
+				f.ev.item(i)
+			}
+		}
+		min, ok := number(m, "minContains")
+		if !ok {
+			min = 1
+		}
+		if float64(matched) < min {
+			f.fail("must contain at least %s matching items, found %d", format(min), matched)
+		}
+		if max, ok := number(m, "maxContains"); ok && float64(matched) > max {
+			f.fail("must contain at most %s matching items, found %d", format(max), matched)
+		}
+	}
+
+	if n, ok := number(m, "maxItems"); ok && float64(len(v)) > n {
+		f.fail("must have at most %s items", format(n))
+	}
+	if n, ok := number(m, "minItems"); ok && float64(len(v)) < n {
+		f.fail("must have at least %s items", format(n))
+	}
+	if unique, _ := m["uniqueItems"].(bool); unique {
+	outer:
+		for i := range v {
+			for j := i + 1; j < len(v); j++ {
+				if equal(v[i], v[j]) {
+					f.fail("items %d and %d must be unique", i, j)
+					break outer
+				}
+			}
+		}
+	}
+
+	if unevaluated, ok := m["unevaluatedItems"]; ok && !f.ev.allItems {
+		for i, item := range v {
+			if !f.ev.items[i] {
+				f.child(unevaluated, item, itemPtr(i))
+			}
+		}
+		f.ev.allItems = true
+	}
+}
+
+func (f *frame) validateObject(m map[string]any, v map[string]any) {
+	keys := make([]string, 0, len(v))
+	for k := range v {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	propPtr := func(k string) string {
+		return f.ptr + "/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(k)
+	}
+
+	if required, ok := m["required"].([]any); ok {
+		for _, name := range required {
+			if name, ok := name.(string); ok {
+				if _, ok := v[name]; !ok {
+					f.failAt(propPtr(name), "required property is missing")
+				}
+			}
+		}
+	}
+	if deps, ok := m["dependentRequired"].(map[string]any); ok {
+		for _, name := range sortedKeys(deps) {
+			if _, ok := v[name]; !ok {
+				continue
+			}
+			list, _ := deps[name].([]any)
+			for _, dep := range list {
+				if dep, ok := dep.(string); ok {
+					if _, ok := v[dep]; !ok {
+						f.failAt(propPtr(dep), "required when '%s' is present", name)
+					}
+				}
+			}
+		}
+	}
+	if deps, ok := m["dependentSchemas"].(map[string]any); ok {
+		for _, name := range sortedKeys(deps) {
+			if _, ok := v[name]; ok {
+				f.inPlace(f.doc, deps[name], v)
+			}
+		}
+	}
+
+	if n, ok := number(m, "maxProperties"); ok && float64(len(v)) > n {
+		f.fail("must have at most %s properties", format(n))
+	}
+	if n, ok := number(m, "minProperties"); ok && float64(len(v)) < n {
+		f.fail("must have at least %s properties", format(n))
+	}
+	if names, ok := m["propertyNames"]; ok {
+		for _, k := range keys {
+			if !f.matches(names, k) {
+				f.failAt(propPtr(k), "property name does not match propertyNames")
+			}
+		}
+	}
+
+	props, _ := m["properties"].(map[string]any)
+	patterns, _ := m["patternProperties"].(map[string]any)
+	additional, hasAdditional := m["additionalProperties"]
+
+	for _, k := range keys {
+		matched := false
+		if sub, ok := props[k]; ok {
+			f.child(sub, v[k], propPtr(k))
+			matched = true
+		}
+		for _, pattern := range sortedKeys(patterns) {
+			if f.s.reg.patterns[pattern].MatchString(k) {
+				f.child(patterns[pattern], v[k], propPtr(k))
+				matched = true
+			}
+		}
+		if !matched && hasAdditional {
+			if additional == false {
+				f.failAt(propPtr(k), "additional property is not allowed")
+			} else {
+				f.child(additional, v[k], propPtr(k))
+			}
+			matched = true
+		}
+		if matched {
+			f.ev.prop(k)
+		}
+	}
+
+	if unevaluated, ok := m["unevaluatedProperties"]; ok {
+		for _, k := range keys {
+			if f.ev.props[k] {
+				continue
+			}
+			if unevaluated == false {
+				f.failAt(propPtr(k), "unevaluated property is not allowed")
+			} else {
+				f.child(unevaluated, v[k], propPtr(k))
+			}
+			f.ev.prop(k)
+		}
+	}
+}
+
+func hasType(v any, names []string) bool {
+	actual := typeOf(v)
+	for _, name := range names {
+		if name == actual {
+			return true
+		}
+		if n, ok := v.(float64); ok && name == "integer" && n == math.Trunc(n) {
+			return true
+		}
+	}
+	return false
+}
+
+func typeOf(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// equal compares JSON values; numbers compare by value, so 1 equals 1.0
+func equal(a, b any) bool {
+	switch a := a.(type) {
+	case []any:
+		b, ok := b.([]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !equal(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]any:
+		b, ok := b.(map[string]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for k, v := range a {
+			if w, ok := b[k]; !ok || !equal(v, w) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
+
+func number(m map[string]any, key string) (float64, bool) {
+	n, ok := m[key].(float64)
+	return n, ok
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func format(n float64) string {
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
+
+func encode(v any) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
@@ -0,0 +1,187 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func decode(t *testing.T, s string) any {
+	t.Helper()
+	var v any
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+// failures validates and returns the failing pointers, nil when valid
+func failures(t *testing.T, schema *Schema, value string) []string {
+	t.Helper()
+	err := schema.Validate(decode(t, value))
+	if err == nil {
+		return nil
+	}
+	var errs Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("unexpected error type %T", err)
+	}
+	return errs.Paths()
+}
+
+const user = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "type": "object",
+  "required": ["id", "email"],
+  "properties": {
+    "id": {"type": "integer", "minimum": 1},
+    "email": {"type": "string", "pattern": "^[^@]+@[^@]+$"},
+    "name": {"type": "string", "minLength": 1, "maxLength": 10},
+    "role": {"enum": ["admin", "member"]},
+    "tags": {"type": "array", "items": {"type": "string"}, "uniqueItems": true, "maxItems": 3},
+    "address": {"$ref": "#/$defs/address"}
+  },
+  "additionalProperties": false,
+  "$defs": {
+    "address": {
+      "type": "object",
+      "properties": {"zip": {"type": "string", "pattern": "^\\d{5}$"}},
+      "required": ["zip"]
+    }
+  }
+}`
+
+func TestValidate(t *testing.T) {
+	schema := MustCompile(user)
+
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{`{"id": 1, "email": "a@b.c"}`, nil},
+		{`{"id": 7, "email": "a@b.c", "name": "Ada", "role": "admin", "tags": ["x", "y"], "address": {"zip": "12345"}}`, nil},
+		{`{"id": 1.5, "email": "a@b.c"}`, []string{"/id"}},
+		{`{"id": 0, "email": "nope"}`, []string{"/email", "/id"}},
+		{`{"id": 1}`, []string{"/email"}},
+		{`{"id": 1, "email": "a@b.c", "role": "owner"}`, []string{"/role"}},
+		{`{"id": 1, "email": "a@b.c", "tags": ["x", 2, "x", "y"]}`, []string{"/tags/1", "/tags"}},
+		{`{"id": 1, "email": "a@b.c", "address": {"zip": "1"}}`, []string{"/address/zip"}},
+		{`{"id": 1, "email": "a@b.c", "address": {}}`, []string{"/address/zip"}},
+		{`{"id": 1, "email": "a@b.c", "extra": true}`, []string{"/extra"}},
+		{`{"id": 1, "email": "a@b.c", "name": ""}`, []string{"/name"}},
+		{`[]`, []string{""}},
+	}
+
+	for _, tt := range tests {
+		if got := failures(t, schema, tt.value); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: failed at %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestValidate_Keywords(t *testing.T) {
+	tests := []struct {
+		schema  string
+		valid   []string
+		invalid []string
+	}{
+		{`{"type": ["string", "null"]}`, []string{`"a"`, `null`}, []string{`1`}},
+		{`{"const": {"a": [1, 2]}}`, []string{`{"a": [1.0, 2]}`}, []string{`{"a": [2, 1]}`}},
+		{`{"multipleOf": 0.1}`, []string{`0.3`, `2`}, []string{`0.35`}},
+		{`{"exclusiveMinimum": 0, "exclusiveMaximum": 10}`, []string{`5`, `"x"`}, []string{`0`, `10`}},
+		{`{"prefixItems": [{"type": "integer"}], "items": false}`, []string{`[1]`}, []string{`["a"]`, `[1, 2]`}},
+		{`{"contains": {"type": "string"}, "minContains": 2, "maxContains": 3}`, []string{`["a", "b", 1]`}, []string{`["a", 1]`, `["a", "b", "c", "d"]`}},
+		{`{"anyOf": [{"type": "string"}, {"minimum": 10}]}`, []string{`"a"`, `12`}, []string{`5`}},
+		{`{"oneOf": [{"type": "integer"}, {"minimum": 2}]}`, []string{`1`, `2.5`}, []string{`3`}},
+		{`{"not": {"type": "null"}}`, []string{`1`}, []string{`null`}},
+		{`{"if": {"properties": {"kind": {"const": "card"}}}, "then": {"required": ["number"]}, "else": {"required": ["iban"]}}`,
+			[]string{`{"kind": "card", "number": "4111"}`, `{"kind": "bank", "iban": "DE00"}`},
+			[]string{`{"kind": "card"}`, `{"kind": "bank"}`}},
+		{`{"dependentRequired": {"credit_card": ["billing_address"]}}`, []string{`{}`, `{"credit_card": 1, "billing_address": 2}`}, []string{`{"credit_card": 1}`}},
+		{`{"dependentSchemas": {"a": {"required": ["b"]}}}`, []string{`{"b": 1}`}, []string{`{"a": 1}`}},
+		{`{"propertyNames": {"pattern": "^[a-z]+$"}, "maxProperties": 2}`, []string{`{"ab": 1}`}, []string{`{"Ab": 1}`, `{"a": 1, "b": 2, "c": 3}`}},
+		{`{"patternProperties": {"^x-": {"type": "string"}}, "additionalProperties": {"type": "integer"}}`, []string{`{"x-a": "s", "b": 1}`}, []string{`{"x-a": 1}`, `{"b": "s"}`}},
+		{`{"allOf": [{"properties": {"a": true}}], "unevaluatedProperties": false}`, []string{`{"a": 1}`}, []string{`{"a": 1, "b": 2}`}},
+		{`{"anyOf": [{"properties": {"a": true}}, {"properties": {"b": true}}], "unevaluatedProperties": false}`, []string{`{"a": 1, "b": 2}`}, []string{`{"c": 1}`}},
+		{`{"prefixItems": [true], "contains": {"type": "string"}, "unevaluatedItems": {"type": "integer"}}`, []string{`[null, "a", 2]`}, []string{`[null, "a", null]`}},
+		{`{"$defs": {"node": {"$anchor": "node", "type": "object", "properties": {"next": {"$ref": "#node"}}}}, "$ref": "#/$defs/node"}`,
+			[]string{`{"next": {"next": {}}}`}, []string{`{"next": {"next": 1}}`}},
+		{`false`, nil, []string{`1`}},
+	}
+
+	for _, tt := range tests {
+		schema, err := Compile([]byte(tt.schema))
+		if err != nil {
+			t.Errorf("%s: %v", tt.schema, err)
+			continue
+		}
+		for _, v := range tt.valid {
+			if err := schema.Validate(decode(t, v)); err != nil {
+				t.Errorf("%s: %s should be valid: %v", tt.schema, v, err)
+			}
+		}
+		for _, v := range tt.invalid {
+			if err := schema.Validate(decode(t, v)); err == nil {
+				t.Errorf("%s: %s should be invalid", tt.schema, v)
+			}
+		}
+	}
+}
+
+func TestLoad_FileRefs(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("common.json", `{"$defs": {"id": {"type": "integer", "minimum": 1}}}`)
+	write("order.json", `{
+  "$id": "https://example.com/order",
+  "type": "object",
+  "properties": {
+    "id": {"$ref": "common.json#/$defs/id"},
+    "lines": {"type": "array", "items": {"$ref": "https://example.com/line"}}
+  },
+  "$defs": {
+    "line": {"$id": "line", "type": "object", "required": ["sku"]}
+  }
+}`)
+
+	schema, err := Load(filepath.Join(dir, "order.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := failures(t, schema, `{"id": 3, "lines": [{"sku": "a"}]}`); got != nil {
+		t.Errorf("valid order failed at %q", got)
+	}
+	if got := failures(t, schema, `{"id": 0, "lines": [{"sku": "a"}, {}]}`); !reflect.DeepEqual(got, []string{"/id", "/lines/1/sku"}) {
+		t.Errorf("invalid order failed at %q", got)
+	}
+}
+
+func TestCompile_Errors(t *testing.T) {
+	for _, schema := range []string{
+		`[]`,
+		`{"pattern": "("}`,
+		`{"$ref": "#/$defs/missing"}`,
+		`{"$ref": "#nowhere"}`,
+		`{"properties": {"a": {"$ref": "missing.json"}}}`,
+	} {
+		if _, err := Compile([]byte(schema)); err == nil {
+			t.Errorf("%s: expected an error", schema)
+		}
+	}
+}
+
+func TestErrors_Error(t *testing.T) {
+	err := MustCompile(user).Validate(decode(t, `{"id": "x", "extra": 1}`))
+	want := `/email: required property is missing; /extra: additional property is not allowed; /id: expected integer, got string`
+	if err == nil || err.Error() != want {
+		t.Errorf("error = %v, want %s", err, want)
+	}
+}
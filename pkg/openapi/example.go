@@ -0,0 +1,203 @@
+package openapi
+
+import (
+	"sort"
+	"strings"
+)
+
+// maxExampleDepth stops generating nested values, e.g. for recursive schemas
+const maxExampleDepth = 8
+
+// formatExamples are the values generated for string formats
+var formatExamples = map[string]string{
+	"date-time": "2024-01-01T00:00:00Z",
+	"date":      "2024-01-01",
+	"time":      "00:00:00Z",
+	"email":     "user@example.com",
+	"uuid":      "00000000-0000-0000-0000-000000000000",
+	"uri":       "https://example.com",
+	"url":       "https://example.com",
+	"hostname":  "example.com",
+	"ipv4":      "192.0.2.1",
+	"ipv6":      "2001:db8::1",
+	"password":  "password",
+	"byte":      "ZXhhbXBsZQ==",
+}
+
+// MediaExample returns the example of a media type: the one it gives, its
+// first named example, or one generated from its schema
+func (s *Spec) MediaExample(mt MediaType, forRequest bool) any {
+	if mt.Example != nil {
+		return mt.Example
+	}
+	if v, ok := s.firstExample(mt.Examples); ok {
+		return v
+	}
+	return s.Example(mt.Schema, forRequest)
+}
+
+// ParameterExample returns the example of a parameter, or one generated from
+// its schema
+func (s *Spec) ParameterExample(p *Parameter) any {
+	if p.Example != nil {
+		return p.Example
+	}
+	if v, ok := s.firstExample(p.Examples); ok {
+		return v
+	}
+	return s.Example(p.Schema, true)
+}
+
+// firstExample returns the value of the first named example, in name order
+func (s *Spec) firstExample(examples map[string]any) (any, bool) {
+	names := make([]string, 0, len(examples))
+	for name := range examples {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		example, ok := examples[name].(map[string]any)
+		if !ok {
+			continue
+		}
+		if ref, ok := example["$ref"].(string); ok {
+			resolved, err := s.Lookup(ref)
+			if example, ok = resolved.(map[string]any); err != nil || !ok {
+				continue
+			}
+		}
+		if v, ok := example["value"]; ok {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// Example generates a value that matches a schema, preferring the examples,
+// defaults and enum values it gives. Request examples leave out readOnly
+// properties; response examples leave out writeOnly ones.
+func (s *Spec) Example(schema map[string]any, forRequest bool) any {
+	return s.example(schema, forRequest, 0)
+}
+
+func (s *Spec) example(schema map[string]any, forRequest bool, depth int) any {
+	if schema == nil || depth > maxExampleDepth {
+		return nil
+	}
+	if ref, ok := schema["$ref"].(string); ok {
+		resolved, err := s.Lookup(ref)
+		if err != nil {
+			return nil
+		}
+		m, _ := resolved.(map[string]any)
+		return s.example(m, forRequest, depth+1)
+	}
+
+	for _, key := range []string{"example", "default", "const"} {
+		if v, ok := schema[key]; ok {
+			return v
+		}
+	}
+	if examples, ok := schema["examples"].([]any); ok && len(examples) > 0 {
+		return examples[0]
+	}
+	if enum, ok := schema["enum"].([]any); ok && len(enum) > 0 {
+		return enum[0]
+	}
+
+	if all, ok := schema["allOf"].([]any); ok {
+		merged := map[string]any{}
+		for _, sub := range all {
+			m, _ := sub.(map[string]any)
+			if obj, ok := s.example(m, forRequest, depth+1).(map[string]any); ok {
+				for k, v := range obj {
+					merged[k] = v
+				}
+			}
+		}
+		if props, ok := s.example(withoutKey(schema, "allOf"), forRequest, depth+1).(map[string]any); ok {
+			for k, v := range props {
+				merged[k] = v
+			}
+		}
+		return merged
+	}
+	for _, key := range []string{"oneOf", "anyOf"} {
+		if options, ok := schema[key].([]any); ok && len(options) > 0 {
+			m, _ := options[0].(map[string]any)
+			return s.example(m, forRequest, depth+1)
+		}
+	}
+
+	switch schemaType(schema) {
+	case "object":
+		obj := map[string]any{}
+		props, _ := schema["properties"].(map[string]any)
+		for name, sub := range props {
+			m, _ := sub.(map[string]any)
+			if m["readOnly"] == true && forRequest || m["writeOnly"] == true && !forRequest {
+				continue
+			}
+			if v := s.example(m, forRequest, depth+1); v != nil || m["nullable"] == true {
+				obj[name] = v
+			}
+		}
+		return obj
+	case "array":
+		items, _ := schema["items"].(map[string]any)
+		if v := s.example(items, forRequest, depth+1); v != nil {
+			return []any{v}
+		}
+		return []any{}
+	case "string":
+		format, _ := schema["format"].(string)
+		if v, ok := formatExamples[format]; ok {
+			return v
+		}
+		return "string"
+	case "integer":
+		if min, ok := schema["minimum"].(float64); ok {
+			return min
+		}
+		return float64(1)
+	case "number":
+		if min, ok := schema["minimum"].(float64); ok {
+			return min
+		}
+		return 1.5
+	case "boolean":
+		return true
+	}
+	return nil
+}
+
+// schemaType returns a schema's type, the first non-null one when it lists
+// several, or object when it only has properties
+func schemaType(schema map[string]any) string {
+	switch t := schema["type"].(type) {
+	case string:
+		return t
+	case []any:
+		for _, item := range t {
+			if name, ok := item.(string); ok && name != "null" {
+				return name
+			}
+		}
+	}
+	if _, ok := schema["properties"]; ok {
+		return "object"
+	}
+	return ""
+}
+
+// withoutKey returns a copy of a schema without one keyword
+func withoutKey(schema map[string]any, key string) map[string]any {
+	m := make(map[string]any, len(schema))
+	for k, v := range schema {
+		if !strings.EqualFold(k, key) {
+			m[k] = v
+		}
+	}
+	return m
+}
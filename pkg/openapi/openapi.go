@@ -0,0 +1,410 @@
+// Package openapi reads OpenAPI 3 specifications, in YAML or JSON, and
+// resolves the references between their parts.
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Spec is an OpenAPI 3.x document. Schemas are kept as decoded JSON so they
+// can be walked and validated as they are written.
+type Spec struct {
+	OpenAPI    string                `json:"openapi"`
+	Info       Info                  `json:"info"`
+	Servers    []Server              `json:"servers,omitempty"`
+	Paths      map[string]*PathItem  `json:"paths"`
+	Components Components            `json:"components"`
+	Security   []SecurityRequirement `json:"security,omitempty"`
+
+	doc map[string]any // The whole document, for resolving $ref
+}
+
+// Info describes the API
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// Server is a base URL of the API, possibly with {variables}
+type Server struct {
+	URL       string                    `json:"url"`
+	Variables map[string]ServerVariable `json:"variables,omitempty"`
+}
+
+// ServerVariable is a variable of a server URL
+type ServerVariable struct {
+	Default string `json:"default"`
+}
+
+// PathItem holds the operations of a path
+type PathItem struct {
+	Ref        string       `json:"$ref,omitempty"`
+	Parameters []*Parameter `json:"parameters,omitempty"`
+	Get        *Operation   `json:"get,omitempty"`
+	Put        *Operation   `json:"put,omitempty"`
+	Post       *Operation   `json:"post,omitempty"`
+	Delete     *Operation   `json:"delete,omitempty"`
+	Options    *Operation   `json:"options,omitempty"`
+	Head       *Operation   `json:"head,omitempty"`
+	Patch      *Operation   `json:"patch,omitempty"`
+	Trace      *Operation   `json:"trace,omitempty"`
+}
+
+// Operation is a method on a path. Method and Path are filled in by
+// Operations; Parameters then include those of the path, and references are
+// resolved.
+type Operation struct {
+	Method      string                 `json:"-"`
+	Path        string                 `json:"-"`
+	OperationID string                 `json:"operationId,omitempty"`
+	Summary     string                 `json:"summary,omitempty"`
+	Tags        []string               `json:"tags,omitempty"`
+	Parameters  []*Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody           `json:"requestBody,omitempty"`
+	Responses   map[string]*Response   `json:"responses"`
+	Security    *[]SecurityRequirement `json:"security,omitempty"` // nil inherits the spec's
+	Deprecated  bool                   `json:"deprecated,omitempty"`
+}
+
+// Parameter is a path, query, header or cookie parameter
+type Parameter struct {
+	Ref      string         `json:"$ref,omitempty"`
+	Name     string         `json:"name"`
+	In       string         `json:"in"`
+	Required bool           `json:"required,omitempty"`
+	Schema   map[string]any `json:"schema,omitempty"`
+	Example  any            `json:"example,omitempty"`
+	Examples map[string]any `json:"examples,omitempty"`
+}
+
+// RequestBody is the body an operation accepts, by media type
+type RequestBody struct {
+	Ref      string               `json:"$ref,omitempty"`
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response is a documented response
+type Response struct {
+	Ref         string               `json:"$ref,omitempty"`
+	Description string               `json:"description"`
+	Headers     map[string]*Header   `json:"headers,omitempty"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// Header is a documented response header
+type Header struct {
+	Ref      string         `json:"$ref,omitempty"`
+	Required bool           `json:"required,omitempty"`
+	Schema   map[string]any `json:"schema,omitempty"`
+}
+
+// MediaType is the schema and examples of one content type
+type MediaType struct {
+	Schema   map[string]any `json:"schema,omitempty"`
+	Example  any            `json:"example,omitempty"`
+	Examples map[string]any `json:"examples,omitempty"`
+}
+
+// Components holds the reusable parts of the spec
+type Components struct {
+	Schemas         map[string]any             `json:"schemas,omitempty"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme is a way of authenticating: http (basic or bearer), apiKey,
+// oauth2 or openIdConnect
+type SecurityScheme struct {
+	Ref    string `json:"$ref,omitempty"`
+	Type   string `json:"type"`
+	Scheme string `json:"scheme,omitempty"` // For http: basic or bearer
+	Name   string `json:"name,omitempty"`   // For apiKey
+	In     string `json:"in,omitempty"`     // For apiKey: header, query or cookie
+}
+
+// SecurityRequirement names the schemes one way of authenticating needs
+type SecurityRequirement map[string][]string
+
+// methods are the operation fields of a path item, in the order operations
+// are listed
+var methods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS", "TRACE"}
+
+// Load reads a spec from a .yaml, .yml or .json file
+func Load(path string) (*Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Parse decodes a spec written in YAML or JSON
+func Parse(data []byte) (*Spec, error) {
+	var raw any
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI document: %w", err)
+	}
+	doc, ok := normalize(raw).(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("invalid OpenAPI document: not an object")
+	}
+
+	version, _ := doc["openapi"].(string)
+	if !strings.HasPrefix(version, "3.") {
+		if _, ok := doc["swagger"]; ok {
+			return nil, fmt.Errorf("swagger 2.0 documents are not supported; convert to OpenAPI 3 first")
+		}
+		return nil, fmt.Errorf("unsupported OpenAPI version '%s' (expected 3.x)", version)
+	}
+
+	// The typed view comes from the normalized document, so YAML and JSON
+	// specs decode the same way
+	encoded, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI document: %w", err)
+	}
+	var spec Spec
+	if err := json.Unmarshal(encoded, &spec); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI document: %w", err)
+	}
+	spec.doc = doc
+
+	return &spec, nil
+}
+
+// normalize converts decoded YAML to the types encoding/json produces:
+// map[string]any, []any, float64 numbers and strings for timestamps
+func normalize(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, item := range v {
+			v[k] = normalize(item)
+		}
+		return v
+	case map[any]any:
+		m := make(map[string]any, len(v))
+		for k, item := range v {
+			m[fmt.Sprint(k)] = normalize(item)
+		}
+		return m
+	case []any:
+		for i, item := range v {
+			v[i] = normalize(item)
+		}
+		return v
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	case time.Time:
+		// An unquoted date such as 2024-01-01 is still a string in JSON
+		if v.Equal(v.Truncate(24*time.Hour)) && v.Location() == time.UTC {
+			return v.Format(time.DateOnly)
+		}
+		return v.Format(time.RFC3339Nano)
+	default:
+		return v
+	}
+}
+
+// Operations lists every operation, sorted by path and then method, with
+// path parameters merged in and references resolved
+func (s *Spec) Operations() ([]*Operation, error) {
+	paths := make([]string, 0, len(s.Paths))
+	for path := range s.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var ops []*Operation
+	for _, path := range paths {
+		item := s.Paths[path]
+		if item.Ref != "" {
+			resolved := &PathItem{}
+			if err := s.Resolve(item.Ref, resolved); err != nil {
+				return nil, fmt.Errorf("path %s: %w", path, err)
+			}
+			item = resolved
+		}
+
+		for _, method := range methods {
+			op := item.operation(method)
+			if op == nil {
+				continue
+			}
+			op.Method, op.Path = method, path
+
+			params, err := s.parameters(item.Parameters, op.Parameters)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %w", method, path, err)
+			}
+			op.Parameters = params
+
+			if body := op.RequestBody; body != nil && body.Ref != "" {
+				op.RequestBody = &RequestBody{}
+				if err := s.Resolve(body.Ref, op.RequestBody); err != nil {
+					return nil, fmt.Errorf("%s %s: %w", method, path, err)
+				}
+			}
+			for code, resp := range op.Responses {
+				if resp.Ref != "" {
+					op.Responses[code] = &Response{}
+					if err := s.Resolve(resp.Ref, op.Responses[code]); err != nil {
+						return nil, fmt.Errorf("%s %s: %w", method, path, err)
+					}
+				}
+			}
+
+			ops = append(ops, op)
+		}
+	}
+
+	return ops, nil
+}
+
+func (p *PathItem) operation(method string) *Operation {
+	switch method {
+	case "GET":
+		return p.Get
+	case "PUT":
+		return p.Put
+	case "POST":
+		return p.Post
+	case "DELETE":
+		return p.Delete
+	case "OPTIONS":
+		return p.Options
+	case "HEAD":
+		return p.Head
+	case "PATCH":
+		return p.Patch
+	case "TRACE":
+		return p.Trace
+	}
+	return nil
+}
+
+// parameters resolves the path item's and the operation's parameters. An
+// operation parameter replaces a path one with the same name and location.
+func (s *Spec) parameters(shared, own []*Parameter) ([]*Parameter, error) {
+	var params []*Parameter
+	index := make(map[string]int)
+
+	for _, list := range [][]*Parameter{shared, own} {
+		for _, p := range list {
+			if p.Ref != "" {
+				resolved := &Parameter{}
+				if err := s.Resolve(p.Ref, resolved); err != nil {
+					return nil, err
+				}
+				p = resolved
+			}
+
+			key := p.In + ":" + p.Name
+			if i, ok := index[key]; ok {
+				params[i] = p
+				continue
+			}
+			index[key] = len(params)
+			params = append(params, p)
+		}
+	}
+
+	return params, nil
+}
+
+// SecurityFor returns the security requirements of an operation: its own, or
+// the spec's when it does not set any
+func (s *Spec) SecurityFor(op *Operation) []SecurityRequirement {
+	if op.Security != nil {
+		return *op.Security
+	}
+	return s.Security
+}
+
+// Scheme returns a security scheme by name
+func (s *Spec) Scheme(name string) (*SecurityScheme, error) {
+	scheme, ok := s.Components.SecuritySchemes[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown security scheme '%s'", name)
+	}
+	if scheme.Ref != "" {
+		resolved := &SecurityScheme{}
+		if err := s.Resolve(scheme.Ref, resolved); err != nil {
+			return nil, err
+		}
+		return resolved, nil
+	}
+	return scheme, nil
+}
+
+// BaseURL returns the URL of the first server with its variables set to their
+// defaults, or "" when the spec lists none
+func (s *Spec) BaseURL() string {
+	if len(s.Servers) == 0 {
+		return ""
+	}
+	server := s.Servers[0]
+	base := server.URL
+	for name, v := range server.Variables {
+		base = strings.ReplaceAll(base, "{"+name+"}", v.Default)
+	}
+	return strings.TrimRight(base, "/")
+}
+
+// Resolve decodes the part of the document a local reference such as
+// #/components/parameters/Limit points to into v
+func (s *Spec) Resolve(ref string, v any) error {
+	node, err := s.Lookup(ref)
+	if err != nil {
+		return err
+	}
+	encoded, err := json.Marshal(node)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(encoded, v)
+}
+
+// Lookup returns the decoded JSON a local reference points to
+func (s *Spec) Lookup(ref string) (any, error) {
+	pointer, ok := strings.CutPrefix(ref, "#")
+	if !ok {
+		return nil, fmt.Errorf("unsupported reference '%s' (only references within the document are)", ref)
+	}
+
+	var node any = s.doc
+	for _, token := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		if token == "" {
+			continue
+		}
+		if unescaped, err := url.PathUnescape(token); err == nil {
+			token = unescaped
+		}
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+
+		m, ok := node.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("reference '%s' not found", ref)
+		}
+		if node, ok = m[token]; !ok {
+			return nil, fmt.Errorf("reference '%s' not found", ref)
+		}
+	}
+	return node, nil
+}
+
+// Document returns the whole spec as decoded JSON
+func (s *Spec) Document() map[string]any {
+	return s.doc
+}
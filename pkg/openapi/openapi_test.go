@@ -0,0 +1,126 @@
+package openapi
+
+import (
+	"reflect"
+	"testing"
+)
+
+func loadExample(t *testing.T) *Spec {
+	t.Helper()
+	spec, err := Load("../../examples/openapi-example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return spec
+}
+
+func TestOperations(t *testing.T) {
+	spec := loadExample(t)
+
+	if spec.Info.Title != "Users API" || spec.BaseURL() != "https://api.example.com/v1" {
+		t.Errorf("info = %+v, base URL = %s", spec.Info, spec.BaseURL())
+	}
+
+	ops, err := spec.Operations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, op := range ops {
+		got = append(got, op.Method+" "+op.Path)
+	}
+	want := []string{
+		"GET /health",
+		"POST /login",
+		"GET /users",
+		"POST /users",
+		"GET /users/{userId}",
+		"PATCH /users/{userId}",
+		"DELETE /users/{userId}",
+		"PUT /users/{userId}/avatar",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("operations = %v, want %v", got, want)
+	}
+
+	// The shared $ref'd path parameter is resolved and merged in
+	getUser := ops[4]
+	if len(getUser.Parameters) != 1 || getUser.Parameters[0].Name != "userId" || getUser.Parameters[0].Example != float64(42) {
+		t.Errorf("parameters = %+v", getUser.Parameters)
+	}
+	if resp := getUser.Responses["404"]; resp == nil || resp.Description != "An error" {
+		t.Errorf("404 response = %+v", resp)
+	}
+
+	if sec := spec.SecurityFor(ops[0]); len(sec) != 0 {
+		t.Errorf("health security = %v, want none", sec)
+	}
+	if sec := spec.SecurityFor(getUser); len(sec) != 1 || sec[0]["bearerAuth"] == nil {
+		t.Errorf("get user security = %v", sec)
+	}
+	if scheme, err := spec.Scheme("apiKey"); err != nil || scheme.In != "header" || scheme.Name != "X-Api-Key" {
+		t.Errorf("apiKey scheme = %+v, %v", scheme, err)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		doc  string
+		want string
+	}{
+		{`swagger: "2.0"`, "swagger 2.0 documents are not supported; convert to OpenAPI 3 first"},
+		{`{"openapi": "4.0.0"}`, "unsupported OpenAPI version '4.0.0' (expected 3.x)"},
+		{`- a list`, "invalid OpenAPI document: not an object"},
+	}
+	for _, tt := range tests {
+		if _, err := Parse([]byte(tt.doc)); err == nil || err.Error() != tt.want {
+			t.Errorf("%s: error %v, want %s", tt.doc, err, tt.want)
+		}
+	}
+
+	spec, err := Parse([]byte(`{"openapi": "3.1.0", "paths": {"/a": {"$ref": "#/components/pathItems/missing"}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := spec.Operations(); err == nil || err.Error() != "path /a: reference '#/components/pathItems/missing' not found" {
+		t.Errorf("error = %v", err)
+	}
+}
+
+func TestExample(t *testing.T) {
+	spec := loadExample(t)
+	schema := map[string]any{"$ref": "#/components/schemas/NewUser"}
+
+	// readOnly properties are left out of requests, writeOnly ones out of
+	// responses
+	request := spec.Example(schema, true)
+	want := map[string]any{"name": "Ada", "email": "user@example.com", "role": "member", "password": "correct-horse"}
+	if !reflect.DeepEqual(request, want) {
+		t.Errorf("request example = %v, want %v", request, want)
+	}
+	response := spec.Example(schema, false)
+	want = map[string]any{"id": float64(1), "name": "Ada", "email": "user@example.com", "role": "member", "created_at": "2024-01-01T00:00:00Z"}
+	if !reflect.DeepEqual(response, want) {
+		t.Errorf("response example = %v, want %v", response, want)
+	}
+
+	tests := []struct {
+		schema string
+		want   any
+	}{
+		{`{type: integer, minimum: 5}`, float64(5)},
+		{`{type: [string, "null"], format: uuid}`, "00000000-0000-0000-0000-000000000000"},
+		{`{type: array, items: {type: boolean}}`, []any{true}},
+		{`{oneOf: [{type: number}, {type: string}]}`, 1.5},
+		{`{type: string, example: 2024-05-01}`, "2024-05-01"},
+	}
+	for _, tt := range tests {
+		doc, err := Parse([]byte("openapi: 3.0.0\ncomponents: {schemas: {S: " + tt.schema + "}}"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := doc.Example(map[string]any{"$ref": "#/components/schemas/S"}, true); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: example = %#v, want %#v", tt.schema, got, tt.want)
+		}
+	}
+}
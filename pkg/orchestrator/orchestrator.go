@@ -2,12 +2,18 @@ package orchestrator
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/vikasavnish/httptool/pkg/arrival"
 	"github.com/vikasavnish/httptool/pkg/evaluator"
 	"github.com/vikasavnish/httptool/pkg/executor"
+	"github.com/vikasavnish/httptool/pkg/extract"
+	"github.com/vikasavnish/httptool/pkg/histogram"
 	"github.com/vikasavnish/httptool/pkg/ir"
 )
 
@@ -20,6 +26,27 @@ type Result struct {
 	StartTime  time.Time
 	EndTime    time.Time
 	Attempt    int
+	Branches   []string       // Steps jumped to by branch decisions, in order
+	Extracted  map[string]any // Vars from the evaluators' extract actions
+
+	hops          []*Result      // Steps of a branch chain before the current one
+	stepStart     time.Time      // When the current step of the chain started
+	stepExtracted map[string]any // Vars extracted by the current step
+}
+
+// Steps splits a branch chain into one result per step that ran, in order.
+// Each has its own response, decision, timing and extracted values, and its
+// Branches names the step it jumped to. Without branches it is just r.
+func (r *Result) Steps() []*Result {
+	if len(r.hops) == 0 {
+		return []*Result{r}
+	}
+	last := *r
+	last.StartTime = r.stepStart
+	last.Branches = nil
+	last.Extracted = r.stepExtracted
+	last.hops = nil
+	return append(append([]*Result(nil), r.hops...), &last)
 }
 
 // Stats holds execution statistics
@@ -32,6 +59,12 @@ type Stats struct {
 	MinLatency  float64
 	MaxLatency  float64
 	TotalBytes  int64
+	Dropped     int // Load executions skipped because every worker was busy
+
+	// Latency distributions in milliseconds of executions that got a response
+	Latency   histogram.Summary
+	ByRequest map[string]histogram.Summary // Keyed by "METHOD URL"
+	ByStatus  map[int]histogram.Summary
 }
 
 // Orchestrator manages execution flow with retries and load testing
@@ -39,8 +72,13 @@ type Orchestrator struct {
 	executor  *executor.Executor
 	evaluator *evaluator.Manager
 	maxRetries int
+	steps      map[string]*ir.IR // Branch targets by name
+	maxHops    int
 }
 
+// DefaultMaxHops bounds how many branch decisions one execution may follow
+const DefaultMaxHops = 10
+
 // NewOrchestrator creates a new orchestrator
 func NewOrchestrator(maxRetries int, evalTimeout time.Duration) *Orchestrator {
 	return &Orchestrator{
@@ -50,6 +88,38 @@ func NewOrchestrator(maxRetries int, evalTimeout time.Duration) *Orchestrator {
 	}
 }
 
+// NewOrchestratorWithEvaluator creates an orchestrator that evaluates with the
+// given manager, e.g. one from evaluator.NewPooledManager for load tests
+func NewOrchestratorWithEvaluator(maxRetries int, evalManager *evaluator.Manager) *Orchestrator {
+	return &Orchestrator{
+		executor:   executor.NewExecutor(),
+		evaluator:  evalManager,
+		maxRetries: maxRetries,
+	}
+}
+
+// RegisterSteps makes named requests available as targets of branch
+// decisions (actions.goto), e.g. the requests of a workflow or scenario file
+func (o *Orchestrator) RegisterSteps(steps map[string]*ir.IR) {
+	if o.steps == nil {
+		o.steps = make(map[string]*ir.IR)
+	}
+	for name, irSpec := range steps {
+		o.steps[name] = irSpec
+	}
+}
+
+// SetMaxHops limits how many branch decisions one execution may follow
+// (default DefaultMaxHops)
+func (o *Orchestrator) SetMaxHops(n int) {
+	o.maxHops = n
+}
+
+// Close stops any persistent evaluator workers
+func (o *Orchestrator) Close() error {
+	return o.evaluator.Close()
+}
+
 // ExecuteOne executes a single IR with retry logic
 func (o *Orchestrator) ExecuteOne(ctx context.Context, irSpec *ir.IR) (*Result, error) {
 	result := &Result{
@@ -57,6 +127,8 @@ func (o *Orchestrator) ExecuteOne(ctx context.Context, irSpec *ir.IR) (*Result,
 		StartTime: time.Now(),
 		Attempt:   1,
 	}
+	result.stepStart = result.StartTime
+	visited := make(map[string]bool)
 
 	for attempt := 1; attempt <= o.maxRetries; attempt++ {
 		result.Attempt = attempt
@@ -70,8 +142,8 @@ func (o *Orchestrator) ExecuteOne(ctx context.Context, irSpec *ir.IR) (*Result,
 		}
 		irSpec.Evaluation.Vars["attempt"] = attempt
 
-		// Execute
-		execCtx, err := o.executor.Execute(irSpec)
+		// Execute, with ${name} references filled in from the vars
+		execCtx, err := o.executor.Execute(resolveVars(irSpec))
 		if err != nil {
 			result.Error = err
 			result.EndTime = time.Now()
@@ -80,6 +152,20 @@ func (o *Orchestrator) ExecuteOne(ctx context.Context, irSpec *ir.IR) (*Result,
 
 		result.Context = execCtx
 
+		// A body that breaks its schema fails without asking the evaluator
+		if irSpec.Evaluation.Schema != "" {
+			decision, err := evaluator.SchemaDecision(execCtx, irSpec.Evaluation.Schema)
+			if err == nil && decision != nil {
+				result.Decision = decision
+				err = fmt.Errorf("evaluation failed: %s", decision.Reason)
+			}
+			if err != nil {
+				result.Error = err
+				result.EndTime = time.Now()
+				return result, err
+			}
+		}
+
 		// Evaluate
 		evalType := "bun"
 		evalPath := ""
@@ -98,6 +184,27 @@ func (o *Orchestrator) ExecuteOne(ctx context.Context, irSpec *ir.IR) (*Result,
 
 		result.Decision = decision
 
+		// Extracted values become vars of later attempts and branch targets
+		if decision.Actions != nil && len(decision.Actions.Extract) > 0 {
+			extracted, err := extract.Apply(execCtx.Response, decision.Actions.Extract)
+			if err != nil {
+				result.Error = err
+				result.EndTime = time.Now()
+				return result, err
+			}
+			if result.Extracted == nil {
+				result.Extracted = make(map[string]any)
+			}
+			if result.stepExtracted == nil {
+				result.stepExtracted = make(map[string]any)
+			}
+			for k, v := range extracted {
+				result.Extracted[k] = v
+				result.stepExtracted[k] = v
+				irSpec.Evaluation.Vars[k] = v
+			}
+		}
+
 		// Handle decision
 		switch decision.Decision {
 		case "pass":
@@ -133,9 +240,33 @@ func (o *Orchestrator) ExecuteOne(ctx context.Context, irSpec *ir.IR) (*Result,
 			continue
 
 		case "branch":
-			// TODO: Implement branching logic
-			result.EndTime = time.Now()
-			return result, fmt.Errorf("branching not yet implemented")
+			next, err := o.branch(irSpec, decision, result, visited)
+			if err != nil {
+				result.EndTime = time.Now()
+				result.Error = err
+				return result, err
+			}
+
+			// Keep the step that branched, so callers can report each step
+			now := time.Now()
+			result.hops = append(result.hops, &Result{
+				IR:        irSpec,
+				Context:   result.Context,
+				Decision:  decision,
+				StartTime: result.stepStart,
+				EndTime:   now,
+				Attempt:   result.Attempt,
+				Branches:  []string{decision.Actions.Goto},
+				Extracted: result.stepExtracted,
+			})
+			result.stepStart = now
+			result.stepExtracted = nil
+
+			// The target starts over with its own retry budget
+			irSpec = next
+			result.IR = next
+			attempt = 0
+			continue
 		}
 	}
 
@@ -144,6 +275,98 @@ func (o *Orchestrator) ExecuteOne(ctx context.Context, irSpec *ir.IR) (*Result,
 	return result, result.Error
 }
 
+// branch prepares the target of a branch decision: a copy of the named step
+// carrying the current vars and the decision's mutations. It fails when the
+// target is unknown, the hop limit is reached, or the same step is about to
+// run with the same vars again, which would repeat forever.
+func (o *Orchestrator) branch(current *ir.IR, decision *ir.EvaluatorDecision, result *Result, visited map[string]bool) (*ir.IR, error) {
+	if decision.Actions == nil || decision.Actions.Goto == "" {
+		return nil, fmt.Errorf("branch decision without actions.goto")
+	}
+	name := decision.Actions.Goto
+	step, ok := o.steps[name]
+	if !ok {
+		return nil, fmt.Errorf("branch target '%s' not found", name)
+	}
+
+	maxHops := o.maxHops
+	if maxHops <= 0 {
+		maxHops = DefaultMaxHops
+	}
+	if len(result.Branches) >= maxHops {
+		return nil, fmt.Errorf("branch limit of %d hops reached at '%s'", maxHops, name)
+	}
+
+	vars := make(map[string]any)
+	for k, v := range current.Evaluation.Vars {
+		vars[k] = v
+	}
+	delete(vars, "attempt")
+
+	next := cloneIR(step)
+	if next.Evaluation == nil {
+		next.Evaluation = ir.DefaultEvaluation()
+	}
+	if next.Evaluation.Vars == nil {
+		next.Evaluation.Vars = make(map[string]any)
+	}
+	for k, v := range vars {
+		next.Evaluation.Vars[k] = v
+	}
+	if decision.Mutations != nil {
+		o.applyMutations(next, decision.Mutations)
+	}
+
+	// The first hop also records where the chain started
+	if len(result.Branches) == 0 {
+		visited[stepKey(o.registeredName(current), vars)] = true
+	}
+	key := stepKey(name, next.Evaluation.Vars)
+	if visited[key] {
+		path := append(result.Branches, name)
+		return nil, fmt.Errorf("branch loop detected: %s", strings.Join(path, " -> "))
+	}
+	visited[key] = true
+
+	result.Branches = append(result.Branches, name)
+	return next, nil
+}
+
+// stepName names an IR for branch bookkeeping
+func stepName(irSpec *ir.IR) string {
+	if irSpec.Metadata != nil && irSpec.Metadata.ID != "" {
+		return irSpec.Metadata.ID
+	}
+	return irSpec.Request.Method + " " + irSpec.Request.URL
+}
+
+// registeredName names an IR the way branch targets do: the name it was
+// registered under, found by ID or else by request, so that a chain which
+// comes back to where it started is caught on that hop
+func (o *Orchestrator) registeredName(irSpec *ir.IR) string {
+	name := stepName(irSpec)
+	if _, ok := o.steps[name]; ok {
+		return name
+	}
+	var matches []string
+	for registered, step := range o.steps {
+		if step.Request.Method == irSpec.Request.Method && step.Request.URL == irSpec.Request.URL {
+			matches = append(matches, registered)
+		}
+	}
+	if len(matches) == 0 {
+		return name
+	}
+	sort.Strings(matches)
+	return matches[0]
+}
+
+// stepKey identifies a step together with the vars it runs with
+func stepKey(name string, vars map[string]any) string {
+	data, _ := json.Marshal(vars)
+	return name + "\x00" + string(data)
+}
+
 // ExecuteConcurrent runs multiple executions concurrently
 func (o *Orchestrator) ExecuteConcurrent(ctx context.Context, irSpecs []*ir.IR, concurrency int) ([]*Result, *Stats) {
 	results := make([]*Result, len(irSpecs))
@@ -170,44 +393,63 @@ func (o *Orchestrator) ExecuteConcurrent(ctx context.Context, irSpecs []*ir.IR,
 	return results, stats
 }
 
-// ExecuteLoad runs load testing with specified duration and rate
-func (o *Orchestrator) ExecuteLoad(ctx context.Context, irSpec *ir.IR, duration time.Duration, rps int) ([]*Result, *Stats) {
-	var results []*Result
-	var mu sync.Mutex
-	ticker := time.NewTicker(time.Second / time.Duration(rps))
-	defer ticker.Stop()
-
-	deadline := time.Now().Add(duration)
+// ExecuteLoad starts executions of irSpec at a constant rate for duration on a
+// pool of maxVUs workers (defaulting to rps). Executions that would start while
+// every worker is busy are dropped and counted in Stats.Dropped.
+func (o *Orchestrator) ExecuteLoad(ctx context.Context, irSpec *ir.IR, duration time.Duration, rps int, maxVUs int) ([]*Result, *Stats, error) {
+	schedule := arrival.Constant(float64(rps), duration)
+	if err := schedule.Validate(); err != nil {
+		return nil, nil, fmt.Errorf("invalid load: %w", err)
+	}
 
-	for {
-		select {
-		case <-ctx.Done():
-			stats := o.calculateStats(results)
-			return results, stats
+	if maxVUs <= 0 {
+		maxVUs = rps
+	}
 
-		case <-ticker.C:
-			if time.Now().After(deadline) {
-				stats := o.calculateStats(results)
-				return results, stats
-			}
+	var results []*Result
+	var mu sync.Mutex
 
-			go func() {
-				result, _ := o.ExecuteOne(context.Background(), irSpec)
-				mu.Lock()
-				results = append(results, result)
-				mu.Unlock()
-			}()
-		}
+	run, err := arrival.Run(ctx, schedule, arrival.Pool{PreAllocated: maxVUs, Max: maxVUs}, func(ctx context.Context, vu, iter int) {
+		// ExecuteOne mutates its IR, so every execution gets its own copy
+		result, _ := o.ExecuteOne(ctx, cloneIR(irSpec))
+		mu.Lock()
+		results = append(results, result)
+		mu.Unlock()
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid load: %w", err)
 	}
+
+	stats := o.calculateStats(results)
+	stats.Dropped = run.Dropped
+	return results, stats, nil
 }
 
 // Replay executes stored IR files in sequence
 func (o *Orchestrator) Replay(ctx context.Context, irSpecs []*ir.IR) ([]*Result, *Stats) {
 	results := make([]*Result, 0, len(irSpecs))
+	extracted := make(map[string]any)
 
 	for _, spec := range irSpecs {
+		// Values extracted by earlier steps are passed on to later ones
+		if len(extracted) > 0 {
+			spec = cloneIR(spec)
+			if spec.Evaluation == nil {
+				spec.Evaluation = ir.DefaultEvaluation()
+			}
+			if spec.Evaluation.Vars == nil {
+				spec.Evaluation.Vars = make(map[string]any)
+			}
+			for k, v := range extracted {
+				spec.Evaluation.Vars[k] = v
+			}
+		}
+
 		result, err := o.ExecuteOne(ctx, spec)
 		results = append(results, result)
+		for k, v := range result.Extracted {
+			extracted[k] = v
+		}
 
 		if err != nil && result.Decision != nil && result.Decision.Decision == "fail" {
 			// Stop on fail
@@ -219,6 +461,14 @@ func (o *Orchestrator) Replay(ctx context.Context, irSpecs []*ir.IR) ([]*Result,
 	return results, stats
 }
 
+// cloneIR returns a deep copy of an IR
+func cloneIR(irSpec *ir.IR) *ir.IR {
+	data, _ := json.Marshal(irSpec)
+	var cloned ir.IR
+	json.Unmarshal(data, &cloned)
+	return &cloned
+}
+
 func (o *Orchestrator) applyMutations(irSpec *ir.IR, mutations *ir.Mutations) {
 	if mutations.Headers != nil {
 		if irSpec.Request.Headers == nil {
@@ -260,23 +510,29 @@ func (o *Orchestrator) applyMutations(irSpec *ir.IR, mutations *ir.Mutations) {
 
 func (o *Orchestrator) calculateStats(results []*Result) *Stats {
 	stats := &Stats{
-		Total:      len(results),
-		MinLatency: 999999,
+		Total: len(results),
 	}
 
-	var totalLatency float64
+	overall := histogram.New()
+	byRequest := make(map[string]*histogram.Histogram)
+	byStatus := make(map[int]*histogram.Histogram)
 
 	for _, result := range results {
-		if result.Context != nil {
-			latency := result.Context.Response.LatencyMs
-			totalLatency += latency
+		if result.Context != nil && result.Context.Response.Status != 0 {
+			latency := time.Duration(result.Context.Response.LatencyMs * float64(time.Millisecond))
+			overall.Record(latency)
 
-			if latency < stats.MinLatency {
-				stats.MinLatency = latency
+			name := result.IR.Request.Method + " " + result.IR.Request.URL
+			if byRequest[name] == nil {
+				byRequest[name] = histogram.New()
 			}
-			if latency > stats.MaxLatency {
-				stats.MaxLatency = latency
+			byRequest[name].Record(latency)
+
+			status := result.Context.Response.Status
+			if byStatus[status] == nil {
+				byStatus[status] = histogram.New()
 			}
+			byStatus[status].Record(latency)
 
 			stats.TotalBytes += result.Context.Response.SizeBytes
 		}
@@ -292,8 +548,18 @@ func (o *Orchestrator) calculateStats(results []*Result) *Stats {
 		}
 	}
 
-	if stats.Total > 0 {
-		stats.AvgLatency = totalLatency / float64(stats.Total)
+	stats.Latency = overall.Summary()
+	stats.AvgLatency = stats.Latency.Mean
+	stats.MinLatency = stats.Latency.Min
+	stats.MaxLatency = stats.Latency.Max
+
+	stats.ByRequest = make(map[string]histogram.Summary, len(byRequest))
+	for name, h := range byRequest {
+		stats.ByRequest[name] = h.Summary()
+	}
+	stats.ByStatus = make(map[int]histogram.Summary, len(byStatus))
+	for status, h := range byStatus {
+		stats.ByStatus[status] = h.Summary()
 	}
 
 	return stats
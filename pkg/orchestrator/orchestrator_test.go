@@ -0,0 +1,232 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vikasavnish/httptool/pkg/ir"
+)
+
+// The test binary doubles as a one-shot go evaluator: with
+// ORCHESTRATOR_EVALUATOR set it reads a context and prints a decision.
+func TestMain(m *testing.M) {
+	if mode := os.Getenv("ORCHESTRATOR_EVALUATOR"); mode != "" {
+		var ctx ir.EvaluationContext
+		json.NewDecoder(os.Stdin).Decode(&ctx)
+		json.NewEncoder(os.Stdout).Encode(decide(mode, &ctx))
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func decide(mode string, ctx *ir.EvaluationContext) *ir.EvaluatorDecision {
+	branch := func(target string, mutations *ir.Mutations) *ir.EvaluatorDecision {
+		return &ir.EvaluatorDecision{
+			Decision:  "branch",
+			Reason:    "goto " + target,
+			Mutations: mutations,
+			Actions:   &ir.Actions{Goto: target},
+		}
+	}
+
+	switch mode {
+	case "auth":
+		// Log in when the orders call is unauthorized, then go back to it
+		switch {
+		case ctx.Response.Status == http.StatusUnauthorized:
+			return branch("login", &ir.Mutations{Vars: map[string]any{"return_to": "orders"}})
+		case strings.HasSuffix(ctx.Request.URL, "/login"):
+			token := ctx.Response.Body.(map[string]any)["token"].(string)
+			return branch(ctx.Vars["return_to"].(string), &ir.Mutations{
+				Headers: map[string]string{"Authorization": "Bearer " + token},
+			})
+		}
+	case "pingpong":
+		if strings.HasSuffix(ctx.Request.URL, "/a") {
+			return branch("b", nil)
+		}
+		return branch("a", nil)
+	case "extract":
+		// Log in once and hand the token to later steps
+		if strings.HasSuffix(ctx.Request.URL, "/login") {
+			return &ir.EvaluatorDecision{
+				Decision: "pass",
+				Actions: &ir.Actions{Extract: map[string]ir.ExtractRule{
+					"token": {JSONPath: "$.token"},
+					"scope": {JSONPath: "$.scope", Default: "read"},
+				}},
+			}
+		}
+		if ctx.Vars["token"] != "secret" || ctx.Vars["scope"] != "read" {
+			return &ir.EvaluatorDecision{Decision: "fail", Reason: fmt.Sprintf("vars %v", ctx.Vars)}
+		}
+	case "count":
+		n, _ := ctx.Vars["n"].(float64)
+		return branch("a", &ir.Mutations{Vars: map[string]any{"n": n + 1}})
+	}
+	return &ir.EvaluatorDecision{Decision: "pass", Reason: "ok"}
+}
+
+func newServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"token":"secret"}`)
+		case "/orders":
+			if r.Header.Get("Authorization") != "Bearer secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func step(server *httptest.Server, path string) *ir.IR {
+	evaluation := ir.DefaultEvaluation()
+	evaluation.Evaluator = "go"
+	evaluation.EvaluatorPath = os.Args[0]
+	return &ir.IR{
+		Version:    "1.0",
+		Metadata:   &ir.Metadata{ID: strings.TrimPrefix(path, "/")},
+		Request:    ir.Request{Method: "GET", URL: server.URL + path},
+		Transport:  ir.DefaultTransport(),
+		Evaluation: evaluation,
+	}
+}
+
+func TestExecuteOne_Branch(t *testing.T) {
+	t.Setenv("ORCHESTRATOR_EVALUATOR", "auth")
+	server := newServer(t)
+
+	o := NewOrchestrator(3, 5*time.Second)
+	o.RegisterSteps(map[string]*ir.IR{
+		"login":  step(server, "/login"),
+		"orders": step(server, "/orders"),
+	})
+
+	result, err := o.ExecuteOne(context.Background(), step(server, "/orders"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(result.Branches, ","); got != "login,orders" {
+		t.Errorf("branches = %s", got)
+	}
+	if result.Context.Response.Status != http.StatusOK || result.Decision.Decision != "pass" {
+		t.Errorf("final response %d, decision %s", result.Context.Response.Status, result.Decision.Decision)
+	}
+	if result.Context.Vars["return_to"] != "orders" {
+		t.Errorf("vars were not carried over: %v", result.Context.Vars)
+	}
+
+	var steps []string
+	for _, s := range result.Steps() {
+		steps = append(steps, fmt.Sprintf("%s %s %v", s.Context.Request.URL[len(server.URL):], s.Decision.Decision, s.Branches))
+	}
+	if got := strings.Join(steps, ", "); got != "/orders branch [login], /login branch [orders], /orders pass []" {
+		t.Errorf("steps = %s", got)
+	}
+}
+
+func TestExecuteOne_BranchLoop(t *testing.T) {
+	t.Setenv("ORCHESTRATOR_EVALUATOR", "pingpong")
+	server := newServer(t)
+
+	o := NewOrchestrator(3, 5*time.Second)
+	o.RegisterSteps(map[string]*ir.IR{
+		"a": step(server, "/a"),
+		"b": step(server, "/b"),
+	})
+
+	_, err := o.ExecuteOne(context.Background(), step(server, "/a"))
+	if err == nil || err.Error() != "branch loop detected: b -> a" {
+		t.Errorf("expected loop error, got %v", err)
+	}
+
+	// A start IR without the registered ID is still recognized as step a
+	start := step(server, "/a")
+	start.Metadata = nil
+	_, err = o.ExecuteOne(context.Background(), start)
+	if err == nil || err.Error() != "branch loop detected: b -> a" {
+		t.Errorf("expected loop error without ID, got %v", err)
+	}
+}
+
+func TestExecuteOne_BranchLimits(t *testing.T) {
+	t.Setenv("ORCHESTRATOR_EVALUATOR", "count")
+	server := newServer(t)
+
+	o := NewOrchestrator(3, 5*time.Second)
+	o.SetMaxHops(3)
+	o.RegisterSteps(map[string]*ir.IR{"a": step(server, "/a")})
+
+	result, err := o.ExecuteOne(context.Background(), step(server, "/a"))
+	if err == nil || !strings.Contains(err.Error(), "limit of 3 hops") {
+		t.Errorf("expected hop limit error, got %v", err)
+	}
+	if len(result.Branches) != 3 {
+		t.Errorf("followed %d hops, want 3", len(result.Branches))
+	}
+
+	o = NewOrchestrator(3, 5*time.Second)
+	if _, err := o.ExecuteOne(context.Background(), step(server, "/a")); err == nil || !strings.Contains(err.Error(), "'a' not found") {
+		t.Errorf("expected unknown target error, got %v", err)
+	}
+}
+
+func TestReplay_Extract(t *testing.T) {
+	t.Setenv("ORCHESTRATOR_EVALUATOR", "extract")
+	server := newServer(t)
+
+	o := NewOrchestrator(3, 5*time.Second)
+	results, stats := o.Replay(context.Background(), []*ir.IR{
+		step(server, "/login"),
+		step(server, "/orders"),
+	})
+
+	if stats.Success != 2 {
+		for _, r := range results {
+			t.Logf("%s: %v", r.IR.Request.URL, r.Error)
+		}
+		t.Fatalf("%d of 2 steps passed", stats.Success)
+	}
+	if results[0].Extracted["token"] != "secret" {
+		t.Errorf("extracted = %v", results[0].Extracted)
+	}
+}
+
+func TestExecuteOne_Schema(t *testing.T) {
+	t.Setenv("ORCHESTRATOR_EVALUATOR", "pass")
+	server := newServer(t)
+
+	schema := filepath.Join(t.TempDir(), "login.schema.json")
+	os.WriteFile(schema, []byte(`{"type": "object", "required": ["token"], "properties": {"token": {"type": "string", "minLength": 6}}}`), 0o644)
+
+	o := NewOrchestrator(1, 5*time.Second)
+
+	login := step(server, "/login")
+	login.Evaluation.Schema = schema
+	if result, err := o.ExecuteOne(context.Background(), login); err != nil {
+		t.Errorf("valid body failed: %v (%+v)", err, result.Decision)
+	}
+
+	orders := step(server, "/orders")
+	orders.Evaluation.Schema = schema
+	result, err := o.ExecuteOne(context.Background(), orders)
+	want := "evaluation failed: response does not match schema: (root): expected object, got string"
+	if err == nil || err.Error() != want {
+		t.Fatalf("error = %v, want %s", err, want)
+	}
+	if result.Decision.Decision != "fail" || result.Decision.Metadata["schema"] != schema {
+		t.Errorf("decision = %+v", result.Decision)
+	}
+}
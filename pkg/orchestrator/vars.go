@@ -0,0 +1,112 @@
+package orchestrator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/vikasavnish/httptool/pkg/ir"
+)
+
+var varPattern = regexp.MustCompile(`\$\{([\w.-]+)\}`)
+
+// resolveVars returns the IR to send: a copy with ${name} references in the
+// request replaced by the IR's evaluation vars. Dots follow into objects, as
+// in ${user.id}. Unknown names are left as written. An IR without references
+// is returned as is.
+func resolveVars(irSpec *ir.IR) *ir.IR {
+	if irSpec.Evaluation == nil || len(irSpec.Evaluation.Vars) == 0 {
+		return irSpec
+	}
+	data, _ := json.Marshal(irSpec.Request)
+	if !bytes.Contains(data, []byte("${")) {
+		return irSpec
+	}
+
+	resolved := cloneIR(irSpec)
+	vars := irSpec.Evaluation.Vars
+	req := &resolved.Request
+
+	req.URL = replaceVars(req.URL, vars)
+	for k, v := range req.Headers {
+		req.Headers[k] = replaceVars(v, vars)
+	}
+	for k, v := range req.Query {
+		req.Query[k] = resolveValue(v, vars)
+	}
+	for k, v := range req.Cookies {
+		req.Cookies[k] = replaceVars(v, vars)
+	}
+	if auth := req.Auth; auth != nil {
+		auth.Token = replaceVars(auth.Token, vars)
+		auth.Username = replaceVars(auth.Username, vars)
+		auth.Password = replaceVars(auth.Password, vars)
+	}
+	if req.Body != nil {
+		req.Body.Content = resolveValue(req.Body.Content, vars)
+	}
+
+	return resolved
+}
+
+// resolveValue replaces references throughout a decoded JSON value. A string
+// that is nothing but one reference takes the var's value with its type, so
+// {"id": "${user_id}"} sends a number when user_id is one.
+func resolveValue(value any, vars map[string]any) any {
+	switch v := value.(type) {
+	case string:
+		if m := varPattern.FindStringSubmatch(v); m != nil && m[0] == v {
+			if resolved, ok := lookupVar(vars, m[1]); ok {
+				return resolved
+			}
+		}
+		return replaceVars(v, vars)
+	case map[string]any:
+		for k, item := range v {
+			v[k] = resolveValue(item, vars)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = resolveValue(item, vars)
+		}
+	}
+	return value
+}
+
+func replaceVars(s string, vars map[string]any) string {
+	if !strings.Contains(s, "${") {
+		return s
+	}
+	return varPattern.ReplaceAllStringFunc(s, func(match string) string {
+		if value, ok := lookupVar(vars, match[2:len(match)-1]); ok {
+			return fmt.Sprintf("%v", value)
+		}
+		return match
+	})
+}
+
+// lookupVar resolves a name, following dots into objects when the full name
+// is not set
+func lookupVar(vars map[string]any, name string) (any, bool) {
+	if value, ok := vars[name]; ok {
+		return value, true
+	}
+
+	parts := strings.Split(name, ".")
+	current, ok := vars[parts[0]]
+	if !ok {
+		return nil, false
+	}
+	for _, part := range parts[1:] {
+		m, ok := current.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if current, ok = m[part]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
@@ -1,5 +1,7 @@
 package parser
 
+import "fmt"
+
 // Node is the base interface for all AST nodes
 type Node interface {
 	TokenLiteral() string
@@ -12,6 +14,11 @@ type Position struct {
 	Column int
 }
 
+// String returns the position formatted as line:column
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
 // Statement represents a statement node
 type Statement interface {
 	Node
@@ -69,6 +76,7 @@ type RequestDeclaration struct {
 	Assertions  []*Assertion
 	Extractions []*Extraction
 	RetryConfig *RetryConfig
+	Evaluator   *EvaluatorConfig
 	Pos         Position
 }
 
@@ -81,6 +89,8 @@ type ScenarioDeclaration struct {
 	Name       string
 	LoadConfig *LoadConfig
 	Flow       []FlowStatement
+	Thresholds []*Threshold
+	Cookies    string // shared or per_vu, from `cookies shared`; empty means per_vu
 	Pos        Position
 }
 
@@ -88,6 +98,38 @@ func (s *ScenarioDeclaration) TokenLiteral() string { return "scenario" }
 func (s *ScenarioDeclaration) Position() Position   { return s.Pos }
 func (s *ScenarioDeclaration) statementNode()       {}
 
+// Threshold represents one entry of a thresholds { ... } block
+type Threshold struct {
+	Expr        string // e.g. "p95(latency) < 300ms"
+	AbortOnFail bool
+	AbortDelay  string // Duration to wait before an abort can happen
+	Pos         Position
+}
+
+// LifecycleDeclaration represents a setup { ... } or teardown { ... } block
+type LifecycleDeclaration struct {
+	Phase string // "setup" or "teardown"
+	Flow  []FlowStatement
+	Pos   Position
+}
+
+func (l *LifecycleDeclaration) TokenLiteral() string { return l.Phase }
+func (l *LifecycleDeclaration) Position() Position   { return l.Pos }
+func (l *LifecycleDeclaration) statementNode()       {}
+
+// DataDeclaration represents: data name = [ ... ] or data name = file("path")
+type DataDeclaration struct {
+	Name   string
+	Inline string // Raw inline array source, when declared inline
+	File   string // File path, when declared with file(...)
+	Mode   string // Row assignment from "with <mode>", empty for default
+	Pos    Position
+}
+
+func (d *DataDeclaration) TokenLiteral() string { return "data" }
+func (d *DataDeclaration) Position() Position   { return d.Pos }
+func (d *DataDeclaration) statementNode()       {}
+
 // =========================================
 // Curl Command
 // =========================================
@@ -101,6 +143,7 @@ type CurlCommand struct {
 	Body        string              // Body from -d flag
 	Cookies     map[string]string   // Cookies from -b flag
 	RawArgs     []string            // All raw arguments
+	Raw         string              // Source text of the command, continuations joined
 	Pos         Position
 }
 
@@ -117,12 +160,26 @@ type LoadConfig struct {
 	RPS        int
 	Iterations int
 	Duration   string
+	RampUp     string
+	MaxVUs     int
+	Stages     []*Stage
 	Pos        Position
 }
 
 func (l *LoadConfig) TokenLiteral() string { return "load" }
 func (l *LoadConfig) Position() Position   { return l.Pos }
 
+// Stage represents one load stage: ramp to VUs (or RPS) over Duration
+type Stage struct {
+	Duration string
+	VUs      int
+	RPS      int
+	Pos      Position
+}
+
+func (s *Stage) TokenLiteral() string { return "stage" }
+func (s *Stage) Position() Position   { return s.Pos }
+
 // =========================================
 // Extraction
 // =========================================
@@ -154,9 +211,10 @@ func (e *Extraction) Position() Position   { return e.Pos }
 // Assertion represents an assertion
 type Assertion struct {
 	Field    string
-	Operator string
-	Value    Expression
+	Operator string       // ==, <, contains, matches, in, exists, not in, ...; empty when Field is a boolean expression
+	Value    Expression   // single-token values only
 	Values   []Expression // for 'in' operator
+	Raw      string       // value as written, e.g. /^\d+$/ or [200, 201]
 	Pos      Position
 }
 
@@ -172,12 +230,26 @@ type RetryConfig struct {
 	MaxAttempts int
 	Backoff     string
 	BaseDelay   string
+	MaxDelay    string
+	Jitter      string   // true or false
+	On          []string // What to retry: status codes, 4xx/5xx, network, assertion, retry_after
 	Pos         Position
 }
 
 func (r *RetryConfig) TokenLiteral() string { return "retry" }
 func (r *RetryConfig) Position() Position   { return r.Pos }
 
+// EvaluatorConfig names the external evaluator that judges a request, as in
+// `evaluate bun ./checks/auth.js`
+type EvaluatorConfig struct {
+	Type string // bun, python or go
+	Path string
+	Pos  Position
+}
+
+func (e *EvaluatorConfig) TokenLiteral() string { return "evaluate" }
+func (e *EvaluatorConfig) Position() Position   { return e.Pos }
+
 // =========================================
 // Flow Statements
 // =========================================
@@ -219,6 +291,17 @@ func (n *NestedFlow) TokenLiteral() string { return "run" }
 func (n *NestedFlow) Position() Position   { return n.Pos }
 func (n *NestedFlow) flowNode()            {}
 
+// ParallelFlow represents: run parallel max 2 { a, b -> c, run d { run e } }
+type ParallelFlow struct {
+	Branches []FlowStatement // Each runs concurrently with the others
+	Max      int             // Most branches running at once, 0 for all
+	Pos      Position
+}
+
+func (p *ParallelFlow) TokenLiteral() string { return "parallel" }
+func (p *ParallelFlow) Position() Position   { return p.Pos }
+func (p *ParallelFlow) flowNode()            {}
+
 // ConditionalFlow represents: if condition { ... } else { ... }
 type ConditionalFlow struct {
 	Condition *Condition
@@ -231,6 +314,28 @@ func (c *ConditionalFlow) TokenLiteral() string { return "if" }
 func (c *ConditionalFlow) Position() Position   { return c.Pos }
 func (c *ConditionalFlow) flowNode()            {}
 
+// ForEachFlow represents: for each item in data { ... }
+type ForEachFlow struct {
+	ItemVar  string
+	DataName string
+	Body     []FlowStatement
+	Pos      Position
+}
+
+func (f *ForEachFlow) TokenLiteral() string { return "for" }
+func (f *ForEachFlow) Position() Position   { return f.Pos }
+func (f *ForEachFlow) flowNode()            {}
+
+// ThinkStatement represents: think 2s
+type ThinkStatement struct {
+	Duration string
+	Pos      Position
+}
+
+func (t *ThinkStatement) TokenLiteral() string { return "think" }
+func (t *ThinkStatement) Position() Position   { return t.Pos }
+func (t *ThinkStatement) flowNode()            {}
+
 // =========================================
 // Expressions
 // =========================================
@@ -304,13 +409,12 @@ func (b *BooleanLiteral) expressionNode()    {}
 // Condition
 // =========================================
 
-// Condition represents a boolean condition
+// Condition is the boolean expression of an if statement, kept as source
+// text, e.g. status == 200 && ${role} in ["admin", "ops"]
 type Condition struct {
-	Left     Expression
-	Operator string
-	Right    Expression
-	Pos      Position
+	Expr string
+	Pos  Position
 }
 
-func (c *Condition) TokenLiteral() string { return c.Operator }
+func (c *Condition) TokenLiteral() string { return c.Expr }
 func (c *Condition) Position() Position   { return c.Pos }
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -157,6 +158,31 @@ func (p *CurlParser) Parse(curlCmd string) (*ir.IR, error) {
 				}
 				i++
 
+			case "--keepalive-time":
+				if i >= len(tokens) {
+					return nil, fmt.Errorf("missing value for %s", flag)
+				}
+				seconds, err := strconv.ParseFloat(tokens[i], 64)
+				if err != nil || seconds < 0 {
+					return nil, fmt.Errorf("invalid value for %s: %s", flag, tokens[i])
+				}
+				result.Transport.KeepAliveMs = int(seconds * 1000)
+				i++
+
+			case "--no-keepalive":
+				// Like curl, this turns off TCP keep-alive probes; connections
+				// are still reused
+				result.Transport.KeepAliveMs = -1
+
+			case "--http1.0", "--http1.1":
+				result.Transport.Protocol = "http1"
+
+			case "--http2":
+				result.Transport.Protocol = "http2"
+
+			case "--http2-prior-knowledge":
+				result.Transport.Protocol = "h2c"
+
 			case "-G", "--get":
 				result.Request.Method = "GET"
 
@@ -327,9 +353,12 @@ func extractQueryParams(req *ir.Request) error {
 			}
 		}
 
-		// Remove query from URL
-		parsedURL.RawQuery = ""
-		req.URL = parsedURL.String()
+		// Remove query from URL. Cutting the text rather than re-encoding the
+		// parsed URL keeps ${var} references in the path intact.
+		req.URL, _, _ = strings.Cut(req.URL, "?")
+		if parsedURL.Fragment != "" {
+			req.URL += "#" + parsedURL.EscapedFragment()
+		}
 	}
 
 	return nil
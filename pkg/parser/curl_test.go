@@ -0,0 +1,20 @@
+package parser
+
+import "testing"
+
+func TestCurlParser_KeepAliveTime(t *testing.T) {
+	got, err := NewCurlParser().Parse("curl --keepalive-time 1.5 https://api.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Transport.KeepAliveMs != 1500 {
+		t.Errorf("keep-alive %dms, want 1500ms", got.Transport.KeepAliveMs)
+	}
+
+	for _, value := range []string{"soon", "-1"} {
+		_, err := NewCurlParser().Parse("curl --keepalive-time " + value + " https://api.example.com")
+		if err == nil || err.Error() != "invalid value for --keepalive-time: "+value {
+			t.Errorf("%s: got error %v", value, err)
+		}
+	}
+}
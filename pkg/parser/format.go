@@ -0,0 +1,165 @@
+package parser
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/vikasavnish/httptool/pkg/ir"
+)
+
+// FormatCurl renders an IR as a curl command that CurlParser reads back to
+// the same request: the method and URL on the first line, then one flag per
+// line joined by backslash continuations, so it can be pasted into a .httpx
+// request block. Arguments are single-quoted
+// with backslash escapes, as the curl and .httpx tokenizers expect.
+func FormatCurl(irSpec *ir.IR) string {
+	req := &irSpec.Request
+	first := "curl"
+
+	method := req.Method
+	if method == "" {
+		method = "GET"
+	}
+	if method != "GET" || req.Body != nil {
+		first += " -X " + method
+	}
+	args := []string{first + " " + quoteArg(requestURL(req))}
+
+	for _, key := range sortedKeys(req.Headers) {
+		args = append(args, "-H "+quoteArg(key+": "+req.Headers[key]))
+	}
+
+	if len(req.Cookies) > 0 {
+		var pairs []string
+		for _, name := range sortedKeys(req.Cookies) {
+			pairs = append(pairs, name+"="+req.Cookies[name])
+		}
+		args = append(args, "-b "+quoteArg(strings.Join(pairs, "; ")))
+	}
+
+	if auth := req.Auth; auth != nil {
+		switch auth.Type {
+		case "basic":
+			args = append(args, "-u "+quoteArg(auth.Username+":"+auth.Password))
+		case "bearer":
+			args = append(args, "-H "+quoteArg("Authorization: Bearer "+auth.Token))
+		}
+	}
+
+	if data, flag := bodyArg(req.Body); flag != "" {
+		args = append(args, flag+" "+quoteArg(data))
+	}
+
+	if t := irSpec.Transport; t != nil {
+		if !t.TLSVerify {
+			args = append(args, "-k")
+		}
+		switch t.Protocol {
+		case "http1":
+			args = append(args, "--http1.1")
+		case "http2":
+			args = append(args, "--http2")
+		case "h2c":
+			args = append(args, "--http2-prior-knowledge")
+		}
+		if t.TimeoutMs > 0 && t.TimeoutMs != ir.DefaultTransport().TimeoutMs {
+			args = append(args, fmt.Sprintf("-m %g", float64(t.TimeoutMs)/1000))
+		}
+	}
+
+	return strings.Join(args, " \\\n  ")
+}
+
+// requestURL returns the URL with the IR's query parameters added back
+func requestURL(req *ir.Request) string {
+	if len(req.Query) == 0 {
+		return req.URL
+	}
+	u, err := url.Parse(req.URL)
+	if err != nil {
+		return req.URL
+	}
+
+	q := u.Query()
+	for key, value := range req.Query {
+		switch v := value.(type) {
+		case []string:
+			for _, item := range v {
+				q.Add(key, item)
+			}
+		case []any:
+			for _, item := range v {
+				q.Add(key, fmt.Sprintf("%v", item))
+			}
+		default:
+			q.Add(key, fmt.Sprintf("%v", v))
+		}
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// bodyArg renders a body as the data flag that parses back to it
+func bodyArg(body *ir.Body) (data string, flag string) {
+	if body == nil {
+		return "", ""
+	}
+
+	switch body.Type {
+	case "json":
+		encoded, err := json.Marshal(body.Content)
+		if err != nil {
+			return "", ""
+		}
+		return string(encoded), "-d"
+	case "form":
+		values := url.Values{}
+		switch content := body.Content.(type) {
+		case map[string]string:
+			for k, v := range content {
+				values.Set(k, v)
+			}
+		case map[string]any:
+			for k, v := range content {
+				values.Set(k, fmt.Sprintf("%v", v))
+			}
+		}
+		// Keep ${var} references readable so .httpx variables still apply
+		return escapedVarRef.ReplaceAllString(values.Encode(), "$${$1}"), "-d"
+	case "binary":
+		decoded, err := base64.StdEncoding.DecodeString(body.ContentBase64)
+		if err != nil {
+			return "", ""
+		}
+		return string(decoded), "--data-binary"
+	default:
+		if text, ok := body.Content.(string); ok {
+			return text, "--data-raw"
+		}
+		return "", ""
+	}
+}
+
+// escapedVarRef matches a ${var} reference after URL encoding
+var escapedVarRef = regexp.MustCompile(`%24%7B([\w.-]+)%7D`)
+
+// quoteArg single-quotes an argument, escaping backslashes and quotes
+func quoteArg(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `'`, `\'`)
+	return "'" + s + "'"
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,54 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/vikasavnish/httptool/pkg/ir"
+)
+
+func TestFormatCurl_RoundTrip(t *testing.T) {
+	transport := ir.DefaultTransport()
+	transport.TLSVerify = false
+	transport.Protocol = "http2"
+
+	spec := &ir.IR{
+		Request: ir.Request{
+			Method:  "POST",
+			URL:     "https://api.example.com/users",
+			Headers: map[string]string{"Content-Type": "application/json", "X-Note": `it's "quoted"`},
+			Query:   map[string]any{"page": "2"},
+			Cookies: map[string]string{"sid": "abc"},
+			Auth:    &ir.Auth{Type: "basic", Username: "ada", Password: "s3cr3t"},
+			Body:    &ir.Body{Type: "json", Content: map[string]any{"path": `C:\tmp`, "name": "O'Brien"}},
+		},
+		Transport: transport,
+	}
+
+	cmd := FormatCurl(spec)
+	got, err := NewCurlParser().Parse(cmd)
+	if err != nil {
+		t.Fatalf("parse %s: %v", cmd, err)
+	}
+
+	want := spec.Request
+	if got.Request.Method != want.Method || got.Request.URL != want.URL {
+		t.Errorf("request line %s %s\n%s", got.Request.Method, got.Request.URL, cmd)
+	}
+	for _, field := range []struct {
+		name      string
+		got, want any
+	}{
+		{"headers", got.Request.Headers, want.Headers},
+		{"query", got.Request.Query, want.Query},
+		{"cookies", got.Request.Cookies, want.Cookies},
+		{"auth", got.Request.Auth, want.Auth},
+		{"body", got.Request.Body, want.Body},
+		{"tls verify", got.Transport.TLSVerify, false},
+		{"protocol", got.Transport.Protocol, "http2"},
+	} {
+		if !reflect.DeepEqual(field.got, field.want) {
+			t.Errorf("%s = %#v, want %#v\n%s", field.name, field.got, field.want, cmd)
+		}
+	}
+}
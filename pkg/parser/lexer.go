@@ -14,19 +14,54 @@ type Lexer struct {
 	line         int  // current line number
 	column       int  // current column number
 	inCurl       bool // true when inside curl command
+	lineStarts   []int
 }
 
 // NewLexer creates a new lexer for the given input
 func NewLexer(input string) *Lexer {
 	l := &Lexer{
-		input:  input,
-		line:   1,
-		column: 0,
+		input:      input,
+		line:       1,
+		column:     0,
+		lineStarts: []int{0},
+	}
+	for i := 0; i < len(input); i++ {
+		if input[i] == '\n' {
+			l.lineStarts = append(l.lineStarts, i+1)
+		}
 	}
 	l.readChar()
 	return l
 }
 
+// offset converts a token's line/column into a byte offset in the input
+func (l *Lexer) offset(tok Token) int {
+	if tok.Line < 1 || tok.Line > len(l.lineStarts) {
+		return len(l.input)
+	}
+	pos := l.lineStarts[tok.Line-1] + tok.Column - 1
+	if pos < 0 {
+		return 0
+	}
+	if pos > len(l.input) {
+		return len(l.input)
+	}
+	return pos
+}
+
+// slice returns the raw source text from the start of one token up to the
+// start of another
+func (l *Lexer) slice(from, to Token) string {
+	start, end := l.offset(from), l.offset(to)
+	if to.Type == EOF {
+		end = len(l.input)
+	}
+	if end < start {
+		return ""
+	}
+	return l.input[start:end]
+}
+
 // readChar reads the next character and advances position
 func (l *Lexer) readChar() {
 	if l.readPosition >= len(l.input) {
@@ -166,6 +201,9 @@ func (l *Lexer) NextToken() Token {
 	case ':':
 		tok.Type = COLON
 		tok.Literal = string(l.ch)
+	case ';':
+		tok.Type = SEMICOLON
+		tok.Literal = string(l.ch)
 	case '\\':
 		tok.Type = BACKSLASH
 		tok.Literal = string(l.ch)
@@ -218,12 +256,20 @@ func (l *Lexer) NextToken() Token {
 // readCurlArg reads a curl argument/flag
 func (l *Lexer) readCurlArg() Token {
 	var tok Token
-	tok.Line = l.line
-	tok.Column = l.column
 
 	// Skip whitespace
 	l.skipWhitespace()
 
+	tok.Line = l.line
+	tok.Column = l.column
+
+	// End of input also ends the curl command
+	if l.ch == 0 {
+		l.inCurl = false
+		tok.Type = EOF
+		return tok
+	}
+
 	// Check for newline without backslash - end of curl command
 	if l.ch == '\n' {
 		l.inCurl = false
@@ -236,18 +282,21 @@ func (l *Lexer) readCurlArg() Token {
 	}
 
 	// Check for backslash-newline continuation
-	if l.ch == '\\' && l.peekChar() == '\n' {
+	if l.atLineContinuation() {
 		l.readChar() // skip backslash
-		l.readChar() // skip newline
+		if l.ch == '\r' {
+			l.readChar()
+		}
 		l.line++
 		l.column = 0
+		l.readChar() // skip newline
 		// Continue reading curl args
 		return l.readCurlArg()
 	}
 
 	// Check for 'assert', 'extract', 'retry' - these end curl mode
 	if isLetter(l.ch) {
-		pos := l.position
+		pos, col := l.position, l.column
 		ident := l.readIdentifier()
 		tokType := LookupIdent(ident)
 
@@ -264,6 +313,7 @@ func (l *Lexer) readCurlArg() Token {
 		// Reset and read as curl arg
 		l.position = pos
 		l.readPosition = pos + 1
+		l.column = col
 		l.ch = l.input[l.position]
 	}
 
@@ -285,6 +335,14 @@ func (l *Lexer) readCurlArg() Token {
 	return tok
 }
 
+// atLineContinuation reports whether the lexer is on a backslash that ends the line
+func (l *Lexer) atLineContinuation() bool {
+	if l.ch != '\\' {
+		return false
+	}
+	return l.peekChar() == '\n' || (l.peekChar() == '\r' && l.peekAhead(2) == '\n')
+}
+
 // readCurlRawArg reads a raw curl argument
 func (l *Lexer) readCurlRawArg() string {
 	position := l.position
@@ -301,11 +359,15 @@ func (l *Lexer) readCurlRawArg() string {
 func (l *Lexer) readCurlComplexArg() string {
 	position := l.position
 
-	for l.ch != 0 && l.ch != '\n' && l.ch != '\\' && !unicode.IsSpace(rune(l.ch)) {
+	for l.ch != 0 && l.ch != '\n' && !unicode.IsSpace(rune(l.ch)) {
 		// Stop at variable reference - let it be read separately
 		if l.ch == '$' && l.peekChar() == '{' {
 			break
 		}
+		// Stop at line continuation
+		if l.atLineContinuation() {
+			break
+		}
 		l.readChar()
 	}
 
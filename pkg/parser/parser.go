@@ -86,6 +86,86 @@ func (p *Parser) skipCommentsAndNewlines() {
 	}
 }
 
+// skipToLineEnd advances to the end of the current line or block
+func (p *Parser) skipToLineEnd() {
+	for !p.currentTokenIs(NEWLINE) && !p.currentTokenIs(RBRACE) && !p.currentTokenIs(EOF) {
+		p.nextToken()
+	}
+}
+
+// skipStatement skips the rest of an unrecognized statement, including any
+// block it opens
+func (p *Parser) skipStatement() {
+	depth := 0
+	for !p.currentTokenIs(EOF) {
+		switch p.currentToken.Type {
+		case LBRACE:
+			depth++
+		case RBRACE:
+			if depth == 0 {
+				return
+			}
+			depth--
+			if depth == 0 {
+				p.nextToken()
+				return
+			}
+		case NEWLINE:
+			if depth == 0 {
+				return
+			}
+		}
+		p.nextToken()
+	}
+}
+
+// describe returns a readable name for a token in error messages
+func describe(tok Token) string {
+	if tok.Type == IDENT {
+		return fmt.Sprintf("'%s'", tok.Literal)
+	}
+	return tokenTypeNames[tok.Type]
+}
+
+// readRaw consumes tokens until stop matches one outside of brackets and
+// returns the source text they span. Newlines, comments and EOF always stop.
+// Leaves the parser on the terminating token.
+func (p *Parser) readRaw(stop func(Token) bool) string {
+	start := p.currentToken
+	depth := 0
+
+	for !p.currentTokenIs(NEWLINE) && !p.currentTokenIs(COMMENT) && !p.currentTokenIs(EOF) {
+		if depth == 0 && stop(p.currentToken) {
+			break
+		}
+
+		// Brackets inside a /regex/, as in /x[/]/ or /a{2}/, do not nest
+		if !inRegex(p.lexer.slice(start, p.currentToken)) {
+			switch p.currentToken.Type {
+			case LBRACE, LPAREN, LBRACKET:
+				depth++
+			case RBRACE, RPAREN, RBRACKET:
+				if depth > 0 {
+					depth--
+				}
+			}
+		}
+
+		p.nextToken()
+	}
+
+	return strings.TrimSpace(p.lexer.slice(start, p.currentToken))
+}
+
+// isWord reports whether a token is an identifier or keyword
+func isWord(tok Token) bool {
+	if tok.Type == IDENT {
+		return true
+	}
+	_, ok := keywords[tok.Literal]
+	return ok
+}
+
 // Parse parses the input and returns an AST
 func (p *Parser) Parse() *Program {
 	program := &Program{
@@ -122,6 +202,20 @@ func (p *Parser) parseStatement() Statement {
 		return p.parseScenarioDeclaration()
 	case COMMENT:
 		return p.parseComment()
+	case IDENT:
+		switch p.currentToken.Literal {
+		case "setup", "teardown":
+			if p.peekTokenIs(LBRACE) {
+				return p.parseLifecycleDeclaration()
+			}
+		case "req":
+			return p.parseShorthandRequest()
+		case "data":
+			return p.parseDataDeclaration()
+		}
+		p.error(fmt.Sprintf("unexpected token %s", describe(p.currentToken)))
+		p.skipStatement()
+		return nil
 	default:
 		p.error(fmt.Sprintf("unexpected token %s", tokenTypeNames[p.currentToken.Type]))
 		return nil
@@ -153,11 +247,108 @@ func (p *Parser) parseVariableDeclaration() *VariableDeclaration {
 	}
 
 	p.nextToken()
-	stmt.Value = p.parseExpression()
+
+	if p.peekTokenIs(NEWLINE) || p.peekTokenIs(EOF) || p.peekTokenIs(COMMENT) {
+		stmt.Value = p.parseExpression()
+		return stmt
+	}
+
+	// Unquoted compound values (env.API_KEY, a-b) are kept verbatim
+	pos := Position{Line: p.currentToken.Line, Column: p.currentToken.Column}
+	stmt.Value = &StringLiteral{
+		Value: p.readRaw(func(Token) bool { return false }),
+		Pos:   pos,
+	}
+
+	return stmt
+}
+
+// parseDataDeclaration parses:
+//
+//	data name = [ {...}, ... ] [with mode]
+//	data name = file("path") [with mode]
+func (p *Parser) parseDataDeclaration() *DataDeclaration {
+	stmt := &DataDeclaration{
+		Pos: Position{Line: p.currentToken.Line, Column: p.currentToken.Column},
+	}
+
+	if !p.expectPeek(IDENT) {
+		p.skipStatement()
+		return nil
+	}
+
+	stmt.Name = p.currentToken.Literal
+
+	if !p.expectPeek(ASSIGN) {
+		p.skipStatement()
+		return nil
+	}
+
+	p.nextToken()
+
+	switch {
+	case p.currentTokenIs(LBRACKET):
+		stmt.Inline = p.readBracketed()
+	case p.currentTokenIs(IDENT) && p.currentToken.Literal == "file":
+		if !p.expectPeek(LPAREN) || !p.expectPeek(STRING) {
+			p.skipStatement()
+			return nil
+		}
+		stmt.File = p.currentToken.Literal
+		if !p.expectPeek(RPAREN) {
+			p.skipStatement()
+			return nil
+		}
+		p.nextToken()
+	default:
+		p.error(fmt.Sprintf("expected '[' or file(...) for data '%s', got %s", stmt.Name, describe(p.currentToken)))
+		p.skipStatement()
+		return nil
+	}
+
+	if p.currentTokenIs(WITH) {
+		if !p.expectPeek(IDENT) {
+			p.skipStatement()
+			return nil
+		}
+		stmt.Mode = p.currentToken.Literal
+		p.nextToken()
+	}
+
+	if !p.currentTokenIs(NEWLINE) && !p.currentTokenIs(COMMENT) && !p.currentTokenIs(EOF) {
+		p.error(fmt.Sprintf("unexpected token after data '%s': %s", stmt.Name, describe(p.currentToken)))
+		p.skipStatement()
+	}
 
 	return stmt
 }
 
+// readBracketed consumes a bracketed value that may span several lines and
+// returns its source text. Leaves the parser on the token after the closing
+// bracket.
+func (p *Parser) readBracketed() string {
+	start := p.currentToken
+	depth := 0
+
+	for !p.currentTokenIs(EOF) {
+		switch p.currentToken.Type {
+		case LBRACE, LBRACKET:
+			depth++
+		case RBRACE, RBRACKET:
+			depth--
+		}
+
+		p.nextToken()
+
+		if depth == 0 {
+			return p.lexer.slice(start, p.currentToken)
+		}
+	}
+
+	p.error("unterminated '['")
+	return ""
+}
+
 // parseRequestDeclaration parses a request block
 func (p *Parser) parseRequestDeclaration() *RequestDeclaration {
 	stmt := &RequestDeclaration{
@@ -196,9 +387,16 @@ func (p *Parser) parseRequestDeclaration() *RequestDeclaration {
 			p.nextToken()
 		case NEWLINE:
 			p.nextToken()
+		case IDENT:
+			if p.currentToken.Literal != "evaluate" {
+				p.error(fmt.Sprintf("unexpected token in request block: %s", describe(p.currentToken)))
+				p.skipStatement()
+				break
+			}
+			stmt.Evaluator = p.parseEvaluator()
 		default:
-			p.error(fmt.Sprintf("unexpected token in request block: %s", tokenTypeNames[p.currentToken.Type]))
-			p.nextToken()
+			p.error(fmt.Sprintf("unexpected token in request block: %s", describe(p.currentToken)))
+			p.skipStatement()
 		}
 
 		p.skipCommentsAndNewlines()
@@ -217,6 +415,7 @@ func (p *Parser) parseCurlCommand() *CurlCommand {
 		URLParts: []Expression{},
 	}
 
+	start := p.currentToken
 	p.nextToken() // consume 'curl'
 
 	var urlBuilder strings.Builder
@@ -228,6 +427,15 @@ func (p *Parser) parseCurlCommand() *CurlCommand {
 		switch p.currentToken.Type {
 		case STRING:
 			arg := p.currentToken.Literal
+
+			// A pipe ends the command in shorthand requests
+			if arg == "|" {
+				cmd.Raw = joinContinuations(p.lexer.slice(start, p.currentToken))
+				cmd.URL = urlBuilder.String()
+				setDefaultMethod(cmd)
+				return cmd
+			}
+
 			cmd.RawArgs = append(cmd.RawArgs, arg)
 
 			// Parse curl flags
@@ -300,9 +508,15 @@ func (p *Parser) parseCurlCommand() *CurlCommand {
 		p.nextToken()
 	}
 
+	cmd.Raw = joinContinuations(p.lexer.slice(start, p.currentToken))
 	cmd.URL = urlBuilder.String()
+	setDefaultMethod(cmd)
+
+	return cmd
+}
 
-	// Default method
+// setDefaultMethod fills in the method curl would use when -X is absent
+func setDefaultMethod(cmd *CurlCommand) {
 	if cmd.Method == "" {
 		if cmd.Body != "" {
 			cmd.Method = "POST"
@@ -310,8 +524,13 @@ func (p *Parser) parseCurlCommand() *CurlCommand {
 			cmd.Method = "GET"
 		}
 	}
+}
 
-	return cmd
+// joinContinuations folds backslash-newline continuations into a single line
+func joinContinuations(raw string) string {
+	raw = strings.ReplaceAll(raw, "\\\r\n", " ")
+	raw = strings.ReplaceAll(raw, "\\\n", " ")
+	return strings.TrimSpace(raw)
 }
 
 // parseHeader parses a header string
@@ -338,34 +557,42 @@ func (p *Parser) parseCookies(cmd *CurlCommand, cookies string) {
 // parseAssertBlock parses assert block or single assertion
 func (p *Parser) parseAssertBlock() []*Assertion {
 	assertions := []*Assertion{}
-	pos := Position{Line: p.currentToken.Line, Column: p.currentToken.Column}
 
 	p.nextToken() // consume 'assert'
 
-	// Single line assertion: assert status == 200
+	// Inline assertions: assert status == 200, latency < 500ms
 	if !p.currentTokenIs(LBRACE) {
-		assertion := p.parseAssertion(pos)
-		if assertion != nil {
+		for {
+			assertion := p.parseAssertion()
+			if assertion == nil {
+				p.skipToLineEnd()
+				return assertions
+			}
 			assertions = append(assertions, assertion)
+
+			if !p.currentTokenIs(COMMA) {
+				return assertions
+			}
+			p.nextToken()
 		}
-		// parseAssertion leaves us on the value token
-		// Advance to move past it
-		p.nextToken()
-		return assertions
 	}
 
 	// Block assertion: assert { ... }
 	p.nextToken() // consume '{'
-	p.skipNewlines()
+	p.skipCommentsAndNewlines()
 
 	for !p.currentTokenIs(RBRACE) && !p.currentTokenIs(EOF) {
-		assertion := p.parseAssertion(pos)
+		assertion := p.parseAssertion()
 		if assertion != nil {
 			assertions = append(assertions, assertion)
+		} else {
+			p.skipToLineEnd()
 		}
 
-		p.nextToken() // advance past assertion value
-		p.skipNewlines()
+		if p.currentTokenIs(COMMA) {
+			p.nextToken()
+		}
+		p.skipCommentsAndNewlines()
 	}
 
 	// Consume the closing brace
@@ -376,140 +603,173 @@ func (p *Parser) parseAssertBlock() []*Assertion {
 	return assertions
 }
 
-// parseAssertion parses a single assertion
-func (p *Parser) parseAssertion(pos Position) *Assertion {
+// isAssertOperator reports whether a token can separate an assertion field from its value
+func isAssertOperator(tok Token) bool {
+	switch tok.Type {
+	case EQ, NOT_EQ, LT, GT, LTE, GTE, IN, ASSIGN:
+		return true
+	case IDENT:
+		switch tok.Literal {
+		case "contains", "matches", "exists", "not", "between":
+			return true
+		}
+	}
+	return false
+}
+
+// parseAssertion parses a single assertion and leaves the parser on the token
+// after it
+func (p *Parser) parseAssertion() *Assertion {
 	assertion := &Assertion{
-		Pos: pos,
+		Pos: Position{Line: p.currentToken.Line, Column: p.currentToken.Column},
 	}
 
-	// Field (status, latency, body.field, etc.)
-	field := p.currentToken.Literal
+	// Field (status, latency, body.field, header.content-type, etc.)
+	assertion.Field = p.readRaw(func(t Token) bool {
+		return isAssertOperator(t) || t.Type == RBRACE || t.Type == COMMA
+	})
 
-	// Handle dotted paths like body.name
-	for p.peekTokenIs(DOT) {
-		p.nextToken() // consume field
-		p.nextToken() // consume dot
-		field += "." + p.currentToken.Literal
+	if assertion.Field == "" {
+		p.error("expected assertion field")
+		return nil
+	}
+	if !isAssertOperator(p.currentToken) {
+		// A boolean expression without a leading comparison, such as
+		// !(status >= 500) or body.active; the operator stays empty
+		return assertion
 	}
 
-	assertion.Field = field
-
+	// Operator, with "not" negating the word operator after it
+	assertion.Operator = p.currentToken.Literal
+	if p.currentToken.Literal == "not" {
+		p.nextToken()
+		if !p.currentTokenIs(IN) && !(p.currentTokenIs(IDENT) && isAssertOperator(p.currentToken)) {
+			p.error(fmt.Sprintf("expected contains, matches, exists or in after 'not', got %s", describe(p.currentToken)))
+			return nil
+		}
+		assertion.Operator = "not " + p.currentToken.Literal
+	}
 	p.nextToken()
 
-	// Operator
-	if p.currentTokenIs(IN) {
-		assertion.Operator = "in"
-		p.nextToken()
+	if assertion.Operator == "exists" || assertion.Operator == "not exists" {
+		return assertion
+	}
 
-		// Parse array: [200, 201, 204]
+	// Parse array: [200, 201, 204]
+	if assertion.Operator == "in" || assertion.Operator == "not in" {
 		if !p.currentTokenIs(LBRACKET) {
-			p.error("expected '[' after 'in'")
+			p.error(fmt.Sprintf("expected '[' after '%s'", assertion.Operator))
 			return nil
 		}
 
+		start := p.currentToken
 		p.nextToken()
 		assertion.Values = []Expression{}
 
-		for !p.currentTokenIs(RBRACKET) && !p.currentTokenIs(EOF) {
-			expr := p.parseExpression()
-			assertion.Values = append(assertion.Values, expr)
+		// Elements that span several tokens, like 1.5, are kept as raw text
+		for !p.currentTokenIs(RBRACKET) && !p.currentTokenIs(EOF) && !p.currentTokenIs(NEWLINE) {
+			elem := p.currentToken
+			if p.peekTokenIs(COMMA) || p.peekTokenIs(RBRACKET) {
+				assertion.Values = append(assertion.Values, p.parseExpression())
+				p.nextToken()
+			} else {
+				raw := p.readRaw(func(t Token) bool { return t.Type == COMMA || t.Type == RBRACKET })
+				assertion.Values = append(assertion.Values, &StringLiteral{
+					Value: raw,
+					Pos:   Position{Line: elem.Line, Column: elem.Column},
+				})
+			}
 
-			p.nextToken()
 			if p.currentTokenIs(COMMA) {
 				p.nextToken()
 			}
 		}
-		// Now on ']', don't consume it - let caller handle
-	} else {
-		// Binary operator
-		assertion.Operator = p.currentToken.Literal
-		p.nextToken()
+		if !p.currentTokenIs(RBRACKET) {
+			p.error(fmt.Sprintf("expected ']' to close the '%s' list", assertion.Operator))
+			return nil
+		}
+		p.nextToken() // consume ']'
+		assertion.Raw = strings.TrimSpace(p.lexer.slice(start, p.currentToken))
+		return assertion
+	}
+
+	// Other values are taken verbatim: 200, 1.5, 500ms, "text", /^\d+$/i.
+	// Commas inside a /regex/ do not end the assertion.
+	start := p.currentToken
+	stop := func(t Token) bool {
+		if t.Type != COMMA && t.Type != RBRACE {
+			return false
+		}
+		raw := strings.TrimSpace(p.lexer.slice(start, t))
+		return !strings.HasPrefix(raw, "/") || regexClosed(raw)
+	}
+	if stop(p.peekToken) || p.peekTokenIs(NEWLINE) || p.peekTokenIs(COMMENT) || p.peekTokenIs(EOF) {
 		assertion.Value = p.parseExpression()
-		// Now on the value token, don't consume - let caller handle
+	}
+	assertion.Raw = p.readRaw(stop)
+
+	if assertion.Raw == "" {
+		p.error(fmt.Sprintf("expected value after '%s %s'", assertion.Field, assertion.Operator))
+		return nil
 	}
 
 	return assertion
 }
 
-// parseExtractBlock parses extract block
+// inRegex reports whether raw ends inside a /regex/ it starts
+func inRegex(raw string) bool {
+	raw = strings.TrimSpace(raw)
+	return strings.HasPrefix(raw, "/") && !regexClosed(raw)
+}
+
+// regexClosed reports whether raw, starting with /, contains the closing /
+func regexClosed(raw string) bool {
+	for i := 1; i < len(raw); i++ {
+		switch raw[i] {
+		case '\\':
+			i++
+		case '/':
+			return true
+		}
+	}
+	return false
+}
+
+// parseExtractBlock parses extract block or inline extractions
 func (p *Parser) parseExtractBlock() []*Extraction {
 	extractions := []*Extraction{}
 
-	p.nextToken()
+	p.nextToken() // consume 'extract'
 
+	// Inline extractions: extract token = $.access_token, user_id = $.user.id
 	if !p.currentTokenIs(LBRACE) {
-		p.error("expected '{' after 'extract'")
-		return nil
-	}
-
-	p.nextToken()
-	p.skipNewlines()
-
-	for !p.currentTokenIs(RBRACE) && !p.currentTokenIs(EOF) {
-		if p.currentTokenIs(IDENT) {
-			extraction := &Extraction{
-				Variable: p.currentToken.Literal,
-				Pos:      Position{Line: p.currentToken.Line, Column: p.currentToken.Column},
+		for {
+			extraction := p.parseExtraction(true)
+			if extraction == nil {
+				p.skipToLineEnd()
+				return extractions
 			}
+			extractions = append(extractions, extraction)
 
-			if !p.expectPeek(ASSIGN) {
+			if !p.currentTokenIs(COMMA) {
 				return extractions
 			}
-
 			p.nextToken()
+		}
+	}
 
-			// Parse extraction path - could be dotted path like $.data.user.id
-			var pathBuilder strings.Builder
-
-			// Check for type prefix
-			if p.currentTokenIs(IDENT) {
-				literal := p.currentToken.Literal
-				pathBuilder.WriteString(literal)
-
-				// Check for type:value pattern (cookie:name, header:Authorization)
-				if p.peekTokenIs(COLON) {
-					p.nextToken() // move to COLON
-					pathBuilder.WriteString(":")
-					p.nextToken() // move to value after COLON
-					pathBuilder.WriteString(p.currentToken.Literal)
-				}
-			} else if p.currentTokenIs(DOLLAR) {
-				// JSONPath like $.data.user.id
-				pathBuilder.WriteString("$")
-
-				// Handle dotted path
-				for p.peekTokenIs(DOT) {
-					p.nextToken() // move to DOT
-					pathBuilder.WriteString(".")
-					p.nextToken() // move to IDENT after DOT
-					pathBuilder.WriteString(p.currentToken.Literal)
-				}
-			}
-
-			path := pathBuilder.String()
-
-			// Determine extraction type
-			if strings.HasPrefix(path, "$.") {
-				extraction.Type = ExtractJSONPath
-			} else if strings.HasPrefix(path, "regex:") {
-				extraction.Type = ExtractRegex
-				path = strings.TrimPrefix(path, "regex:")
-			} else if strings.HasPrefix(path, "header:") {
-				extraction.Type = ExtractHeader
-				path = strings.TrimPrefix(path, "header:")
-			} else if strings.HasPrefix(path, "cookie:") {
-				extraction.Type = ExtractCookie
-				path = strings.TrimPrefix(path, "cookie:")
-			} else {
-				extraction.Type = ExtractJSONPath
-			}
+	p.nextToken() // consume '{'
+	p.skipCommentsAndNewlines()
 
-			extraction.Path = path
+	for !p.currentTokenIs(RBRACE) && !p.currentTokenIs(EOF) {
+		extraction := p.parseExtraction(false)
+		if extraction != nil {
 			extractions = append(extractions, extraction)
+		} else {
+			p.skipToLineEnd()
 		}
 
-		p.nextToken()
-		p.skipNewlines()
+		p.skipCommentsAndNewlines()
 	}
 
 	// Consume the closing brace
@@ -520,6 +780,104 @@ func (p *Parser) parseExtractBlock() []*Extraction {
 	return extractions
 }
 
+// parseExtraction parses a single rule: name = path
+// Leaves the parser on the token that ended the path.
+func (p *Parser) parseExtraction(inline bool) *Extraction {
+	if !isWord(p.currentToken) {
+		p.error(fmt.Sprintf("expected variable name in extract, got %s", tokenTypeNames[p.currentToken.Type]))
+		return nil
+	}
+
+	extraction := &Extraction{
+		Variable: p.currentToken.Literal,
+		Pos:      Position{Line: p.currentToken.Line, Column: p.currentToken.Column},
+	}
+
+	if !p.expectPeek(ASSIGN) {
+		return nil
+	}
+
+	p.nextToken()
+
+	// Paths are taken verbatim from the source: $.items[0].id, header:X-Request-Id, ...
+	path := p.readRaw(func(t Token) bool {
+		return t.Type == RBRACE || (inline && (t.Type == COMMA || t.Type == PIPE))
+	})
+
+	if path == "" {
+		p.error(fmt.Sprintf("missing extraction path for '%s'", extraction.Variable))
+		return nil
+	}
+
+	// Determine extraction type
+	switch {
+	case strings.HasPrefix(path, "regex:"):
+		extraction.Type = ExtractRegex
+		path = strings.TrimPrefix(path, "regex:")
+	case strings.HasPrefix(path, "header:"):
+		extraction.Type = ExtractHeader
+		path = strings.TrimPrefix(path, "header:")
+	case strings.HasPrefix(path, "cookie:"):
+		extraction.Type = ExtractCookie
+		path = strings.TrimPrefix(path, "cookie:")
+	default:
+		extraction.Type = ExtractJSONPath
+	}
+
+	extraction.Path = unquote(strings.TrimSpace(path))
+	return extraction
+}
+
+// unquote strips one pair of matching surrounding quotes
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// parseEvaluator parses `evaluate <type> <path>`
+func (p *Parser) parseEvaluator() *EvaluatorConfig {
+	config := &EvaluatorConfig{
+		Pos: Position{Line: p.currentToken.Line, Column: p.currentToken.Column},
+	}
+
+	p.nextToken()
+	if !isWord(p.currentToken) {
+		p.error(fmt.Sprintf("expected evaluator type after 'evaluate', got %s", describe(p.currentToken)))
+		p.skipToLineEnd()
+		return nil
+	}
+	config.Type = p.currentToken.Literal
+
+	p.nextToken()
+	if p.currentTokenIs(STRING) && (p.peekTokenIs(NEWLINE) || p.peekTokenIs(COMMENT) || p.peekTokenIs(EOF)) {
+		config.Path = p.currentToken.Literal
+		p.nextToken()
+	} else {
+		config.Path = p.readRaw(func(Token) bool { return false })
+	}
+
+	if config.Path == "" {
+		p.error(fmt.Sprintf("expected evaluator path after 'evaluate %s'", config.Type))
+	}
+
+	return config
+}
+
+// splitValues splits a value such as [5xx, 429, network] or a single 503
+// into its items
+func splitValues(value string) []string {
+	value = strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 // parseRetryBlock parses retry configuration
 func (p *Parser) parseRetryBlock() *RetryConfig {
 	config := &RetryConfig{
@@ -534,43 +892,58 @@ func (p *Parser) parseRetryBlock() *RetryConfig {
 	}
 
 	p.nextToken()
-	p.skipNewlines()
+	p.skipCommentsAndNewlines()
 
 	for !p.currentTokenIs(RBRACE) && !p.currentTokenIs(EOF) {
-		if p.currentTokenIs(NEWLINE) {
+		if p.currentTokenIs(NEWLINE) || p.currentTokenIs(COMMENT) || p.currentTokenIs(SEMICOLON) {
 			p.nextToken()
 			continue
 		}
 
-		if p.currentTokenIs(IDENT) {
-			key := p.currentToken.Literal
+		if !isWord(p.currentToken) {
+			p.error(fmt.Sprintf("expected retry setting, got %s", describe(p.currentToken)))
+			p.skipToLineEnd()
+			continue
+		}
 
-			if !p.expectPeek(ASSIGN) {
-				return config
-			}
+		key := p.currentToken.Literal
+		switch key {
+		case "max_attempts", "backoff", "base_delay", "max_delay", "jitter", "on":
+		default:
+			p.error(fmt.Sprintf("unknown retry setting '%s'", key))
+			p.skipToLineEnd()
+			continue
+		}
 
-			p.nextToken()
+		if !p.expectPeek(ASSIGN) {
+			p.skipToLineEnd()
+			continue
+		}
+		p.nextToken()
 
-			switch key {
-			case "max_attempts":
-				if p.currentTokenIs(NUMBER) {
-					config.MaxAttempts, _ = strconv.Atoi(p.currentToken.Literal)
-				}
-			case "backoff":
-				if p.currentTokenIs(IDENT) {
-					config.Backoff = p.currentToken.Literal
-				}
-			case "base_delay":
-				if p.currentTokenIs(DURATION) {
-					config.BaseDelay = p.currentToken.Literal
-				}
-			}
+		value := p.readRaw(func(t Token) bool { return t.Type == SEMICOLON || t.Type == RBRACE })
+		if value == "" {
+			p.error(fmt.Sprintf("expected value for '%s'", key))
+			continue
+		}
 
-			p.nextToken()
-			p.skipNewlines()
-		} else {
-			// Unknown token, skip it
-			p.nextToken()
+		switch key {
+		case "max_attempts":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				p.error(fmt.Sprintf("max_attempts must be a positive number, got '%s'", value))
+			}
+			config.MaxAttempts = n
+		case "backoff":
+			config.Backoff = value
+		case "base_delay":
+			config.BaseDelay = value
+		case "max_delay":
+			config.MaxDelay = value
+		case "jitter":
+			config.Jitter = value
+		case "on":
+			config.On = splitValues(value)
 		}
 	}
 
@@ -606,14 +979,13 @@ func (p *Parser) parseScenarioDeclaration() *ScenarioDeclaration {
 	for !p.currentTokenIs(RBRACE) && !p.currentTokenIs(EOF) {
 		switch p.currentToken.Type {
 		case LOAD:
-			stmt.LoadConfig = p.parseLoadConfig()
-		case RUN:
-			flow := p.parseFlowStatement()
-			if flow != nil {
-				stmt.Flow = append(stmt.Flow, flow)
+			config := p.parseLoadConfig()
+			if stmt.LoadConfig != nil {
+				config.Stages = append(stmt.LoadConfig.Stages, config.Stages...)
 			}
-		case IF:
-			flow := p.parseConditionalFlow()
+			stmt.LoadConfig = config
+		case RUN, IF, FOR, THINK:
+			flow := p.parseFlowItem()
 			if flow != nil {
 				stmt.Flow = append(stmt.Flow, flow)
 			}
@@ -622,9 +994,39 @@ func (p *Parser) parseScenarioDeclaration() *ScenarioDeclaration {
 		case NEWLINE:
 			p.nextToken()
 		default:
-			p.error(fmt.Sprintf("unexpected token in scenario block: %s", tokenTypeNames[p.currentToken.Type]))
-			p.nextToken()
-		}
+			if p.currentTokenIs(IDENT) && p.currentToken.Literal == "stages" && p.peekTokenIs(LBRACE) {
+				if stmt.LoadConfig == nil {
+					stmt.LoadConfig = &LoadConfig{
+						Pos: Position{Line: p.currentToken.Line, Column: p.currentToken.Column},
+					}
+				}
+				stmt.LoadConfig.Stages = append(stmt.LoadConfig.Stages, p.parseStagesBlock()...)
+				break
+			}
+			if p.currentTokenIs(IDENT) && (p.currentToken.Literal == "foreach" || p.atParallel()) {
+				if flow := p.parseFlowItem(); flow != nil {
+					stmt.Flow = append(stmt.Flow, flow)
+				}
+				break
+			}
+			if p.currentTokenIs(IDENT) && p.currentToken.Literal == "cookies" {
+				p.nextToken()
+				if !p.currentTokenIs(IDENT) || p.currentToken.Literal != "shared" && p.currentToken.Literal != "per_vu" {
+					p.error(fmt.Sprintf("expected shared or per_vu after cookies, got %s", describe(p.currentToken)))
+					p.skipStatement()
+					break
+				}
+				stmt.Cookies = p.currentToken.Literal
+				p.nextToken()
+				break
+			}
+			if p.currentTokenIs(IDENT) && p.currentToken.Literal == "thresholds" && p.peekTokenIs(LBRACE) {
+				stmt.Thresholds = append(stmt.Thresholds, p.parseThresholdsBlock()...)
+				break
+			}
+			p.error(fmt.Sprintf("unexpected token in scenario block: %s", describe(p.currentToken)))
+			p.skipStatement()
+		}
 
 		p.skipCommentsAndNewlines()
 	}
@@ -632,6 +1034,62 @@ func (p *Parser) parseScenarioDeclaration() *ScenarioDeclaration {
 	return stmt
 }
 
+// parseLifecycleDeclaration parses: setup { run ... } or teardown { run ... }
+func (p *Parser) parseLifecycleDeclaration() *LifecycleDeclaration {
+	stmt := &LifecycleDeclaration{
+		Phase: p.currentToken.Literal,
+		Pos:   Position{Line: p.currentToken.Line, Column: p.currentToken.Column},
+	}
+
+	p.nextToken() // consume 'setup' / 'teardown'
+	stmt.Flow = p.parseFlowBlock()
+
+	return stmt
+}
+
+// parseShorthandRequest parses: req name: curl ... | extract ... | assert ...
+func (p *Parser) parseShorthandRequest() *RequestDeclaration {
+	stmt := &RequestDeclaration{
+		Pos:         Position{Line: p.currentToken.Line, Column: p.currentToken.Column},
+		Assertions:  []*Assertion{},
+		Extractions: []*Extraction{},
+	}
+
+	if !p.expectPeek(IDENT) {
+		return nil
+	}
+
+	stmt.Name = p.currentToken.Literal
+
+	if !p.expectPeek(COLON) {
+		return nil
+	}
+
+	if !p.expectPeek(CURL) {
+		return nil
+	}
+
+	stmt.CurlCommand = p.parseCurlCommand()
+
+	for !p.currentTokenIs(NEWLINE) && !p.currentTokenIs(EOF) {
+		switch {
+		case p.currentTokenIs(PIPE), p.currentTokenIs(STRING) && p.currentToken.Literal == "|":
+			p.nextToken()
+		case p.currentTokenIs(ASSERT):
+			stmt.Assertions = append(stmt.Assertions, p.parseAssertBlock()...)
+		case p.currentTokenIs(EXTRACT):
+			stmt.Extractions = append(stmt.Extractions, p.parseExtractBlock()...)
+		case p.currentTokenIs(COMMENT):
+			p.nextToken()
+		default:
+			p.error(fmt.Sprintf("unexpected token in request: %s", tokenTypeNames[p.currentToken.Type]))
+			p.skipToLineEnd()
+		}
+	}
+
+	return stmt
+}
+
 // parseLoadConfig parses load configuration
 func (p *Parser) parseLoadConfig() *LoadConfig {
 	config := &LoadConfig{
@@ -688,43 +1146,60 @@ func (p *Parser) parseLoadConfig() *LoadConfig {
 		return config
 	}
 
-	// Block style: load { vus = 10, duration = 5m }
-	if p.currentTokenIs(LBRACE) {
+	// Inline style: load: vus=10, duration=5m
+	if p.currentTokenIs(COLON) {
 		p.nextToken()
-		p.skipNewlines()
-
-		for !p.currentTokenIs(RBRACE) && !p.currentTokenIs(EOF) {
-			if p.currentTokenIs(IDENT) || p.currentTokenIs(VUS) || p.currentTokenIs(RPS) {
-				key := p.currentToken.Literal
 
-				if !p.expectPeek(ASSIGN) {
-					return config
-				}
+		for !p.currentTokenIs(NEWLINE) && !p.currentTokenIs(EOF) && !p.currentTokenIs(RBRACE) {
+			if !p.parseLoadParam(config) {
+				p.skipToLineEnd()
+				return config
+			}
 
+			p.nextToken()
+			if p.currentTokenIs(COMMA) {
 				p.nextToken()
+			}
+		}
 
-				switch key {
-				case "vus":
-					if p.currentTokenIs(NUMBER) {
-						config.VUs, _ = strconv.Atoi(p.currentToken.Literal)
-					}
-				case "rps":
-					if p.currentTokenIs(NUMBER) {
-						config.RPS, _ = strconv.Atoi(p.currentToken.Literal)
-					}
-				case "iterations":
-					if p.currentTokenIs(NUMBER) {
-						config.Iterations, _ = strconv.Atoi(p.currentToken.Literal)
-					}
-				case "duration":
-					if p.currentTokenIs(DURATION) {
-						config.Duration = p.currentToken.Literal
+		return config
+	}
+
+	// Block style: load { vus = 10, duration = 5m }
+	if p.currentTokenIs(LBRACE) {
+		p.nextToken()
+		p.skipCommentsAndNewlines()
+
+		for !p.currentTokenIs(RBRACE) && !p.currentTokenIs(EOF) {
+			if p.currentTokenIs(IDENT) && p.peekTokenIs(LBRACE) {
+				switch p.currentToken.Literal {
+				case "stages":
+					config.Stages = append(config.Stages, p.parseStagesBlock()...)
+					p.skipCommentsAndNewlines()
+					continue
+				case "stage":
+					if stage := p.parseStage(); stage != nil {
+						config.Stages = append(config.Stages, stage)
 					}
+					p.skipCommentsAndNewlines()
+					continue
 				}
 			}
 
+			if !isWord(p.currentToken) || !p.peekTokenIs(ASSIGN) {
+				p.error(fmt.Sprintf("unexpected token in load block: %s", describe(p.currentToken)))
+				p.skipStatement()
+				p.skipCommentsAndNewlines()
+				continue
+			}
+
+			p.parseLoadParam(config)
+
 			p.nextToken()
-			p.skipNewlines()
+			if p.currentTokenIs(COMMA) {
+				p.nextToken()
+			}
+			p.skipCommentsAndNewlines()
 		}
 
 		// Consume the closing brace
@@ -736,6 +1211,308 @@ func (p *Parser) parseLoadConfig() *LoadConfig {
 	return config
 }
 
+// parseStagesBlock parses: stages { 30s -> 10 vus; 2m -> 50 vus }
+// or, for arrival-rate load: stages { 30s -> 100 rps; 1m -> 200 rps }
+// Entries are separated by ';', ',' or newlines. Leaves the parser on the
+// token following the closing brace.
+func (p *Parser) parseStagesBlock() []*Stage {
+	var stages []*Stage
+
+	p.nextToken() // consume 'stages'
+	p.nextToken() // consume '{'
+
+	for {
+		for p.currentTokenIs(NEWLINE) || p.currentTokenIs(COMMENT) ||
+			p.currentTokenIs(SEMICOLON) || p.currentTokenIs(COMMA) {
+			p.nextToken()
+		}
+
+		if p.currentTokenIs(RBRACE) || p.currentTokenIs(EOF) {
+			break
+		}
+
+		stage := &Stage{
+			Pos: Position{Line: p.currentToken.Line, Column: p.currentToken.Column},
+		}
+
+		if !p.currentTokenIs(DURATION) {
+			p.error(fmt.Sprintf("expected stage duration, got %s", describe(p.currentToken)))
+			p.skipStatement()
+			continue
+		}
+		stage.Duration = p.currentToken.Literal
+
+		if !p.expectPeek(ARROW) || !p.expectPeek(NUMBER) {
+			p.skipStatement()
+			continue
+		}
+		target, _ := strconv.Atoi(p.currentToken.Literal)
+
+		p.nextToken()
+		switch {
+		case p.currentTokenIs(RPS):
+			stage.RPS = target
+			p.nextToken()
+		case p.currentTokenIs(VUS):
+			stage.VUs = target
+			p.nextToken()
+		default:
+			stage.VUs = target
+		}
+
+		stages = append(stages, stage)
+	}
+
+	if p.currentTokenIs(RBRACE) {
+		p.nextToken()
+	} else {
+		p.error("expected '}' to close stages")
+	}
+
+	return stages
+}
+
+// parseThresholdsBlock parses:
+//
+//	thresholds {
+//	  p95(latency) < 300ms
+//	  error_rate < 1% abort_on_fail after 30s
+//	}
+//
+// Entries are separated by ';' or newlines and kept as source text for the
+// scenario package to interpret. Leaves the parser on the token following the
+// closing brace.
+func (p *Parser) parseThresholdsBlock() []*Threshold {
+	var thresholds []*Threshold
+
+	p.nextToken() // consume 'thresholds'
+	p.nextToken() // consume '{'
+
+	for {
+		for p.currentTokenIs(NEWLINE) || p.currentTokenIs(COMMENT) || p.currentTokenIs(SEMICOLON) {
+			p.nextToken()
+		}
+
+		if p.currentTokenIs(RBRACE) || p.currentTokenIs(EOF) {
+			break
+		}
+
+		threshold := &Threshold{
+			Pos: Position{Line: p.currentToken.Line, Column: p.currentToken.Column},
+		}
+		threshold.Expr = p.readRaw(func(t Token) bool {
+			return t.Type == SEMICOLON || t.Type == RBRACE || t.Type == IDENT && t.Literal == "abort_on_fail"
+		})
+		if threshold.Expr == "" {
+			p.error(fmt.Sprintf("expected threshold expression, got %s", describe(p.currentToken)))
+			p.skipToLineEnd()
+			continue
+		}
+
+		if p.currentTokenIs(IDENT) && p.currentToken.Literal == "abort_on_fail" {
+			threshold.AbortOnFail = true
+			p.nextToken()
+
+			if p.currentTokenIs(IDENT) && p.currentToken.Literal == "after" {
+				if !p.expectPeek(DURATION) {
+					p.skipToLineEnd()
+					continue
+				}
+				threshold.AbortDelay = p.currentToken.Literal
+				p.nextToken()
+			}
+
+			if !p.currentTokenIs(NEWLINE) && !p.currentTokenIs(COMMENT) && !p.currentTokenIs(SEMICOLON) &&
+				!p.currentTokenIs(RBRACE) && !p.currentTokenIs(EOF) {
+				p.error(fmt.Sprintf("unexpected %s after abort_on_fail", describe(p.currentToken)))
+				p.skipToLineEnd()
+				continue
+			}
+		}
+
+		thresholds = append(thresholds, threshold)
+	}
+
+	if p.currentTokenIs(RBRACE) {
+		p.nextToken()
+	} else {
+		p.error("expected '}' to close thresholds")
+	}
+
+	return thresholds
+}
+
+// parseStage parses: stage { duration = 1m, vus = 10 }
+// Leaves the parser on the token following the closing brace.
+func (p *Parser) parseStage() *Stage {
+	stage := &Stage{
+		Pos: Position{Line: p.currentToken.Line, Column: p.currentToken.Column},
+	}
+
+	p.nextToken() // consume 'stage'
+	p.nextToken() // consume '{'
+
+	for {
+		for p.currentTokenIs(NEWLINE) || p.currentTokenIs(COMMENT) || p.currentTokenIs(COMMA) {
+			p.nextToken()
+		}
+
+		if p.currentTokenIs(RBRACE) || p.currentTokenIs(EOF) {
+			break
+		}
+
+		key := p.currentToken.Literal
+		if !isWord(p.currentToken) {
+			p.error(fmt.Sprintf("unexpected token in stage: %s", describe(p.currentToken)))
+			p.skipStatement()
+			continue
+		}
+		if !p.expectPeek(ASSIGN) {
+			p.skipStatement()
+			continue
+		}
+		p.nextToken()
+
+		switch {
+		case key == "duration" && p.currentTokenIs(DURATION):
+			stage.Duration = p.currentToken.Literal
+		case key == "vus" && p.currentTokenIs(NUMBER):
+			stage.VUs, _ = strconv.Atoi(p.currentToken.Literal)
+		case key == "rps" && p.currentTokenIs(NUMBER):
+			stage.RPS, _ = strconv.Atoi(p.currentToken.Literal)
+		default:
+			p.error(fmt.Sprintf("invalid stage parameter '%s'", key))
+		}
+		p.nextToken()
+	}
+
+	if p.currentTokenIs(RBRACE) {
+		p.nextToken()
+	} else {
+		p.error("expected '}' to close stage")
+	}
+
+	if stage.Duration == "" {
+		p.errors = append(p.errors, fmt.Sprintf("stage is missing a duration at %s", stage.Pos))
+		return nil
+	}
+
+	return stage
+}
+
+// parseLoadParam parses a single key = value load parameter
+// Leaves the parser on the value token.
+func (p *Parser) parseLoadParam(config *LoadConfig) bool {
+	key := p.currentToken.Literal
+
+	if !p.expectPeek(ASSIGN) {
+		return false
+	}
+
+	p.nextToken()
+
+	switch key {
+	case "vus":
+		if p.currentTokenIs(NUMBER) {
+			config.VUs, _ = strconv.Atoi(p.currentToken.Literal)
+		}
+	case "rps":
+		if p.currentTokenIs(NUMBER) {
+			config.RPS, _ = strconv.Atoi(p.currentToken.Literal)
+		}
+	case "iterations":
+		if p.currentTokenIs(NUMBER) {
+			config.Iterations, _ = strconv.Atoi(p.currentToken.Literal)
+		}
+	case "duration":
+		if p.currentTokenIs(DURATION) {
+			config.Duration = p.currentToken.Literal
+		}
+	case "ramp_up":
+		if p.currentTokenIs(DURATION) {
+			config.RampUp = p.currentToken.Literal
+		}
+	case "max_vus":
+		if p.currentTokenIs(NUMBER) {
+			config.MaxVUs, _ = strconv.Atoi(p.currentToken.Literal)
+		}
+	}
+
+	return true
+}
+
+// parseFlowItem parses a run, if or for each statement
+// Leaves the parser on the token following the statement.
+func (p *Parser) parseFlowItem() FlowStatement {
+	switch p.currentToken.Type {
+	case RUN:
+		return p.parseFlowStatement()
+	case IF:
+		if flow := p.parseConditionalFlow(); flow != nil {
+			return flow
+		}
+		return nil
+	case FOR:
+		if flow := p.parseForEachFlow(); flow != nil {
+			return flow
+		}
+		return nil
+	case THINK:
+		if stmt := p.parseThinkStatement(); stmt != nil {
+			return stmt
+		}
+		return nil
+	case IDENT:
+		if p.currentToken.Literal == "foreach" {
+			if flow := p.parseForEachFlow(); flow != nil {
+				return flow
+			}
+			return nil
+		}
+		if p.atParallel() {
+			if flow := p.parseParallelFlow(); flow != nil {
+				return flow
+			}
+			return nil
+		}
+		fallthrough
+	default:
+		p.error(fmt.Sprintf("unexpected token in flow block: %s", describe(p.currentToken)))
+		p.skipStatement()
+		return nil
+	}
+}
+
+// parseFlowBlock parses { flow_statement* } starting at '{'
+// Leaves the parser on the token following the closing brace.
+func (p *Parser) parseFlowBlock() []FlowStatement {
+	statements := []FlowStatement{}
+
+	if !p.currentTokenIs(LBRACE) {
+		p.error("expected '{'")
+		return statements
+	}
+
+	p.nextToken() // consume '{'
+	p.skipCommentsAndNewlines()
+
+	for !p.currentTokenIs(RBRACE) && !p.currentTokenIs(EOF) {
+		if stmt := p.parseFlowItem(); stmt != nil {
+			statements = append(statements, stmt)
+		}
+		p.skipCommentsAndNewlines()
+	}
+
+	// Consume the closing brace
+	if p.currentTokenIs(RBRACE) {
+		p.nextToken()
+	} else {
+		p.error("expected '}' to close block")
+	}
+
+	return statements
+}
+
 // parseFlowStatement parses a flow statement
 func (p *Parser) parseFlowStatement() FlowStatement {
 	pos := Position{Line: p.currentToken.Line, Column: p.currentToken.Column}
@@ -744,9 +1521,25 @@ func (p *Parser) parseFlowStatement() FlowStatement {
 
 	if !p.currentTokenIs(IDENT) {
 		p.error("expected identifier after 'run'")
+		p.skipToLineEnd()
+		return nil
+	}
+
+	if p.atParallel() {
+		if flow := p.parseParallelFlow(); flow != nil {
+			flow.Pos = pos
+			return flow
+		}
 		return nil
 	}
 
+	return p.parseRunTarget(pos)
+}
+
+// parseRunTarget parses what follows 'run': req, req1 -> req2 or
+// parent { ... }, starting at the first request name
+// Leaves the parser on the token following the statement.
+func (p *Parser) parseRunTarget(pos Position) FlowStatement {
 	firstRequest := p.currentToken.Literal
 
 	// Check for sequential flow: run req1 -> req2 -> req3
@@ -777,36 +1570,10 @@ func (p *Parser) parseFlowStatement() FlowStatement {
 	// Check for nested flow: run parent { run child }
 	if p.peekTokenIs(LBRACE) {
 		p.nextToken() // consume identifier
-		p.nextToken() // consume '{'
-		p.skipNewlines()
-
-		children := []FlowStatement{}
-
-		for !p.currentTokenIs(RBRACE) && !p.currentTokenIs(EOF) {
-			if p.currentTokenIs(RUN) {
-				child := p.parseFlowStatement()
-				if child != nil {
-					children = append(children, child)
-				}
-			} else if p.currentTokenIs(IF) {
-				child := p.parseConditionalFlow()
-				if child != nil {
-					children = append(children, child)
-				}
-			}
-
-			p.nextToken()
-			p.skipNewlines()
-		}
-
-		// Consume the closing brace
-		if p.currentTokenIs(RBRACE) {
-			p.nextToken()
-		}
 
 		return &NestedFlow{
 			Parent:   firstRequest,
-			Children: children,
+			Children: p.parseFlowBlock(),
 			Pos:      pos,
 		}
 	}
@@ -821,6 +1588,79 @@ func (p *Parser) parseFlowStatement() FlowStatement {
 	}
 }
 
+// atParallel reports whether the current identifier opens a parallel block
+// rather than naming a request called parallel
+func (p *Parser) atParallel() bool {
+	if p.currentToken.Literal != "parallel" {
+		return false
+	}
+	return p.peekTokenIs(LBRACE) || p.peekTokenIs(IDENT) && p.peekToken.Literal == "max"
+}
+
+// parseParallelFlow parses: parallel [max N] { branch, branch ... }
+// Branches are separated by commas or newlines. A branch is a request name,
+// a chain (a -> b), a nested flow (a { ... }) or any flow statement.
+// Leaves the parser on the token following the closing brace.
+func (p *Parser) parseParallelFlow() *ParallelFlow {
+	flow := &ParallelFlow{
+		Pos: Position{Line: p.currentToken.Line, Column: p.currentToken.Column},
+	}
+
+	p.nextToken() // consume 'parallel'
+
+	if p.currentTokenIs(IDENT) && p.currentToken.Literal == "max" {
+		if !p.expectPeek(NUMBER) {
+			p.skipStatement()
+			return nil
+		}
+		max, err := strconv.Atoi(p.currentToken.Literal)
+		if err != nil || max < 1 {
+			p.error(fmt.Sprintf("parallel max must be a positive whole number, got %s", p.currentToken.Literal))
+			p.skipStatement()
+			return nil
+		}
+		flow.Max = max
+		p.nextToken()
+	}
+
+	if !p.currentTokenIs(LBRACE) {
+		p.error("expected '{' after parallel")
+		p.skipStatement()
+		return nil
+	}
+
+	p.nextToken() // consume '{'
+	p.skipCommentsAndNewlines()
+
+	for !p.currentTokenIs(RBRACE) && !p.currentTokenIs(EOF) {
+		var branch FlowStatement
+		if p.currentTokenIs(IDENT) && p.currentToken.Literal != "foreach" && !p.atParallel() {
+			branch = p.parseRunTarget(Position{Line: p.currentToken.Line, Column: p.currentToken.Column})
+		} else {
+			branch = p.parseFlowItem()
+		}
+		if branch != nil {
+			flow.Branches = append(flow.Branches, branch)
+		}
+
+		if p.currentTokenIs(COMMA) {
+			p.nextToken()
+		}
+		p.skipCommentsAndNewlines()
+	}
+
+	if !p.currentTokenIs(RBRACE) {
+		p.error("expected '}' to close block")
+		return nil
+	}
+	if len(flow.Branches) == 0 {
+		p.error("parallel block has no branches")
+	}
+	p.nextToken() // consume '}'
+
+	return flow
+}
+
 // parseConditionalFlow parses if/else flow
 func (p *Parser) parseConditionalFlow() *ConditionalFlow {
 	flow := &ConditionalFlow{
@@ -831,90 +1671,123 @@ func (p *Parser) parseConditionalFlow() *ConditionalFlow {
 
 	p.nextToken() // consume 'if'
 
-	// Parse condition
 	flow.Condition = p.parseCondition()
-
-	// parseCondition leaves us on the right value, advance past it
-	p.nextToken()
+	if flow.Condition == nil {
+		p.skipToLineEnd()
+		return nil
+	}
 
 	// Skip any newlines before the opening brace
 	p.skipNewlines()
 
 	if !p.currentTokenIs(LBRACE) {
 		p.error("expected '{' after condition")
+		p.skipToLineEnd()
 		return nil
 	}
 
-	p.nextToken()
-	p.skipNewlines()
+	flow.ThenBlock = p.parseFlowBlock()
 
-	// Parse then block
-	for !p.currentTokenIs(RBRACE) && !p.currentTokenIs(EOF) {
-		if p.currentTokenIs(RUN) {
-			stmt := p.parseFlowStatement()
-			if stmt != nil {
-				flow.ThenBlock = append(flow.ThenBlock, stmt)
+	// Check for else block: } else { ... } or } else if ...
+	if p.currentTokenIs(ELSE) {
+		p.nextToken() // consume 'else'
+
+		if p.currentTokenIs(IF) {
+			if elseIf := p.parseConditionalFlow(); elseIf != nil {
+				flow.ElseBlock = append(flow.ElseBlock, elseIf)
 			}
+			return flow
 		}
-		p.nextToken()
-		p.skipNewlines()
-	}
-
-	// Now on RBRACE of then block
-	// Check for else block
-	if p.peekTokenIs(ELSE) {
-		p.nextToken() // consume '}', now on 'else'
-		p.nextToken() // consume 'else', now on '{'
 
 		if !p.currentTokenIs(LBRACE) {
 			p.error("expected '{' after 'else'")
 			return flow
 		}
 
-		p.nextToken() // consume '{'
-		p.skipNewlines()
+		flow.ElseBlock = p.parseFlowBlock()
+	}
 
-		for !p.currentTokenIs(RBRACE) && !p.currentTokenIs(EOF) {
-			if p.currentTokenIs(RUN) {
-				stmt := p.parseFlowStatement()
-				if stmt != nil {
-					flow.ElseBlock = append(flow.ElseBlock, stmt)
-				}
-			}
-			p.nextToken()
-			p.skipNewlines()
-		}
+	return flow
+}
 
-		// Consume else block's closing brace
-		if p.currentTokenIs(RBRACE) {
-			p.nextToken()
-		}
-	} else {
-		// No else block, consume then block's closing brace
-		if p.currentTokenIs(RBRACE) {
-			p.nextToken()
+// parseForEachFlow parses: for each item in data { ... } (or foreach ...)
+func (p *Parser) parseForEachFlow() *ForEachFlow {
+	flow := &ForEachFlow{
+		Pos: Position{Line: p.currentToken.Line, Column: p.currentToken.Column},
+	}
+
+	if p.currentTokenIs(FOR) {
+		if !p.peekTokenIs(IDENT) || p.peekToken.Literal != "each" {
+			p.error("expected 'each' after 'for'")
+			p.skipStatement()
+			return nil
 		}
+		p.nextToken() // consume 'for'
+	}
+
+	if !p.expectPeek(IDENT) {
+		p.skipStatement()
+		return nil
 	}
+	flow.ItemVar = p.currentToken.Literal
+
+	if !p.expectPeek(IN) || !p.expectPeek(IDENT) {
+		p.skipStatement()
+		return nil
+	}
+	flow.DataName = p.currentToken.Literal
+
+	p.nextToken()
+	p.skipNewlines()
+
+	if !p.currentTokenIs(LBRACE) {
+		p.error("expected '{' after for each")
+		p.skipStatement()
+		return nil
+	}
+
+	flow.Body = p.parseFlowBlock()
 
 	return flow
 }
 
-// parseCondition parses a condition expression
-func (p *Parser) parseCondition() *Condition {
-	cond := &Condition{
+// parseThinkStatement parses: think 2s
+// Leaves the parser on the token following the duration.
+func (p *Parser) parseThinkStatement() *ThinkStatement {
+	stmt := &ThinkStatement{
 		Pos: Position{Line: p.currentToken.Line, Column: p.currentToken.Column},
 	}
 
-	cond.Left = p.parseExpression()
-
+	p.nextToken() // consume 'think'
+	if !p.currentTokenIs(DURATION) {
+		p.error(fmt.Sprintf("expected a duration after think, got %s", describe(p.currentToken)))
+		p.skipStatement()
+		return nil
+	}
+	stmt.Duration = p.currentToken.Literal
 	p.nextToken()
 
-	// Operator
-	cond.Operator = p.currentToken.Literal
+	return stmt
+}
 
-	p.nextToken()
+// parseCondition reads the expression of an if statement up to its opening
+// brace. The scenario package compiles it.
+func (p *Parser) parseCondition() *Condition {
+	cond := &Condition{
+		Pos: Position{Line: p.currentToken.Line, Column: p.currentToken.Column},
+	}
 
-	cond.Right = p.parseExpression()
+	// Brackets are not balanced here, so that a missing ')' is reported by
+	// the expression compiler rather than swallowing the block
+	start := p.currentToken
+	for !p.currentTokenIs(LBRACE) && !p.currentTokenIs(NEWLINE) && !p.currentTokenIs(COMMENT) && !p.currentTokenIs(EOF) {
+		p.nextToken()
+	}
+	cond.Expr = strings.TrimSpace(p.lexer.slice(start, p.currentToken))
+	if cond.Expr == "" {
+		p.error(fmt.Sprintf("expected condition, got %s", describe(p.currentToken)))
+		return nil
+	}
 
 	return cond
 }
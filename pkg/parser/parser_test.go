@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -307,6 +308,82 @@ func TestParser_NestedFlow(t *testing.T) {
 	}
 }
 
+func TestParser_ParallelFlow(t *testing.T) {
+	input := `scenario parallel_test {
+	load 5 vus for 30s
+	run parallel max 2 {
+		stats, activity
+		login -> profile
+		run list {
+			run detail
+		}
+		parallel { a, b }
+	}
+	parallel {
+		run parallel
+	}
+}`
+
+	l := NewLexer(input)
+	p := NewParser(l)
+	program := p.Parse()
+
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ScenarioDeclaration)
+	if len(stmt.Flow) != 2 {
+		t.Fatalf("expected 2 flows. got=%d", len(stmt.Flow))
+	}
+
+	flow, ok := stmt.Flow[0].(*ParallelFlow)
+	if !ok {
+		t.Fatalf("flow is not *ParallelFlow. got=%T", stmt.Flow[0])
+	}
+	if flow.Max != 2 {
+		t.Errorf("max wrong. got=%d", flow.Max)
+	}
+	if len(flow.Branches) != 5 {
+		t.Fatalf("expected 5 branches. got=%d", len(flow.Branches))
+	}
+
+	if run, ok := flow.Branches[1].(*RunStatement); !ok || run.RequestName != "activity" {
+		t.Errorf("branch 1 is not run activity. got=%#v", flow.Branches[1])
+	}
+	if seq, ok := flow.Branches[2].(*SequentialFlow); !ok || len(seq.Steps) != 2 {
+		t.Errorf("branch 2 is not login -> profile. got=%#v", flow.Branches[2])
+	}
+	if nested, ok := flow.Branches[3].(*NestedFlow); !ok || nested.Parent != "list" || len(nested.Children) != 1 {
+		t.Errorf("branch 3 is not list { detail }. got=%#v", flow.Branches[3])
+	}
+	if inner, ok := flow.Branches[4].(*ParallelFlow); !ok || len(inner.Branches) != 2 || inner.Max != 0 {
+		t.Errorf("branch 4 is not parallel { a, b }. got=%#v", flow.Branches[4])
+	}
+
+	// A request may still be called parallel
+	second := stmt.Flow[1].(*ParallelFlow)
+	if run, ok := second.Branches[0].(*RunStatement); !ok || run.RequestName != "parallel" {
+		t.Errorf("expected run parallel. got=%#v", second.Branches[0])
+	}
+}
+
+func TestParser_ParallelFlowErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"scenario s {\n  run parallel max 0 { a }\n}", "parallel max must be a positive whole number, got 0"},
+		{"scenario s {\n  run parallel {\n  }\n}", "parallel block has no branches"},
+	}
+
+	for _, tt := range tests {
+		p := NewParser(NewLexer(tt.input))
+		p.Parse()
+		if errs := p.Errors(); len(errs) == 0 || !strings.Contains(errs[0], tt.want) {
+			t.Errorf("errors %v, want %q", errs, tt.want)
+		}
+	}
+}
+
 func TestParser_ConditionalFlow(t *testing.T) {
 	input := `scenario conditional_test {
 	load 10 vus for 1m
@@ -329,8 +406,8 @@ func TestParser_ConditionalFlow(t *testing.T) {
 		t.Fatalf("flow is not *ConditionalFlow. got=%T", stmt.Flow[0])
 	}
 
-	if flow.Condition.Operator != "==" {
-		t.Errorf("condition operator wrong. got=%s", flow.Condition.Operator)
+	if flow.Condition.Expr != `${feature_enabled} == "true"` {
+		t.Errorf("condition wrong. got=%s", flow.Condition.Expr)
 	}
 
 	if len(flow.ThenBlock) != 1 {
@@ -511,3 +588,173 @@ func checkParserErrors(t *testing.T, p *Parser) {
 	}
 	t.FailNow()
 }
+
+func TestParser_DataAndForEach(t *testing.T) {
+	input := `data products = [
+  { id: 1, name: "A" },
+  { id: 2, name: "B" }
+]
+data users = file("users.csv") with unique
+
+scenario s {
+  for each product in products {
+    run get_product
+  }
+  foreach user in users {
+    run get_user
+  }
+}`
+
+	l := NewLexer(input)
+	p := NewParser(l)
+	program := p.Parse()
+
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 3 {
+		t.Fatalf("expected 3 statements, got %d", len(program.Statements))
+	}
+
+	inline, ok := program.Statements[0].(*DataDeclaration)
+	if !ok {
+		t.Fatalf("statement is not *DataDeclaration. got=%T", program.Statements[0])
+	}
+	if inline.Name != "products" || !strings.HasPrefix(inline.Inline, "[") || !strings.HasSuffix(inline.Inline, "]") {
+		t.Errorf("unexpected inline data: %s = %q", inline.Name, inline.Inline)
+	}
+
+	file := program.Statements[1].(*DataDeclaration)
+	if file.File != "users.csv" || file.Mode != "unique" {
+		t.Errorf("unexpected file data: file=%q mode=%q", file.File, file.Mode)
+	}
+
+	scenario := program.Statements[2].(*ScenarioDeclaration)
+	if len(scenario.Flow) != 2 {
+		t.Fatalf("expected 2 flow statements, got %d", len(scenario.Flow))
+	}
+
+	loop, ok := scenario.Flow[0].(*ForEachFlow)
+	if !ok {
+		t.Fatalf("flow is not *ForEachFlow. got=%T", scenario.Flow[0])
+	}
+	if loop.ItemVar != "product" || loop.DataName != "products" || len(loop.Body) != 1 {
+		t.Errorf("unexpected for each: %+v", loop)
+	}
+
+	if loop, ok := scenario.Flow[1].(*ForEachFlow); !ok || loop.ItemVar != "user" {
+		t.Errorf("foreach shorthand not parsed: %T", scenario.Flow[1])
+	}
+}
+
+func TestParser_LoadStages(t *testing.T) {
+	input := `scenario spike {
+  load {
+    stage { duration = 1m, vus = 10 }
+    stages { 30s -> 50 vus; 10s -> 0 vus }
+  }
+  stages {
+    5s -> 1 vus
+  }
+  run health
+}`
+
+	l := NewLexer(input)
+	p := NewParser(l)
+	program := p.Parse()
+
+	checkParserErrors(t, p)
+
+	scenario := program.Statements[0].(*ScenarioDeclaration)
+	stages := scenario.LoadConfig.Stages
+	if len(stages) != 4 {
+		t.Fatalf("expected 4 stages, got %d", len(stages))
+	}
+
+	want := []struct {
+		duration string
+		vus      int
+	}{{"1m", 10}, {"30s", 50}, {"10s", 0}, {"5s", 1}}
+	for i, w := range want {
+		if stages[i].Duration != w.duration || stages[i].VUs != w.vus {
+			t.Errorf("stage %d = %s -> %d, want %s -> %d", i, stages[i].Duration, stages[i].VUs, w.duration, w.vus)
+		}
+	}
+
+	if len(scenario.Flow) != 1 {
+		t.Errorf("expected run statement after stages, got %d flow statements", len(scenario.Flow))
+	}
+}
+
+func TestParser_Thresholds(t *testing.T) {
+	input := `scenario gate {
+  load 10 iterations with 1 vus
+  thresholds {
+    p95(latency) < 300ms; error_rate < 1% abort_on_fail after 10s
+    checks > 99%  # every assertion
+  }
+  run health
+}`
+
+	l := NewLexer(input)
+	p := NewParser(l)
+	program := p.Parse()
+
+	checkParserErrors(t, p)
+
+	scenario := program.Statements[0].(*ScenarioDeclaration)
+	if len(scenario.Thresholds) != 3 {
+		t.Fatalf("expected 3 thresholds, got %d", len(scenario.Thresholds))
+	}
+
+	want := []string{"p95(latency) < 300ms", "error_rate < 1%", "checks > 99%"}
+	for i, expr := range want {
+		if scenario.Thresholds[i].Expr != expr {
+			t.Errorf("threshold %d = %q, want %q", i, scenario.Thresholds[i].Expr, expr)
+		}
+	}
+
+	abort := scenario.Thresholds[1]
+	if !abort.AbortOnFail || abort.AbortDelay != "10s" {
+		t.Errorf("abort_on_fail not parsed: %+v", abort)
+	}
+	if len(scenario.Flow) != 1 {
+		t.Errorf("expected run statement after thresholds, got %d flow statements", len(scenario.Flow))
+	}
+}
+
+func TestParser_ScenarioCookies(t *testing.T) {
+	p := NewParser(NewLexer("scenario s {\n  load 1 vus for 1s\n  cookies shared\n  run a\n}"))
+	program := p.Parse()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ScenarioDeclaration)
+	if stmt.Cookies != "shared" || len(stmt.Flow) != 1 {
+		t.Errorf("cookies = %q, flows = %d", stmt.Cookies, len(stmt.Flow))
+	}
+
+	p = NewParser(NewLexer("scenario s {\n  cookies global\n}"))
+	p.Parse()
+	if errs := p.Errors(); len(errs) == 0 || !strings.Contains(errs[0], "expected shared or per_vu after cookies, got 'global'") {
+		t.Errorf("errors = %v", errs)
+	}
+}
+
+func TestParser_Think(t *testing.T) {
+	p := NewParser(NewLexer("scenario s {\n  run a\n  think 250ms\n  run parallel {\n    think 1s\n    b\n  }\n}"))
+	program := p.Parse()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ScenarioDeclaration)
+	if think, ok := stmt.Flow[1].(*ThinkStatement); !ok || think.Duration != "250ms" {
+		t.Errorf("flow 1 is not think 250ms. got=%#v", stmt.Flow[1])
+	}
+	if think, ok := stmt.Flow[2].(*ParallelFlow).Branches[0].(*ThinkStatement); !ok || think.Duration != "1s" {
+		t.Errorf("branch 0 is not think 1s. got=%#v", stmt.Flow[2].(*ParallelFlow).Branches[0])
+	}
+
+	p = NewParser(NewLexer("scenario s {\n  think soon\n}"))
+	p.Parse()
+	if errs := p.Errors(); len(errs) == 0 || !strings.Contains(errs[0], "expected a duration after think") {
+		t.Errorf("errors = %v", errs)
+	}
+}
@@ -59,6 +59,7 @@ const (
 	DOT          // .
 	COMMA        // ,
 	COLON        // :
+	SEMICOLON    // ;
 	BACKSLASH    // \
 	PIPE         // |
 
@@ -189,6 +190,7 @@ var tokenTypeNames = map[TokenType]string{
 	DOT:          ".",
 	COMMA:        ",",
 	COLON:        ":",
+	SEMICOLON:    ";",
 	BACKSLASH:    "\\",
 	PIPE:         "|",
 	LBRACE:       "{",
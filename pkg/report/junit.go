@@ -0,0 +1,152 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/vikasavnish/httptool/pkg/scenario"
+)
+
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Name     string       `xml:"name,attr"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Time     float64      `xml:"time,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name      string      `xml:"name,attr"`
+	Tests     int         `xml:"tests,attr"`
+	Failures  int         `xml:"failures,attr"`
+	Time      float64     `xml:"time,attr"`
+	Timestamp string      `xml:"timestamp,attr,omitempty"`
+	Cases     []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the run as JUnit XML. Each named request is a test case,
+// failing if any execution of it failed, followed by one case per assertion
+// on it. Thresholds make up a second suite.
+func WriteJUnit(w io.Writer, result *scenario.ScenarioResult) error {
+	duration := result.EndTime.Sub(result.StartTime).Seconds()
+
+	requests := junitSuite{
+		Name:      result.Name,
+		Time:      duration,
+		Timestamp: result.StartTime.Format("2006-01-02T15:04:05"),
+	}
+
+	if result.Stats != nil {
+		names := make([]string, 0, len(result.Stats.Requests))
+		for name := range result.Stats.Requests {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			outcome := result.Stats.Requests[name]
+			latency := result.Stats.ByRequest[name]
+
+			testCase := junitCase{
+				Name:      name,
+				ClassName: result.Name,
+				Time:      latency.Mean / 1000,
+			}
+			if outcome.Failed > 0 {
+				testCase.Failure = &junitFailure{
+					Message: fmt.Sprintf("%d of %d requests failed", outcome.Failed, outcome.Count),
+					Type:    "request",
+					Text:    outcome.LastError,
+				}
+			}
+			requests.Cases = append(requests.Cases, testCase)
+
+			for _, check := range outcome.Checks {
+				testCase := junitCase{
+					Name:      check.Name,
+					ClassName: result.Name + "." + name,
+				}
+				if check.Fails > 0 {
+					testCase.Failure = &junitFailure{
+						Message: fmt.Sprintf("%d of %d checks failed", check.Fails, check.Passes+check.Fails),
+						Type:    "assertion",
+					}
+				}
+				requests.Cases = append(requests.Cases, testCase)
+			}
+		}
+	}
+
+	suites := junitSuites{
+		Name:   "httptool",
+		Time:   duration,
+		Suites: []junitSuite{requests},
+	}
+
+	if len(result.Thresholds) > 0 || result.AbortReason != "" {
+		thresholds := junitSuite{Name: result.Name + " thresholds"}
+		for _, t := range result.Thresholds {
+			testCase := junitCase{
+				Name:      t.Threshold.Expr,
+				ClassName: result.Name + ".thresholds",
+			}
+			if !t.Passed {
+				testCase.Failure = &junitFailure{
+					Message: "actual " + t.Threshold.FormatValue(t.Actual),
+					Type:    "threshold",
+				}
+			}
+			thresholds.Cases = append(thresholds.Cases, testCase)
+		}
+		if result.AbortReason != "" {
+			thresholds.Cases = append(thresholds.Cases, junitCase{
+				Name:      "run completed",
+				ClassName: result.Name + ".thresholds",
+				Failure: &junitFailure{
+					Message: result.AbortReason,
+					Type:    "aborted",
+				},
+			})
+		}
+		suites.Suites = append(suites.Suites, thresholds)
+	}
+
+	for i := range suites.Suites {
+		suite := &suites.Suites[i]
+		suite.Tests = len(suite.Cases)
+		for _, c := range suite.Cases {
+			if c.Failure != nil {
+				suite.Failures++
+			}
+		}
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
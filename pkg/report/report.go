@@ -0,0 +1,137 @@
+// Package report writes scenario results in machine-readable formats: a JSON
+// summary, an NDJSON stream with one line per request, and JUnit XML for CI
+// test reporters.
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/vikasavnish/httptool/pkg/histogram"
+	"github.com/vikasavnish/httptool/pkg/ir"
+	"github.com/vikasavnish/httptool/pkg/scenario"
+)
+
+// Summary is the JSON form of a finished scenario run
+type Summary struct {
+	Scenario    string          `json:"scenario"`
+	StartTime   time.Time       `json:"start_time"`
+	EndTime     time.Time       `json:"end_time"`
+	DurationMs  float64         `json:"duration_ms"`
+	VUs         int             `json:"vus"`
+	Stats       *scenario.Stats `json:"stats"`
+	Thresholds  []Threshold     `json:"thresholds,omitempty"`
+	AbortReason string          `json:"abort_reason,omitempty"`
+	Passed      bool            `json:"passed"`
+}
+
+// Threshold is the JSON form of a threshold outcome
+type Threshold struct {
+	Expr        string  `json:"expr"`
+	Actual      float64 `json:"actual"`
+	Limit       float64 `json:"limit"`
+	Passed      bool    `json:"passed"`
+	AbortOnFail bool    `json:"abort_on_fail,omitempty"`
+}
+
+// NewSummary builds the JSON summary of a run
+func NewSummary(result *scenario.ScenarioResult) *Summary {
+	summary := &Summary{
+		Scenario:    result.Name,
+		StartTime:   result.StartTime,
+		EndTime:     result.EndTime,
+		DurationMs:  histogram.Millis(result.EndTime.Sub(result.StartTime)),
+		VUs:         len(result.VUResults),
+		Stats:       result.Stats,
+		AbortReason: result.AbortReason,
+		Passed:      result.ThresholdsPassed(),
+	}
+
+	for _, t := range result.Thresholds {
+		summary.Thresholds = append(summary.Thresholds, Threshold{
+			Expr:        t.Threshold.Expr,
+			Actual:      t.Actual,
+			Limit:       t.Threshold.Value,
+			Passed:      t.Passed,
+			AbortOnFail: t.Threshold.AbortOnFail,
+		})
+	}
+
+	return summary
+}
+
+// WriteJSON writes the run summary as indented JSON
+func WriteJSON(w io.Writer, result *scenario.ScenarioResult) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(NewSummary(result))
+}
+
+// RequestLine is one line of the NDJSON request stream
+type RequestLine struct {
+	Time      time.Time              `json:"time"`
+	VU        int                    `json:"vu"`
+	Iteration int                    `json:"iteration"`
+	Name      string                 `json:"name"`
+	Method    string                 `json:"method"`
+	URL       string                 `json:"url"`
+	Status    int                    `json:"status"`
+	LatencyMs float64                `json:"latency_ms"`
+	Size      int64                  `json:"size"`
+	Error     string                 `json:"error,omitempty"`
+	Attempts  int                    `json:"attempts"`
+	Checks    []scenario.CheckResult `json:"checks,omitempty"`
+	Timing    *ir.Timing             `json:"timing,omitempty"`
+}
+
+// NDJSON streams one JSON line per finished request. It is safe for
+// concurrent use, so Record can be passed straight to Executor.OnRequest.
+type NDJSON struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+	err     error
+}
+
+// NewNDJSON creates a stream writing to w
+func NewNDJSON(w io.Writer) *NDJSON {
+	return &NDJSON{encoder: json.NewEncoder(w)}
+}
+
+// Record writes one request. The first write error stops the stream and is
+// reported by Err.
+func (n *NDJSON) Record(req *scenario.RequestResult) {
+	line := RequestLine{
+		Time:      req.StartTime,
+		VU:        req.VUID,
+		Iteration: req.Iteration,
+		Name:      req.Name,
+		Method:    req.Method,
+		URL:       req.URL,
+		Status:    req.Status,
+		LatencyMs: histogram.Millis(req.Latency),
+		Size:      req.Size,
+		Error:     req.Error,
+		Attempts:  req.Attempts,
+		Checks:    req.Checks,
+		Timing:    req.Timing,
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.err == nil {
+		if err := n.encoder.Encode(line); err != nil {
+			n.err = fmt.Errorf("write ndjson: %w", err)
+		}
+	}
+}
+
+// Err returns the first write error, if any
+func (n *NDJSON) Err() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.err
+}
@@ -0,0 +1,150 @@
+package report
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/vikasavnish/httptool/pkg/scenario"
+)
+
+func runScenario(t *testing.T, stream *NDJSON) *scenario.ScenarioResult {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	input := `var base = "` + server.URL + `"
+
+request home {
+  curl ${base}/
+  assert status == 200
+}
+
+request missing {
+  curl ${base}/missing
+  retry {
+    on = [404]
+    max_attempts = 2
+    base_delay = 1ms
+  }
+  assert status == 200
+}
+
+scenario smoke {
+  load 2 iterations with 1 vus
+  thresholds {
+    error_rate < 1%
+  }
+  run home -> missing
+}`
+
+	s, err := scenario.NewParser(input).Parse()
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	compiled, err := scenario.NewCompiler().Compile(s, "smoke")
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+
+	executor := scenario.NewExecutor()
+	if stream != nil {
+		executor.OnRequest(stream.Record)
+	}
+	result, err := executor.Execute(context.Background(), compiled)
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	return result
+}
+
+func TestNDJSON(t *testing.T) {
+	var buf bytes.Buffer
+	stream := NewNDJSON(&buf)
+	runScenario(t, stream)
+
+	if err := stream.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	var lines []RequestLine
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var line RequestLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("invalid line %q: %v", scanner.Text(), err)
+		}
+		lines = append(lines, line)
+	}
+
+	if len(lines) != 4 {
+		t.Fatalf("expected 4 lines, got %d", len(lines))
+	}
+	missing := 0
+	for _, line := range lines {
+		if line.Name == "missing" {
+			missing++
+			if line.Status != 404 || len(line.Checks) != 1 || line.Checks[0].Passed || line.Attempts != 2 {
+				t.Errorf("unexpected line %+v", line)
+			}
+		} else if line.Attempts != 1 {
+			t.Errorf("home made %d attempts, want 1", line.Attempts)
+		}
+	}
+	if missing != 2 {
+		t.Errorf("expected 2 lines for 'missing', got %d", missing)
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	result := runScenario(t, nil)
+
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, result); err != nil {
+		t.Fatal(err)
+	}
+
+	var summary Summary
+	if err := json.Unmarshal(buf.Bytes(), &summary); err != nil {
+		t.Fatal(err)
+	}
+	if summary.Passed || summary.Stats.TotalRequests != 4 || summary.Stats.Requests["missing"].Failed != 2 {
+		t.Errorf("unexpected summary %s", buf.String())
+	}
+	if len(summary.Thresholds) != 1 || summary.Thresholds[0].Actual != 0.5 {
+		t.Errorf("thresholds = %+v", summary.Thresholds)
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	result := runScenario(t, nil)
+
+	var buf bytes.Buffer
+	if err := WriteJUnit(&buf, result); err != nil {
+		t.Fatal(err)
+	}
+
+	var suites junitSuites
+	if err := xml.Unmarshal(buf.Bytes(), &suites); err != nil {
+		t.Fatalf("invalid XML: %v\n%s", err, buf.String())
+	}
+
+	// home, its check, missing, its check, and one threshold
+	if suites.Tests != 5 || suites.Failures != 3 {
+		t.Errorf("tests=%d failures=%d\n%s", suites.Tests, suites.Failures, buf.String())
+	}
+	if !strings.Contains(buf.String(), `message="2 of 2 checks failed"`) {
+		t.Errorf("missing check failure message\n%s", buf.String())
+	}
+}
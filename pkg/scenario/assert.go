@@ -0,0 +1,492 @@
+package scenario
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vikasavnish/httptool/pkg/expr"
+	"github.com/vikasavnish/httptool/pkg/extract"
+	"github.com/vikasavnish/httptool/pkg/ir"
+	"github.com/vikasavnish/httptool/pkg/jsonpath"
+	"github.com/vikasavnish/httptool/pkg/jsonschema"
+)
+
+// runtimeValue is an expected value that refers to variables, e.g.
+// "${user_id}". It is parsed once the variables are known.
+type runtimeValue string
+
+// sizePattern matches sizes such as 512B, 10KB or 1.5MB (powers of 1024)
+var sizePattern = regexp.MustCompile(`(?i)^(\d+(?:\.\d+)?)\s*(b|kb|mb|gb)$`)
+
+// jsonTypes are the names accepted by type(...) assertions
+var jsonTypes = map[string]bool{
+	"string": true, "number": true, "boolean": true, "null": true, "array": true, "object": true,
+}
+
+// ParseAssertion builds an assertion from its field, operator and expected
+// value as written, e.g. ("body.items", "contains", `"a"`). Unknown fields and
+// operators, and values that cannot work with the operator, are errors.
+// Assertions that do not compare a field, such as len(body.items) > 0 or one
+// given without an operator, are compiled as boolean expressions.
+func ParseAssertion(field, operator, raw string) (Assertion, error) {
+	a := Assertion{
+		Field:    strings.TrimSpace(field),
+		Operator: strings.TrimSpace(operator),
+		Raw:      strings.TrimSpace(raw),
+	}
+	if a.Operator == "=" {
+		a.Operator = "=="
+	}
+	if a.Operator == "" {
+		return parseExpressionAssertion(a.Field)
+	}
+
+	subject := a.Field
+	if strings.HasPrefix(subject, "type(") && strings.HasSuffix(subject, ")") {
+		subject = strings.TrimSpace(subject[len("type(") : len(subject)-1])
+		a.TypeOf = true
+	} else if s, ok := strings.CutSuffix(subject, " type"); ok {
+		subject = strings.TrimSpace(s)
+		a.TypeOf = true
+	}
+
+	switch {
+	case subject == "status":
+		a.Type = AssertStatus
+	case subject == "latency" || subject == "latency_ms":
+		a.Type = AssertLatency
+	case subject == "size":
+		a.Type = AssertSize
+	case subject == "body" || strings.HasPrefix(subject, "body.") || strings.HasPrefix(subject, "body["):
+		a.Type = AssertBody
+		a.Path = "$" + strings.TrimPrefix(subject, "body")
+		if _, err := jsonpath.Compile(a.Path); err != nil {
+			return orExpression(a, err)
+		}
+	case strings.HasPrefix(subject, "header."):
+		a.Type = AssertHeader
+		a.Path = strings.TrimPrefix(subject, "header.")
+	case strings.HasPrefix(subject, "cookie."):
+		a.Type = AssertCookie
+		a.Path = strings.TrimPrefix(subject, "cookie.")
+	default:
+		// Not a field, so the assertion may be an expression such as
+		// len(body.items) > 0
+		e, err := parseExpressionAssertion(a.String())
+		if err != nil && !strings.ContainsAny(subject, "(!$") {
+			err = fmt.Errorf("unknown field '%s' (expected status, latency, size, body, header.<name> or cookie.<name>)", subject)
+		}
+		return e, err
+	}
+	if a.Path == "" && (a.Type == AssertHeader || a.Type == AssertCookie) {
+		return a, fmt.Errorf("%s assertion needs a name, e.g. %s.X-Request-Id", a.Type, a.Type)
+	}
+	if a.TypeOf && a.Type != AssertBody {
+		return a, fmt.Errorf("type() applies to body fields only")
+	}
+
+	if err := a.parseValue(); err != nil {
+		// status == 200 || status == 204 is an expression, not a comparison
+		// with the value "200 || status == 204"
+		if strings.ContainsAny(a.Raw, " \t") {
+			return orExpression(a, err)
+		}
+		return a, err
+	}
+	return a, nil
+}
+
+// orExpression retries an assertion that is not a valid comparison of a field
+// as a boolean expression, returning err if it is not one either
+func orExpression(a Assertion, err error) (Assertion, error) {
+	if e, exprErr := parseExpressionAssertion(a.String()); exprErr == nil {
+		return e, nil
+	}
+	return a, err
+}
+
+// parseExpressionAssertion builds an assertion that holds when a boolean
+// expression does, e.g. len(body.items) > 0 || status == 204
+func parseExpressionAssertion(src string) (Assertion, error) {
+	e, err := compileExpression(src)
+	if err == nil {
+		if t := e.Type(responseTypes); t != "" && t != "boolean" {
+			err = fmt.Errorf("expected a boolean expression, got %s", t)
+		}
+	}
+	return Assertion{Type: AssertExpr, Field: src, Expr: e}, err
+}
+
+// parseValue parses Raw into Value and checks it suits the operator
+func (a *Assertion) parseValue() error {
+	op := strings.TrimPrefix(a.Operator, "not ")
+
+	switch op {
+	case "exists":
+		if a.Raw != "" {
+			return fmt.Errorf("'%s' takes no value", a.Operator)
+		}
+		if a.Type != AssertBody && a.Type != AssertHeader && a.Type != AssertCookie {
+			return fmt.Errorf("'%s' applies to body, header and cookie fields", a.Operator)
+		}
+		return nil
+
+	case "==", "!=", "<", "<=", ">", ">=", "contains", "matches", "in", "between":
+		if op != a.Operator && op != "contains" && op != "matches" && op != "in" {
+			return fmt.Errorf("unknown operator '%s'", a.Operator)
+		}
+
+	default:
+		return fmt.Errorf("unknown operator '%s'", a.Operator)
+	}
+
+	if a.Raw == "" {
+		return fmt.Errorf("'%s' needs a value", a.Operator)
+	}
+
+	if op == "matches" && strings.HasPrefix(a.Raw, "schema(") {
+		return a.parseSchema()
+	}
+
+	if op == "between" {
+		low, high, ok := strings.Cut(a.Raw, " and ")
+		if !ok {
+			return fmt.Errorf("expected 'between <low> and <high>'")
+		}
+		var bounds [2]any
+		for i, s := range []string{low, high} {
+			v, err := a.parseLiteral(s)
+			if err != nil {
+				return err
+			}
+			if err := a.checkOrdered(v); err != nil {
+				return err
+			}
+			bounds[i] = v
+		}
+		a.Value = bounds
+		return nil
+	}
+
+	v, err := a.parseLiteral(a.Raw)
+	if err != nil {
+		return err
+	}
+	a.Value = v
+
+	if _, ok := v.(runtimeValue); ok {
+		return nil
+	}
+
+	switch op {
+	case "<", "<=", ">", ">=":
+		return a.checkOrdered(v)
+	case "matches":
+		switch p := v.(type) {
+		case *regexp.Regexp:
+		case string:
+			re, err := regexp.Compile(p)
+			if err != nil {
+				return fmt.Errorf("invalid regex: %w", err)
+			}
+			a.Value = re
+		default:
+			return fmt.Errorf("'%s' expects a /regex/ or string", a.Operator)
+		}
+	case "in":
+		if _, ok := v.([]any); !ok {
+			return fmt.Errorf("'%s' expects a list, e.g. [200, 201]", a.Operator)
+		}
+	}
+
+	if a.TypeOf {
+		name, ok := v.(string)
+		if !ok || !jsonTypes[name] || (op != "==" && op != "!=") {
+			return fmt.Errorf("type() compares with == or != against string, number, boolean, null, array or object")
+		}
+	}
+	return nil
+}
+
+// parseSchema reads the file name of body matches schema("user.schema.json")
+func (a *Assertion) parseSchema() error {
+	if a.Type != AssertBody || a.TypeOf {
+		return fmt.Errorf("schema() applies to body fields only")
+	}
+
+	arg, ok := strings.CutSuffix(strings.TrimPrefix(a.Raw, "schema("), ")")
+	arg = strings.TrimSpace(arg)
+	if !ok || len(arg) < 3 || (arg[0] != '"' && arg[0] != '\'') || arg[len(arg)-1] != arg[0] {
+		return fmt.Errorf(`expected schema("file.json")`)
+	}
+	if a.Schema == "" {
+		a.Schema = unquote(arg)
+	}
+	return nil
+}
+
+// checkOrdered rejects values that cannot be ordered against the field
+func (a *Assertion) checkOrdered(v any) error {
+	switch v.(type) {
+	case float64, runtimeValue:
+		return nil
+	case time.Duration:
+		if a.Type == AssertLatency {
+			return nil
+		}
+	}
+	if a.Type == AssertLatency {
+		return fmt.Errorf("'%s' on latency expects a duration such as 500ms", a.Operator)
+	}
+	return fmt.Errorf("'%s' expects a number, got '%v'", a.Operator, v)
+}
+
+// parseLiteral parses one expected value: "text", 'text', /regex/i, numbers,
+// durations, sizes, true, false, null, [lists] and bare words
+func (a *Assertion) parseLiteral(raw string) (any, error) {
+	raw = strings.TrimSpace(raw)
+
+	switch {
+	case strings.ContainsAny(raw, " \t") && !strings.ContainsAny(raw[:1], `"'[/`):
+		return nil, fmt.Errorf("values with spaces must be quoted, got '%s'", raw)
+	case strings.Contains(raw, "${"):
+		return runtimeValue(raw), nil
+	case strings.HasPrefix(raw, "/"):
+		end := strings.LastIndex(raw, "/")
+		if end == 0 {
+			return nil, fmt.Errorf("unterminated regex %s", raw)
+		}
+		pattern, flags := raw[1:end], raw[end+1:]
+		if flags != "" {
+			if strings.Trim(flags, "ims") != "" {
+				return nil, fmt.Errorf("unknown regex flags '%s'", flags)
+			}
+			pattern = "(?" + flags + ")" + pattern
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex: %w", err)
+		}
+		return re, nil
+	case len(raw) >= 2 && (raw[0] == '"' || raw[0] == '\'') && raw[len(raw)-1] == raw[0]:
+		return unquote(raw), nil
+	case strings.HasPrefix(raw, "["):
+		if !strings.HasSuffix(raw, "]") {
+			return nil, fmt.Errorf("unterminated list %s", raw)
+		}
+		items := []any{}
+		for _, item := range splitList(raw[1 : len(raw)-1]) {
+			v, err := a.parseLiteral(item)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, v)
+		}
+		return items, nil
+	case raw == "true" || raw == "false":
+		return raw == "true", nil
+	case raw == "null":
+		return nil, nil
+	}
+
+	if n, err := strconv.ParseFloat(raw, 64); err == nil {
+		return n, nil
+	}
+	if m := sizePattern.FindStringSubmatch(raw); m != nil && a.Type == AssertSize {
+		n, _ := strconv.ParseFloat(m[1], 64)
+		switch strings.ToLower(m[2]) {
+		case "kb":
+			n *= 1 << 10
+		case "mb":
+			n *= 1 << 20
+		case "gb":
+			n *= 1 << 30
+		}
+		return n, nil
+	}
+	if d, err := time.ParseDuration(raw); err == nil && a.Type == AssertLatency {
+		return d, nil
+	}
+	return raw, nil
+}
+
+func unquote(raw string) string {
+	if raw[0] == '"' {
+		if s, err := strconv.Unquote(raw); err == nil {
+			return s
+		}
+	}
+	s := raw[1 : len(raw)-1]
+	return strings.ReplaceAll(s, `\`+raw[:1], raw[:1])
+}
+
+// splitList splits list items on commas outside quotes and brackets
+func splitList(s string) []string {
+	var items []string
+	var quote byte
+	depth, start := 0, 0
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		case c == ',' && depth == 0:
+			items = append(items, s[start:i])
+			start = i + 1
+		}
+	}
+	if strings.TrimSpace(s[start:]) != "" || len(items) > 0 {
+		items = append(items, s[start:])
+	}
+	return items
+}
+
+// Check evaluates the assertion against a response. vars looks up the
+// variables expected values and expressions refer to. When the assertion
+// fails, the detail says why: the value seen, "missing", or the schema
+// violations.
+func (a Assertion) Check(resp *ir.Response, vars func(name string) (any, bool)) (bool, string) {
+	if a.Expr != nil {
+		passed, err := a.Expr.Bool(responseEnv{resp: resp, vars: vars})
+		if err != nil {
+			return false, err.Error()
+		}
+		return passed, "got false"
+	}
+
+	actual, found, err := a.actual(resp)
+	if err != nil {
+		return false, err.Error()
+	}
+
+	resolved, err := a.resolve(func(s string) string {
+		return runtimeVarPattern.ReplaceAllStringFunc(s, func(match string) string {
+			if v, ok := vars(match[2 : len(match)-1]); ok {
+				return expr.Format(v)
+			}
+			return match
+		})
+	})
+	if err != nil {
+		return false, err.Error()
+	}
+	if strings.Contains(resolved.Raw, "${") {
+		return false, fmt.Sprintf("unresolved %s", resolved.Raw)
+	}
+	expected := resolved.Value
+
+	op := strings.TrimPrefix(a.Operator, "not ")
+	negate := op != a.Operator
+	detail := "got " + expr.Format(actual)
+
+	var passed bool
+	switch {
+	case op == "exists":
+		passed = found
+	case !found:
+		passed = false
+	case a.Schema != "":
+		schema, ok := expected.(*jsonschema.Schema)
+		if !ok {
+			return false, "schema not loaded"
+		}
+		err := schema.Validate(actual)
+		if passed = err == nil; !passed {
+			detail = err.Error()
+		}
+	default:
+		passed = compare(op, actual, expected)
+	}
+	if negate && (found || op == "exists") {
+		passed = !passed
+	}
+
+	if !found {
+		return passed, "missing"
+	}
+	return passed, detail
+}
+
+// loadSchema loads the schema of a 'matches schema(...)' assertion. Relative
+// paths resolve against baseDir.
+func (a *Assertion) loadSchema(baseDir string) error {
+	if a.Schema == "" {
+		return nil
+	}
+
+	path := a.Schema
+	if !filepath.IsAbs(path) && baseDir != "" {
+		path = filepath.Join(baseDir, path)
+	}
+	schema, err := jsonschema.Load(path)
+	if err != nil {
+		return err
+	}
+	a.Schema = path
+	a.Value = schema
+	return nil
+}
+
+// resolve substitutes variables in an expected value that refers to them and
+// parses the result. Values still referring to unknown variables are kept.
+func (a Assertion) resolve(replace func(string) string) (Assertion, error) {
+	if !strings.Contains(a.Raw, "${") {
+		return a, nil
+	}
+	a.Raw = replace(a.Raw)
+	if err := a.parseValue(); err != nil {
+		return a, err
+	}
+	return a, nil
+}
+
+// actual reads the asserted field from the response
+func (a Assertion) actual(resp *ir.Response) (any, bool, error) {
+	switch a.Type {
+	case AssertStatus:
+		return float64(resp.Status), true, nil
+	case AssertLatency:
+		return time.Duration(resp.LatencyMs * float64(time.Millisecond)), true, nil
+	case AssertSize:
+		return float64(resp.SizeBytes), true, nil
+	case AssertHeader:
+		return extract.Value(resp, ir.ExtractRule{Header: a.Path})
+	case AssertCookie:
+		return extract.Value(resp, ir.ExtractRule{Cookie: a.Path})
+	}
+
+	value, found, err := extract.JSONPath(resp.Body, a.Path)
+	if err != nil || !found {
+		return nil, false, err
+	}
+	if a.TypeOf {
+		return expr.TypeOf(value), true, nil
+	}
+	return value, true, nil
+}
+
+func compare(op string, actual, expected any) bool {
+	if op == "between" {
+		bounds := expected.([2]any)
+		low, _ := expr.Compare(">=", actual, bounds[0])
+		high, _ := expr.Compare("<=", actual, bounds[1])
+		return low && high
+	}
+	passed, _ := expr.Compare(op, actual, expected)
+	return passed
+}
@@ -0,0 +1,245 @@
+package scenario
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseAssertion_Errors(t *testing.T) {
+	tests := []struct {
+		field, operator, value string
+	}{
+		{"status", "~=", "200"},
+		{"status", ">", "ok"},
+		{"status", "in", "200"},
+		{"status", "exists", ""},
+		{"latency", "<", "soon"},
+		{"body.name", "matches", "/[a-/"},
+		{"body.name", "exists", "true"},
+		{"body.items type", "==", "list"},
+		{"type(body.items)", ">", "array"},
+		{"header.", "==", "x"},
+		{"response.code", "==", "200"},
+		{"body.items[", "==", "1"},
+		{"latency", "between", "100ms"},
+		{"status", "matches", `schema("status.json")`},
+		{"body", "matches", `schema(status.json)`},
+		{"status", "==", "200 || stauts == 204"},
+		{"len(body.items)", ">", "two"},
+		{"!(status >= 500", "", ""},
+		{"status", "", ""},
+		{"len(body.items)", "", ""},
+	}
+
+	for _, tt := range tests {
+		if _, err := ParseAssertion(tt.field, tt.operator, tt.value); err == nil {
+			t.Errorf("%s %s %s: expected an error", tt.field, tt.operator, tt.value)
+		}
+	}
+}
+
+func TestParser_InvalidAssertionPosition(t *testing.T) {
+	input := `request health {
+  curl http://localhost/health
+  assert {
+    status == 200
+    status >> 200
+  }
+}`
+
+	_, err := NewParser(input).Parse()
+	if err == nil || !strings.Contains(err.Error(), "5:") {
+		t.Errorf("expected assertion error on line 5, got %v", err)
+	}
+
+	// Brackets in a regex do not swallow the closing brace
+	input = `request health {
+  curl http://localhost/health
+  assert { body.a matches /x[/ }
+}
+
+scenario smoke {
+  load 1 iterations with 1 vus
+  run health
+}`
+	_, err = NewParser(input).Parse()
+	if err == nil || !strings.Contains(err.Error(), "at 3:12: invalid regex") {
+		t.Errorf("expected regex error at 3:12, got %v", err)
+	}
+}
+
+func TestExecutor_Assertions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("X-Request-Id", "req-42")
+		w.Header().Set("X-Remaining", "97")
+		http.SetCookie(w, &http.Cookie{Name: "theme", Value: "dark", Expires: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)})
+		http.SetCookie(w, &http.Cookie{Name: "sid", Value: "abc123", Path: "/"})
+		switch r.URL.Path {
+		case "/users/7":
+			fmt.Fprint(w, `{"id": 7, "name": "Ada", "email": "ada@example.com", "score": 9.5, "admin": true, "manager": null, "roles": ["admin", "ops"], "meta": {"v": 2}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	input := `var base = "` + server.URL + `"
+var user_id = 7
+
+request user {
+  curl ${base}/users/${user_id}
+  assert {
+    status == 200
+    status in [200, 201]
+    status between 200 and 299
+    body.score between ${user_id} and 10
+    latency < 5s
+    size > 10B
+    size < 1KB
+    body.id == ${user_id}
+    body.id >= 7
+    body.score > 9.25
+    body.name == "Ada"
+    body.name != 'Bob'
+    body.email matches /^[a-z]+@example\.com$/
+    body.name matches /^ada$/i
+    body.name in ["Ada", "Grace"]
+    body.name not in [Bob, Eve]
+    body.admin == true
+    body.manager == null
+    body.roles contains "ops"
+    body.roles not contains "root"
+    body.meta contains v
+    body.roles type == array
+    type(body.meta) == object
+    type(body.score) == number
+    body.roles.length() == 2
+    body.email exists
+    body.password not exists
+    header.content-type contains "application/json"
+    header.X-Request-Id matches /^req-\d+$/
+    header.x-remaining > 50
+    header.X-Missing not exists
+    cookie.sid == abc123
+    cookie.sid exists
+    cookie.theme == dark
+    status == 200 || status == 204
+    !(status >= 500)
+    len(body.roles) == 2 && body.admin
+    body.score * 2 > ${user_id} + 10
+    header("x-remaining") > 50 && cookie("sid") == "abc123" && cookie("theme") == "dark"
+  }
+}
+
+request failing {
+  curl ${base}/users/${user_id}
+  assert {
+    status < 300
+    body.id > 100
+    header.X-Request-Id == "req-1"
+    cookie.missing exists
+    body.roles type == string
+  }
+}
+
+scenario checks {
+  load 1 iterations with 1 vus
+  run user -> failing
+}`
+
+	result := runScenario(t, input, "checks")
+
+	user, failing := result.Stats.Requests["user"], result.Stats.Requests["failing"]
+	for _, check := range user.Checks {
+		if check.Fails != 0 {
+			t.Errorf("check failed: %s (%s)", check.Name, user.LastError)
+		}
+	}
+
+	if result.Stats.ChecksFailed != 4 {
+		t.Errorf("%d failed checks, want 4", result.Stats.ChecksFailed)
+	}
+	if want := "assertion failed: body.roles type == string (got array)"; failing.LastError != want {
+		t.Errorf("error = %q, want %q", failing.LastError, want)
+	}
+}
+
+func TestExecutor_SchemaAssertion(t *testing.T) {
+	dir := t.TempDir()
+	schema := `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "type": "object",
+  "required": ["id", "email"],
+  "properties": {
+    "id": {"type": "integer"},
+    "email": {"type": "string"},
+    "roles": {"type": "array", "items": {"enum": ["admin", "ops"]}}
+  }
+}`
+	if err := os.WriteFile(filepath.Join(dir, "user.schema.json"), []byte(schema), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/users/1":
+			fmt.Fprint(w, `{"id": 1, "email": "ada@example.com", "roles": ["admin"]}`)
+		case "/users/2":
+			fmt.Fprint(w, `{"id": "2", "roles": ["admin", "root"]}`)
+		}
+	}))
+	defer server.Close()
+
+	input := `var base = "` + server.URL + `"
+
+request valid {
+  curl ${base}/users/1
+  assert body matches schema("user.schema.json")
+}
+
+request invalid {
+  curl ${base}/users/2
+  assert body matches schema("user.schema.json")
+}
+
+scenario users {
+  load 1 iterations with 1 vus
+  run valid -> invalid
+}`
+
+	parser := NewParser(input)
+	parser.BaseDir = dir
+	s, err := parser.Parse()
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	compiled, err := NewCompiler().Compile(s, "users")
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	result, err := NewExecutor().Execute(context.Background(), compiled)
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+
+	if valid := result.Stats.Requests["valid"]; valid.Failed != 0 {
+		t.Errorf("valid user failed: %s", valid.LastError)
+	}
+	want := `assertion failed: body matches schema("user.schema.json") (/email: required property is missing; /id: expected integer, got string; /roles/1: must be one of ["admin","ops"])`
+	if invalid := result.Stats.Requests["invalid"]; invalid.LastError != want {
+		t.Errorf("error = %s\nwant %s", invalid.LastError, want)
+	}
+
+	if _, err := NewParser(strings.ReplaceAll(input, "user.schema.json", "missing.json")).Parse(); err == nil {
+		t.Error("expected an error for a missing schema file")
+	}
+}
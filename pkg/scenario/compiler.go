@@ -13,6 +13,7 @@ import (
 type Compiler struct {
 	parser *parser.CurlParser
 	vars   map[string]string
+	looped map[string]bool // Data sets iterated by for each in the current scenario
 }
 
 // NewCompiler creates a new scenario compiler
@@ -36,10 +37,14 @@ func (c *Compiler) Compile(scenario *Scenario, scenarioName string) (*CompiledSc
 	}
 
 	compiled := &CompiledScenario{
-		Name:      scenarioName,
-		Load:      scenarioDef.Load,
-		Variables: c.vars,
+		Name:          scenarioName,
+		Load:          scenarioDef.Load,
+		Variables:     c.vars,
+		Data:          make(map[string]*DataSet),
+		Thresholds:    scenarioDef.Thresholds,
+		SharedCookies: scenarioDef.SharedCookies,
 	}
+	c.looped = make(map[string]bool)
 
 	// Compile setup
 	for _, setupReq := range scenario.Setup {
@@ -65,6 +70,34 @@ func (c *Compiler) Compile(scenario *Scenario, scenarioName string) (*CompiledSc
 		compiled.Main = nodes
 	}
 
+	// Data sets not consumed by a for each are bound to every iteration
+	for name, rows := range scenario.Data {
+		if c.looped[name] {
+			continue
+		}
+		mode := scenario.DataModes[name]
+		if mode == "" {
+			mode = DataSequential
+		}
+		compiled.Data[name] = &DataSet{
+			Name: name,
+			Rows: rows,
+			Mode: mode,
+		}
+	}
+
+	// Requests an evaluator can branch to, by name
+	for _, request := range scenario.Requests {
+		if request.Evaluator != nil {
+			steps, err := c.compileSteps(scenario)
+			if err != nil {
+				return nil, err
+			}
+			compiled.Steps = steps
+			break
+		}
+	}
+
 	// Compile teardown
 	for _, teardownReq := range scenario.Teardown {
 		request, ok := scenario.Requests[teardownReq]
@@ -83,7 +116,106 @@ func (c *Compiler) Compile(scenario *Scenario, scenarioName string) (*CompiledSc
 	return compiled, nil
 }
 
+// compileSteps compiles every request in the file as a branch target
+func (c *Compiler) compileSteps(scenario *Scenario) (map[string]*RequestNode, error) {
+	steps := make(map[string]*RequestNode, len(scenario.Requests))
+	for name, request := range scenario.Requests {
+		node, err := c.compileRequestNode(scenario, request)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile request '%s': %w", name, err)
+		}
+		steps[name] = node
+	}
+	return steps, nil
+}
+
 func (c *Compiler) compileFlow(scenario *Scenario, flow *Flow) ([]*RequestNode, error) {
+	switch flow.Type {
+	case FlowNested:
+		if len(flow.Steps) != 1 {
+			return nil, fmt.Errorf("nested flow must have exactly one parent request")
+		}
+
+		request, ok := scenario.Requests[flow.Steps[0]]
+		if !ok {
+			return nil, fmt.Errorf("request '%s' not found", flow.Steps[0])
+		}
+
+		node, err := c.compileRequestNode(scenario, request)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile request '%s': %w", flow.Steps[0], err)
+		}
+
+		children, err := c.compileFlows(scenario, flow.Children)
+		if err != nil {
+			return nil, err
+		}
+		node.Children = append(node.Children, children...)
+
+		return []*RequestNode{node}, nil
+
+	case FlowConditional:
+		then, err := c.compileFlows(scenario, flow.Children)
+		if err != nil {
+			return nil, err
+		}
+
+		otherwise, err := c.compileFlows(scenario, flow.Else)
+		if err != nil {
+			return nil, err
+		}
+
+		cond, err := c.compileCondition(flow.Condition)
+		if err != nil {
+			return nil, err
+		}
+
+		return []*RequestNode{{
+			Condition: flow.Condition,
+			cond:      cond,
+			Children:  then,
+			Else:      otherwise,
+		}}, nil
+
+	case FlowParallel:
+		node := &RequestNode{Parallel: true, MaxParallel: flow.MaxParallel}
+		for _, branch := range flow.Children {
+			nodes, err := c.compileFlow(scenario, branch)
+			if err != nil {
+				return nil, err
+			}
+			// A branch of several steps runs them in order under a plain
+			// branch node
+			if len(nodes) == 1 {
+				node.Children = append(node.Children, nodes[0])
+			} else {
+				node.Children = append(node.Children, &RequestNode{Children: nodes})
+			}
+		}
+		return []*RequestNode{node}, nil
+
+	case FlowForEach:
+		rows, ok := scenario.Data[flow.ForEach.DataName]
+		if !ok {
+			return nil, fmt.Errorf("data '%s' not found", flow.ForEach.DataName)
+		}
+		c.looped[flow.ForEach.DataName] = true
+
+		children, err := c.compileFlows(scenario, flow.Children)
+		if err != nil {
+			return nil, err
+		}
+
+		return []*RequestNode{{
+			ForEach:  flow.ForEach,
+			Rows:     rows,
+			Children: children,
+		}}, nil
+
+	case FlowThink:
+		return []*RequestNode{{ThinkTime: flow.ThinkTime}}, nil
+	}
+
 	var nodes []*RequestNode
 
 	for _, stepName := range flow.Steps {
@@ -100,6 +232,25 @@ func (c *Compiler) compileFlow(scenario *Scenario, flow *Flow) ([]*RequestNode,
 		nodes = append(nodes, node)
 	}
 
+	children, err := c.compileFlows(scenario, flow.Children)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(nodes, children...), nil
+}
+
+func (c *Compiler) compileFlows(scenario *Scenario, flows []*Flow) ([]*RequestNode, error) {
+	var nodes []*RequestNode
+
+	for _, flow := range flows {
+		compiled, err := c.compileFlow(scenario, flow)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, compiled...)
+	}
+
 	return nodes, nil
 }
 
@@ -110,11 +261,34 @@ func (c *Compiler) compileRequestNode(scenario *Scenario, request *Request) (*Re
 		return nil, err
 	}
 
+	// Scenario variables in expected values are known now; the rest are
+	// resolved per request
+	asserts := make([]Assertion, 0, len(request.Assert))
+	for _, a := range request.Assert {
+		resolved, err := a.resolve(c.replaceVariables)
+		if err != nil {
+			return nil, fmt.Errorf("request '%s': invalid assertion '%s': %w", request.Name, a, err)
+		}
+		if resolved.Expr != nil {
+			resolved.Expr = resolved.Expr.Bind(c.staticVar)
+		}
+		asserts = append(asserts, resolved)
+	}
+
+	cond, err := c.compileCondition(request.Condition)
+	if err != nil {
+		return nil, fmt.Errorf("request '%s': %w", request.Name, err)
+	}
+
 	node := &RequestNode{
+		Name:      request.Name,
 		IR:        irSpec,
 		Extract:   request.Extract,
-		Assert:    request.Assert,
+		Assert:    asserts,
+		Evaluator: request.Evaluator,
+		Retry:     request.Retry,
 		Condition: request.Condition,
+		cond:      cond,
 		Parallel:  request.Parallel,
 	}
 
@@ -146,12 +320,22 @@ func (c *Compiler) compileRequest(request *Request) (*ir.IR, error) {
 		return nil, fmt.Errorf("failed to parse curl: %w", err)
 	}
 
-	// Add metadata
+	// Add metadata; the ID is the request name, so exchange hooks and
+	// orchestrator steps can tell requests apart
 	if irSpec.Metadata == nil {
 		irSpec.Metadata = &ir.Metadata{}
 	}
+	irSpec.Metadata.ID = request.Name
 	irSpec.Metadata.Source = "scenario"
 
+	if request.Evaluator != nil {
+		if irSpec.Evaluation == nil {
+			irSpec.Evaluation = ir.DefaultEvaluation()
+		}
+		irSpec.Evaluation.Evaluator = request.Evaluator.Type
+		irSpec.Evaluation.EvaluatorPath = request.Evaluator.Path
+	}
+
 	// Configure retry if specified
 	if request.Retry != nil {
 		// Store retry config in evaluation vars
@@ -221,11 +405,39 @@ func ReplaceRuntimeVariables(input string, vu int, iter int, extractedVars map[s
 	result = strings.ReplaceAll(result, "${__ITER}", fmt.Sprintf("%d", iter))
 	result = strings.ReplaceAll(result, "${ITER}", fmt.Sprintf("%d", iter))
 
-	// Replace extracted variables
-	for key, value := range extractedVars {
-		placeholder := fmt.Sprintf("${%s}", key)
-		result = strings.ReplaceAll(result, placeholder, fmt.Sprintf("%v", value))
+	// Replace extracted variables and data row fields (${product.id})
+	return runtimeVarPattern.ReplaceAllStringFunc(result, func(match string) string {
+		if value, ok := lookupVariable(extractedVars, match[2:len(match)-1]); ok {
+			return fmt.Sprintf("%v", value)
+		}
+		return match
+	})
+}
+
+var runtimeVarPattern = regexp.MustCompile(`\$\{[\w.-]+\}`)
+
+// lookupVariable resolves a variable name, following dots into data rows and
+// extracted objects when the full name is not set
+func lookupVariable(vars map[string]any, name string) (any, bool) {
+	if value, ok := vars[name]; ok {
+		return value, true
 	}
 
-	return result
+	parts := strings.Split(name, ".")
+	current, ok := vars[parts[0]]
+	if !ok {
+		return nil, false
+	}
+
+	for _, part := range parts[1:] {
+		m, ok := current.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if current, ok = m[part]; !ok {
+			return nil, false
+		}
+	}
+
+	return current, true
 }
@@ -0,0 +1,129 @@
+package scenario
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/vikasavnish/httptool/pkg/expr"
+	"github.com/vikasavnish/httptool/pkg/extract"
+	"github.com/vikasavnish/httptool/pkg/ir"
+	"github.com/vikasavnish/httptool/pkg/parser"
+)
+
+// responseFields are the names expressions can use besides ${variables}.
+// In conditions they refer to the most recent response of the iteration.
+var responseFields = []string{"status", "latency", "size", "body", "header", "cookie"}
+
+// responseTypes are the types of the response fields that always have one
+var responseTypes = map[string]string{"status": "number", "latency": "duration", "size": "number"}
+
+// compileExpression compiles a condition or expression assertion
+func compileExpression(src string) (*expr.Expr, error) {
+	return expr.Compile(src, responseFields...)
+}
+
+// lowerCondition checks the expression of an if statement, reporting syntax
+// errors at their line and column
+func lowerCondition(cond *parser.Condition) (string, error) {
+	if _, err := compileExpression(cond.Expr); err != nil {
+		pos := cond.Pos
+		var syntax *expr.SyntaxError
+		if errors.As(err, &syntax) {
+			pos.Column += syntax.Offset
+			err = errors.New(syntax.Msg)
+		}
+		return "", fmt.Errorf("invalid condition '%s' at %s: %w", cond.Expr, pos, err)
+	}
+	return cond.Expr, nil
+}
+
+// responseEnv gives expressions a response and the variables of the
+// iteration. resp is nil before the iteration's first response.
+type responseEnv struct {
+	resp *ir.Response
+	vars func(name string) (any, bool)
+}
+
+func (e responseEnv) Field(name string) (any, bool) {
+	if e.resp == nil {
+		return nil, false
+	}
+
+	switch name {
+	case "status":
+		return float64(e.resp.Status), true
+	case "latency":
+		return time.Duration(e.resp.LatencyMs * float64(time.Millisecond)), true
+	case "size":
+		return float64(e.resp.SizeBytes), true
+	case "body":
+		return e.resp.Body, true
+	case "header":
+		return e.lookup(func(name string) ir.ExtractRule { return ir.ExtractRule{Header: name} }), true
+	case "cookie":
+		return e.lookup(func(name string) ir.ExtractRule { return ir.ExtractRule{Cookie: name} }), true
+	}
+	return nil, false
+}
+
+// lookup builds header() and cookie(), which return null for missing names
+func (e responseEnv) lookup(rule func(name string) ir.ExtractRule) expr.Func {
+	return func(args ...any) (any, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("expected 1 argument, got %d", len(args))
+		}
+		value, found, err := extract.Value(e.resp, rule(expr.Format(args[0])))
+		if !found {
+			return nil, err
+		}
+		return value, err
+	}
+}
+
+func (e responseEnv) Var(name string) (any, bool) {
+	if e.vars == nil {
+		return nil, false
+	}
+	return e.vars(name)
+}
+
+// runtimeVars looks variables up the way ReplaceRuntimeVariables does
+func runtimeVars(vu int, iter int, vars map[string]any) func(string) (any, bool) {
+	return func(name string) (any, bool) {
+		switch name {
+		case "VU", "__VU":
+			return float64(vu), true
+		case "ITER", "__ITER":
+			return float64(iter), true
+		}
+		return lookupVariable(vars, name)
+	}
+}
+
+// staticVar looks up a scenario variable for binding into expressions.
+// Numeric values become numbers.
+func (c *Compiler) staticVar(name string) (any, bool) {
+	value, ok := c.vars[name]
+	if !ok {
+		return nil, false
+	}
+	if n, err := strconv.ParseFloat(value, 64); err == nil {
+		return n, true
+	}
+	return value, true
+}
+
+// compileCondition compiles the condition of a branch or request, fixing the
+// scenario variables it uses. An empty condition always holds.
+func (c *Compiler) compileCondition(src string) (*expr.Expr, error) {
+	if src == "" {
+		return nil, nil
+	}
+	cond, err := compileExpression(src)
+	if err != nil {
+		return nil, fmt.Errorf("invalid condition '%s': %w", src, err)
+	}
+	return cond.Bind(c.staticVar), nil
+}
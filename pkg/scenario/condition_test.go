@@ -0,0 +1,138 @@
+package scenario
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParser_InvalidConditionPosition(t *testing.T) {
+	tests := []struct {
+		condition string
+		want      string
+	}{
+		{`status = 200`, "at 6:13: unexpected '=' (use == to compare)"},
+		{`${plan} == "pro" && stauts < 400`, "at 6:26: unknown name 'stauts'"},
+		{`len(body.items > 0`, "at 6:24: expected ')'"},
+	}
+
+	for _, tt := range tests {
+		input := `request health {
+  curl http://localhost/health
+}
+
+scenario s {
+  if ` + tt.condition + ` {
+    run health
+  }
+}`
+		_, err := NewParser(input).Parse()
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: error %v, want %q", tt.condition, err, tt.want)
+		}
+	}
+}
+
+func TestExecutor_Conditions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Plan", "pro")
+		switch r.URL.Path {
+		case "/flags":
+			fmt.Fprint(w, `{"beta": true, "regions": ["eu", "us"], "quota": {"used": 40, "limit": 50}}`)
+		default:
+			fmt.Fprint(w, `{}`)
+		}
+	}))
+	defer server.Close()
+
+	input := `var base = "` + server.URL + `"
+var region = "eu"
+var retries = 3
+
+request flags {
+  curl ${base}/flags
+  extract {
+    owner = $.owner
+  }
+}
+
+request beta {
+  curl ${base}/beta
+}
+request stable {
+  curl ${base}/stable
+}
+request regional {
+  curl ${base}/regional
+}
+request pro {
+  curl ${base}/pro
+}
+request owned {
+  curl ${base}/owned
+}
+request never {
+  curl ${base}/never
+}
+
+scenario flow {
+  load 1 iterations with 1 vus
+  run flags
+  if body.quota.limit - body.quota.used < 5 {
+    run never
+  } else if ${region} in body.regions && ${retries} * 2 > 5 && body.quota.used / body.quota.limit >= 80% {
+    run regional
+  }
+  if body.beta == null && lower(header("x-plan")) == "pro" && !(latency > 1m) {
+    run pro
+  }
+  run flags
+  if status == 200 && body.beta {
+    run beta
+  } else {
+    run stable
+  }
+  if ${owner} != null {
+    run owned
+  }
+  if status >= 500 || ${ITER} > 1 {
+    run never
+  }
+}`
+
+	result := runScenario(t, input, "flow")
+
+	for _, name := range []string{"flags", "regional", "pro", "beta"} {
+		if result.Stats.Requests[name] == nil {
+			t.Errorf("%s did not run", name)
+		}
+	}
+	for _, name := range []string{"stable", "owned", "never"} {
+		if result.Stats.Requests[name] != nil {
+			t.Errorf("%s ran", name)
+		}
+	}
+}
+
+func TestExecutor_ConditionBeforeFirstRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	input := `request health {
+  curl ` + server.URL + `/health
+}
+
+scenario s {
+  load 1 iterations with 1 vus
+  if status == 200 {
+    run health
+  }
+}`
+
+	result := runScenario(t, input, "s")
+	if result.Stats.Requests["health"] != nil {
+		t.Error("a condition on status held before any request")
+	}
+}
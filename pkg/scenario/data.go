@@ -0,0 +1,220 @@
+package scenario
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+)
+
+// DataMode defines how rows of a data set are assigned to iterations
+type DataMode string
+
+const (
+	DataSequential DataMode = "sequential" // Next row for every iteration, shared by all VUs
+	DataPerVU      DataMode = "per_vu"     // Each VU keeps the same row for all its iterations
+	DataRandom     DataMode = "random"     // Random row for every iteration
+	DataUnique     DataMode = "unique"     // Every row is used at most once per run
+)
+
+// ParseDataMode validates a data mode name, defaulting to sequential
+func ParseDataMode(s string) (DataMode, error) {
+	switch DataMode(s) {
+	case "":
+		return DataSequential, nil
+	case DataSequential, DataPerVU, DataRandom, DataUnique:
+		return DataMode(s), nil
+	}
+	return "", fmt.Errorf("unknown data mode '%s' (expected sequential, per_vu, random or unique)", s)
+}
+
+// DataSet is a named set of rows bound to iterations at runtime
+type DataSet struct {
+	Name string
+	Rows []map[string]any
+	Mode DataMode
+	next atomic.Int64
+}
+
+// Row returns the row assigned to an iteration of a VU.
+// It returns false once a unique data set has been used up.
+func (d *DataSet) Row(vu int) (map[string]any, bool) {
+	n := int64(len(d.Rows))
+	if n == 0 {
+		return nil, false
+	}
+
+	switch d.Mode {
+	case DataPerVU:
+		return d.Rows[int64(vu-1)%n], true
+	case DataRandom:
+		return d.Rows[rand.Int63n(n)], true
+	case DataUnique:
+		i := d.next.Add(1) - 1
+		if i >= n {
+			return nil, false
+		}
+		return d.Rows[i], true
+	default:
+		i := d.next.Add(1) - 1
+		return d.Rows[i%n], true
+	}
+}
+
+// LoadDataFile reads rows from a CSV, JSON or NDJSON file, chosen by extension
+func LoadDataFile(path string) ([]map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return parseCSVRows(data)
+	case ".json":
+		return parseJSONRows(data)
+	case ".ndjson", ".jsonl":
+		return parseNDJSONRows(data)
+	default:
+		return nil, fmt.Errorf("unsupported data file type '%s' (expected .csv, .json or .ndjson)", filepath.Ext(path))
+	}
+}
+
+// ParseInlineData parses an inline data array. Object keys may be left
+// unquoted: [{ id: 1, name: "A" }]
+func ParseInlineData(raw string) ([]map[string]any, error) {
+	return parseJSONRows([]byte(quoteBareKeys(raw)))
+}
+
+func parseCSVRows(data []byte) ([]map[string]any, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	header := records[0]
+	rows := make([]map[string]any, 0, len(records)-1)
+	for _, record := range records[1:] {
+		row := make(map[string]any, len(header))
+		for i, key := range header {
+			if i < len(record) {
+				row[key] = record[i]
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+func parseJSONRows(data []byte) ([]map[string]any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var values []any
+	if err := decoder.Decode(&values); err != nil {
+		return nil, err
+	}
+
+	rows := make([]map[string]any, 0, len(values))
+	for i, value := range values {
+		row, ok := value.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("row %d is not an object", i+1)
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+func parseNDJSONRows(data []byte) ([]map[string]any, error) {
+	var rows []map[string]any
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		decoder := json.NewDecoder(strings.NewReader(text))
+		decoder.UseNumber()
+
+		var row map[string]any
+		if err := decoder.Decode(&row); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, scanner.Err()
+}
+
+// quoteBareKeys quotes unquoted object keys so relaxed inline data is valid JSON
+func quoteBareKeys(raw string) string {
+	var out strings.Builder
+	inString := false
+	expectKey := false
+
+	for i := 0; i < len(raw); i++ {
+		ch := raw[i]
+
+		if inString {
+			out.WriteByte(ch)
+			if ch == '\\' && i+1 < len(raw) {
+				i++
+				out.WriteByte(raw[i])
+			} else if ch == '"' {
+				inString = false
+			}
+			continue
+		}
+
+		switch {
+		case ch == '"':
+			inString = true
+			expectKey = false
+		case ch == '{' || ch == ',':
+			expectKey = true
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+		case expectKey && (ch == '_' || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z'):
+			j := i
+			for j < len(raw) && (raw[j] == '_' || raw[j] == '-' || raw[j] >= 'a' && raw[j] <= 'z' ||
+				raw[j] >= 'A' && raw[j] <= 'Z' || raw[j] >= '0' && raw[j] <= '9') {
+				j++
+			}
+			expectKey = false
+			if !strings.HasPrefix(strings.TrimLeft(raw[j:], " \t\r\n"), ":") {
+				out.WriteString(raw[i:j])
+				i = j - 1
+				continue
+			}
+			out.WriteString(`"` + raw[i:j] + `"`)
+			i = j - 1
+			continue
+		default:
+			expectKey = false
+		}
+
+		out.WriteByte(ch)
+	}
+
+	return out.String()
+}
@@ -0,0 +1,138 @@
+package scenario
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestParseInlineData_BareKeys(t *testing.T) {
+	rows, err := ParseInlineData(`[
+  { id: 1, name: "Product A", tags: [true, false] },
+  { "id": 2, name: "a, b: c" }
+]`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(rows))
+	}
+	if got := ReplaceRuntimeVariables("${p.id}/${p.name}", 1, 1, map[string]any{"p": rows[0]}); got != "1/Product A" {
+		t.Errorf("row 1 substituted as %q", got)
+	}
+	if rows[1]["name"] != "a, b: c" {
+		t.Errorf("string value altered: %v", rows[1]["name"])
+	}
+}
+
+func TestLoadDataFile(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"users.csv":    "id,email\n1,a@example.com\n2,b@example.com\n",
+		"users.json":   `[{"id": 1, "email": "a@example.com"}, {"id": 2, "email": "b@example.com"}]`,
+		"users.ndjson": "{\"id\": 1, \"email\": \"a@example.com\"}\n\n{\"id\": 2, \"email\": \"b@example.com\"}\n",
+	}
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		rows, err := LoadDataFile(path)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(rows) != 2 || rows[1]["email"] != "b@example.com" {
+			t.Errorf("%s: unexpected rows %v", name, rows)
+		}
+	}
+}
+
+func TestDataSet_Modes(t *testing.T) {
+	rows := []map[string]any{{"n": "a"}, {"n": "b"}}
+
+	sequential := &DataSet{Rows: rows, Mode: DataSequential}
+	var got []string
+	for i := 0; i < 3; i++ {
+		row, _ := sequential.Row(1)
+		got = append(got, row["n"].(string))
+	}
+	if strings.Join(got, "") != "aba" {
+		t.Errorf("sequential rows = %v", got)
+	}
+
+	perVU := &DataSet{Rows: rows, Mode: DataPerVU}
+	if row, _ := perVU.Row(2); row["n"] != "b" {
+		t.Errorf("per_vu row for VU 2 = %v", row)
+	}
+	if row, _ := perVU.Row(3); row["n"] != "a" {
+		t.Errorf("per_vu row for VU 3 = %v", row)
+	}
+
+	unique := &DataSet{Rows: rows, Mode: DataUnique}
+	unique.Row(1)
+	unique.Row(2)
+	if _, ok := unique.Row(1); ok {
+		t.Error("unique data set should be exhausted after every row is used")
+	}
+}
+
+func TestExecutor_ForEach(t *testing.T) {
+	var mu sync.Mutex
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	input := `var base = "` + server.URL + `"
+
+data products = [{ id: 1 }, { id: 2 }, { id: 3 }]
+data users = [{ name: "ann" }, { name: "bob" }] with unique
+
+request get_user {
+  curl ${base}/users/${users.name}
+}
+
+request get_product {
+  curl ${base}/products/${product.id}
+}
+
+scenario test {
+  load 5 iterations with 1 vus
+  run get_user
+  for each product in products {
+    run get_product
+  }
+}`
+
+	s, err := NewParser(input).Parse()
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+
+	compiled, err := NewCompiler().Compile(s, "test")
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+
+	if _, err := NewExecutor().Execute(context.Background(), compiled); err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+
+	sort.Strings(paths)
+	want := "/products/1 /products/1 /products/2 /products/2 /products/3 /products/3 /users/ann /users/bob"
+	if got := strings.Join(paths, " "); got != want {
+		t.Errorf("requested paths:\n got  %s\n want %s", got, want)
+	}
+}
@@ -0,0 +1,221 @@
+package scenario
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/vikasavnish/httptool/pkg/evaluator"
+	"github.com/vikasavnish/httptool/pkg/ir"
+)
+
+// The test binary doubles as a go evaluator when SCENARIO_EVALUATOR is set.
+// In pooled mode it refuses to run one-shot.
+func TestMain(m *testing.M) {
+	if mode := os.Getenv("SCENARIO_EVALUATOR"); mode != "" {
+		switch {
+		case os.Args[len(os.Args)-1] == evaluator.PersistentFlag:
+			serve()
+		case mode == "pooled":
+			os.Exit(1)
+		default:
+			var ctx ir.EvaluationContext
+			json.NewDecoder(os.Stdin).Decode(&ctx)
+			json.NewEncoder(os.Stdout).Encode(decide(&ctx))
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// serve answers requests the way a persistent worker does
+func serve() {
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		var req struct {
+			ID      uint64                `json:"id"`
+			Type    string                `json:"type"`
+			Context *ir.EvaluationContext `json:"context"`
+		}
+		json.Unmarshal(scanner.Bytes(), &req)
+
+		resp := map[string]any{"id": req.ID}
+		if req.Type == "evaluate" {
+			resp["decision"] = decide(req.Context)
+		}
+		json.NewEncoder(os.Stdout).Encode(resp)
+	}
+}
+
+func decide(ctx *ir.EvaluationContext) *ir.EvaluatorDecision {
+	switch {
+	case strings.HasSuffix(ctx.Request.URL, "/login"):
+		return &ir.EvaluatorDecision{
+			Decision: "pass",
+			Actions: &ir.Actions{Extract: map[string]ir.ExtractRule{
+				"token": {JSONPath: "$.token"},
+			}},
+		}
+	case strings.HasSuffix(ctx.Request.URL, "/reject"):
+		return &ir.EvaluatorDecision{Decision: "fail", Reason: "rejected"}
+	case strings.HasSuffix(ctx.Request.URL, "/expired"):
+		return &ir.EvaluatorDecision{
+			Decision:  "branch",
+			Actions:   &ir.Actions{Goto: "refresh"},
+			Mutations: &ir.Mutations{Vars: map[string]any{"reason": "expired"}},
+		}
+	case strings.HasSuffix(ctx.Request.URL, "/ping"):
+		return &ir.EvaluatorDecision{Decision: "branch", Actions: &ir.Actions{Goto: "pong"}}
+	case strings.HasSuffix(ctx.Request.URL, "/pong"):
+		return &ir.EvaluatorDecision{Decision: "branch", Actions: &ir.Actions{Goto: "ping"}}
+	case strings.HasSuffix(ctx.Request.URL, "/lost"):
+		return &ir.EvaluatorDecision{Decision: "branch", Actions: &ir.Actions{Goto: "nowhere"}}
+	}
+	return &ir.EvaluatorDecision{Decision: "pass", Reason: fmt.Sprintf("token %v", ctx.Vars["token"])}
+}
+
+func TestExecutor_EvaluatorExtract(t *testing.T) {
+	t.Setenv("SCENARIO_EVALUATOR", "1")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"token":"t1"}`)
+		case "/orders":
+			if r.Header.Get("Authorization") != "Bearer t1" {
+				w.WriteHeader(http.StatusUnauthorized)
+			}
+		}
+	}))
+	defer server.Close()
+
+	input := fmt.Sprintf(`var base = "%s"
+
+request login {
+  curl ${base}/login
+  evaluate go "%s"
+}
+
+request orders {
+  curl -H "Authorization: Bearer ${token}" ${base}/orders
+  assert status == 200
+}
+
+request reject {
+  curl ${base}/reject
+  evaluate go "%s"
+}
+
+scenario flow {
+  load 1 iterations with 1 vus
+  run login -> orders -> reject
+}`, server.URL, os.Args[0], os.Args[0])
+
+	result := runScenario(t, input, "flow")
+
+	requests := result.Stats.Requests
+	if requests["login"].Failed != 0 || requests["orders"].Failed != 0 {
+		t.Errorf("login failed %d, orders failed %d: %s", requests["login"].Failed, requests["orders"].Failed, requests["orders"].LastError)
+	}
+	if requests["reject"].Failed != 1 || requests["reject"].LastError != "evaluation failed: rejected" {
+		t.Errorf("reject = %+v", requests["reject"])
+	}
+}
+
+func TestExecutor_EvaluatorBranch(t *testing.T) {
+	t.Setenv("SCENARIO_EVALUATOR", "1")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/refresh" && r.URL.Query().Get("reason") != "expired" {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	input := fmt.Sprintf(`var base = "%[1]s"
+
+request orders {
+  curl ${base}/expired
+  evaluate go "%[2]s"
+}
+
+request refresh {
+  curl ${base}/refresh?reason=${reason}
+  assert status == 200
+}
+
+request ping {
+  curl ${base}/ping
+  evaluate go "%[2]s"
+}
+
+request pong {
+  curl ${base}/pong
+  evaluate go "%[2]s"
+}
+
+request lost {
+  curl ${base}/lost
+  evaluate go "%[2]s"
+}
+
+scenario flow {
+  load 1 iterations with 1 vus
+  run orders -> ping -> lost
+}`, server.URL, os.Args[0])
+
+	result := runScenario(t, input, "flow")
+
+	requests := result.Stats.Requests
+	if requests["orders"].Failed != 0 || requests["refresh"].Count != 1 || requests["refresh"].Failed != 0 {
+		t.Errorf("orders = %+v, refresh = %+v", requests["orders"], requests["refresh"])
+	}
+	// ping -> pong -> ping runs ping again with the same vars
+	if requests["ping"].Count != 1 || requests["pong"].Failed != 1 || requests["pong"].LastError != "branch loop detected: pong -> ping" {
+		t.Errorf("ping = %+v, pong = %+v", requests["ping"], requests["pong"])
+	}
+	if requests["lost"].Failed != 1 || requests["lost"].LastError != "branch target 'nowhere' not found" {
+		t.Errorf("lost = %+v", requests["lost"])
+	}
+}
+
+func TestExecutor_PersistentEvaluators(t *testing.T) {
+	t.Setenv("SCENARIO_EVALUATOR", "pooled")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	s, err := NewParser(fmt.Sprintf(`request reject {
+  curl %s/reject
+  evaluate go "%s"
+}
+
+scenario flow {
+  load 2 iterations with 1 vus
+  run reject
+}`, server.URL, os.Args[0])).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	compiled, err := NewCompiler().Compile(s, "flow")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := NewExecutor()
+	e.PersistentEvaluators()
+	result, err := e.Execute(context.Background(), compiled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reject := result.Stats.Requests["reject"]; reject.Failed != 2 || reject.LastError != "evaluation failed: rejected" {
+		t.Errorf("reject = %+v", reject)
+	}
+}
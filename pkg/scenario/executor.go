@@ -3,25 +3,46 @@ package scenario
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
 	"math/rand"
-	"regexp"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/vikasavnish/httptool/pkg/evaluator"
 	"github.com/vikasavnish/httptool/pkg/executor"
+	"github.com/vikasavnish/httptool/pkg/extract"
 	"github.com/vikasavnish/httptool/pkg/ir"
+	"github.com/vikasavnish/httptool/pkg/orchestrator"
 )
 
 // Executor runs compiled scenarios
+//
+// Like a browser user, each VU has its own HTTP client, with its own cookie
+// jar and connections, and its own variables, which carry over from one of
+// its iterations to the next. Scenarios with `cookies shared` give every VU
+// the cookie jar that setup and teardown use.
 type Executor struct {
-	httpExecutor   *executor.Executor
+	httpExecutor   *executor.Executor // Runs setup and teardown
 	evalManager    *evaluator.Manager
-	cookieJar      *executor.CookieJar
 	progressChan   chan ProgressUpdate
 	enableProgress bool
+	metrics        *metrics
+	requestHooks   []func(*RequestResult)
+	exchangeHooks  []func(time.Time, *ir.EvaluationContext)
+
+	steps     map[string]*RequestNode // Branch targets by request name
+	sharedJar *executor.CookieJar     // Set when VUs share one cookie jar
+	vus       map[int]*vuState
+	vusMu     sync.Mutex
+}
+
+// vuState is what a VU keeps between iterations
+type vuState struct {
+	http *executor.Executor
+	vars map[string]any // Variables extracted in earlier iterations
 }
 
 // ProgressUpdate represents a progress update during execution
@@ -38,16 +59,24 @@ type ProgressUpdate struct {
 
 // NewExecutor creates a new scenario executor
 func NewExecutor() *Executor {
-	cookieJar := executor.NewCookieJar()
 	return &Executor{
-		httpExecutor:   executor.NewExecutorWithCookieJar(cookieJar),
-		evalManager:    evaluator.NewManager(5 * time.Second),
-		cookieJar:      cookieJar,
+		httpExecutor:   executor.NewExecutor(),
+		evalManager:    evaluator.NewManager(evalTimeout),
 		progressChan:   make(chan ProgressUpdate, 1000),
 		enableProgress: false,
 	}
 }
 
+// evalTimeout bounds each evaluation
+const evalTimeout = 5 * time.Second
+
+// PersistentEvaluators runs evaluators as long-lived workers instead of
+// starting a process per evaluation. The evaluators must support
+// evaluator.PersistentFlag. The workers are stopped when Execute returns.
+func (e *Executor) PersistentEvaluators() {
+	e.evalManager = evaluator.NewPooledManager(evalTimeout, evaluator.PoolConfig{})
+}
+
 // EnableProgress turns on progress reporting
 func (e *Executor) EnableProgress() chan ProgressUpdate {
 	e.enableProgress = true
@@ -66,6 +95,27 @@ func (e *Executor) sendProgress(update ProgressUpdate) {
 	}
 }
 
+// OnRequest registers fn to receive every finished request. Unlike progress
+// updates none are dropped. fn is called concurrently from VU goroutines.
+func (e *Executor) OnRequest(fn func(*RequestResult)) {
+	e.requestHooks = append(e.requestHooks, fn)
+}
+
+// OnExchange registers fn to receive the request and response of every
+// attempt that was sent, with the time it started, e.g. to record a HAR
+// file. fn is called concurrently from VU goroutines.
+func (e *Executor) OnExchange(fn func(start time.Time, ctx *ir.EvaluationContext)) {
+	e.exchangeHooks = append(e.exchangeHooks, fn)
+}
+
+// finishRequest records a finished request and hands it to the hooks
+func (e *Executor) finishRequest(req *RequestResult) {
+	e.metrics.record(req)
+	for _, hook := range e.requestHooks {
+		hook(req)
+	}
+}
+
 // Execute runs a compiled scenario
 func (e *Executor) Execute(ctx context.Context, scenario *CompiledScenario) (*ScenarioResult, error) {
 	result := &ScenarioResult{
@@ -73,21 +123,22 @@ func (e *Executor) Execute(ctx context.Context, scenario *CompiledScenario) (*Sc
 		StartTime: time.Now(),
 		VUResults: make([]*VUResult, 0),
 	}
+	e.metrics = newMetrics()
+	defer e.evalManager.Close()
+	e.vus = make(map[int]*vuState)
+	e.steps = scenario.Steps
+	e.sharedJar = nil
+	if scenario.SharedCookies {
+		e.sharedJar = e.httpExecutor.GetCookieJar()
+	}
 
 	// Run setup
 	if len(scenario.Setup) > 0 {
 		setupVars := make(map[string]any)
 		for _, irSpec := range scenario.Setup {
-			execCtx, err := e.httpExecutor.Execute(irSpec)
-			if err != nil {
+			if _, err := e.httpExecutor.Execute(irSpec); err != nil {
 				return nil, fmt.Errorf("setup failed: %w", err)
 			}
-
-			// Extract variables from setup
-			extractedVars := e.extractVariables(execCtx, nil)
-			for k, v := range extractedVars {
-				setupVars[k] = v
-			}
 		}
 
 		// Store setup vars for VUs to use
@@ -99,11 +150,25 @@ func (e *Executor) Execute(ctx context.Context, scenario *CompiledScenario) (*Sc
 		return nil, fmt.Errorf("no load configuration specified")
 	}
 
+	// Abort-on-fail thresholds cancel the load phase; teardown still runs
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stopWatching := e.watchThresholds(ctx, cancel, scenario.Thresholds)
+
 	// Execute based on load config
-	if scenario.Load.VUs > 0 && scenario.Load.Duration != "" {
+	dropped := 0
+	if usesArrivalRate(scenario.Load) {
+		n, err := e.executeArrivalRate(ctx, scenario, result)
+		if err != nil {
+			return nil, err
+		}
+		dropped = n
+	} else if len(scenario.Load.Stages) > 0 || scenario.Load.RampUp != "" && scenario.Load.VUs > 0 {
+		if err := e.executeStages(ctx, scenario, result); err != nil {
+			return nil, err
+		}
+	} else if scenario.Load.VUs > 0 && scenario.Load.Duration != "" {
 		e.executeVUs(ctx, scenario, result)
-	} else if scenario.Load.RPS > 0 && scenario.Load.Duration != "" {
-		e.executeRPS(ctx, scenario, result)
 	} else if scenario.Load.Iterations > 0 {
 		e.executeIterations(ctx, scenario, result)
 	} else {
@@ -111,6 +176,8 @@ func (e *Executor) Execute(ctx context.Context, scenario *CompiledScenario) (*Sc
 	}
 
 	result.EndTime = time.Now()
+	result.AbortReason = stopWatching()
+	e.closeVUs()
 
 	// Run teardown
 	if len(scenario.Teardown) > 0 {
@@ -122,13 +189,67 @@ func (e *Executor) Execute(ctx context.Context, scenario *CompiledScenario) (*Sc
 			}
 		}
 	}
+	e.httpExecutor.CloseIdleConnections()
 
 	// Calculate stats
-	result.Stats = e.calculateStats(result.VUResults)
+	result.Stats = e.metrics.snapshot()
+	result.Stats.DroppedIterations = dropped
+	result.Thresholds = e.metrics.evaluate(scenario.Thresholds)
 
 	return result, nil
 }
 
+// watchThresholds re-checks abort_on_fail thresholds while the load runs and
+// cancels it on the first failure past the threshold's delay. The returned
+// function stops watching and reports why the run was aborted, if it was.
+func (e *Executor) watchThresholds(ctx context.Context, cancel context.CancelFunc, thresholds []*Threshold) func() string {
+	var abortOn []*Threshold
+	for _, t := range thresholds {
+		if t.AbortOnFail {
+			abortOn = append(abortOn, t)
+		}
+	}
+	if len(abortOn) == 0 {
+		return func() string { return "" }
+	}
+
+	start := time.Now()
+	stop := make(chan struct{})
+	reason := make(chan string, 1)
+
+	go func() {
+		ticker := time.NewTicker(thresholdCheckInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				reason <- ""
+				return
+			case <-ctx.Done():
+				reason <- ""
+				return
+			case <-ticker.C:
+			}
+
+			elapsed := time.Since(start)
+			for _, result := range e.metrics.evaluate(abortOn) {
+				if !result.Passed && elapsed >= result.Threshold.AbortDelay {
+					cancel()
+					reason <- fmt.Sprintf("threshold '%s' failed after %v (actual %s)",
+						result.Threshold.Expr, elapsed.Round(time.Second), result.Threshold.FormatValue(result.Actual))
+					return
+				}
+			}
+		}
+	}()
+
+	return func() string {
+		close(stop)
+		return <-reason
+	}
+}
+
 func (e *Executor) executeVUs(ctx context.Context, scenario *CompiledScenario, result *ScenarioResult) {
 	duration, _ := parseDuration(scenario.Load.Duration)
 	deadline := time.Now().Add(duration)
@@ -142,38 +263,8 @@ func (e *Executor) executeVUs(ctx context.Context, scenario *CompiledScenario, r
 		go func(vuID int) {
 			defer wg.Done()
 
-			e.sendProgress(ProgressUpdate{
-				Type: "vu_start",
-				VUID: vuID,
-			})
-
-			vuResult := &VUResult{
-				VUID:       vuID,
-				Iterations: make([]*IterationResult, 0),
-			}
-
-			iteration := 1
-			for time.Now().Before(deadline) {
-				select {
-				case <-ctx.Done():
-					return
-				default:
-				}
-
-				e.sendProgress(ProgressUpdate{
-					Type:      "iteration_start",
-					VUID:      vuID,
-					Iteration: iteration,
-				})
-
-				iterResult := e.executeIteration(ctx, scenario, vuID, iteration, result.SetupVars)
-				vuResult.Iterations = append(vuResult.Iterations, iterResult)
-				iteration++
-			}
-
-			e.sendProgress(ProgressUpdate{
-				Type: "vu_done",
-				VUID: vuID,
+			vuResult := e.runVU(ctx, scenario, vuID, result.SetupVars, func() bool {
+				return time.Now().Before(deadline)
 			})
 
 			mu.Lock()
@@ -185,54 +276,36 @@ func (e *Executor) executeVUs(ctx context.Context, scenario *CompiledScenario, r
 	wg.Wait()
 }
 
-func (e *Executor) executeRPS(ctx context.Context, scenario *CompiledScenario, result *ScenarioResult) {
-	duration, _ := parseDuration(scenario.Load.Duration)
-	deadline := time.Now().Add(duration)
-	ticker := time.NewTicker(time.Second / time.Duration(scenario.Load.RPS))
-	defer ticker.Stop()
-
-	var mu sync.Mutex
-	vuID := 1
-	iteration := 1
+// runVU runs iterations for one VU while keepRunning reports true. An
+// iteration in flight always completes before the VU stops.
+func (e *Executor) runVU(ctx context.Context, scenario *CompiledScenario, vuID int, setupVars map[string]any, keepRunning func() bool) *VUResult {
+	e.sendProgress(ProgressUpdate{
+		Type: "vu_start",
+		VUID: vuID,
+	})
 
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-ticker.C:
-			if time.Now().After(deadline) {
-				return
-			}
+	vuResult := &VUResult{VUID: vuID}
 
-			go func(vu, iter int) {
-				iterResult := e.executeIteration(ctx, scenario, vu, iter, result.SetupVars)
-
-				mu.Lock()
-				// Find or create VU result
-				var vuResult *VUResult
-				for _, vr := range result.VUResults {
-					if vr.VUID == vu {
-						vuResult = vr
-						break
-					}
-				}
-				if vuResult == nil {
-					vuResult = &VUResult{
-						VUID:       vu,
-						Iterations: make([]*IterationResult, 0),
-					}
-					result.VUResults = append(result.VUResults, vuResult)
-				}
-				vuResult.Iterations = append(vuResult.Iterations, iterResult)
-				mu.Unlock()
-			}(vuID, iteration)
+	for iteration := 1; keepRunning() && ctx.Err() == nil; iteration++ {
+		e.sendProgress(ProgressUpdate{
+			Type:      "iteration_start",
+			VUID:      vuID,
+			Iteration: iteration,
+		})
 
-			iteration++
-			if iteration%scenario.Load.RPS == 0 {
-				vuID++
-			}
+		iterResult := e.executeIteration(ctx, scenario, vuID, iteration, setupVars)
+		if iterResult == nil {
+			break
 		}
+		vuResult.add(iterResult)
 	}
+
+	e.sendProgress(ProgressUpdate{
+		Type: "vu_done",
+		VUID: vuID,
+	})
+
+	return vuResult
 }
 
 func (e *Executor) executeIterations(ctx context.Context, scenario *CompiledScenario, result *ScenarioResult) {
@@ -257,10 +330,7 @@ func (e *Executor) executeIterations(ctx context.Context, scenario *CompiledScen
 		go func(vuID int, maxIter int) {
 			defer wg.Done()
 
-			vuResult := &VUResult{
-				VUID:       vuID,
-				Iterations: make([]*IterationResult, 0),
-			}
+			vuResult := &VUResult{VUID: vuID}
 
 			for iter := 1; iter <= maxIter; iter++ {
 				select {
@@ -270,7 +340,10 @@ func (e *Executor) executeIterations(ctx context.Context, scenario *CompiledScen
 				}
 
 				iterResult := e.executeIteration(ctx, scenario, vuID, iter, result.SetupVars)
-				vuResult.Iterations = append(vuResult.Iterations, iterResult)
+				if iterResult == nil {
+					break
+				}
+				vuResult.add(iterResult)
 			}
 
 			mu.Lock()
@@ -282,18 +355,62 @@ func (e *Executor) executeIterations(ctx context.Context, scenario *CompiledScen
 	wg.Wait()
 }
 
-func (e *Executor) executeIteration(ctx context.Context, scenario *CompiledScenario, vu int, iter int, setupVars map[string]any) *IterationResult {
-	iterResult := &IterationResult{
-		IterationNum: iter,
-		StartTime:    time.Now(),
-		Requests:     make([]*RequestResult, 0),
+// vu returns the state of a VU, creating it for the VU's first request
+func (e *Executor) vu(id int) *vuState {
+	e.vusMu.Lock()
+	defer e.vusMu.Unlock()
+
+	state, ok := e.vus[id]
+	if !ok {
+		jar := e.sharedJar
+		if jar == nil {
+			jar = executor.NewCookieJar()
+		}
+		state = &vuState{http: executor.NewExecutorWithCookieJar(jar)}
+		e.vus[id] = state
 	}
+	return state
+}
+
+// closeVUs closes the connections VUs keep open between requests
+func (e *Executor) closeVUs() {
+	e.vusMu.Lock()
+	defer e.vusMu.Unlock()
+
+	for _, state := range e.vus {
+		state.http.CloseIdleConnections()
+	}
+}
 
-	// Execution context with extracted variables
+// executeIteration runs one pass over the request tree. It returns nil when a
+// unique data set has no rows left, which ends the VU.
+func (e *Executor) executeIteration(ctx context.Context, scenario *CompiledScenario, vu int, iter int, setupVars map[string]any) *IterationResult {
+	state := e.vu(vu)
+
+	// Execution context with extracted variables, starting from what setup
+	// and the VU's earlier iterations extracted
 	execVars := make(map[string]any)
 	for k, v := range setupVars {
 		execVars[k] = v
 	}
+	for k, v := range state.vars {
+		execVars[k] = v
+	}
+
+	// Bind this iteration's data rows by data set name
+	for name, data := range scenario.Data {
+		row, ok := data.Row(vu)
+		if !ok {
+			return nil
+		}
+		execVars[name] = row
+	}
+
+	iterResult := &IterationResult{
+		IterationNum: iter,
+		StartTime:    time.Now(),
+		Requests:     make([]*RequestResult, 0),
+	}
 
 	// Execute request tree
 	for _, node := range scenario.Main {
@@ -301,30 +418,307 @@ func (e *Executor) executeIteration(ctx context.Context, scenario *CompiledScena
 	}
 
 	iterResult.EndTime = time.Now()
+	state.vars = execVars
 	return iterResult
 }
 
 func (e *Executor) executeNode(ctx context.Context, node *RequestNode, vu int, iter int, vars map[string]any, iterResult *IterationResult) {
+	// Run children once per data row, with the row bound to the loop variable
+	if node.ForEach != nil {
+		previous, hadPrevious := vars[node.ForEach.ItemVar]
+		for _, row := range node.Rows {
+			if ctx.Err() != nil {
+				break
+			}
+			vars[node.ForEach.ItemVar] = row
+			for _, child := range node.Children {
+				e.executeNode(ctx, child, vu, iter, vars, iterResult)
+			}
+		}
+		if hadPrevious {
+			vars[node.ForEach.ItemVar] = previous
+		} else {
+			delete(vars, node.ForEach.ItemVar)
+		}
+		return
+	}
+
+	if node.IR == nil && node.ThinkTime != nil {
+		e.think(ctx, node.ThinkTime)
+		return
+	}
+
+	if node.IR == nil && node.Parallel {
+		e.executeParallel(ctx, node.Children, node.MaxParallel, vu, iter, vars, iterResult)
+		return
+	}
+
+	// Branch nodes pick children or else without making a request
+	if node.IR == nil {
+		branch := node.Children
+		if !e.evaluateCondition(node, vu, iter, vars, iterResult.last) {
+			branch = node.Else
+		}
+		for _, child := range branch {
+			e.executeNode(ctx, child, vu, iter, vars, iterResult)
+		}
+		return
+	}
+
 	// Check condition
-	if node.Condition != "" && !e.evaluateCondition(node.Condition, vars) {
+	if !e.evaluateCondition(node, vu, iter, vars, iterResult.last) {
+		return
+	}
+
+	// A branch decision jumps to another request of the file, whose children
+	// run in place of this node's
+	var chain branchChain
+	next, ok := e.request(ctx, node, vu, iter, vars, iterResult, &chain)
+	for ok && next != nil && ctx.Err() == nil {
+		node = next
+		next, ok = e.request(ctx, node, vu, iter, vars, iterResult, &chain)
+	}
+	if !ok {
 		return
 	}
 
+	// Execute children
+	if len(node.Children) > 0 {
+		if node.Parallel {
+			e.executeParallel(ctx, node.Children, node.MaxParallel, vu, iter, vars, iterResult)
+		} else {
+			for _, child := range node.Children {
+				e.executeNode(ctx, child, vu, iter, vars, iterResult)
+			}
+		}
+	}
+
+	// Think time
+	if node.ThinkTime != nil {
+		e.think(ctx, node.ThinkTime)
+	}
+}
+
+// request makes a node's request, retrying as its retry block allows, and
+// records the result. It returns the node a branch decision jumps to, if
+// any, and false when no response came back.
+func (e *Executor) request(ctx context.Context, node *RequestNode, vu int, iter int, vars map[string]any, iterResult *IterationResult, chain *branchChain) (*RequestNode, bool) {
+	reqResult, execCtx := e.attempt(node, vu, iter, vars)
+	for retry := node.Retry; retry != nil && reqResult.Attempts < retry.MaxAttempts; {
+		var resp *ir.Response
+		if execCtx != nil {
+			resp = execCtx.Response
+		}
+		if !retry.shouldRetry(reqResult, resp) || !sleep(ctx, retry.delay(reqResult.Attempts, resp)) {
+			break
+		}
+		attempts := reqResult.Attempts
+		reqResult, execCtx = e.attempt(node, vu, iter, vars)
+		reqResult.Attempts = attempts + 1
+	}
+
+	if execCtx == nil || execCtx.Response.Status == 0 {
+		iterResult.Requests = append(iterResult.Requests, reqResult)
+		e.finishRequest(reqResult)
+		return nil, false
+	}
+	iterResult.last = execCtx.Response
+
+	// Extract variables
+	if len(node.Extract) > 0 {
+		extracted, err := extract.Apply(execCtx.Response, node.Extract)
+		if err != nil {
+			reqResult.Error = err.Error()
+		}
+		iterResult.setVars(vars, extracted)
+	}
+
+	var next *RequestNode
+	if node.Evaluator != nil {
+		extracted, decision := e.evaluate(ctx, node.Evaluator, execCtx, reqResult, vars)
+		iterResult.setVars(vars, extracted)
+
+		if decision != nil && decision.Decision == "branch" {
+			var err error
+			if next, err = e.branch(node.Name, decision, vars, chain); err != nil {
+				reqResult.Checks = append(reqResult.Checks, CheckResult{Name: "branch", Passed: false})
+				reqResult.AssertionsFailed++
+				reqResult.Error = err.Error()
+			}
+		}
+	}
+
+	iterResult.Requests = append(iterResult.Requests, reqResult)
+	e.finishRequest(reqResult)
+	return next, true
+}
+
+// branchChain is the requests a chain of branch decisions went through
+type branchChain struct {
+	hops    []string
+	visited map[string]bool // By request and vars
+}
+
+// branch finds the request a branch decision jumps to, applying its
+// mutations. Like orchestrator steps, a chain may take at most
+// orchestrator.DefaultMaxHops hops and may not come back to a request with
+// the same variables.
+func (e *Executor) branch(current string, decision *ir.EvaluatorDecision, vars map[string]any, chain *branchChain) (*RequestNode, error) {
+	if decision.Actions == nil || decision.Actions.Goto == "" {
+		return nil, fmt.Errorf("branch decision without actions.goto")
+	}
+	name := decision.Actions.Goto
+	target, ok := e.steps[name]
+	if !ok {
+		return nil, fmt.Errorf("branch target '%s' not found", name)
+	}
+	if len(chain.hops) >= orchestrator.DefaultMaxHops {
+		return nil, fmt.Errorf("branch limit of %d hops reached at '%s'", orchestrator.DefaultMaxHops, name)
+	}
+
+	// The first hop also records where the chain started
+	if chain.visited == nil {
+		chain.visited = map[string]bool{branchKey(current, vars): true}
+	}
+	if m := decision.Mutations; m != nil {
+		for k, v := range m.Vars {
+			vars[k] = v
+		}
+		target = mutate(target, m)
+	}
+
+	chain.hops = append(chain.hops, name)
+	key := branchKey(name, vars)
+	if chain.visited[key] {
+		return nil, fmt.Errorf("branch loop detected: %s", strings.Join(chain.hops, " -> "))
+	}
+	chain.visited[key] = true
+	return target, nil
+}
+
+// branchKey identifies a request together with the variables it runs with
+func branchKey(name string, vars map[string]any) string {
+	data, _ := json.Marshal(vars)
+	return name + "\x00" + string(data)
+}
+
+// mutate returns a copy of a node whose request has a decision's header,
+// query and body mutations applied
+func mutate(node *RequestNode, m *ir.Mutations) *RequestNode {
+	if m.Headers == nil && m.Query == nil && m.Body == nil {
+		return node
+	}
+	data, _ := json.Marshal(node.IR)
+	var irSpec ir.IR
+	json.Unmarshal(data, &irSpec)
+
+	if m.Headers != nil && irSpec.Request.Headers == nil {
+		irSpec.Request.Headers = make(map[string]string)
+	}
+	for k, v := range m.Headers {
+		irSpec.Request.Headers[k] = v
+	}
+	if m.Query != nil && irSpec.Request.Query == nil {
+		irSpec.Request.Query = make(map[string]any)
+	}
+	for k, v := range m.Query {
+		irSpec.Request.Query[k] = v
+	}
+	if m.Body != nil && irSpec.Request.Body != nil {
+		irSpec.Request.Body.Content = m.Body
+	}
+
+	mutated := *node
+	mutated.IR = &irSpec
+	return &mutated
+}
+
+// think pauses for a think time, varied randomly by its variance, or until
+// the run is cancelled
+func (e *Executor) think(ctx context.Context, t *ThinkTime) {
+	d, _ := parseDuration(t.Duration)
+	if t.Variance > 0 {
+		factor := 1.0 + (rand.Float64()*2-1)*t.Variance
+		d = time.Duration(float64(d) * factor)
+	}
+	sleep(ctx, d)
+}
+
+// executeParallel runs nodes concurrently, at most limit at a time when limit
+// is positive. Each branch works on its own copy of the variables and records
+// its own requests. Once all have finished both are merged back in the order
+// the branches are declared, so the last branch to extract a variable wins
+// and conditions after the block read the last branch's most recent response.
+func (e *Executor) executeParallel(ctx context.Context, nodes []*RequestNode, limit int, vu int, iter int, vars map[string]any, iterResult *IterationResult) {
+	var slots chan struct{}
+	if limit > 0 {
+		slots = make(chan struct{}, limit)
+	}
+
+	branchVars := make([]map[string]any, len(nodes))
+	branchResults := make([]*IterationResult, len(nodes))
+
+	var wg sync.WaitGroup
+	for i, node := range nodes {
+		branchVars[i] = maps.Clone(vars)
+		branchResults[i] = &IterationResult{
+			last:      iterResult.last,
+			extracted: make(map[string]any),
+		}
+
+		wg.Add(1)
+		go func(i int, node *RequestNode) {
+			defer wg.Done()
+			if slots != nil {
+				slots <- struct{}{}
+				defer func() { <-slots }()
+			}
+			e.executeNode(ctx, node, vu, iter, branchVars[i], branchResults[i])
+		}(i, node)
+	}
+	wg.Wait()
+
+	previous := iterResult.last
+	for _, branch := range branchResults {
+		iterResult.Requests = append(iterResult.Requests, branch.Requests...)
+		if branch.last != previous {
+			iterResult.last = branch.last
+		}
+		iterResult.setVars(vars, branch.extracted)
+	}
+}
+
+// attempt makes one request for a node and checks its assertions. The
+// context is nil when the request could not be built.
+func (e *Executor) attempt(node *RequestNode, vu int, iter int, vars map[string]any) (*RequestResult, *ir.EvaluationContext) {
 	// Clone IR and replace runtime variables
 	irSpec := e.cloneIRWithVars(node.IR, vu, iter, vars)
 
 	// Execute request
-	execCtx, err := e.httpExecutor.Execute(irSpec)
+	start := time.Now()
+	execCtx, err := e.vu(vu).http.Execute(irSpec)
+	if execCtx != nil {
+		for _, hook := range e.exchangeHooks {
+			hook(start, execCtx)
+		}
+	}
 
 	reqResult := &RequestResult{
+		Name:      node.Name,
 		URL:       irSpec.Request.URL,
 		Method:    irSpec.Request.Method,
-		StartTime: time.Now(),
+		VUID:      vu,
+		Iteration: iter,
+		Attempts:  1,
+		StartTime: start,
 	}
 
+	if err == nil && execCtx.Response.Status == 0 {
+		// No response, e.g. connection refused
+		err = errors.New(execCtx.Response.Error)
+	}
 	if err != nil {
 		reqResult.Error = err.Error()
-		iterResult.Requests = append(iterResult.Requests, reqResult)
 		e.sendProgress(ProgressUpdate{
 			Type:        "request",
 			VUID:        vu,
@@ -332,12 +726,16 @@ func (e *Executor) executeNode(ctx context.Context, node *RequestNode, vu int, i
 			RequestName: node.IR.Request.Method + " " + node.IR.Request.URL,
 			Error:       err.Error(),
 		})
-		return
+		return reqResult, execCtx
 	}
 
 	reqResult.Status = execCtx.Response.Status
-	reqResult.Latency = time.Duration(execCtx.Response.LatencyMs * 1000000)
+	reqResult.Latency = time.Duration(execCtx.Response.LatencyMs * float64(time.Millisecond))
 	reqResult.Size = execCtx.Response.SizeBytes
+	reqResult.Timing = execCtx.Response.Timing
+	if execCtx.Response.Error != "" {
+		reqResult.Error = execCtx.Response.Error
+	}
 
 	// Send progress update
 	e.sendProgress(ProgressUpdate{
@@ -351,51 +749,15 @@ func (e *Executor) executeNode(ctx context.Context, node *RequestNode, vu int, i
 
 	// Check assertions
 	for _, assertion := range node.Assert {
-		if !e.checkAssertion(assertion, execCtx) {
+		passed, detail := assertion.Check(execCtx.Response, runtimeVars(vu, iter, vars))
+		reqResult.Checks = append(reqResult.Checks, CheckResult{Name: assertion.String(), Passed: passed})
+		if !passed {
 			reqResult.AssertionsFailed++
-			reqResult.Error = fmt.Sprintf("assertion failed: %s %s %v", assertion.Field, assertion.Operator, assertion.Value)
-		}
-	}
-
-	// Extract variables
-	if len(node.Extract) > 0 {
-		extracted := e.extractVariables(execCtx, node.Extract)
-		for k, v := range extracted {
-			vars[k] = v
+			reqResult.Error = fmt.Sprintf("assertion failed: %s (%s)", assertion, detail)
 		}
 	}
 
-	iterResult.Requests = append(iterResult.Requests, reqResult)
-
-	// Execute children
-	if len(node.Children) > 0 {
-		if node.Parallel {
-			var wg sync.WaitGroup
-			for _, child := range node.Children {
-				wg.Add(1)
-				go func(childNode *RequestNode) {
-					defer wg.Done()
-					e.executeNode(ctx, childNode, vu, iter, vars, iterResult)
-				}(child)
-			}
-			wg.Wait()
-		} else {
-			for _, child := range node.Children {
-				e.executeNode(ctx, child, vu, iter, vars, iterResult)
-			}
-		}
-	}
-
-	// Think time
-	if node.ThinkTime != nil {
-		thinkDuration, _ := parseDuration(node.ThinkTime.Duration)
-		if node.ThinkTime.Variance > 0 {
-			variance := node.ThinkTime.Variance
-			factor := 1.0 + (rand.Float64()*2-1)*variance
-			thinkDuration = time.Duration(float64(thinkDuration) * factor)
-		}
-		time.Sleep(thinkDuration)
-	}
+	return reqResult, execCtx
 }
 
 func (e *Executor) cloneIRWithVars(irSpec *ir.IR, vu int, iter int, vars map[string]any) *ir.IR {
@@ -412,6 +774,21 @@ func (e *Executor) cloneIRWithVars(irSpec *ir.IR, vu int, iter int, vars map[str
 		cloned.Request.Headers[k] = ReplaceRuntimeVariables(v, vu, iter, vars)
 	}
 
+	// Replace in query parameters and cookies
+	for k, v := range cloned.Request.Query {
+		cloned.Request.Query[k] = replaceRuntimeValue(v, vu, iter, vars)
+	}
+	for k, v := range cloned.Request.Cookies {
+		cloned.Request.Cookies[k] = ReplaceRuntimeVariables(v, vu, iter, vars)
+	}
+
+	// Replace in credentials, e.g. a bearer token extracted at login
+	if auth := cloned.Request.Auth; auth != nil {
+		auth.Token = ReplaceRuntimeVariables(auth.Token, vu, iter, vars)
+		auth.Username = ReplaceRuntimeVariables(auth.Username, vu, iter, vars)
+		auth.Password = ReplaceRuntimeVariables(auth.Password, vu, iter, vars)
+	}
+
 	// Replace in body
 	if cloned.Request.Body != nil {
 		if cloned.Request.Body.Type == "json" {
@@ -424,205 +801,92 @@ func (e *Executor) cloneIRWithVars(irSpec *ir.IR, vu int, iter int, vars map[str
 			if str, ok := cloned.Request.Body.Content.(string); ok {
 				cloned.Request.Body.Content = ReplaceRuntimeVariables(str, vu, iter, vars)
 			}
-		}
-	}
-
-	return &cloned
-}
-
-func (e *Executor) extractVariables(execCtx *ir.EvaluationContext, extractRules map[string]string) map[string]any {
-	extracted := make(map[string]any)
-
-	if extractRules == nil {
-		return extracted
-	}
-
-	for varName, rule := range extractRules {
-		// JSONPath extraction: $.field.subfield
-		if strings.HasPrefix(rule, "$.") {
-			value := e.extractJSONPath(execCtx.Response.Body, rule)
-			if value != nil {
-				extracted[varName] = value
-			}
-		}
-
-		// Regex extraction: regex:pattern
-		if strings.HasPrefix(rule, "regex:") {
-			pattern := strings.TrimPrefix(rule, "regex:")
-			value := e.extractRegex(execCtx.Response.Body, pattern)
-			if value != "" {
-				extracted[varName] = value
-			}
-		}
-
-		// Header extraction: header:Header-Name
-		if strings.HasPrefix(rule, "header:") {
-			headerName := strings.TrimPrefix(rule, "header:")
-			if value, ok := execCtx.Response.Headers[headerName]; ok {
-				extracted[varName] = value
-			}
-		}
-
-		// Cookie extraction: cookie:cookie-name
-		if strings.HasPrefix(rule, "cookie:") {
-			cookieName := strings.TrimPrefix(rule, "cookie:")
-			if value, ok := execCtx.Response.Headers["Set-Cookie"]; ok {
-				// Parse cookie from Set-Cookie header
-				// Simple extraction - get value after cookie name
-				if strings.Contains(value, cookieName+"=") {
-					extracted[varName] = e.extractCookieValue(value, cookieName)
+		} else if cloned.Request.Body.Type == "form" {
+			if form, ok := cloned.Request.Body.Content.(map[string]any); ok {
+				for k, v := range form {
+					form[k] = replaceRuntimeValue(v, vu, iter, vars)
 				}
 			}
 		}
 	}
 
-	return extracted
+	return &cloned
 }
 
-func (e *Executor) extractCookieValue(setCookieHeader, cookieName string) string {
-	// Simple cookie value extraction
-	// Format: "name=value; ..."
-	parts := strings.Split(setCookieHeader, ";")
-	for _, part := range parts {
-		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
-		if len(kv) == 2 && kv[0] == cookieName {
-			return kv[1]
+// replaceRuntimeValue replaces variables in a query or form value, which is
+// a string or, for repeated parameters, a list of them
+func replaceRuntimeValue(value any, vu int, iter int, vars map[string]any) any {
+	switch v := value.(type) {
+	case string:
+		return ReplaceRuntimeVariables(v, vu, iter, vars)
+	case []any:
+		for i, item := range v {
+			v[i] = replaceRuntimeValue(item, vu, iter, vars)
 		}
 	}
-	return ""
+	return value
 }
 
-func (e *Executor) extractJSONPath(body any, path string) any {
-	// Simplified JSONPath (only supports simple paths like $.field.subfield)
-	if bodyMap, ok := body.(map[string]any); ok {
-		path = strings.TrimPrefix(path, "$.")
-		parts := strings.Split(path, ".")
-
-		current := any(bodyMap)
-		for _, part := range parts {
-			if m, ok := current.(map[string]any); ok {
-				current = m[part]
-			} else {
-				return nil
-			}
+// evaluate asks the request's evaluator for a decision. A fail decision fails
+// the request; otherwise the decision is returned with the values its extract
+// actions pull from the response.
+func (e *Executor) evaluate(ctx context.Context, ref *EvaluatorRef, execCtx *ir.EvaluationContext, reqResult *RequestResult, vars map[string]any) (map[string]any, *ir.EvaluatorDecision) {
+	for k, v := range vars {
+		if _, ok := execCtx.Vars[k]; !ok {
+			execCtx.Vars[k] = v
 		}
-		return current
 	}
-	return nil
-}
 
-func (e *Executor) extractRegex(body any, pattern string) string {
-	bodyStr := fmt.Sprintf("%v", body)
-	re := regexp.MustCompile(pattern)
-	matches := re.FindStringSubmatch(bodyStr)
-	if len(matches) > 1 {
-		return matches[1]
-	}
-	return ""
-}
-
-func (e *Executor) evaluateCondition(condition string, vars map[string]any) bool {
-	// Simplified condition evaluation: ${var} == value
-	for k, v := range vars {
-		placeholder := fmt.Sprintf("${%s}", k)
-		condition = strings.ReplaceAll(condition, placeholder, fmt.Sprintf("%v", v))
+	check := CheckResult{Name: "evaluate " + ref.Path, Passed: true}
+	decision, err := e.evalManager.Evaluate(ctx, execCtx, ref.Type, ref.Path)
+	switch {
+	case err != nil:
+		check.Passed = false
+		reqResult.Error = err.Error()
+	case decision.Decision == "fail":
+		check.Passed = false
+		reqResult.Error = fmt.Sprintf("evaluation failed: %s", decision.Reason)
 	}
-
-	// Simple evaluation (extend for complex logic)
-	if strings.Contains(condition, "==") {
-		parts := strings.Split(condition, "==")
-		if len(parts) == 2 {
-			return strings.TrimSpace(parts[0]) == strings.TrimSpace(parts[1])
-		}
+	reqResult.Checks = append(reqResult.Checks, check)
+	if !check.Passed {
+		reqResult.AssertionsFailed++
+		return nil, nil
 	}
 
-	return true
-}
-
-func (e *Executor) checkAssertion(assertion Assertion, execCtx *ir.EvaluationContext) bool {
-	switch assertion.Type {
-	case AssertStatus:
-		expected := fmt.Sprintf("%v", assertion.Value)
-		actual := fmt.Sprintf("%d", execCtx.Response.Status)
-		return e.compareValues(actual, assertion.Operator, expected)
-
-	case AssertLatency:
-		latency := execCtx.Response.LatencyMs
-		expected := fmt.Sprintf("%v", assertion.Value)
-		// Parse expected (e.g., "500ms", "1s")
-		expectedMs := parseLatency(expected)
-		return e.compareValues(fmt.Sprintf("%f", latency), assertion.Operator, fmt.Sprintf("%f", expectedMs))
-
-	case AssertBody:
-		// Extract field from body
-		field := strings.TrimPrefix(assertion.Field, "body.")
-		value := e.extractJSONPath(execCtx.Response.Body, "$."+field)
-		return e.compareValues(fmt.Sprintf("%v", value), assertion.Operator, fmt.Sprintf("%v", assertion.Value))
+	if decision.Actions == nil || len(decision.Actions.Extract) == 0 {
+		return nil, decision
 	}
-
-	return true
-}
-
-func (e *Executor) compareValues(actual, operator, expected string) bool {
-	actual = strings.TrimSpace(actual)
-	expected = strings.TrimSpace(expected)
-
-	switch operator {
-	case "==":
-		return actual == expected
-	case "!=":
-		return actual != expected
-	case "contains":
-		return strings.Contains(actual, expected)
-	// Add more operators as needed
-	default:
-		return true
+	extracted, err := extract.Apply(execCtx.Response, decision.Actions.Extract)
+	if err != nil {
+		reqResult.Error = err.Error()
 	}
+	return extracted, decision
 }
 
-func (e *Executor) calculateStats(vuResults []*VUResult) *Stats {
-	stats := &Stats{}
-
-	for _, vuResult := range vuResults {
-		for _, iterResult := range vuResult.Iterations {
-			for _, reqResult := range iterResult.Requests {
-				stats.TotalRequests++
-				stats.TotalBytes += reqResult.Size
-
-				latencyMs := float64(reqResult.Latency.Milliseconds())
-				stats.TotalLatency += latencyMs
-
-				if latencyMs < stats.MinLatency || stats.MinLatency == 0 {
-					stats.MinLatency = latencyMs
-				}
-				if latencyMs > stats.MaxLatency {
-					stats.MaxLatency = latencyMs
-				}
-
-				if reqResult.Error != "" || reqResult.AssertionsFailed > 0 {
-					stats.FailedRequests++
-				} else {
-					stats.SuccessRequests++
-				}
-			}
+// evaluateCondition reports whether a node's condition holds. Conditions read
+// the iteration's most recent response; one that cannot be evaluated, such as
+// status before the first request, does not hold.
+func (e *Executor) evaluateCondition(node *RequestNode, vu int, iter int, vars map[string]any, last *ir.Response) bool {
+	cond := node.cond
+	if cond == nil {
+		if node.Condition == "" {
+			return true
+		}
+		var err error
+		if cond, err = compileExpression(node.Condition); err != nil {
+			return false
 		}
 	}
 
-	if stats.TotalRequests > 0 {
-		stats.AvgLatency = stats.TotalLatency / float64(stats.TotalRequests)
-	}
-
-	return stats
+	holds, err := cond.Bool(responseEnv{resp: last, vars: runtimeVars(vu, iter, vars)})
+	return err == nil && holds
 }
 
+// thresholdCheckInterval is how often abort_on_fail thresholds are checked
+const thresholdCheckInterval = time.Second
+
 // Helper functions
 func parseDuration(s string) (time.Duration, error) {
 	// Simple parser: "5m", "30s", "1h"
 	return time.ParseDuration(s)
 }
-
-func parseLatency(s string) float64 {
-	// Parse "500ms", "1s" to milliseconds
-	d, _ := time.ParseDuration(s)
-	return float64(d.Milliseconds())
-}
@@ -0,0 +1,288 @@
+package scenario
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vikasavnish/httptool/pkg/ir"
+)
+
+func TestExecutor_JSONPath(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/users":
+			fmt.Fprint(w, `[{"id": 1, "active": false}, {"id": 2, "active": true}, {"id": 3, "active": true}]`)
+		case "/users/2":
+			fmt.Fprint(w, `{"id": 2, "roles": ["admin", "ops"]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	input := `var base = "` + server.URL + `"
+
+request list {
+  curl ${base}/users
+  extract {
+    active_id = $[1].id
+  }
+  assert {
+    body[0].id == 1
+    body.length() == 3
+    body[?(@.active)].length() == 2
+  }
+}
+
+request detail {
+  curl ${base}/users/${active_id}
+  assert {
+    status == 200
+    body.roles[-1] == ops
+    body.roles.length == 2
+  }
+}
+
+scenario lookup {
+  load 1 iterations with 1 vus
+  run list -> detail
+}`
+
+	result := runScenario(t, input, "lookup")
+
+	for name, stats := range result.Stats.Requests {
+		if stats.Failed != 0 {
+			t.Errorf("%s failed: %s", name, stats.LastError)
+		}
+	}
+	if result.Stats.TotalRequests != 2 {
+		t.Errorf("ran %d requests, want 2", result.Stats.TotalRequests)
+	}
+}
+
+func TestExecutor_ParallelFlows(t *testing.T) {
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		maxInFlight = max(maxInFlight, inFlight)
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+		}()
+		time.Sleep(20 * time.Millisecond)
+
+		switch r.URL.Path {
+		case "/a":
+			fmt.Fprint(w, `{"who": "a", "token": "t1"}`)
+		case "/b":
+			fmt.Fprint(w, `{"who": "b"}`)
+		case "/list":
+			fmt.Fprint(w, `[{"id": 7}]`)
+		case "/items/7", "/c", "/d", "/after/t1/b/7":
+			fmt.Fprint(w, `{}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	input := `var base = "` + server.URL + `"
+
+request a {
+  curl ${base}/a
+  extract {
+    who = $.who
+    token = $.token
+  }
+}
+request b {
+  curl ${base}/b
+  extract who = $.who
+}
+request c {
+  curl ${base}/c
+}
+request d {
+  curl ${base}/d
+}
+request list {
+  curl ${base}/list
+  extract first = $[0].id
+}
+request detail {
+  curl ${base}/items/${first}
+  assert status == 200
+}
+request after {
+  curl ${base}/after/${token}/${who}/${first}
+  assert status == 200
+}
+
+scenario s {
+  load 2 iterations with 1 vus
+  run parallel max 2 {
+    a, b
+    c -> d
+    run list {
+      run detail
+    }
+  }
+  run after
+}`
+
+	result := runScenario(t, input, "s")
+
+	for _, name := range []string{"a", "b", "c", "d", "list", "detail", "after"} {
+		stats := result.Stats.Requests[name]
+		if stats == nil {
+			t.Errorf("%s did not run", name)
+			continue
+		}
+		if stats.Failed != 0 {
+			t.Errorf("%s failed: %s", name, stats.LastError)
+		}
+	}
+	if result.Stats.TotalRequests != 14 {
+		t.Errorf("ran %d requests, want 14", result.Stats.TotalRequests)
+	}
+	if maxInFlight != 2 {
+		t.Errorf("%d requests in flight at once, want 2", maxInFlight)
+	}
+}
+
+func TestExecutor_VUState(t *testing.T) {
+	var mu sync.Mutex
+	logins := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			mu.Lock()
+			logins++
+			n := logins
+			mu.Unlock()
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: fmt.Sprint(n)})
+			fmt.Fprintf(w, `{"token": "t%d"}`, n)
+		case "/me":
+			cookie, err := r.Cookie("session")
+			if err != nil {
+				w.WriteHeader(http.StatusUnauthorized)
+			} else if auth := r.Header.Get("Authorization"); auth != "" && auth != "Bearer t"+cookie.Value {
+				w.WriteHeader(http.StatusForbidden)
+			}
+		}
+	}))
+	defer server.Close()
+
+	requests := `var base = "` + server.URL + `"
+
+request login {
+  curl ${base}/login
+  extract token = $.token
+}
+
+request me {
+  curl ${base}/me -H "Authorization: Bearer ${token}"
+  assert status == 200
+}
+
+request profile {
+  curl ${base}/me
+  assert status == 200
+}
+`
+
+	// Each VU logs in once and keeps its own session and token
+	result := runScenario(t, requests+`
+scenario s {
+  load 6 iterations with 2 vus
+  if ${token} == null {
+    run login
+  }
+  run me
+}`, "s")
+
+	if logins != 2 {
+		t.Errorf("logged in %d times, want once per VU", logins)
+	}
+	if me := result.Stats.Requests["me"]; me == nil || me.Count != 6 || me.Failed != 0 {
+		t.Errorf("me = %+v, want 6 authorized requests", me)
+	}
+
+	// A shared jar hands the setup session to every VU
+	logins = 0
+	result = runScenario(t, requests+`
+setup {
+  run login
+}
+
+scenario s {
+  load 4 iterations with 2 vus
+  cookies shared
+  run profile
+}`, "s")
+
+	if profile := result.Stats.Requests["profile"]; profile == nil || profile.Count != 4 || profile.Failed != 0 {
+		t.Errorf("profile = %+v, want 4 requests with the setup session", profile)
+	}
+}
+
+func TestExecutor_Think(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{}`)
+	}))
+	defer server.Close()
+
+	input := `var base = "` + server.URL + `"
+
+request a {
+  curl ${base}/a
+}
+request b {
+  curl ${base}/b
+}
+
+scenario s {
+  load 1 iterations with 1 vus
+  run a
+  think 150ms
+  run b
+}`
+
+	s, err := NewParser(input).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	compiled, err := NewCompiler().Compile(s, "s")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	started := map[string]time.Time{}
+	executor := NewExecutor()
+	executor.OnExchange(func(start time.Time, ctx *ir.EvaluationContext) {
+		mu.Lock()
+		started[ctx.Request.URL[len(server.URL):]] = start
+		mu.Unlock()
+	})
+	if _, err := executor.Execute(context.Background(), compiled); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(started) != 2 {
+		t.Fatalf("exchanges = %v", started)
+	}
+	if gap := started["/b"].Sub(started["/a"]); gap < 150*time.Millisecond {
+		t.Errorf("b started %v after a, want at least the 150ms think time", gap)
+	}
+}
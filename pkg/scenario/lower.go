@@ -0,0 +1,404 @@
+package scenario
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/vikasavnish/httptool/pkg/ir"
+	"github.com/vikasavnish/httptool/pkg/parser"
+)
+
+// lowerer converts a parsed .httpx AST into a Scenario
+type lowerer struct {
+	scenario *Scenario
+	baseDir  string
+}
+
+// Lower converts a parsed .httpx program into a Scenario. Data files are
+// resolved relative to baseDir. Errors carry the line:column of the
+// offending declaration.
+func Lower(program *parser.Program, baseDir string) (*Scenario, error) {
+	l := &lowerer{
+		baseDir: baseDir,
+		scenario: &Scenario{
+			Variables: make(map[string]string),
+			Data:      make(map[string][]map[string]any),
+			DataModes: make(map[string]DataMode),
+			Requests:  make(map[string]*Request),
+			Scenarios: make(map[string]*ScenarioDefinition),
+		},
+	}
+
+	// Declarations first, so flows may reference requests defined later
+	for _, stmt := range program.Statements {
+		switch s := stmt.(type) {
+		case *parser.VariableDeclaration:
+			l.scenario.Variables[s.Name] = exprString(s.Value)
+		case *parser.RequestDeclaration:
+			if err := l.lowerRequest(s); err != nil {
+				return nil, err
+			}
+		case *parser.DataDeclaration:
+			if err := l.lowerData(s); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, stmt := range program.Statements {
+		switch s := stmt.(type) {
+		case *parser.ScenarioDeclaration:
+			if err := l.lowerScenario(s); err != nil {
+				return nil, err
+			}
+		case *parser.LifecycleDeclaration:
+			if err := l.lowerLifecycle(s); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return l.scenario, nil
+}
+
+func (l *lowerer) lowerRequest(decl *parser.RequestDeclaration) error {
+	if _, exists := l.scenario.Requests[decl.Name]; exists {
+		return fmt.Errorf("duplicate request '%s' at %s", decl.Name, decl.Pos)
+	}
+
+	if decl.CurlCommand == nil {
+		return fmt.Errorf("request '%s' has no curl command at %s", decl.Name, decl.Pos)
+	}
+
+	req := &Request{
+		Name:    decl.Name,
+		CurlCmd: decl.CurlCommand.Raw,
+		Extract: make(map[string]ir.ExtractRule),
+	}
+
+	for _, ext := range decl.Extractions {
+		req.Extract[ext.Variable] = extractionRule(ext)
+	}
+
+	for _, a := range decl.Assertions {
+		assertion, err := ParseAssertion(a.Field, a.Operator, a.Raw)
+		if err == nil {
+			err = assertion.loadSchema(l.baseDir)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid assertion '%s' at %s: %w", assertion, a.Pos, err)
+		}
+		req.Assert = append(req.Assert, assertion)
+	}
+
+	if decl.Evaluator != nil {
+		path := decl.Evaluator.Path
+		if !filepath.IsAbs(path) {
+			// Joining cleans "./ev.py" to "ev.py", which exec would look up
+			// on $PATH, so resolve it to an absolute path
+			abs, err := filepath.Abs(filepath.Join(l.baseDir, path))
+			if err != nil {
+				return fmt.Errorf("invalid evaluator path in request '%s' at %s: %w", decl.Name, decl.Evaluator.Pos, err)
+			}
+			path = abs
+		}
+		req.Evaluator = &EvaluatorRef{Type: decl.Evaluator.Type, Path: path}
+	}
+
+	if decl.RetryConfig != nil {
+		retry, err := lowerRetry(decl.RetryConfig)
+		if err != nil {
+			return fmt.Errorf("invalid retry in request '%s' at %s: %w", decl.Name, decl.RetryConfig.Pos, err)
+		}
+		req.Retry = retry
+	}
+
+	l.scenario.Requests[decl.Name] = req
+	return nil
+}
+
+func (l *lowerer) lowerData(decl *parser.DataDeclaration) error {
+	if _, exists := l.scenario.Data[decl.Name]; exists {
+		return fmt.Errorf("duplicate data '%s' at %s", decl.Name, decl.Pos)
+	}
+
+	mode, err := ParseDataMode(decl.Mode)
+	if err != nil {
+		return fmt.Errorf("%v at %s", err, decl.Pos)
+	}
+
+	var rows []map[string]any
+	if decl.File != "" {
+		path := decl.File
+		if !filepath.IsAbs(path) && l.baseDir != "" {
+			path = filepath.Join(l.baseDir, path)
+		}
+		rows, err = LoadDataFile(path)
+	} else {
+		rows, err = ParseInlineData(decl.Inline)
+	}
+	if err != nil {
+		return fmt.Errorf("data '%s' at %s: %w", decl.Name, decl.Pos, err)
+	}
+
+	l.scenario.Data[decl.Name] = rows
+	l.scenario.DataModes[decl.Name] = mode
+	return nil
+}
+
+func (l *lowerer) lowerScenario(decl *parser.ScenarioDeclaration) error {
+	if _, exists := l.scenario.Scenarios[decl.Name]; exists {
+		return fmt.Errorf("duplicate scenario '%s' at %s", decl.Name, decl.Pos)
+	}
+
+	def := &ScenarioDefinition{
+		Name:          decl.Name,
+		SharedCookies: decl.Cookies == "shared",
+	}
+
+	if decl.LoadConfig != nil {
+		def.Load = &LoadConfig{
+			VUs:        decl.LoadConfig.VUs,
+			Duration:   decl.LoadConfig.Duration,
+			RPS:        decl.LoadConfig.RPS,
+			Iterations: decl.LoadConfig.Iterations,
+			RampUp:     decl.LoadConfig.RampUp,
+			MaxVUs:     decl.LoadConfig.MaxVUs,
+		}
+		rateStages := 0
+		for _, stage := range decl.LoadConfig.Stages {
+			if _, err := parseDuration(stage.Duration); err != nil {
+				return fmt.Errorf("invalid stage duration '%s' at %s", stage.Duration, stage.Pos)
+			}
+			if stage.RPS > 0 {
+				rateStages++
+			}
+			def.Load.Stages = append(def.Load.Stages, &Stage{
+				Duration: stage.Duration,
+				VUs:      stage.VUs,
+				RPS:      stage.RPS,
+			})
+		}
+		if rateStages > 0 && rateStages < len(decl.LoadConfig.Stages) {
+			for _, stage := range decl.LoadConfig.Stages {
+				if stage.VUs > 0 {
+					return fmt.Errorf("cannot mix vus and rps stages at %s", stage.Pos)
+				}
+			}
+		}
+	}
+
+	for _, t := range decl.Thresholds {
+		threshold, err := ParseThreshold(t.Expr)
+		if err != nil {
+			return fmt.Errorf("invalid threshold '%s' at %s: %w", t.Expr, t.Pos, err)
+		}
+		threshold.AbortOnFail = t.AbortOnFail
+		if t.AbortDelay != "" {
+			threshold.AbortDelay, err = parseDuration(t.AbortDelay)
+			if err != nil {
+				return fmt.Errorf("invalid abort delay '%s' at %s", t.AbortDelay, t.Pos)
+			}
+		}
+		def.Thresholds = append(def.Thresholds, threshold)
+	}
+
+	if len(decl.Flow) > 0 {
+		children, err := l.lowerFlows(decl.Flow)
+		if err != nil {
+			return fmt.Errorf("scenario '%s': %w", decl.Name, err)
+		}
+		def.Flow = &Flow{
+			Type:     FlowSequential,
+			Children: children,
+		}
+	}
+
+	l.scenario.Scenarios[decl.Name] = def
+	return nil
+}
+
+func (l *lowerer) lowerLifecycle(decl *parser.LifecycleDeclaration) error {
+	var steps []string
+
+	for _, stmt := range decl.Flow {
+		switch s := stmt.(type) {
+		case *parser.RunStatement:
+			steps = append(steps, s.RequestName)
+		case *parser.SequentialFlow:
+			steps = append(steps, s.Steps...)
+		default:
+			return fmt.Errorf("only run statements are allowed in %s at %s", decl.Phase, stmt.Position())
+		}
+	}
+
+	for _, name := range steps {
+		if _, ok := l.scenario.Requests[name]; !ok {
+			return fmt.Errorf("%s request '%s' not found at %s", decl.Phase, name, decl.Pos)
+		}
+	}
+
+	if decl.Phase == "setup" {
+		l.scenario.Setup = append(l.scenario.Setup, steps...)
+	} else {
+		l.scenario.Teardown = append(l.scenario.Teardown, steps...)
+	}
+
+	return nil
+}
+
+func (l *lowerer) lowerFlows(stmts []parser.FlowStatement) ([]*Flow, error) {
+	flows := make([]*Flow, 0, len(stmts))
+
+	for _, stmt := range stmts {
+		flow, err := l.lowerFlow(stmt)
+		if err != nil {
+			return nil, err
+		}
+		flows = append(flows, flow)
+	}
+
+	return flows, nil
+}
+
+func (l *lowerer) lowerFlow(stmt parser.FlowStatement) (*Flow, error) {
+	switch s := stmt.(type) {
+	case *parser.RunStatement:
+		if err := l.checkRequest(s.RequestName, s.Pos); err != nil {
+			return nil, err
+		}
+		return &Flow{
+			Type:  FlowSequential,
+			Steps: []string{s.RequestName},
+		}, nil
+
+	case *parser.SequentialFlow:
+		for _, step := range s.Steps {
+			if err := l.checkRequest(step, s.Pos); err != nil {
+				return nil, err
+			}
+		}
+		return &Flow{
+			Type:  FlowSequential,
+			Steps: s.Steps,
+		}, nil
+
+	case *parser.NestedFlow:
+		if err := l.checkRequest(s.Parent, s.Pos); err != nil {
+			return nil, err
+		}
+		children, err := l.lowerFlows(s.Children)
+		if err != nil {
+			return nil, err
+		}
+		return &Flow{
+			Type:     FlowNested,
+			Steps:    []string{s.Parent},
+			Children: children,
+		}, nil
+
+	case *parser.ParallelFlow:
+		branches, err := l.lowerFlows(s.Branches)
+		if err != nil {
+			return nil, err
+		}
+		return &Flow{
+			Type:        FlowParallel,
+			Children:    branches,
+			MaxParallel: s.Max,
+		}, nil
+
+	case *parser.ConditionalFlow:
+		if s.Condition == nil {
+			return nil, fmt.Errorf("missing condition at %s", s.Pos)
+		}
+		condition, err := lowerCondition(s.Condition)
+		if err != nil {
+			return nil, err
+		}
+		then, err := l.lowerFlows(s.ThenBlock)
+		if err != nil {
+			return nil, err
+		}
+		otherwise, err := l.lowerFlows(s.ElseBlock)
+		if err != nil {
+			return nil, err
+		}
+		return &Flow{
+			Type:      FlowConditional,
+			Condition: condition,
+			Children:  then,
+			Else:      otherwise,
+		}, nil
+
+	case *parser.ForEachFlow:
+		if _, ok := l.scenario.Data[s.DataName]; !ok {
+			return nil, fmt.Errorf("data '%s' not found at %s", s.DataName, s.Pos)
+		}
+		children, err := l.lowerFlows(s.Body)
+		if err != nil {
+			return nil, err
+		}
+		return &Flow{
+			Type:     FlowForEach,
+			Children: children,
+			ForEach: &ForEachLoop{
+				ItemVar:  s.ItemVar,
+				DataName: s.DataName,
+			},
+		}, nil
+
+	case *parser.ThinkStatement:
+		if _, err := time.ParseDuration(s.Duration); err != nil {
+			return nil, fmt.Errorf("invalid think duration '%s' at %s", s.Duration, s.Pos)
+		}
+		return &Flow{
+			Type:      FlowThink,
+			ThinkTime: &ThinkTime{Duration: s.Duration},
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported flow statement at %s", stmt.Position())
+}
+
+func (l *lowerer) checkRequest(name string, pos parser.Position) error {
+	if _, ok := l.scenario.Requests[name]; !ok {
+		return fmt.Errorf("request '%s' not found at %s", name, pos)
+	}
+	return nil
+}
+
+// extractionRule converts an extraction to the rule evaluators also use
+func extractionRule(ext *parser.Extraction) ir.ExtractRule {
+	switch ext.Type {
+	case parser.ExtractRegex:
+		return ir.ExtractRule{Regex: ext.Path}
+	case parser.ExtractHeader:
+		return ir.ExtractRule{Header: ext.Path}
+	case parser.ExtractCookie:
+		return ir.ExtractRule{Cookie: ext.Path}
+	default:
+		return ir.ExtractRule{JSONPath: ext.Path}
+	}
+}
+
+// exprString renders an expression as the plain text used by the runtime
+func exprString(expr parser.Expression) string {
+	switch e := expr.(type) {
+	case *parser.StringLiteral:
+		return e.Value
+	case *parser.NumberLiteral:
+		return strconv.Itoa(e.Value)
+	case *parser.DurationLiteral:
+		return e.Value
+	case *parser.VariableReference:
+		return "${" + e.Name + "}"
+	case *parser.Identifier:
+		return e.Name
+	case *parser.BooleanLiteral:
+		return strconv.FormatBool(e.Value)
+	}
+	return ""
+}
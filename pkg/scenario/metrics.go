@@ -0,0 +1,193 @@
+package scenario
+
+import (
+	"sync"
+	"time"
+
+	"github.com/vikasavnish/httptool/pkg/histogram"
+)
+
+// metrics aggregates request results as they complete, so a run's stats use
+// the same memory however many requests it makes.
+type metrics struct {
+	mu        sync.Mutex
+	stats     Stats
+	overall   *histogram.Histogram
+	byRequest map[string]*histogram.Histogram
+	byStatus  map[int]*histogram.Histogram
+	phases    map[string]*histogram.Histogram
+	requests  map[string]*RequestStats
+}
+
+// TimingPhases names the phases of ir.Timing in Stats.Timing, in the order
+// they happen
+var TimingPhases = []string{"dns", "connect", "tls", "wait", "ttfb", "transfer"}
+
+func newMetrics() *metrics {
+	return &metrics{
+		overall:   histogram.New(),
+		byRequest: make(map[string]*histogram.Histogram),
+		byStatus:  make(map[int]*histogram.Histogram),
+		phases:    make(map[string]*histogram.Histogram),
+		requests:  make(map[string]*RequestStats),
+	}
+}
+
+// record adds one finished request. Requests that never got a response count
+// as failures but carry no latency.
+func (m *metrics) record(req *RequestResult) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.stats.TotalRequests++
+	m.stats.TotalBytes += req.Size
+	m.stats.Checks += len(req.Checks)
+	m.stats.ChecksFailed += req.AssertionsFailed
+	if req.Failed() {
+		m.stats.FailedRequests++
+	} else {
+		m.stats.SuccessRequests++
+	}
+
+	outcome, ok := m.requests[req.Name]
+	if !ok {
+		outcome = &RequestStats{}
+		m.requests[req.Name] = outcome
+	}
+	outcome.Count++
+	if req.Attempts > 1 {
+		m.stats.Retries += req.Attempts - 1
+		outcome.Retries += req.Attempts - 1
+	}
+	if req.Failed() {
+		outcome.Failed++
+		outcome.LastError = req.Error
+	}
+	for i, check := range req.Checks {
+		if i == len(outcome.Checks) {
+			outcome.Checks = append(outcome.Checks, &CheckStats{Name: check.Name})
+		}
+		if check.Passed {
+			outcome.Checks[i].Passes++
+		} else {
+			outcome.Checks[i].Fails++
+		}
+	}
+
+	if req.Status == 0 {
+		return
+	}
+
+	m.overall.Record(req.Latency)
+
+	byRequest, ok := m.byRequest[req.Name]
+	if !ok {
+		byRequest = histogram.New()
+		m.byRequest[req.Name] = byRequest
+	}
+	byRequest.Record(req.Latency)
+
+	byStatus, ok := m.byStatus[req.Status]
+	if !ok {
+		byStatus = histogram.New()
+		m.byStatus[req.Status] = byStatus
+	}
+	byStatus.Record(req.Latency)
+
+	if timing := req.Timing; timing != nil {
+		if timing.ConnReused {
+			m.stats.ReusedConnections++
+		}
+		for i, ms := range []float64{timing.DNSMs, timing.ConnectMs, timing.TLSMs, timing.WaitMs, timing.TTFBMs, timing.TransferMs} {
+			phase, ok := m.phases[TimingPhases[i]]
+			if !ok {
+				phase = histogram.New()
+				m.phases[TimingPhases[i]] = phase
+			}
+			phase.Record(time.Duration(ms * float64(time.Millisecond)))
+		}
+	}
+}
+
+// snapshot returns the stats recorded so far
+func (m *metrics) snapshot() *Stats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats := m.stats
+	stats.Latency = m.overall.Summary()
+	stats.TotalLatency = histogram.Millis(m.overall.Sum())
+	stats.AvgLatency = stats.Latency.Mean
+	stats.MinLatency = stats.Latency.Min
+	stats.MaxLatency = stats.Latency.Max
+
+	stats.ByRequest = make(map[string]histogram.Summary, len(m.byRequest))
+	for name, h := range m.byRequest {
+		stats.ByRequest[name] = h.Summary()
+	}
+	stats.ByStatus = make(map[int]histogram.Summary, len(m.byStatus))
+	for status, h := range m.byStatus {
+		stats.ByStatus[status] = h.Summary()
+	}
+	stats.Timing = make(map[string]histogram.Summary, len(m.phases))
+	for phase, h := range m.phases {
+		stats.Timing[phase] = h.Summary()
+	}
+	stats.Requests = make(map[string]*RequestStats, len(m.requests))
+	for name, outcome := range m.requests {
+		copied := *outcome
+		copied.Checks = make([]*CheckStats, len(outcome.Checks))
+		for i, check := range outcome.Checks {
+			c := *check
+			copied.Checks[i] = &c
+		}
+		stats.Requests[name] = &copied
+	}
+
+	return &stats
+}
+
+// value returns the current value of a threshold's metric. Rates with nothing
+// to measure yet count as 0 errors and 100% checks passed.
+func (m *metrics) value(t *Threshold) float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	switch t.Metric {
+	case "error_rate":
+		if m.stats.TotalRequests == 0 {
+			return 0
+		}
+		return float64(m.stats.FailedRequests) / float64(m.stats.TotalRequests)
+	case "checks":
+		if m.stats.Checks == 0 {
+			return 1
+		}
+		return float64(m.stats.Checks-m.stats.ChecksFailed) / float64(m.stats.Checks)
+	}
+
+	switch t.Stat {
+	case "avg":
+		return histogram.Millis(m.overall.Mean())
+	case "min":
+		return histogram.Millis(m.overall.Min())
+	case "max":
+		return histogram.Millis(m.overall.Max())
+	}
+	q, _ := thresholdQuantile(t.Stat)
+	return histogram.Millis(m.overall.Quantile(q))
+}
+
+// evaluate checks every threshold against the results so far
+func (m *metrics) evaluate(thresholds []*Threshold) []ThresholdResult {
+	results := make([]ThresholdResult, 0, len(thresholds))
+	for _, t := range thresholds {
+		actual := m.value(t)
+		results = append(results, ThresholdResult{
+			Threshold: t,
+			Actual:    actual,
+			Passed:    t.Check(actual),
+		})
+	}
+	return results
+}
@@ -0,0 +1,79 @@
+package scenario
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestExecutor_LatencyBreakdown(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	input := `var base = "` + server.URL + `"
+
+request home {
+  curl ${base}/
+}
+
+request missing {
+  curl ${base}/missing
+}
+
+scenario test {
+  load 3 iterations with 1 vus
+  run home -> missing
+}`
+
+	s, err := NewParser(input).Parse()
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+
+	compiled, err := NewCompiler().Compile(s, "test")
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+
+	result, err := NewExecutor().Execute(context.Background(), compiled)
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+
+	stats := result.Stats
+	if stats.TotalRequests != 6 || stats.Latency.Count != 6 {
+		t.Fatalf("total=%d recorded=%d, want 6", stats.TotalRequests, stats.Latency.Count)
+	}
+	if stats.ByRequest["home"].Count != 3 || stats.ByRequest["missing"].Count != 3 {
+		t.Errorf("by request = %+v", stats.ByRequest)
+	}
+	if stats.ByStatus[200].Count != 3 || stats.ByStatus[404].Count != 3 {
+		t.Errorf("by status = %+v", stats.ByStatus)
+	}
+	if stats.Latency.P50 > stats.Latency.P99 || stats.Latency.P99 > stats.MaxLatency {
+		t.Errorf("percentiles out of order: %+v", stats.Latency)
+	}
+
+	// One VU keeps its connection alive after the first request
+	if stats.ReusedConnections != 5 {
+		t.Errorf("reused connections = %d, want 5", stats.ReusedConnections)
+	}
+	for _, phase := range TimingPhases {
+		if stats.Timing[phase].Count != 6 {
+			t.Errorf("timing %s recorded %d requests, want 6", phase, stats.Timing[phase].Count)
+		}
+	}
+	if stats.Timing["ttfb"].Max > stats.MaxLatency {
+		t.Errorf("ttfb %.2fms exceeds latency %.2fms", stats.Timing["ttfb"].Max, stats.MaxLatency)
+	}
+
+	vu := result.VUResults[0]
+	if vu.Iterations != 3 || vu.Requests != 6 {
+		t.Errorf("VU totals = %+v", vu)
+	}
+}
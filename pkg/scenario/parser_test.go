@@ -0,0 +1,149 @@
+package scenario
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParser_NestedAndConditionalFlows(t *testing.T) {
+	input := `var base = "https://api.example.com"
+
+request login {
+  curl -X POST ${base}/login \
+    -d '{"user":"a"}'
+  extract token = $.access_token
+  assert status == 200
+}
+
+request profile {
+  curl ${base}/profile -H "Authorization: Bearer ${token}"
+}
+
+request new_api {
+  curl ${base}/v2
+}
+
+request old_api {
+  curl ${base}/v1
+}
+
+scenario journey {
+  load 5 vus for 10s
+
+  run login {
+    run profile
+  }
+  if ${token} != "" {
+    run new_api
+  } else {
+    run old_api
+  }
+}`
+
+	s, err := NewParser(input).Parse()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	login := s.Requests["login"]
+	if login == nil {
+		t.Fatal("request 'login' not lowered")
+	}
+	if !strings.Contains(login.CurlCmd, `-d '{"user":"a"}'`) || strings.Contains(login.CurlCmd, "\\\n") {
+		t.Errorf("unexpected curl command: %q", login.CurlCmd)
+	}
+	if login.Extract["token"].JSONPath != "$.access_token" {
+		t.Errorf("extract token = %q", login.Extract["token"].JSONPath)
+	}
+
+	compiled, err := NewCompiler().Compile(s, "journey")
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+
+	if len(compiled.Main) != 2 {
+		t.Fatalf("expected 2 top-level nodes, got %d", len(compiled.Main))
+	}
+
+	nested := compiled.Main[0]
+	if nested.Name != "login" || len(nested.Children) != 1 || nested.Children[0].Name != "profile" {
+		t.Errorf("nested flow not compiled as login { profile }")
+	}
+
+	branch := compiled.Main[1]
+	if branch.IR != nil {
+		t.Fatalf("conditional node should not carry a request")
+	}
+	if branch.Condition != `${token} != ""` {
+		t.Errorf("condition = %q", branch.Condition)
+	}
+	if len(branch.Children) != 1 || branch.Children[0].Name != "new_api" {
+		t.Errorf("then branch not compiled")
+	}
+	if len(branch.Else) != 1 || branch.Else[0].Name != "old_api" {
+		t.Errorf("else branch not compiled")
+	}
+}
+
+func TestParser_ErrorsCarryPositions(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name: "unknown request",
+			input: `request a {
+  curl https://example.com
+}
+
+scenario s {
+  run missing
+}`,
+			want: "request 'missing' not found at 6:3",
+		},
+		{
+			name: "syntax error",
+			input: `request a {
+  curl https://example.com
+}
+bogus`,
+			want: "at 4:1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewParser(tt.input).Parse()
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error %q does not contain %q", err.Error(), tt.want)
+			}
+		})
+	}
+}
+
+func TestParser_EvaluatorPathRelativeToBaseDir(t *testing.T) {
+	input := `request check {
+  curl https://api.example.com/check
+  evaluate python "./ev.py"
+}`
+
+	parser := NewParser(input)
+	parser.BaseDir = "evaluators"
+	s, err := parser.Parse()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want, err := filepath.Abs(filepath.Join("evaluators", "ev.py"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Requests["check"].Evaluator.Path; got != want {
+		t.Errorf("evaluator path = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,97 @@
+package scenario
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/vikasavnish/httptool/pkg/arrival"
+)
+
+// usesArrivalRate reports whether a load config describes an open model
+func usesArrivalRate(load *LoadConfig) bool {
+	if load.RPS > 0 && load.Duration != "" {
+		return true
+	}
+	for _, stage := range load.Stages {
+		if stage.RPS > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// arrivalSchedule builds the arrival schedule for a load config: a constant
+// RPS for Duration, or RPS stages ramping from RPS (or 0)
+func arrivalSchedule(load *LoadConfig) (arrival.Schedule, error) {
+	if len(load.Stages) == 0 {
+		duration, err := parseDuration(load.Duration)
+		if err != nil {
+			return arrival.Schedule{}, fmt.Errorf("invalid duration '%s'", load.Duration)
+		}
+		return arrival.Constant(float64(load.RPS), duration), nil
+	}
+
+	schedule := arrival.Schedule{StartRate: float64(load.RPS)}
+	for i, stage := range load.Stages {
+		duration, err := parseDuration(stage.Duration)
+		if err != nil {
+			return arrival.Schedule{}, fmt.Errorf("invalid duration '%s' in stage %d", stage.Duration, i+1)
+		}
+		schedule.Stages = append(schedule.Stages, arrival.Stage{
+			Duration: duration,
+			Rate:     float64(stage.RPS),
+		})
+	}
+
+	return schedule, schedule.Validate()
+}
+
+// executeArrivalRate starts iterations at the configured rate on a bounded VU
+// pool and returns the number of iterations dropped because every VU was busy.
+// VUs defaults to one pre-allocated VU per request per second at peak rate,
+// at most MaxVUs, and MaxVUs to the pre-allocated count.
+func (e *Executor) executeArrivalRate(ctx context.Context, scenario *CompiledScenario, result *ScenarioResult) (int, error) {
+	schedule, err := arrivalSchedule(scenario.Load)
+	if err != nil {
+		return 0, err
+	}
+
+	pool := arrival.Pool{
+		PreAllocated: scenario.Load.VUs,
+		Max:          scenario.Load.MaxVUs,
+	}
+	if pool.PreAllocated == 0 {
+		pool.PreAllocated = int(math.Ceil(schedule.Peak()))
+		if pool.Max > 0 && pool.PreAllocated > pool.Max {
+			pool.PreAllocated = pool.Max
+		}
+	}
+
+	var mu sync.Mutex
+	vuResults := make(map[int]*VUResult)
+
+	run, err := arrival.Run(ctx, schedule, pool, func(ctx context.Context, vu, iter int) {
+		iterResult := e.executeIteration(ctx, scenario, vu, iter, result.SetupVars)
+		if iterResult == nil {
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		vuResult, ok := vuResults[vu]
+		if !ok {
+			vuResult = &VUResult{VUID: vu}
+			vuResults[vu] = vuResult
+			result.VUResults = append(result.VUResults, vuResult)
+		}
+		vuResult.add(iterResult)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("arrival-rate load: %w", err)
+	}
+
+	return run.Dropped, nil
+}
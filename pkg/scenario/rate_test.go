@@ -0,0 +1,92 @@
+package scenario
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestExecutor_ArrivalRateStages(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}))
+	defer server.Close()
+
+	input := `request ping {
+  curl ` + server.URL + `
+}
+
+scenario ramp {
+  load {
+    rps = 20
+    vus = 2
+    max_vus = 4
+  }
+  stages { 500ms -> 40 rps; 0s -> 0 rps }
+  run ping
+}`
+
+	s, err := NewParser(input).Parse()
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+
+	compiled, err := NewCompiler().Compile(s, "ramp")
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+
+	result, err := NewExecutor().Execute(context.Background(), compiled)
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+
+	// Ramping 20 -> 40 rps over 500ms schedules 15 iterations
+	total := result.Stats.TotalRequests + result.Stats.DroppedIterations
+	if total != 15 || int(hits.Load()) != result.Stats.TotalRequests {
+		t.Errorf("requests=%d dropped=%d hits=%d, want 15 scheduled", result.Stats.TotalRequests, result.Stats.DroppedIterations, hits.Load())
+	}
+	if len(result.VUResults) > 4 {
+		t.Errorf("used %d VUs, max_vus is 4", len(result.VUResults))
+	}
+}
+
+func TestExecutor_ArrivalRateDefaultVUsCappedByMax(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	input := `request ping {
+  curl ` + server.URL + `
+}
+
+scenario capped {
+  load {
+    rps = 50
+    max_vus = 3
+  }
+  stages { 200ms -> 50 rps }
+  run ping
+}`
+
+	s, err := NewParser(input).Parse()
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+
+	compiled, err := NewCompiler().Compile(s, "capped")
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+
+	result, err := NewExecutor().Execute(context.Background(), compiled)
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+
+	if len(result.VUResults) > 3 {
+		t.Errorf("used %d VUs, max_vus is 3", len(result.VUResults))
+	}
+}
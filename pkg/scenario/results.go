@@ -1,6 +1,11 @@
 package scenario
 
-import "time"
+import (
+	"time"
+
+	"github.com/vikasavnish/httptool/pkg/histogram"
+	"github.com/vikasavnish/httptool/pkg/ir"
+)
 
 // ScenarioResult holds the results of a scenario execution
 type ScenarioResult struct {
@@ -10,44 +15,137 @@ type ScenarioResult struct {
 	SetupVars map[string]any
 	VUResults []*VUResult
 	Stats     *Stats
+
+	Thresholds  []ThresholdResult
+	AbortReason string // Set when an abort_on_fail threshold stopped the run early
 }
 
-// VUResult holds results for a single virtual user
+// ThresholdsPassed reports whether every threshold held and the run was not
+// aborted
+func (r *ScenarioResult) ThresholdsPassed() bool {
+	if r.AbortReason != "" {
+		return false
+	}
+	for _, t := range r.Thresholds {
+		if !t.Passed {
+			return false
+		}
+	}
+	return true
+}
+
+// VUResult holds totals for a single virtual user. Individual requests are
+// folded into the scenario stats as they complete rather than kept here.
 type VUResult struct {
-	VUID       int
-	Iterations []*IterationResult
+	VUID         int
+	Iterations   int
+	Requests     int
+	Failed       int
+	TotalLatency time.Duration
+}
+
+// add folds a finished iteration into the VU totals
+func (r *VUResult) add(iter *IterationResult) {
+	r.Iterations++
+	for _, req := range iter.Requests {
+		r.Requests++
+		r.TotalLatency += req.Latency
+		if req.Failed() {
+			r.Failed++
+		}
+	}
 }
 
-// IterationResult holds results for a single iteration
+// IterationResult holds results for a single iteration. It lives only until
+// the iteration has been added to its VU.
 type IterationResult struct {
 	IterationNum int
 	StartTime    time.Time
 	EndTime      time.Time
 	Requests     []*RequestResult
+
+	last      *ir.Response   // Most recent response, which conditions read
+	extracted map[string]any // Variables extracted so far, tracked in parallel branches
+}
+
+// setVars stores extracted variables for the rest of the iteration
+func (r *IterationResult) setVars(vars map[string]any, extracted map[string]any) {
+	for k, v := range extracted {
+		vars[k] = v
+		if r.extracted != nil {
+			r.extracted[k] = v
+		}
+	}
 }
 
 // RequestResult holds results for a single request
 type RequestResult struct {
+	Name              string
 	URL               string
 	Method            string
+	VUID              int
+	Iteration         int
 	Status            int
 	Latency           time.Duration
 	Size              int64
 	Error             string
+	Checks            []CheckResult
 	AssertionsFailed  int
+	Attempts          int // 1, plus one per retry
 	StartTime         time.Time
+	Timing            *ir.Timing // Phases of the last attempt, when it got a response
 }
 
-// Stats holds aggregated statistics
+// CheckResult is the outcome of one assertion on a request
+type CheckResult struct {
+	Name   string `json:"name"` // e.g. "status == 200"
+	Passed bool   `json:"passed"`
+}
+
+// Failed reports whether the request errored or failed an assertion
+func (r *RequestResult) Failed() bool {
+	return r.Error != "" || r.AssertionsFailed > 0
+}
+
+// Stats holds aggregated statistics. Latencies are in milliseconds.
 type Stats struct {
-	TotalRequests   int
-	SuccessRequests int
-	FailedRequests  int
-	TotalBytes      int64
-	TotalLatency    float64
-	AvgLatency      float64
-	MinLatency      float64
-	MaxLatency      float64
+	TotalRequests     int     `json:"total_requests"`
+	SuccessRequests   int     `json:"success_requests"`
+	FailedRequests    int     `json:"failed_requests"`
+	TotalBytes        int64   `json:"total_bytes"`
+	TotalLatency      float64 `json:"total_latency_ms"`
+	AvgLatency        float64 `json:"avg_latency_ms"`
+	MinLatency        float64 `json:"min_latency_ms"`
+	MaxLatency        float64 `json:"max_latency_ms"`
+	DroppedIterations int     `json:"dropped_iterations"` // Arrival-rate iterations skipped because every VU was busy
+	Checks            int     `json:"checks"`             // Assertions evaluated
+	ChecksFailed      int     `json:"checks_failed"`
+	Retries           int     `json:"retries"`            // Attempts beyond the first
+	ReusedConnections int     `json:"reused_connections"` // Requests sent on a kept-alive connection
+
+	// Latency distributions of requests that got a response
+	Latency   histogram.Summary            `json:"latency"`
+	ByRequest map[string]histogram.Summary `json:"latency_by_request"` // Keyed by request name
+	ByStatus  map[int]histogram.Summary    `json:"latency_by_status"`
+	Timing    map[string]histogram.Summary `json:"timing"` // By phase: dns, connect, tls, wait, ttfb, transfer
+
+	Requests map[string]*RequestStats `json:"requests"` // Outcomes keyed by request name
+}
+
+// RequestStats counts the outcomes of one named request
+type RequestStats struct {
+	Count     int           `json:"count"`
+	Failed    int           `json:"failed"`
+	Retries   int           `json:"retries,omitempty"`
+	LastError string        `json:"last_error,omitempty"`
+	Checks    []*CheckStats `json:"checks,omitempty"` // In assertion order
+}
+
+// CheckStats counts the outcomes of one assertion
+type CheckStats struct {
+	Name   string `json:"name"`
+	Passes int    `json:"passes"`
+	Fails  int    `json:"fails"`
 }
 
 // PrintSummary prints a human-readable summary
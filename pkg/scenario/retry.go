@@ -0,0 +1,184 @@
+package scenario
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vikasavnish/httptool/pkg/ir"
+	"github.com/vikasavnish/httptool/pkg/parser"
+)
+
+// DefaultRetryOn is what a retry block retries when it has no 'on' setting:
+// requests that got no response, server errors and rate limiting
+var DefaultRetryOn = []string{"network", "5xx", "429"}
+
+const (
+	defaultRetryAttempts  = 3
+	defaultRetryBaseDelay = 100 * time.Millisecond
+)
+
+// statusClass matches conditions such as 5xx
+var statusClass = regexp.MustCompile(`^[1-5]xx$`)
+
+// lowerRetry checks a retry block and fills in its defaults
+func lowerRetry(decl *parser.RetryConfig) (*RetryConfig, error) {
+	retry := &RetryConfig{
+		MaxAttempts: decl.MaxAttempts,
+		Backoff:     BackoffExponential,
+		BaseDelay:   decl.BaseDelay,
+		MaxDelay:    decl.MaxDelay,
+		On:          decl.On,
+	}
+	if retry.MaxAttempts == 0 {
+		retry.MaxAttempts = defaultRetryAttempts
+	}
+	if len(retry.On) == 0 {
+		retry.On = DefaultRetryOn
+	}
+
+	switch BackoffStrategy(decl.Backoff) {
+	case "":
+	case BackoffFixed, BackoffLinear, BackoffExponential:
+		retry.Backoff = BackoffStrategy(decl.Backoff)
+	default:
+		return nil, fmt.Errorf("unknown backoff '%s' (expected fixed, linear or exponential)", decl.Backoff)
+	}
+
+	for _, d := range []string{decl.BaseDelay, decl.MaxDelay} {
+		if d == "" {
+			continue
+		}
+		if _, err := time.ParseDuration(d); err != nil {
+			return nil, fmt.Errorf("invalid delay '%s'", d)
+		}
+	}
+
+	switch decl.Jitter {
+	case "", "false":
+	case "true":
+		retry.Jitter = true
+	default:
+		return nil, fmt.Errorf("jitter must be true or false, got '%s'", decl.Jitter)
+	}
+
+	for _, cond := range retry.On {
+		switch {
+		case cond == "network", cond == "assertion", cond == "retry_after", statusClass.MatchString(cond):
+		default:
+			code, err := strconv.Atoi(cond)
+			if err != nil || code < 100 || code > 599 {
+				return nil, fmt.Errorf("unknown retry condition '%s' (expected a status code, 4xx, 5xx, network, assertion or retry_after)", cond)
+			}
+		}
+	}
+
+	return retry, nil
+}
+
+// shouldRetry reports whether a finished attempt meets one of the retry
+// conditions
+func (r *RetryConfig) shouldRetry(result *RequestResult, resp *ir.Response) bool {
+	for _, cond := range r.On {
+		switch {
+		case cond == "network":
+			if result.Status == 0 && result.Error != "" {
+				return true
+			}
+		case cond == "assertion":
+			if result.AssertionsFailed > 0 {
+				return true
+			}
+		case cond == "retry_after":
+			if _, ok := retryAfter(resp); ok {
+				return true
+			}
+		case statusClass.MatchString(cond):
+			if result.Status/100 == int(cond[0]-'0') {
+				return true
+			}
+		default:
+			if strconv.Itoa(result.Status) == cond {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// delay is how long to wait after the given attempt. A Retry-After header on
+// the response takes precedence over the backoff; max_delay caps both.
+func (r *RetryConfig) delay(attempt int, resp *ir.Response) time.Duration {
+	base := defaultRetryBaseDelay
+	if d, err := time.ParseDuration(r.BaseDelay); err == nil {
+		base = d
+	}
+
+	var d time.Duration
+	switch r.Backoff {
+	case BackoffFixed:
+		d = base
+	case BackoffLinear:
+		d = base * time.Duration(attempt)
+	default:
+		d = base << min(attempt-1, 30)
+	}
+	if r.Jitter && d > 0 {
+		// Equal jitter: keep half the delay, randomise the rest
+		d = d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+	}
+
+	if wait, ok := retryAfter(resp); ok {
+		d = wait
+	}
+	if limit, err := time.ParseDuration(r.MaxDelay); err == nil && d > limit {
+		d = limit
+	}
+	return d
+}
+
+// retryAfter reads a Retry-After header, given in seconds or as an HTTP date
+func retryAfter(resp *ir.Response) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+
+	var value string
+	for k, v := range resp.Headers {
+		if strings.EqualFold(k, "Retry-After") {
+			value = strings.TrimSpace(v)
+		}
+	}
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0), true
+	}
+	return 0, false
+}
+
+// sleep waits for d, returning false if the context ends first
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,197 @@
+package scenario
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vikasavnish/httptool/pkg/ir"
+)
+
+func TestRetryConfig_Delay(t *testing.T) {
+	tests := []struct {
+		retry   RetryConfig
+		attempt int
+		want    time.Duration
+	}{
+		{RetryConfig{Backoff: BackoffFixed, BaseDelay: "200ms"}, 3, 200 * time.Millisecond},
+		{RetryConfig{Backoff: BackoffLinear, BaseDelay: "200ms"}, 3, 600 * time.Millisecond},
+		{RetryConfig{Backoff: BackoffExponential, BaseDelay: "200ms"}, 3, 800 * time.Millisecond},
+		{RetryConfig{Backoff: BackoffExponential}, 1, 100 * time.Millisecond},
+		{RetryConfig{Backoff: BackoffExponential, BaseDelay: "1s", MaxDelay: "5s"}, 10, 5 * time.Second},
+		{RetryConfig{Backoff: BackoffExponential, BaseDelay: "1s"}, 100, time.Second << 30},
+	}
+	for _, tt := range tests {
+		if got := tt.retry.delay(tt.attempt, nil); got != tt.want {
+			t.Errorf("%+v attempt %d: delay %v, want %v", tt.retry, tt.attempt, got, tt.want)
+		}
+	}
+
+	jittered := RetryConfig{Backoff: BackoffFixed, BaseDelay: "1s", Jitter: true}
+	for i := 0; i < 20; i++ {
+		if got := jittered.delay(1, nil); got < 500*time.Millisecond || got > time.Second {
+			t.Fatalf("jittered delay %v outside [500ms, 1s]", got)
+		}
+	}
+
+	retryAfter := &ir.Response{Status: 429, Headers: map[string]string{"Retry-After": "2"}}
+	if got := (&RetryConfig{BaseDelay: "10ms"}).delay(1, retryAfter); got != 2*time.Second {
+		t.Errorf("Retry-After delay %v, want 2s", got)
+	}
+	if got := (&RetryConfig{BaseDelay: "10ms", MaxDelay: "1s"}).delay(1, retryAfter); got != time.Second {
+		t.Errorf("capped Retry-After delay %v, want 1s", got)
+	}
+}
+
+func TestParser_InvalidRetry(t *testing.T) {
+	for _, block := range []string{
+		"max_attempts = 0",
+		"backoff = random",
+		"base_delay = soon",
+		"jitter = maybe",
+		"on = [5xx, 600]",
+		"on = [timeouts]",
+		"attempts = 3",
+	} {
+		input := "request flaky {\n  curl http://localhost/flaky\n  retry {\n    " + block + "\n  }\n}"
+		if _, err := NewParser(input).Parse(); err == nil {
+			t.Errorf("%s: expected an error", block)
+		}
+	}
+}
+
+func TestExecutor_Retry(t *testing.T) {
+	var mu sync.Mutex
+	calls := make(map[string]int)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls[r.URL.Path]++
+		n := calls[r.URL.Path]
+		mu.Unlock()
+
+		switch r.URL.Path {
+		case "/flaky":
+			if n < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+		case "/limited":
+			if n == 1 {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+		case "/pending":
+			if n == 1 {
+				fmt.Fprint(w, `{"state": "pending"}`)
+				return
+			}
+			fmt.Fprint(w, `{"state": "done"}`)
+			return
+		case "/broken":
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, `{}`)
+	}))
+	defer server.Close()
+
+	input := `var base = "` + server.URL + `"
+
+request flaky {
+  curl ${base}/flaky
+  retry {
+    max_attempts = 5
+    backoff = exponential
+    base_delay = 1ms
+    jitter = true
+  }
+  assert status == 200
+}
+
+request limited {
+  curl ${base}/limited
+  retry {
+    on = [429]
+    base_delay = 1ms
+  }
+}
+
+request pending {
+  curl ${base}/pending
+  retry {
+    on = assertion
+    backoff = fixed
+    base_delay = 1ms
+  }
+  assert body.state == done
+}
+
+request broken {
+  curl ${base}/broken
+  retry { max_attempts = 2; base_delay = 1ms }
+}
+
+request missing {
+  curl ${base}/missing
+  retry { base_delay = 1ms }
+}
+
+scenario retries {
+  load 1 iterations with 1 vus
+  run flaky -> limited -> pending -> broken -> missing
+}`
+
+	result := runScenario(t, input, "retries")
+
+	want := map[string]struct{ calls, retries, failed int }{
+		"flaky":   {3, 2, 0},
+		"limited": {2, 1, 0},
+		"pending": {2, 1, 0},
+		"broken":  {2, 1, 0},
+		"missing": {1, 0, 0},
+	}
+	for name, w := range want {
+		stats := result.Stats.Requests[name]
+		if got := calls["/"+name]; got != w.calls {
+			t.Errorf("%s: %d calls, want %d", name, got, w.calls)
+		}
+		if stats.Retries != w.retries || stats.Failed != w.failed {
+			t.Errorf("%s: %d retries, %d failed (%s)", name, stats.Retries, stats.Failed, stats.LastError)
+		}
+	}
+	if result.Stats.Retries != 5 {
+		t.Errorf("%d retries in total, want 5", result.Stats.Retries)
+	}
+}
+
+func TestExecutor_RetryNetworkError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	url := server.URL
+	server.Close()
+
+	input := `request down {
+  curl ` + url + `/health
+  retry { max_attempts = 3; base_delay = 1ms }
+}
+
+scenario outage {
+  load 1 iterations with 1 vus
+  run down
+}`
+
+	result := runScenario(t, input, "outage")
+
+	stats := result.Stats.Requests["down"]
+	if stats.Failed != 1 || stats.Retries != 2 || !strings.Contains(stats.LastError, "refused") {
+		t.Errorf("down = %+v", stats)
+	}
+}
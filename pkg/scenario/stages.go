@@ -0,0 +1,141 @@
+package scenario
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// stageTick is how often the stage scheduler adjusts the number of VUs
+const stageTick = 100 * time.Millisecond
+
+// stageTarget is a parsed stage: ramp linearly to vus over duration
+type stageTarget struct {
+	duration time.Duration
+	vus      int
+}
+
+// stagePlan returns the stages to run for a load config: the explicit stages,
+// or a ramp to VUs over RampUp followed by a steady phase for the rest of
+// Duration.
+func stagePlan(load *LoadConfig) ([]stageTarget, error) {
+	if len(load.Stages) == 0 {
+		rampUp, err := parseDuration(load.RampUp)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ramp_up '%s'", load.RampUp)
+		}
+		duration, err := parseDuration(load.Duration)
+		if err != nil {
+			return nil, fmt.Errorf("invalid duration '%s'", load.Duration)
+		}
+
+		plan := []stageTarget{{duration: rampUp, vus: load.VUs}}
+		if duration > rampUp {
+			plan = append(plan, stageTarget{duration: duration - rampUp, vus: load.VUs})
+		}
+		return plan, nil
+	}
+
+	plan := make([]stageTarget, 0, len(load.Stages))
+	for i, stage := range load.Stages {
+		duration, err := parseDuration(stage.Duration)
+		if err != nil {
+			return nil, fmt.Errorf("invalid duration '%s' in stage %d", stage.Duration, i+1)
+		}
+		if stage.VUs < 0 {
+			return nil, fmt.Errorf("negative VUs in stage %d", i+1)
+		}
+		plan = append(plan, stageTarget{duration: duration, vus: stage.VUs})
+	}
+
+	return plan, nil
+}
+
+// vusAt returns the target number of VUs after elapsed time, interpolating
+// linearly from the previous stage's target (starting at 0), and whether the
+// plan has finished.
+func vusAt(plan []stageTarget, elapsed time.Duration) (int, bool) {
+	from := 0
+
+	for _, stage := range plan {
+		if elapsed < stage.duration {
+			progress := float64(elapsed) / float64(stage.duration)
+			return from + int(math.Round(float64(stage.vus-from)*progress)), false
+		}
+		elapsed -= stage.duration
+		from = stage.vus
+	}
+
+	return from, true
+}
+
+// executeStages runs a staged load profile. VUs are added as the target
+// rises; when it falls, the newest VUs are retired after finishing their
+// current iteration.
+func (e *Executor) executeStages(ctx context.Context, scenario *CompiledScenario, result *ScenarioResult) error {
+	plan, err := stagePlan(scenario.Load)
+	if err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+
+	var active []chan struct{} // Stop signals of running VUs, newest last
+	nextVU := 1
+
+	start := time.Now()
+	ticker := time.NewTicker(stageTick)
+	defer ticker.Stop()
+
+	for ctx.Err() == nil {
+		target, finished := vusAt(plan, time.Since(start))
+		if finished {
+			break
+		}
+
+		for len(active) < target {
+			stop := make(chan struct{})
+			active = append(active, stop)
+
+			wg.Add(1)
+			go func(vuID int) {
+				defer wg.Done()
+
+				vuResult := e.runVU(ctx, scenario, vuID, result.SetupVars, func() bool {
+					select {
+					case <-stop:
+						return false
+					default:
+						return true
+					}
+				})
+
+				mu.Lock()
+				result.VUResults = append(result.VUResults, vuResult)
+				mu.Unlock()
+			}(nextVU)
+			nextVU++
+		}
+
+		for len(active) > target {
+			close(active[len(active)-1])
+			active = active[:len(active)-1]
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-ticker.C:
+		}
+	}
+
+	// Let the remaining VUs finish their current iteration
+	for _, stop := range active {
+		close(stop)
+	}
+	wg.Wait()
+
+	return nil
+}
@@ -0,0 +1,90 @@
+package scenario
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/vikasavnish/httptool/pkg/ir"
+)
+
+func TestVUsAt(t *testing.T) {
+	plan := []stageTarget{
+		{duration: 10 * time.Second, vus: 10},
+		{duration: 0, vus: 20},
+		{duration: 10 * time.Second, vus: 0},
+	}
+
+	tests := []struct {
+		elapsed  time.Duration
+		vus      int
+		finished bool
+	}{
+		{0, 0, false},
+		{5 * time.Second, 5, false},
+		{10 * time.Second, 20, false},
+		{15 * time.Second, 10, false},
+		{20 * time.Second, 0, true},
+	}
+
+	for _, tt := range tests {
+		vus, finished := vusAt(plan, tt.elapsed)
+		if vus != tt.vus || finished != tt.finished {
+			t.Errorf("vusAt(%s) = %d, %v; want %d, %v", tt.elapsed, vus, finished, tt.vus, tt.finished)
+		}
+	}
+}
+
+func TestStagePlan_RampUp(t *testing.T) {
+	plan, err := stagePlan(&LoadConfig{VUs: 4, Duration: "1m", RampUp: "10s"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(plan) != 2 || plan[0].duration != 10*time.Second || plan[1].duration != 50*time.Second || plan[1].vus != 4 {
+		t.Errorf("unexpected plan: %+v", plan)
+	}
+}
+
+func TestExecutor_Stages(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	compiled := &CompiledScenario{
+		Name: "stages",
+		Load: &LoadConfig{
+			Stages: []*Stage{
+				{Duration: "0s", VUs: 3},
+				{Duration: "300ms", VUs: 3},
+				{Duration: "200ms", VUs: 1},
+			},
+		},
+		Main: []*RequestNode{{
+			IR: &ir.IR{
+				Version:   ir.Version,
+				Request:   ir.Request{Method: "GET", URL: server.URL},
+				Transport: ir.DefaultTransport(),
+			},
+		}},
+	}
+
+	start := time.Now()
+	result, err := NewExecutor().Execute(context.Background(), compiled)
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+
+	if len(result.VUResults) != 3 {
+		t.Errorf("expected 3 VUs, got %d", len(result.VUResults))
+	}
+	if result.Stats.TotalRequests == 0 || result.Stats.FailedRequests > 0 {
+		t.Errorf("unexpected stats: %+v", result.Stats)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("stages took %s", elapsed)
+	}
+}
@@ -0,0 +1,147 @@
+package scenario
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vikasavnish/httptool/pkg/expr"
+)
+
+// Threshold is a pass/fail criterion over a scenario's aggregated results,
+// e.g. "p95(latency) < 300ms", "error_rate < 1%" or "checks > 99%".
+type Threshold struct {
+	Expr        string
+	Metric      string  // "latency", "error_rate" or "checks"
+	Stat        string  // For latency: "avg", "min", "max", "med" or "p<N>"
+	Operator    string  // <, <=, >, >=, == or !=
+	Value       float64 // Milliseconds for latency, a 0-1 fraction for rates
+	AbortOnFail bool
+	AbortDelay  time.Duration // How long the run must be going before it can abort
+}
+
+// ThresholdResult records how a threshold fared against the final stats
+type ThresholdResult struct {
+	Threshold *Threshold
+	Actual    float64 // Same unit as Threshold.Value
+	Passed    bool
+}
+
+var thresholdPattern = regexp.MustCompile(`^([a-z_][a-z0-9_.]*)(?:\(\s*([a-z0-9_.]+)\s*\))?\s*(<=|>=|==|!=|<|>)\s*(.+)$`)
+
+// ParseThreshold parses a threshold expression. The value is a constant
+// expression, such as 300ms, 1% or 2 * 150ms.
+func ParseThreshold(src string) (*Threshold, error) {
+	src = strings.TrimSpace(src)
+
+	m := thresholdPattern.FindStringSubmatch(src)
+	if m == nil {
+		return nil, fmt.Errorf("expected '<metric> <operator> <value>'")
+	}
+
+	threshold := &Threshold{
+		Expr:     src,
+		Operator: m[3],
+	}
+
+	if m[2] != "" {
+		threshold.Metric = m[2]
+		threshold.Stat = m[1]
+	} else {
+		threshold.Metric = m[1]
+	}
+
+	var err error
+	switch threshold.Metric {
+	case "latency":
+		if threshold.Stat == "" {
+			return nil, fmt.Errorf("latency needs an aggregate, e.g. p95(latency)")
+		}
+		if _, ok := thresholdQuantile(threshold.Stat); !ok && !isLatencyStat(threshold.Stat) {
+			return nil, fmt.Errorf("unknown latency aggregate '%s'", threshold.Stat)
+		}
+		threshold.Value, err = parseThresholdLatency(m[4])
+	case "error_rate", "checks":
+		if threshold.Stat != "" {
+			return nil, fmt.Errorf("%s does not take an aggregate", threshold.Metric)
+		}
+		threshold.Value, err = parseThresholdRate(m[4])
+	default:
+		return nil, fmt.Errorf("unknown metric '%s'", threshold.Metric)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return threshold, nil
+}
+
+// Check reports whether an observed value satisfies the threshold
+func (t *Threshold) Check(actual float64) bool {
+	passed, err := expr.Compare(t.Operator, actual, t.Value)
+	return err == nil && passed
+}
+
+// FormatValue renders a value in the threshold's unit
+func (t *Threshold) FormatValue(v float64) string {
+	if t.Metric == "latency" {
+		return fmt.Sprintf("%.2fms", v)
+	}
+	return fmt.Sprintf("%.2f%%", v*100)
+}
+
+// thresholdQuantile maps "p95" to 0.95 and "med" to 0.5
+func thresholdQuantile(stat string) (float64, bool) {
+	if stat == "med" {
+		return 0.5, true
+	}
+	if !strings.HasPrefix(stat, "p") {
+		return 0, false
+	}
+	p, err := strconv.ParseFloat(stat[1:], 64)
+	if err != nil || p < 0 || p > 100 {
+		return 0, false
+	}
+	return p / 100, true
+}
+
+func isLatencyStat(stat string) bool {
+	return stat == "avg" || stat == "min" || stat == "max"
+}
+
+// thresholdValue evaluates the value side of a threshold
+func thresholdValue(src string) (any, error) {
+	value, err := expr.Compile(src)
+	if err != nil {
+		return nil, err
+	}
+	return value.Eval(nil)
+}
+
+// parseThresholdLatency accepts a duration ("300ms", "1.5s") or a bare
+// number of milliseconds
+func parseThresholdLatency(s string) (float64, error) {
+	v, _ := thresholdValue(s)
+	switch v := v.(type) {
+	case float64:
+		return v, nil
+	case time.Duration:
+		return float64(v) / float64(time.Millisecond), nil
+	}
+	return 0, fmt.Errorf("invalid latency '%s'", s)
+}
+
+// parseThresholdRate accepts a percentage ("1%") or a fraction ("0.01")
+func parseThresholdRate(s string) (float64, error) {
+	v, _ := thresholdValue(s)
+	rate, ok := v.(float64)
+	if !ok {
+		return 0, fmt.Errorf("invalid rate '%s'", s)
+	}
+	if rate < 0 || rate > 1 {
+		return 0, fmt.Errorf("rate '%s' is outside 0-100%%", s)
+	}
+	return rate, nil
+}
@@ -0,0 +1,150 @@
+package scenario
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseThreshold(t *testing.T) {
+	tests := []struct {
+		expr   string
+		metric string
+		stat   string
+		value  float64
+	}{
+		{"p95(latency) < 300ms", "latency", "p95", 300},
+		{"p99.9(latency) <= 1.5s", "latency", "p99.9", 1500},
+		{"avg(latency) < 120", "latency", "avg", 120},
+		{"error_rate < 1%", "error_rate", "", 0.01},
+		{"checks >= 0.99", "checks", "", 0.99},
+		{"max(latency) < 2 * 150ms", "latency", "max", 300},
+	}
+
+	for _, tt := range tests {
+		threshold, err := ParseThreshold(tt.expr)
+		if err != nil {
+			t.Errorf("%s: %v", tt.expr, err)
+			continue
+		}
+		if threshold.Metric != tt.metric || threshold.Stat != tt.stat || threshold.Value != tt.value {
+			t.Errorf("%s parsed as %+v", tt.expr, threshold)
+		}
+	}
+
+	for _, expr := range []string{"latency < 300ms", "p95(latency) < fast", "error_rate < 150%", "throughput > 10", "p95(latency)"} {
+		if _, err := ParseThreshold(expr); err == nil {
+			t.Errorf("%s: expected an error", expr)
+		}
+	}
+}
+
+func TestParser_InvalidThresholdPosition(t *testing.T) {
+	input := `request health {
+  curl http://localhost/health
+}
+
+scenario gate {
+  load 1 iterations with 1 vus
+  thresholds {
+    p95(latency) < soon
+  }
+  run health
+}`
+
+	_, err := NewParser(input).Parse()
+	if err == nil || !strings.Contains(err.Error(), "at 8:5") {
+		t.Errorf("expected threshold error at 8:5, got %v", err)
+	}
+}
+
+func TestExecutor_Thresholds(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	input := `var base = "` + server.URL + `"
+
+request health {
+  curl ${base}/health
+  assert status == 200
+}
+
+scenario gate {
+  load 4 iterations with 1 vus
+  thresholds {
+    p99(latency) < 10s
+    error_rate < 1%
+    checks > 99%
+  }
+  run health
+}`
+
+	result := runScenario(t, input, "gate")
+
+	passed := []bool{true, false, false}
+	for i, r := range result.Thresholds {
+		if r.Passed != passed[i] {
+			t.Errorf("%s: passed=%v actual=%v", r.Threshold.Expr, r.Passed, r.Actual)
+		}
+	}
+	if result.ThresholdsPassed() {
+		t.Error("run with failed thresholds reported as passed")
+	}
+}
+
+func TestExecutor_AbortOnFail(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	input := `var base = "` + server.URL + `"
+
+request health {
+  curl ${base}/health
+  assert status == 200
+}
+
+scenario gate {
+  load 2 vus for 30s
+  thresholds {
+    error_rate < 1% abort_on_fail
+  }
+  run health
+}`
+
+	start := time.Now()
+	result := runScenario(t, input, "gate")
+
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("run took %v, expected abort after the first check", elapsed)
+	}
+	if !strings.Contains(result.AbortReason, "error_rate < 1%") {
+		t.Errorf("abort reason = %q", result.AbortReason)
+	}
+}
+
+func runScenario(t *testing.T, input, name string) *ScenarioResult {
+	t.Helper()
+
+	s, err := NewParser(input).Parse()
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+
+	compiled, err := NewCompiler().Compile(s, name)
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+
+	result, err := NewExecutor().Execute(context.Background(), compiled)
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	return result
+}
@@ -1,6 +1,12 @@
 package scenario
 
-import "github.com/vikasavnish/httptool/pkg/ir"
+import (
+	"fmt"
+	"strings"
+
+	"github.com/vikasavnish/httptool/pkg/expr"
+	"github.com/vikasavnish/httptool/pkg/ir"
+)
 
 // Scenario represents a complete load testing scenario
 type Scenario struct {
@@ -9,6 +15,7 @@ type Scenario struct {
 	Tags        map[string]string
 	Variables   map[string]string
 	Data        map[string][]map[string]any
+	DataModes   map[string]DataMode
 	Requests    map[string]*Request
 	Scenarios   map[string]*ScenarioDefinition
 	Setup       []string // Request names to run before scenario
@@ -17,32 +24,40 @@ type Scenario struct {
 
 // ScenarioDefinition defines a test scenario
 type ScenarioDefinition struct {
-	Name      string
-	Load      *LoadConfig
-	Flow      *Flow
-	ThinkTime *ThinkTime
+	Name          string
+	Load          *LoadConfig
+	Flow          *Flow
+	ThinkTime     *ThinkTime
+	Thresholds    []*Threshold
+	SharedCookies bool // Every VU uses one cookie jar instead of its own
 }
 
 // Request represents a named HTTP request block
 type Request struct {
 	Name       string
 	CurlCmd    string
-	Extract    map[string]string // var_name -> extraction rule
+	Extract    map[string]ir.ExtractRule // var_name -> extraction rule
 	Assert     []Assertion
 	Retry      *RetryConfig
+	Evaluator  *EvaluatorRef
 	Children   []string          // Names of child requests
 	Parallel   bool              // Execute children in parallel
-	Condition  string            // Conditional execution: "${var} == value"
+	Condition  string            // Runs only when this holds, e.g. ${role} == "admin"
 	ForEach    *ForEachLoop
 }
 
 // LoadConfig defines load testing parameters
+//
+// With RPS (or RPS stages) the scenario runs as an open model: iterations
+// start at the given rate on a pool of VUs (pre-allocated VUs, growing up to
+// MaxVUs) and are dropped when every VU is busy.
 type LoadConfig struct {
 	VUs        int
 	Duration   string // "5m", "30s"
 	RPS        int    // Requests per second
 	Iterations int
 	RampUp     string
+	MaxVUs     int
 	Stages     []*Stage
 }
 
@@ -54,11 +69,21 @@ type Stage struct {
 }
 
 // Flow represents execution flow
+//
+// Sequential flows run their Steps and then their Children in order. Nested
+// flows run Steps[0] and then Children with the variables it extracted.
+// Conditional flows run Children when Condition holds and Else otherwise.
+// For-each flows run Children once per row of the ForEach data set. Parallel
+// flows run each of their Children concurrently, at most MaxParallel at once.
 type Flow struct {
-	Type     FlowType // sequential, parallel, conditional
-	Steps    []string // Request names
-	Children []*Flow
-	Condition string
+	Type        FlowType // sequential, parallel, conditional
+	Steps       []string // Request names
+	Children    []*Flow
+	Else        []*Flow
+	Condition   string
+	ForEach     *ForEachLoop
+	MaxParallel int        // 0 runs every branch at once
+	ThinkTime   *ThinkTime // Pause of a think flow
 }
 
 // FlowType defines flow execution type
@@ -69,14 +94,26 @@ const (
 	FlowParallel    FlowType = "parallel"
 	FlowConditional FlowType = "conditional"
 	FlowNested      FlowType = "nested"
+	FlowForEach     FlowType = "foreach"
+	FlowThink       FlowType = "think"
 )
 
 // Assertion represents a response assertion
 type Assertion struct {
 	Type     AssertType
-	Field    string
-	Operator string
-	Value    any
+	Field    string     // As written, e.g. body.items[0].id or header.Content-Type
+	Path     string     // JSONPath for body, name for header and cookie
+	TypeOf   bool       // Compare the JSON type of the field: type(body.items) == array
+	Operator string     // ==, !=, <, <=, >, >=, contains, matches, in, exists, between, not ...
+	Value    any        // Expected value, parsed when the assertion is lowered
+	Raw      string     // Expected value as written
+	Schema   string     // JSON Schema file of body matches schema("user.schema.json")
+	Expr     *expr.Expr // Boolean expression of AssertExpr assertions, written in Field
+}
+
+// String renders the assertion as written, e.g. "status == 200"
+func (a Assertion) String() string {
+	return strings.TrimSpace(fmt.Sprintf("%s %s %s", a.Field, a.Operator, a.Raw))
 }
 
 // AssertType defines assertion type
@@ -85,16 +122,28 @@ type AssertType string
 const (
 	AssertStatus  AssertType = "status"
 	AssertLatency AssertType = "latency"
+	AssertSize    AssertType = "size"
 	AssertBody    AssertType = "body"
 	AssertHeader  AssertType = "header"
+	AssertCookie  AssertType = "cookie"
+	AssertExpr    AssertType = "expr" // e.g. len(body.items) > 0 || status == 204
 )
 
+// EvaluatorRef names the external evaluator that judges a request. Its
+// decision can fail the request and its extract actions add variables.
+type EvaluatorRef struct {
+	Type string // bun, python or go
+	Path string
+}
+
 // RetryConfig defines retry behavior
 type RetryConfig struct {
 	MaxAttempts int
 	Backoff     BackoffStrategy
 	BaseDelay   string
 	MaxDelay    string
+	Jitter      bool
+	On          []string // Retry conditions: status codes, 4xx/5xx, network, assertion, retry_after
 }
 
 // BackoffStrategy defines retry backoff
@@ -120,21 +169,38 @@ type ForEachLoop struct {
 
 // CompiledScenario represents a scenario compiled to IR tree
 type CompiledScenario struct {
-	Name      string
-	Load      *LoadConfig
-	Setup     []*ir.IR
-	Main      []*RequestNode
-	Teardown  []*ir.IR
-	Variables map[string]string
+	Name          string
+	Load          *LoadConfig
+	Setup         []*ir.IR
+	Main          []*RequestNode
+	Teardown      []*ir.IR
+	Variables     map[string]string
+	Data          map[string]*DataSet // Data sets bound to each iteration by name
+	Thresholds    []*Threshold
+	SharedCookies bool                    // Setup, teardown and every VU use one cookie jar
+	Steps         map[string]*RequestNode // Requests evaluators can branch to, by name
 }
 
 // RequestNode represents a node in the request execution tree
+//
+// A node without IR is a branch: it runs Children when Condition holds (or is
+// empty) and Else otherwise. With ForEach set, it runs Children once per row
+// in Rows instead. With Parallel set, children run concurrently, whether the
+// node makes a request first or not.
 type RequestNode struct {
-	IR         *ir.IR
-	Extract    map[string]string
-	Assert     []Assertion
-	Children   []*RequestNode
-	Parallel   bool
-	Condition  string
-	ThinkTime  *ThinkTime
+	Name        string
+	IR          *ir.IR
+	Extract     map[string]ir.ExtractRule
+	Assert      []Assertion
+	Evaluator   *EvaluatorRef
+	Retry       *RetryConfig
+	Children    []*RequestNode
+	Else        []*RequestNode
+	Parallel    bool
+	MaxParallel int // Most children running at once when Parallel, 0 for all
+	Condition   string
+	ThinkTime   *ThinkTime
+	cond        *expr.Expr // Compiled Condition
+	ForEach     *ForEachLoop
+	Rows        []map[string]any
 }
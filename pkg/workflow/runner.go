@@ -0,0 +1,184 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/vikasavnish/httptool/pkg/ir"
+	"github.com/vikasavnish/httptool/pkg/orchestrator"
+)
+
+// Runner executes workflows through an orchestrator, which applies retries,
+// evaluators and branch decisions to each step
+type Runner struct {
+	orchestrator *orchestrator.Orchestrator
+	maxJumps     int
+}
+
+// NewRunner creates a runner that executes steps with o
+func NewRunner(o *orchestrator.Orchestrator) *Runner {
+	return &Runner{orchestrator: o}
+}
+
+// SetMaxJumps limits how often a run may branch back to an earlier or the
+// same step (default orchestrator.DefaultMaxHops)
+func (r *Runner) SetMaxJumps(n int) {
+	r.maxJumps = n
+}
+
+// Report is the outcome of a workflow run
+type Report struct {
+	Name      string
+	Steps     []StepResult   // In the order they ran, branch targets included
+	Skipped   []string       // Steps that never ran: jumped over, or after a failure
+	Vars      map[string]any // Values extracted by the end of the run
+	StartTime time.Time
+	EndTime   time.Time
+}
+
+// StepResult is the outcome of one step. When the evaluator branched,
+// Result.Branches names the step jumped to, which follows as its own result.
+type StepResult struct {
+	Name   string
+	Result *orchestrator.Result
+	Error  error
+}
+
+// Passed counts the steps that ran without an error
+func (r *Report) Passed() int {
+	passed := 0
+	for _, step := range r.Steps {
+		if step.Error == nil {
+			passed++
+		}
+	}
+	return passed
+}
+
+// Failed reports whether a step failed
+func (r *Report) Failed() bool {
+	for _, step := range r.Steps {
+		if step.Error != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Run executes the steps in order. Values extracted by a step's evaluator are
+// added to the vars of the following steps, whose ${name} references they
+// fill in. A branch decision runs the named step and the workflow carries on
+// after it; steps it jumps over are reported as skipped. The run stops at the
+// first step that fails.
+func (r *Runner) Run(ctx context.Context, wf *Workflow) (*Report, error) {
+	if err := wf.Validate(); err != nil {
+		return nil, err
+	}
+
+	steps := make(map[string]*ir.IR, len(wf.Steps))
+	for _, step := range wf.Steps {
+		steps[step.Name] = prepare(step)
+	}
+	r.orchestrator.RegisterSteps(steps)
+
+	maxJumps := r.maxJumps
+	if maxJumps <= 0 {
+		maxJumps = orchestrator.DefaultMaxHops
+	}
+
+	report := &Report{
+		Name:      wf.Name,
+		Vars:      make(map[string]any),
+		StartTime: time.Now(),
+	}
+	jumps := 0
+
+	for i := 0; i < len(wf.Steps); {
+		step := wf.Steps[i]
+		if err := ctx.Err(); err != nil {
+			report.Steps = append(report.Steps, StepResult{Name: step.Name, Error: err})
+			break
+		}
+
+		result, err := r.orchestrator.ExecuteOne(ctx, withVars(steps[step.Name], report.Vars))
+		for k, v := range result.Extracted {
+			report.Vars[k] = v
+		}
+
+		next := i + 1
+		if n := len(result.Branches); n > 0 && err == nil {
+			next = wf.index(result.Branches[n-1]) + 1
+			if next <= i+1 {
+				jumps++
+				if jumps > maxJumps {
+					err = fmt.Errorf("workflow jumped back more than %d times", maxJumps)
+				}
+			}
+		}
+
+		// Each step of a branch chain is reported on its own; the error, if
+		// any, belongs to the last
+		names := append([]string{step.Name}, result.Branches...)
+		for j, stepResult := range result.Steps() {
+			report.Steps = append(report.Steps, StepResult{Name: names[j], Result: stepResult})
+		}
+		report.Steps[len(report.Steps)-1].Error = err
+		if err != nil {
+			break
+		}
+		i = next
+	}
+
+	report.skipUnrun(wf)
+	report.EndTime = time.Now()
+	return report, nil
+}
+
+// skipUnrun records the workflow's steps that never ran as skipped
+func (r *Report) skipUnrun(wf *Workflow) {
+	ran := make(map[string]bool, len(r.Steps))
+	for _, step := range r.Steps {
+		ran[step.Name] = true
+	}
+	for _, step := range wf.Steps {
+		if !ran[step.Name] {
+			r.Skipped = append(r.Skipped, step.Name)
+		}
+	}
+}
+
+// prepare returns a copy of a step's IR ready to run: the transport block
+// the IR schema leaves optional is filled in, and the metadata ID is the step
+// name, which results and branch loop detection refer to
+func prepare(step Step) *ir.IR {
+	spec := withVars(step.IR, nil)
+	if spec.Transport == nil {
+		spec.Transport = ir.DefaultTransport()
+	}
+	if spec.Metadata == nil {
+		spec.Metadata = &ir.Metadata{}
+	}
+	spec.Metadata.ID = step.Name
+	return spec
+}
+
+// withVars returns a deep copy of a step's IR, which the orchestrator is free
+// to mutate, with vars added over the step's own
+func withVars(step *ir.IR, vars map[string]any) *ir.IR {
+	data, _ := json.Marshal(step)
+	var spec ir.IR
+	json.Unmarshal(data, &spec)
+
+	if spec.Evaluation == nil {
+		spec.Evaluation = ir.DefaultEvaluation()
+	}
+	if spec.Evaluation.Vars == nil {
+		spec.Evaluation.Vars = make(map[string]any)
+	}
+	for k, v := range vars {
+		spec.Evaluation.Vars[k] = v
+	}
+	return &spec
+}
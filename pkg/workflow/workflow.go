@@ -0,0 +1,80 @@
+// Package workflow runs multi-step IR workflows: named IR documents executed
+// in order, as in examples/workflow-example.json.
+package workflow
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/vikasavnish/httptool/pkg/ir"
+)
+
+// Workflow is an ordered list of named IR steps
+type Workflow struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Steps       []Step `json:"steps"`
+}
+
+// Step is one named request of a workflow. Its name is what evaluator
+// branch decisions use in actions.goto.
+type Step struct {
+	Name string `json:"name"`
+	IR   *ir.IR `json:"ir"`
+}
+
+// Load reads and validates a workflow file
+func Load(path string) (*Workflow, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Parse decodes and validates a workflow document
+func Parse(data []byte) (*Workflow, error) {
+	var wf Workflow
+	if err := json.Unmarshal(data, &wf); err != nil {
+		return nil, fmt.Errorf("invalid workflow JSON: %w", err)
+	}
+	if err := wf.Validate(); err != nil {
+		return nil, err
+	}
+	return &wf, nil
+}
+
+// Validate checks that the workflow has steps, each with a unique name and a
+// request to send
+func (w *Workflow) Validate() error {
+	if len(w.Steps) == 0 {
+		return fmt.Errorf("workflow has no steps")
+	}
+
+	seen := make(map[string]bool)
+	for i, step := range w.Steps {
+		switch {
+		case step.Name == "":
+			return fmt.Errorf("step %d has no name", i+1)
+		case seen[step.Name]:
+			return fmt.Errorf("step name '%s' is used more than once", step.Name)
+		case step.IR == nil:
+			return fmt.Errorf("step '%s' has no ir", step.Name)
+		case step.IR.Request.URL == "":
+			return fmt.Errorf("step '%s' has no request url", step.Name)
+		}
+		seen[step.Name] = true
+	}
+	return nil
+}
+
+// index returns the position of the named step, or -1
+func (w *Workflow) index(name string) int {
+	for i, step := range w.Steps {
+		if step.Name == name {
+			return i
+		}
+	}
+	return -1
+}
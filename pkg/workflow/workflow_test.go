@@ -0,0 +1,198 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vikasavnish/httptool/pkg/ir"
+	"github.com/vikasavnish/httptool/pkg/orchestrator"
+)
+
+// The test binary doubles as a one-shot go evaluator: with WORKFLOW_EVALUATOR
+// set it reads a context and prints a decision.
+func TestMain(m *testing.M) {
+	if os.Getenv("WORKFLOW_EVALUATOR") != "" {
+		var ctx ir.EvaluationContext
+		json.NewDecoder(os.Stdin).Decode(&ctx)
+		json.NewEncoder(os.Stdout).Encode(decide(&ctx))
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func decide(ctx *ir.EvaluationContext) *ir.EvaluatorDecision {
+	switch {
+	case ctx.Response.Status >= 400:
+		return &ir.EvaluatorDecision{Decision: "fail", Reason: fmt.Sprintf("HTTP %d", ctx.Response.Status)}
+	case strings.HasSuffix(ctx.Request.URL, "/login"):
+		return &ir.EvaluatorDecision{
+			Decision: "pass",
+			Actions: &ir.Actions{Extract: map[string]ir.ExtractRule{
+				"token":   {JSONPath: "$.token"},
+				"user_id": {JSONPath: "$.user.id"},
+			}},
+		}
+	case strings.HasSuffix(ctx.Request.URL, "/status"):
+		if ctx.Response.Body.(map[string]any)["ready"] == true {
+			return &ir.EvaluatorDecision{Decision: "branch", Reason: "ready", Actions: &ir.Actions{Goto: "orders"}}
+		}
+	}
+	return &ir.EvaluatorDecision{Decision: "pass"}
+}
+
+func TestRunner_Run(t *testing.T) {
+	t.Setenv("WORKFLOW_EVALUATOR", "1")
+
+	var mu sync.Mutex
+	var hits []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		hits = append(hits, r.URL.Path+" "+r.Header.Get("Authorization")+" "+string(body))
+		mu.Unlock()
+
+		switch r.URL.Path {
+		case "/login":
+			fmt.Fprint(w, `{"token": "secret", "user": {"id": 7}}`)
+		case "/status":
+			fmt.Fprint(w, `{"ready": true}`)
+		case "/orders":
+			if r.Header.Get("Authorization") != "Bearer secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+			}
+			fmt.Fprint(w, `{}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	doc := `{
+  "name": "checkout",
+  "steps": [
+    {"name": "login", "ir": ` + step(server.URL+"/login", "", "") + `},
+    {"name": "status", "ir": ` + step(server.URL+"/status", "", "") + `},
+    {"name": "skipped", "ir": ` + step(server.URL+"/skipped", "", "") + `},
+    {"name": "orders", "ir": ` + step(server.URL+"/orders", "Bearer ${token}", `{"user": "${user_id}", "note": "for ${user_id}"}`) + `},
+    {"name": "missing", "ir": ` + step(server.URL+"/missing", "", "") + `},
+    {"name": "after", "ir": ` + step(server.URL+"/after", "", "") + `}
+  ]
+}`
+	wf, err := Parse([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The IR schema does not require a transport block
+	wf.Steps[0].IR.Transport = nil
+
+	o := orchestrator.NewOrchestrator(1, 5*time.Second)
+	report, err := NewRunner(o).Run(context.Background(), wf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ran []string
+	for _, s := range report.Steps {
+		ran = append(ran, s.Name)
+	}
+	if got := strings.Join(ran, ","); got != "login,status,orders,missing" {
+		t.Fatalf("ran %s, want login,status,orders,missing", got)
+	}
+	if id := report.Steps[0].Result.IR.Metadata.ID; id != "login" {
+		t.Errorf("login step ran as '%s'", id)
+	}
+
+	// The goto target is a step of its own, with its own response
+	status, orders := report.Steps[1].Result, report.Steps[2].Result
+	if len(status.Branches) != 1 || status.Branches[0] != "orders" {
+		t.Errorf("status branched to %v, want orders", status.Branches)
+	}
+	if !strings.HasSuffix(status.Context.Request.URL, "/status") || status.Decision.Decision != "branch" {
+		t.Errorf("status step holds %s with decision %s", status.Context.Request.URL, status.Decision.Decision)
+	}
+	if !strings.HasSuffix(orders.Context.Request.URL, "/orders") || orders.Context.Response.Status != 200 || len(orders.Branches) != 0 {
+		t.Errorf("orders step holds %s → %d, branches %v", orders.Context.Request.URL, orders.Context.Response.Status, orders.Branches)
+	}
+	if orders.StartTime.Before(status.EndTime) {
+		t.Error("orders step started before status ended")
+	}
+
+	if !report.Failed() || report.Steps[3].Error == nil || report.Passed() != 3 {
+		t.Errorf("want only 'missing' to fail: %+v", report.Steps)
+	}
+	if got := strings.Join(report.Skipped, ","); got != "skipped,after" {
+		t.Errorf("skipped %s, want skipped,after", got)
+	}
+
+	want := []string{
+		"/login  ",
+		"/status  ",
+		`/orders Bearer secret {"note":"for 7","user":7}`,
+		"/missing  ",
+	}
+	if strings.Join(hits, "\n") != strings.Join(want, "\n") {
+		t.Errorf("server saw\n%s\nwant\n%s", strings.Join(hits, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	tests := []struct {
+		doc  string
+		want string
+	}{
+		{`{"steps": []}`, "workflow has no steps"},
+		{`{"steps": [{"ir": {"request": {"url": "http://x"}}}]}`, "step 1 has no name"},
+		{`{"steps": [{"name": "a", "ir": {"request": {"url": "http://x"}}}, {"name": "a", "ir": {"request": {"url": "http://x"}}}]}`, "step name 'a' is used more than once"},
+		{`{"steps": [{"name": "a"}]}`, "step 'a' has no ir"},
+	}
+
+	for _, tt := range tests {
+		if _, err := Parse([]byte(tt.doc)); err == nil || err.Error() != tt.want {
+			t.Errorf("%s: error %v, want %s", tt.doc, err, tt.want)
+		}
+	}
+}
+
+func TestParse_Example(t *testing.T) {
+	wf, err := Load("../../examples/workflow-example.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(wf.Steps) != 5 || wf.Steps[1].Name != "Step 2: Verify Email" {
+		t.Errorf("steps = %+v", wf.Steps)
+	}
+}
+
+// step renders the IR of a workflow step evaluated by the test binary
+func step(url, authorization, body string) string {
+	spec := &ir.IR{
+		Version:   ir.Version,
+		Request:   ir.Request{Method: "GET", URL: url},
+		Transport: ir.DefaultTransport(),
+		Evaluation: &ir.Evaluation{
+			Evaluator:     "go",
+			EvaluatorPath: os.Args[0],
+			TimeoutMs:     5000,
+		},
+	}
+	if authorization != "" {
+		spec.Request.Headers = map[string]string{"Authorization": authorization}
+	}
+	if body != "" {
+		var content any
+		json.Unmarshal([]byte(body), &content)
+		spec.Request.Method = "POST"
+		spec.Request.Body = &ir.Body{Type: "json", Content: content}
+	}
+	data, _ := json.Marshal(spec)
+	return string(data)
+}
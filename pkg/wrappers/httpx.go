@@ -0,0 +1,176 @@
+package wrappers
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/vikasavnish/httptool/pkg/ir"
+	"github.com/vikasavnish/httptool/pkg/parser"
+)
+
+// HTTPXFile is a .httpx document built from an imported collection or spec
+type HTTPXFile struct {
+	Comment  []string // Header comment lines
+	Vars     []HTTPXVar
+	Requests []*HTTPXRequest
+	Scenario *HTTPXScenario
+}
+
+// HTTPXVar is a var declaration
+type HTTPXVar struct {
+	Name  string
+	Value string
+}
+
+// HTTPXRequest is a request block: a curl command with its extract rules and
+// assertions
+type HTTPXRequest struct {
+	Name    string
+	Comment string // Written above the block, e.g. the original name
+	IR      *ir.IR
+	Extract []HTTPXExtract
+	Assert  []string // Assertions as written after assert, e.g. status == 200
+}
+
+// HTTPXExtract is an extract rule, e.g. token = $.access_token
+type HTTPXExtract struct {
+	Name string
+	Rule string
+}
+
+// HTTPXScenario runs the requests once per iteration
+type HTTPXScenario struct {
+	Name  string
+	Load  string // Written after load, e.g. 1 iterations with 1 vus
+	Flows []HTTPXFlow
+}
+
+// HTTPXFlow is a chain of requests run one after the other
+type HTTPXFlow struct {
+	Comment string
+	Runs    []string
+}
+
+// Write renders the document
+func (f *HTTPXFile) Write(w io.Writer) error {
+	var b strings.Builder
+
+	for _, line := range f.Comment {
+		writeComment(&b, "", line)
+	}
+	if len(f.Comment) > 0 {
+		b.WriteString("\n")
+	}
+
+	for _, v := range f.Vars {
+		fmt.Fprintf(&b, "var %s = %s\n", v.Name, quoteString(v.Value))
+	}
+	if len(f.Vars) > 0 {
+		b.WriteString("\n")
+	}
+
+	for _, req := range f.Requests {
+		if req.Comment != "" {
+			writeComment(&b, "", req.Comment)
+		}
+		curl := strings.ReplaceAll(parser.FormatCurl(req.IR), "\\\n", "\\\n  ")
+		fmt.Fprintf(&b, "request %s {\n  %s\n", req.Name, curl)
+		if len(req.Extract) > 0 {
+			b.WriteString("\n  extract {\n")
+			for _, e := range req.Extract {
+				fmt.Fprintf(&b, "    %s = %s\n", e.Name, e.Rule)
+			}
+			b.WriteString("  }\n")
+		}
+		if len(req.Assert) > 0 {
+			b.WriteString("\n  assert {\n")
+			for _, a := range req.Assert {
+				fmt.Fprintf(&b, "    %s\n", a)
+			}
+			b.WriteString("  }\n")
+		}
+		b.WriteString("}\n\n")
+	}
+
+	if s := f.Scenario; s != nil {
+		load := s.Load
+		if load == "" {
+			load = "1 iterations with 1 vus"
+		}
+		fmt.Fprintf(&b, "scenario %s {\n  load %s\n", s.Name, load)
+		for _, flow := range s.Flows {
+			if len(flow.Runs) == 0 {
+				continue
+			}
+			b.WriteString("\n")
+			if flow.Comment != "" {
+				writeComment(&b, "  ", flow.Comment)
+			}
+			fmt.Fprintf(&b, "  run %s\n", strings.Join(flow.Runs, " -> "))
+		}
+		b.WriteString("}\n")
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeComment writes text as # comment lines
+func writeComment(b *strings.Builder, indent, text string) {
+	for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		fmt.Fprintf(b, "%s# %s\n", indent, strings.TrimRight(line, " \t\r"))
+	}
+}
+
+// quoteString writes a double-quoted .httpx string
+func quoteString(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+	return `"` + r.Replace(s) + `"`
+}
+
+var nonIdentChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// identifier turns a display name into a .httpx identifier, e.g.
+// "Get User (by id)" becomes get_user_by_id. Names that are empty, start
+// with a digit or are keywords get fallback added.
+func identifier(name, fallback string) string {
+	id := strings.Trim(nonIdentChars.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	switch {
+	case id == "":
+		id = fallback
+	case id[0] >= '0' && id[0] <= '9':
+		id = fallback + "_" + id
+	case parser.LookupIdent(id) != parser.IDENT:
+		id += "_" + fallback
+	}
+	return id
+}
+
+var nonVarChars = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+// variableName turns a parameter or variable name into a .httpx variable
+// name, keeping its case, e.g. base-url becomes base_url
+func variableName(key string) string {
+	name := strings.Trim(nonVarChars.ReplaceAllString(key, "_"), "_")
+	switch {
+	case name == "":
+		name = "var"
+	case name[0] >= '0' && name[0] <= '9':
+		name = "var_" + name
+	case parser.LookupIdent(name) != parser.IDENT:
+		name += "_var"
+	}
+	return name
+}
+
+// uniqueIdentifier numbers repeated identifiers: login, login_2, ...
+func uniqueIdentifier(id string, seen map[string]bool) string {
+	unique := id
+	for n := 2; seen[unique]; n++ {
+		unique = fmt.Sprintf("%s_%d", id, n)
+	}
+	seen[unique] = true
+	return unique
+}
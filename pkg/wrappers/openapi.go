@@ -0,0 +1,410 @@
+package wrappers
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vikasavnish/httptool/pkg/ir"
+	"github.com/vikasavnish/httptool/pkg/openapi"
+)
+
+// OpenAPIWrapper converts the operations of an OpenAPI 3 spec to .httpx
+// request templates and a smoke test scenario
+type OpenAPIWrapper struct {
+	spec     *openapi.Spec
+	vars     []HTTPXVar
+	declared map[string]bool
+	names    map[string]bool
+	warnings []string
+}
+
+// ignoredHeaderParams are header parameters OpenAPI says to ignore, as they
+// are described elsewhere in the spec
+var ignoredHeaderParams = map[string]bool{"accept": true, "content-type": true, "authorization": true}
+
+// NewOpenAPIWrapper creates a new OpenAPI wrapper
+func NewOpenAPIWrapper() *OpenAPIWrapper {
+	return &OpenAPIWrapper{}
+}
+
+// ConvertSpec converts every operation to a request block. baseURL replaces
+// the spec's first server when set. Parameters and credentials become vars
+// holding their examples; each request's IR also lists the vars it uses in
+// Evaluation.Vars, so it can run on its own as a template.
+func (w *OpenAPIWrapper) ConvertSpec(spec *openapi.Spec, baseURL string) (*HTTPXFile, []string, error) {
+	w.spec = spec
+	w.vars = nil
+	w.declared = make(map[string]bool)
+	w.names = make(map[string]bool)
+	w.warnings = nil
+
+	ops, err := spec.Operations()
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(ops) == 0 {
+		return nil, nil, fmt.Errorf("the spec has no operations")
+	}
+
+	if baseURL == "" {
+		baseURL = spec.BaseURL()
+	}
+	if u, err := url.Parse(baseURL); baseURL == "" || err != nil || u.Host == "" {
+		w.warn("no absolute server URL in the spec; set base_url")
+		baseURL = "http://localhost:8080" + baseURL
+	}
+	w.declare("base_url", baseURL)
+
+	title := spec.Info.Title
+	file := &HTTPXFile{
+		Comment:  []string{fmt.Sprintf("Generated from the OpenAPI spec '%s' %s by httptool import openapi", title, spec.Info.Version)},
+		Scenario: &HTTPXScenario{Name: identifier(title, "api") + "_smoke"},
+	}
+
+	flows := make(map[string]int)
+	for _, op := range ops {
+		req, err := w.convertOperation(op)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s %s: %w", op.Method, op.Path, err)
+		}
+		file.Requests = append(file.Requests, req)
+
+		tag := ""
+		if len(op.Tags) > 0 {
+			tag = op.Tags[0]
+		}
+		i, ok := flows[tag]
+		if !ok {
+			i = len(file.Scenario.Flows)
+			flows[tag] = i
+			file.Scenario.Flows = append(file.Scenario.Flows, HTTPXFlow{Comment: tag})
+		}
+		file.Scenario.Flows[i].Runs = append(file.Scenario.Flows[i].Runs, req.Name)
+	}
+
+	file.Vars = w.vars
+	for _, req := range file.Requests {
+		req.IR.Evaluation.Vars = w.usedVars(req.IR)
+	}
+
+	return file, w.warnings, nil
+}
+
+// convertOperation builds the request block of an operation
+func (w *OpenAPIWrapper) convertOperation(op *openapi.Operation) (*HTTPXRequest, error) {
+	name := op.Method + " " + op.Path
+	if op.OperationID != "" {
+		name = snakeCase(op.OperationID)
+	}
+	name = uniqueIdentifier(identifier(name, "request"), w.names)
+
+	result := &ir.IR{
+		Version: ir.Version,
+		Metadata: &ir.Metadata{
+			ID:        uuid.New().String(),
+			Source:    "openapi",
+			CreatedAt: timePtr(time.Now()),
+			Tags:      map[string]string{"operation": op.Method + " " + op.Path},
+		},
+		Request: ir.Request{
+			Method:  op.Method,
+			Headers: make(map[string]string),
+		},
+		Transport:  ir.DefaultTransport(),
+		Evaluation: ir.DefaultEvaluation(),
+	}
+	if op.OperationID != "" {
+		result.Metadata.Tags["operation_id"] = op.OperationID
+	}
+
+	path := op.Path
+	documented := make(map[string]bool)
+	var query []string
+	for _, p := range op.Parameters {
+		switch p.In {
+		case "path":
+			documented[p.Name] = true
+			path = strings.ReplaceAll(path, "{"+p.Name+"}", "${"+w.paramVar(p)+"}")
+		case "query":
+			if p.Required || p.Example != nil || len(p.Examples) > 0 || p.Schema["default"] != nil {
+				query = append(query, url.QueryEscape(p.Name)+"=${"+w.paramVar(p)+"}")
+			}
+		case "header":
+			if p.Required && !ignoredHeaderParams[strings.ToLower(p.Name)] {
+				result.Request.Headers[p.Name] = "${" + w.paramVar(p) + "}"
+			}
+		case "cookie":
+			if p.Required {
+				if result.Request.Cookies == nil {
+					result.Request.Cookies = make(map[string]string)
+				}
+				result.Request.Cookies[p.Name] = "${" + w.paramVar(p) + "}"
+			}
+		}
+	}
+	for _, m := range pathParam.FindAllStringSubmatch(op.Path, -1) {
+		if documented[m[1]] {
+			continue
+		}
+		w.warn("%s %s: path parameter {%s} is not documented", op.Method, op.Path, m[1])
+		v := variableName(m[1])
+		w.declare(v, "")
+		path = strings.ReplaceAll(path, m[0], "${"+v+"}")
+	}
+	result.Request.URL = "${base_url}" + path
+	if len(query) > 0 {
+		result.Request.URL += "?" + strings.Join(query, "&")
+	}
+
+	if err := w.convertSecurity(op, result); err != nil {
+		return nil, err
+	}
+	if op.RequestBody != nil {
+		w.convertBody(op, result)
+	}
+
+	req := &HTTPXRequest{Name: name, IR: result, Comment: op.Method + " " + op.Path}
+	if op.Summary != "" {
+		req.Comment += ": " + op.Summary
+	}
+	if op.Deprecated {
+		req.Comment += " (deprecated)"
+	}
+	if a := statusAssertion(op.Responses); a != "" {
+		req.Assert = []string{a}
+	}
+
+	return req, nil
+}
+
+// pathParam matches a {name} left in a path
+var pathParam = regexp.MustCompile(`\{([^{}/]+)\}`)
+
+// paramVar declares the var of a parameter, holding its example
+func (w *OpenAPIWrapper) paramVar(p *openapi.Parameter) string {
+	name := variableName(p.Name)
+	w.declare(name, exampleString(w.spec.ParameterExample(p)))
+	return name
+}
+
+// convertSecurity applies the first security requirement of an operation.
+// Credentials become vars to fill in.
+func (w *OpenAPIWrapper) convertSecurity(op *openapi.Operation, result *ir.IR) error {
+	reqs := w.spec.SecurityFor(op)
+	if len(reqs) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(reqs[0]))
+	for name := range reqs[0] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	req := &result.Request
+	for _, name := range names {
+		scheme, err := w.spec.Scheme(name)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case scheme.Type == "http" && strings.EqualFold(scheme.Scheme, "basic"):
+			w.declare("username", "")
+			w.declare("password", "")
+			req.Auth = &ir.Auth{Type: "basic", Username: "${username}", Password: "${password}"}
+		case scheme.Type == "http" && strings.EqualFold(scheme.Scheme, "bearer"),
+			scheme.Type == "oauth2", scheme.Type == "openIdConnect":
+			w.declare("token", "")
+			req.Auth = &ir.Auth{Type: "bearer", Token: "${token}"}
+		case scheme.Type == "apiKey":
+			v := variableName(name)
+			w.declare(v, "")
+			switch scheme.In {
+			case "query":
+				sep := "?"
+				if strings.Contains(req.URL, "?") {
+					sep = "&"
+				}
+				req.URL += sep + url.QueryEscape(scheme.Name) + "=${" + v + "}"
+			case "cookie":
+				if req.Cookies == nil {
+					req.Cookies = make(map[string]string)
+				}
+				req.Cookies[scheme.Name] = "${" + v + "}"
+			default:
+				req.Headers[scheme.Name] = "${" + v + "}"
+			}
+		default:
+			w.warn("%s %s: %s security scheme '%s' not translated", op.Method, op.Path, scheme.Type, name)
+		}
+	}
+
+	return nil
+}
+
+// convertBody sets an example body of the first media type that can be sent:
+// JSON, a URL-encoded form or plain text
+func (w *OpenAPIWrapper) convertBody(op *openapi.Operation, result *ir.IR) {
+	types := make([]string, 0, len(op.RequestBody.Content))
+	for t := range op.RequestBody.Content {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool { return bodyRank(types[i]) < bodyRank(types[j]) })
+
+	if len(types) == 0 || bodyRank(types[0]) == len(bodyTypes) {
+		w.warn("%s %s: %s request body not translated", op.Method, op.Path, strings.Join(types, ", "))
+		return
+	}
+
+	contentType := types[0]
+	example := w.spec.MediaExample(op.RequestBody.Content[contentType], true)
+	req := &result.Request
+	req.Headers["Content-Type"] = contentType
+
+	switch bodyTypes[bodyRank(contentType)] {
+	case "json":
+		req.Body = &ir.Body{Type: "json", Content: example}
+	case "form":
+		form := make(map[string]any)
+		if obj, ok := example.(map[string]any); ok {
+			for k, v := range obj {
+				form[k] = exampleString(v)
+			}
+		}
+		req.Body = &ir.Body{Type: "form", Content: form}
+	case "text":
+		req.Body = &ir.Body{Type: "text", Content: exampleString(example)}
+	}
+}
+
+// bodyTypes are the media types a request body is generated for, by
+// preference
+var bodyTypes = []string{"json", "form", "text"}
+
+// bodyRank orders media types by bodyTypes, unsupported ones last
+func bodyRank(contentType string) int {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		return 0
+	case mediaType == "application/x-www-form-urlencoded":
+		return 1
+	case mediaType == "text/plain":
+		return 2
+	}
+	return len(bodyTypes)
+}
+
+// statusAssertion asserts the documented success codes: 2xx and 3xx, or any
+// code below 400 when only a default response is documented
+func statusAssertion(responses map[string]*openapi.Response) string {
+	var codes []int
+	var ranges []string
+	for code := range responses {
+		switch {
+		case code == "2XX" || code == "2xx":
+			ranges = append(ranges, "status between 200 and 299")
+		case code == "3XX" || code == "3xx":
+			ranges = append(ranges, "status between 300 and 399")
+		default:
+			if n, err := strconv.Atoi(code); err == nil && n >= 200 && n < 400 {
+				codes = append(codes, n)
+			}
+		}
+	}
+	sort.Ints(codes)
+
+	switch {
+	case len(codes) == 1:
+		return fmt.Sprintf("status == %d", codes[0])
+	case len(codes) > 1:
+		list := make([]string, len(codes))
+		for i, c := range codes {
+			list[i] = strconv.Itoa(c)
+		}
+		return "status in [" + strings.Join(list, ", ") + "]"
+	case len(ranges) > 0:
+		sort.Strings(ranges)
+		return ranges[0]
+	case responses["default"] != nil:
+		return "status < 400"
+	}
+	return ""
+}
+
+// declare adds a var unless one of that name exists
+func (w *OpenAPIWrapper) declare(name, value string) {
+	if w.declared[name] {
+		return
+	}
+	w.declared[name] = true
+	w.vars = append(w.vars, HTTPXVar{Name: name, Value: value})
+}
+
+// varRef matches a ${name} reference
+var varRef = regexp.MustCompile(`\$\{(\w+)\}`)
+
+// usedVars returns the vars a request refers to, with their values
+func (w *OpenAPIWrapper) usedVars(irSpec *ir.IR) map[string]any {
+	data, _ := json.Marshal(irSpec.Request)
+	used := make(map[string]bool)
+	for _, m := range varRef.FindAllStringSubmatch(string(data), -1) {
+		used[m[1]] = true
+	}
+
+	vars := make(map[string]any)
+	for _, v := range w.vars {
+		if used[v.Name] {
+			vars[v.Name] = v.Value
+		}
+	}
+	return vars
+}
+
+// warn records a warning once
+func (w *OpenAPIWrapper) warn(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	for _, existing := range w.warnings {
+		if existing == msg {
+			return
+		}
+	}
+	w.warnings = append(w.warnings, msg)
+}
+
+// exampleString renders an example as a parameter or form value: strings as
+// they are, lists comma-separated, anything else as JSON
+func exampleString(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []any:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			parts[i] = exampleString(item)
+		}
+		return strings.Join(parts, ",")
+	default:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+}
+
+var camelBoundary = regexp.MustCompile(`([a-z0-9])([A-Z])`)
+
+// snakeCase splits camelCase words, e.g. getUserById becomes get_User_By_Id
+// (identifier lowercases it)
+func snakeCase(s string) string {
+	return camelBoundary.ReplaceAllString(s, "${1}_${2}")
+}
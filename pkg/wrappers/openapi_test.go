@@ -0,0 +1,185 @@
+package wrappers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vikasavnish/httptool/pkg/openapi"
+	"github.com/vikasavnish/httptool/pkg/scenario"
+)
+
+func convertSpec(t *testing.T, baseURL string) (*HTTPXFile, []string, string) {
+	t.Helper()
+
+	spec, err := openapi.Load("../../examples/openapi-example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, warnings, err := NewOpenAPIWrapper().ConvertSpec(spec, baseURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := file.Write(&out); err != nil {
+		t.Fatal(err)
+	}
+	return file, warnings, out.String()
+}
+
+func TestOpenAPIWrapper_ConvertSpec(t *testing.T) {
+	file, warnings, text := convertSpec(t, "")
+
+	var vars []string
+	for _, v := range file.Vars {
+		vars = append(vars, v.Name+"="+v.Value)
+	}
+	want := []string{"base_url=https://api.example.com/v1", "limit=20", "token=", "userId=42", "apiKey="}
+	if !reflect.DeepEqual(vars, want) {
+		t.Errorf("vars = %v, want %v", vars, want)
+	}
+
+	requests := make(map[string]*HTTPXRequest)
+	var names []string
+	for _, req := range file.Requests {
+		requests[req.Name] = req
+		names = append(names, req.Name)
+	}
+	wantNames := []string{"health", "login", "list_users", "create_user", "get_user_by_id", "update_user", "delete_user", "upload_avatar"}
+	if !reflect.DeepEqual(names, wantNames) {
+		t.Errorf("requests = %v, want %v", names, wantNames)
+	}
+
+	tests := []struct {
+		name   string
+		url    string
+		assert string
+	}{
+		{"health", "${base_url}/health", "status < 400"},
+		{"list_users", "${base_url}/users?limit=${limit}", "status == 200"},
+		{"create_user", "${base_url}/users", "status == 201"},
+		{"get_user_by_id", "${base_url}/users/${userId}", "status == 200"},
+		{"upload_avatar", "${base_url}/users/${userId}/avatar", "status between 200 and 299"},
+	}
+	for _, tt := range tests {
+		req := requests[tt.name]
+		if req.IR.Request.URL != tt.url || !reflect.DeepEqual(req.Assert, []string{tt.assert}) {
+			t.Errorf("%s: url %s, assert %v; want %s, %s", tt.name, req.IR.Request.URL, req.Assert, tt.url, tt.assert)
+		}
+	}
+
+	if auth := requests["health"].IR.Request.Auth; auth != nil {
+		t.Errorf("health auth = %+v, want none", auth)
+	}
+	if auth := requests["get_user_by_id"].IR.Request.Auth; auth == nil || auth.Type != "bearer" || auth.Token != "${token}" {
+		t.Errorf("get user auth = %+v", auth)
+	}
+	if key := requests["upload_avatar"].IR.Request.Headers["X-Api-Key"]; key != "${apiKey}" {
+		t.Errorf("X-Api-Key = %q", key)
+	}
+
+	// Request examples leave out readOnly properties
+	body := requests["create_user"].IR.Request.Body
+	wantBody := map[string]any{"name": "Ada", "email": "user@example.com", "role": "member", "password": "correct-horse"}
+	if body == nil || body.Type != "json" || !reflect.DeepEqual(body.Content, wantBody) {
+		t.Errorf("create user body = %+v", body)
+	}
+	if body := requests["login"].IR.Request.Body; body == nil || body.Type != "form" ||
+		!reflect.DeepEqual(body.Content, map[string]any{"username": "ada", "password": "password"}) {
+		t.Errorf("login body = %+v", body)
+	}
+
+	if got := requests["get_user_by_id"].IR.Evaluation.Vars; !reflect.DeepEqual(got, map[string]any{"base_url": "https://api.example.com/v1", "token": "", "userId": "42"}) {
+		t.Errorf("get user vars = %v", got)
+	}
+
+	wantWarnings := []string{"PUT /users/{userId}/avatar: image/png request body not translated"}
+	if !reflect.DeepEqual(warnings, wantWarnings) {
+		t.Errorf("warnings = %q, want %q", warnings, wantWarnings)
+	}
+
+	for _, s := range []string{
+		"scenario users_api_smoke {",
+		"  # users\n  run list_users -> create_user -> get_user_by_id",
+		"# GET /users: List users\nrequest list_users {",
+	} {
+		if !strings.Contains(text, s) {
+			t.Errorf("missing %q in:\n%s", s, text)
+		}
+	}
+}
+
+func TestOpenAPIWrapper_RunScenario(t *testing.T) {
+	var mu = make(chan struct{}, 1)
+	seen := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu <- struct{}{}
+		seen[r.Method+" "+r.URL.Path] = r.URL.RawQuery + "|" + r.Header.Get("Authorization") + "|" + string(body)
+		<-mu
+
+		switch r.Method {
+		case "POST":
+			if r.URL.Path == "/v1/users" {
+				w.WriteHeader(http.StatusCreated)
+			}
+		case "DELETE":
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		fmt.Fprint(w, `{}`)
+	}))
+	defer server.Close()
+
+	file, _, _ := convertSpec(t, server.URL+"/v1")
+
+	// Credentials are left for the user to fill in
+	for i := range file.Vars {
+		if file.Vars[i].Name == "token" {
+			file.Vars[i].Value = "t-1"
+		}
+	}
+	var out bytes.Buffer
+	if err := file.Write(&out); err != nil {
+		t.Fatal(err)
+	}
+	text := out.String()
+
+	s, err := scenario.NewParser(text).Parse()
+	if err != nil {
+		t.Fatalf("%v\n%s", err, text)
+	}
+	compiled, err := scenario.NewCompiler().Compile(s, file.Scenario.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := scenario.NewExecutor().Execute(context.Background(), compiled)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, req := range file.Requests {
+		stats := result.Stats.Requests[req.Name]
+		if stats == nil || stats.Failed != 0 {
+			t.Errorf("%s: %+v", req.Name, stats)
+		}
+	}
+
+	want := map[string]string{
+		"GET /v1/users":       "limit=20|Bearer t-1|",
+		"POST /v1/login":      "||password=password&username=ada",
+		"DELETE /v1/users/42": "|Bearer t-1|",
+	}
+	for key, value := range want {
+		if got := seen[key]; got != value {
+			t.Errorf("%s got %q, want %q (seen %q)", key, got, value, seen)
+		}
+	}
+}
@@ -0,0 +1,556 @@
+package wrappers
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vikasavnish/httptool/pkg/ir"
+)
+
+// PostmanWrapper converts Postman v2.1 collections to .httpx scenarios
+type PostmanWrapper struct {
+	vars     map[string]string // Postman variable names to .httpx names
+	names    map[string]bool   // Request names in use
+	warnings []string
+}
+
+// PostmanCollection is a Postman collection in the v2.1 format
+type PostmanCollection struct {
+	Info     PostmanInfo       `json:"info"`
+	Item     []PostmanItem     `json:"item"`
+	Auth     *PostmanAuth      `json:"auth,omitempty"`
+	Event    []PostmanEvent    `json:"event,omitempty"`
+	Variable []PostmanVariable `json:"variable,omitempty"`
+}
+
+// PostmanInfo describes a collection
+type PostmanInfo struct {
+	Name   string `json:"name"`
+	Schema string `json:"schema"`
+}
+
+// PostmanItem is a request, or a folder when it has items of its own
+type PostmanItem struct {
+	Name    string          `json:"name"`
+	Item    []PostmanItem   `json:"item,omitempty"`
+	Request *PostmanRequest `json:"request,omitempty"`
+	Auth    *PostmanAuth    `json:"auth,omitempty"` // Folder auth
+	Event   []PostmanEvent  `json:"event,omitempty"`
+}
+
+// PostmanRequest is the request of an item
+type PostmanRequest struct {
+	Method string       `json:"method"`
+	Header []PostmanKV  `json:"header,omitempty"`
+	URL    PostmanURL   `json:"url"`
+	Body   *PostmanBody `json:"body,omitempty"`
+	Auth   *PostmanAuth `json:"auth,omitempty"`
+}
+
+// PostmanKV is a header, query parameter, form field or auth setting
+type PostmanKV struct {
+	Key      string `json:"key"`
+	Value    any    `json:"value"`
+	Type     string `json:"type,omitempty"`
+	Disabled bool   `json:"disabled,omitempty"`
+}
+
+// PostmanURL is a request URL, written either as a string or as its parts
+type PostmanURL struct {
+	Raw      string      `json:"raw"`
+	Query    []PostmanKV `json:"query,omitempty"`
+	Variable []PostmanKV `json:"variable,omitempty"` // Path variables such as :id
+}
+
+// UnmarshalJSON accepts both URL forms
+func (u *PostmanURL) UnmarshalJSON(data []byte) error {
+	var raw string
+	if err := json.Unmarshal(data, &raw); err == nil {
+		*u = PostmanURL{Raw: raw}
+		return nil
+	}
+
+	type plain PostmanURL
+	var parts plain
+	if err := json.Unmarshal(data, &parts); err != nil {
+		return err
+	}
+	*u = PostmanURL(parts)
+	return nil
+}
+
+// PostmanBody is a request body in one of the modes raw, urlencoded,
+// formdata, graphql or file
+type PostmanBody struct {
+	Mode       string          `json:"mode"`
+	Raw        string          `json:"raw,omitempty"`
+	URLEncoded []PostmanKV     `json:"urlencoded,omitempty"`
+	FormData   []PostmanKV     `json:"formdata,omitempty"`
+	GraphQL    *PostmanGraphQL `json:"graphql,omitempty"`
+	Options    struct {
+		Raw struct {
+			Language string `json:"language"`
+		} `json:"raw"`
+	} `json:"options"`
+}
+
+// PostmanGraphQL is a graphql body. Variables is JSON text.
+type PostmanGraphQL struct {
+	Query     string `json:"query"`
+	Variables string `json:"variables,omitempty"`
+}
+
+// PostmanAuth is an auth setting of a collection, folder or request. The
+// settings for Type are in the field of the same name.
+type PostmanAuth struct {
+	Type   string      `json:"type"`
+	Basic  []PostmanKV `json:"basic,omitempty"`
+	Bearer []PostmanKV `json:"bearer,omitempty"`
+	APIKey []PostmanKV `json:"apikey,omitempty"`
+}
+
+// PostmanEvent is a script run before a request (prerequest) or after it
+// (test)
+type PostmanEvent struct {
+	Listen string `json:"listen"`
+	Script struct {
+		Exec PostmanLines `json:"exec"`
+	} `json:"script"`
+}
+
+// PostmanLines is script source, written either as a string or as lines
+type PostmanLines []string
+
+// UnmarshalJSON accepts both script forms
+func (l *PostmanLines) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*l = strings.Split(text, "\n")
+		return nil
+	}
+	var lines []string
+	if err := json.Unmarshal(data, &lines); err != nil {
+		return err
+	}
+	*l = lines
+	return nil
+}
+
+// PostmanVariable is a collection variable
+type PostmanVariable struct {
+	Key      string `json:"key"`
+	Value    any    `json:"value"`
+	Disabled bool   `json:"disabled,omitempty"`
+}
+
+// PostmanEnvironment is an exported Postman environment
+type PostmanEnvironment struct {
+	Name   string `json:"name"`
+	Values []struct {
+		Key     string `json:"key"`
+		Value   any    `json:"value"`
+		Enabled *bool  `json:"enabled,omitempty"`
+	} `json:"values"`
+}
+
+// NewPostmanWrapper creates a new Postman wrapper
+func NewPostmanWrapper() *PostmanWrapper {
+	return &PostmanWrapper{}
+}
+
+// LoadPostmanCollection reads a collection file
+func LoadPostmanCollection(path string) (*PostmanCollection, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var c PostmanCollection
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse Postman collection: %w", err)
+	}
+	if c.Info.Schema != "" && !strings.Contains(c.Info.Schema, "v2.1") && !strings.Contains(c.Info.Schema, "v2.0") {
+		return nil, fmt.Errorf("unsupported Postman collection schema %s (export as v2.1)", c.Info.Schema)
+	}
+	return &c, nil
+}
+
+// LoadPostmanEnvironment reads an environment file
+func LoadPostmanEnvironment(path string) (*PostmanEnvironment, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var env PostmanEnvironment
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("failed to parse Postman environment: %w", err)
+	}
+	return &env, nil
+}
+
+// ConvertCollection converts a collection and an optional environment to a
+// .httpx file. Collection and environment variables become vars, the
+// environment winning, and each folder becomes a flow of the scenario.
+// Anything that has no .httpx equivalent is left out and reported in the
+// warnings.
+func (w *PostmanWrapper) ConvertCollection(c *PostmanCollection, env *PostmanEnvironment) (*HTTPXFile, []string, error) {
+	w.vars = make(map[string]string)
+	w.names = make(map[string]bool)
+	w.warnings = nil
+
+	values := make(map[string]string)
+	var order []string
+	set := func(key string, value any) {
+		if _, ok := values[key]; !ok {
+			order = append(order, key)
+		}
+		values[key] = postmanString(value)
+		w.varName(key)
+	}
+	for _, v := range c.Variable {
+		if !v.Disabled {
+			set(v.Key, v.Value)
+		}
+	}
+	if env != nil {
+		for _, v := range env.Values {
+			if v.Enabled == nil || *v.Enabled {
+				set(v.Key, v.Value)
+			}
+		}
+	}
+
+	name := c.Info.Name
+	file := &HTTPXFile{
+		Comment:  []string{fmt.Sprintf("Imported from the Postman collection '%s' by httptool import postman", name)},
+		Scenario: &HTTPXScenario{Name: identifier(name, "collection")},
+	}
+	if env != nil {
+		file.Comment[0] += fmt.Sprintf(" with the environment '%s'", env.Name)
+	}
+
+	root := postmanScope{auth: c.Auth, events: c.Event}
+	if err := w.convertItems(file, c.Item, nil, root); err != nil {
+		return nil, nil, err
+	}
+
+	// Variables set by test scripts are extracted at run time, so declaring
+	// them would replace the references with their initial values
+	extracted := make(map[string]bool)
+	for _, req := range file.Requests {
+		for _, e := range req.Extract {
+			extracted[e.Name] = true
+		}
+	}
+	for _, key := range order {
+		if name := w.vars[key]; !extracted[name] {
+			file.Vars = append(file.Vars, HTTPXVar{Name: name, Value: w.replaceVars(values[key])})
+		}
+	}
+
+	return file, w.warnings, nil
+}
+
+// postmanScope is what a folder passes down to its items
+type postmanScope struct {
+	auth   *PostmanAuth
+	events []PostmanEvent
+}
+
+// convertItems converts the items of a folder in order. Requests directly in
+// the folder form one flow; a subfolder ends it, runs as flows of its own,
+// and the folder's later requests start a new one.
+func (w *PostmanWrapper) convertItems(file *HTTPXFile, items []PostmanItem, path []string, scope postmanScope) error {
+	flow := HTTPXFlow{Comment: strings.Join(path, " / ")}
+	flush := func() {
+		if len(flow.Runs) > 0 {
+			file.Scenario.Flows = append(file.Scenario.Flows, flow)
+			flow = HTTPXFlow{Comment: flow.Comment}
+		}
+	}
+
+	for _, item := range items {
+		inner := postmanScope{auth: scope.auth, events: append(append([]PostmanEvent{}, scope.events...), item.Event...)}
+		if item.Auth != nil {
+			inner.auth = item.Auth
+		}
+
+		if item.Request == nil {
+			flush()
+			if err := w.convertItems(file, item.Item, append(path, item.Name), inner); err != nil {
+				return err
+			}
+			continue
+		}
+
+		req, err := w.convertRequest(item, inner)
+		if err != nil {
+			return fmt.Errorf("request '%s': %w", strings.Join(append(path, item.Name), " / "), err)
+		}
+		file.Requests = append(file.Requests, req)
+		flow.Runs = append(flow.Runs, req.Name)
+	}
+	flush()
+
+	return nil
+}
+
+// convertRequest converts a request item with the auth and scripts it
+// inherits
+func (w *PostmanWrapper) convertRequest(item PostmanItem, scope postmanScope) (*HTTPXRequest, error) {
+	name := uniqueIdentifier(identifier(item.Name, "request"), w.names)
+	src := item.Request
+
+	method := strings.ToUpper(src.Method)
+	if method == "" {
+		method = "GET"
+	}
+	rawURL := w.requestURL(&src.URL)
+	if rawURL == "" {
+		return nil, fmt.Errorf("no URL")
+	}
+
+	result := &ir.IR{
+		Version: ir.Version,
+		Metadata: &ir.Metadata{
+			ID:        uuid.New().String(),
+			Source:    "postman",
+			CreatedAt: timePtr(time.Now()),
+		},
+		Request: ir.Request{
+			Method:  method,
+			URL:     rawURL,
+			Headers: make(map[string]string),
+		},
+		Transport:  ir.DefaultTransport(),
+		Evaluation: ir.DefaultEvaluation(),
+	}
+
+	for _, h := range src.Header {
+		if !h.Disabled {
+			result.Request.Headers[w.replaceVars(h.Key)] = w.replaceVars(postmanString(h.Value))
+		}
+	}
+
+	auth := scope.auth
+	if src.Auth != nil {
+		auth = src.Auth
+	}
+	w.convertAuth(name, auth, result)
+
+	if src.Body != nil {
+		w.convertBody(name, src.Body, result)
+	}
+
+	req := &HTTPXRequest{Name: name, IR: result}
+	if !strings.EqualFold(item.Name, name) {
+		req.Comment = item.Name
+	}
+
+	for _, event := range scope.events {
+		switch event.Listen {
+		case "test":
+			w.convertTests(req, event.Script.Exec)
+		case "prerequest":
+			if strings.TrimSpace(strings.Join(event.Script.Exec, "")) != "" {
+				w.warn("request '%s': pre-request script not translated", name)
+			}
+		}
+	}
+
+	return req, nil
+}
+
+// requestURL builds the URL with path variables filled in. Raw already
+// holds the enabled query parameters.
+func (w *PostmanWrapper) requestURL(u *PostmanURL) string {
+	raw := u.Raw
+	for _, v := range u.Variable {
+		value := postmanString(v.Value)
+		if value == "" {
+			value = "${" + w.varName(v.Key) + "}"
+		}
+		raw = regexp.MustCompile(`:`+regexp.QuoteMeta(v.Key)+`\b`).ReplaceAllLiteralString(raw, value)
+	}
+	return w.replaceVars(raw)
+}
+
+// convertAuth maps basic and bearer auth to IR auth, and API keys to a header
+// or query parameter
+func (w *PostmanWrapper) convertAuth(name string, auth *PostmanAuth, result *ir.IR) {
+	if auth == nil {
+		return
+	}
+
+	setting := func(settings []PostmanKV, key string) string {
+		for _, kv := range settings {
+			if kv.Key == key {
+				return w.replaceVars(postmanString(kv.Value))
+			}
+		}
+		return ""
+	}
+
+	switch auth.Type {
+	case "", "noauth", "inherit":
+	case "basic":
+		result.Request.Auth = &ir.Auth{
+			Type:     "basic",
+			Username: setting(auth.Basic, "username"),
+			Password: setting(auth.Basic, "password"),
+		}
+	case "bearer":
+		result.Request.Auth = &ir.Auth{Type: "bearer", Token: setting(auth.Bearer, "token")}
+	case "apikey":
+		key, value := setting(auth.APIKey, "key"), setting(auth.APIKey, "value")
+		if setting(auth.APIKey, "in") == "query" {
+			sep := "?"
+			if strings.Contains(result.Request.URL, "?") {
+				sep = "&"
+			}
+			result.Request.URL += sep + key + "=" + value
+		} else {
+			result.Request.Headers[key] = value
+		}
+	default:
+		w.warn("request '%s': %s auth not translated", name, auth.Type)
+	}
+}
+
+// rawContentTypes are the Content-Type headers Postman adds for raw bodies
+var rawContentTypes = map[string]string{
+	"json":       "application/json",
+	"xml":        "application/xml",
+	"html":       "text/html",
+	"javascript": "application/javascript",
+	"text":       "text/plain",
+}
+
+// convertBody maps raw, urlencoded and graphql bodies
+func (w *PostmanWrapper) convertBody(name string, body *PostmanBody, result *ir.IR) {
+	req := &result.Request
+	setContentType := func(value string) {
+		for k := range req.Headers {
+			if strings.EqualFold(k, "Content-Type") {
+				return
+			}
+		}
+		req.Headers["Content-Type"] = value
+	}
+
+	switch body.Mode {
+	case "raw":
+		if body.Raw == "" {
+			return
+		}
+		text := w.replaceVars(body.Raw)
+		language := body.Options.Raw.Language
+		if language == "" {
+			language = "text"
+		}
+		setContentType(rawContentTypes[language])
+
+		var content any
+		if language == "json" && json.Unmarshal([]byte(text), &content) == nil {
+			req.Body = &ir.Body{Type: "json", Content: content}
+		} else {
+			req.Body = &ir.Body{Type: "text", Content: text}
+		}
+
+	case "urlencoded":
+		form := make(map[string]any)
+		for _, kv := range body.URLEncoded {
+			if !kv.Disabled {
+				form[w.replaceVars(kv.Key)] = w.replaceVars(postmanString(kv.Value))
+			}
+		}
+		req.Body = &ir.Body{Type: "form", Content: form}
+		setContentType("application/x-www-form-urlencoded")
+
+	case "graphql":
+		if body.GraphQL == nil {
+			return
+		}
+		content := map[string]any{"query": body.GraphQL.Query}
+		if vars := strings.TrimSpace(w.replaceVars(body.GraphQL.Variables)); vars != "" {
+			var parsed any
+			if err := json.Unmarshal([]byte(vars), &parsed); err == nil {
+				content["variables"] = parsed
+			} else {
+				w.warn("request '%s': graphql variables are not valid JSON and were left out", name)
+			}
+		}
+		req.Body = &ir.Body{Type: "json", Content: content}
+		setContentType("application/json")
+
+	case "":
+	default:
+		w.warn("request '%s': %s body not translated", name, body.Mode)
+	}
+}
+
+// postmanVarRef matches {{name}}, and {{$guid}} style dynamic variables
+var postmanVarRef = regexp.MustCompile(`\{\{\s*([^{}]+?)\s*\}\}`)
+
+// replaceVars rewrites {{name}} as ${name}. Dynamic variables such as
+// {{$guid}} have no .httpx equivalent and are left as they are.
+func (w *PostmanWrapper) replaceVars(s string) string {
+	return postmanVarRef.ReplaceAllStringFunc(s, func(match string) string {
+		key := postmanVarRef.FindStringSubmatch(match)[1]
+		if strings.HasPrefix(key, "$") {
+			w.warn("dynamic variable %s not translated", match)
+			return match
+		}
+		return "${" + w.varName(key) + "}"
+	})
+}
+
+// varName returns the .httpx name of a Postman variable, keeping its case
+// where it is a valid identifier
+func (w *PostmanWrapper) varName(key string) string {
+	if name, ok := w.vars[key]; ok {
+		return name
+	}
+
+	name := variableName(key)
+	taken := make(map[string]bool, len(w.vars))
+	for _, other := range w.vars {
+		taken[other] = true
+	}
+	name = uniqueIdentifier(name, taken)
+
+	w.vars[key] = name
+	return name
+}
+
+// warn records a warning once
+func (w *PostmanWrapper) warn(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	for _, existing := range w.warnings {
+		if existing == msg {
+			return
+		}
+	}
+	w.warnings = append(w.warnings, msg)
+}
+
+// postmanString renders a variable or setting value, which may be any JSON
+func postmanString(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64, bool:
+		return fmt.Sprint(v)
+	default:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+}
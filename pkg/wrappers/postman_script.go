@@ -0,0 +1,222 @@
+package wrappers
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Test scripts are translated statement by statement. Only the common
+// pm.test/pm.expect/pm.environment.set patterns are recognised; any other
+// statement is reported as a warning.
+var (
+	// pm.test("name", function () {   and   pm.test("name", () => {
+	testOpen = regexp.MustCompile(`pm\.test\(\s*(?:"[^"]*"|'[^']*'|` + "`[^`]*`" + `)\s*,\s*(?:function\s*\(\s*\)|\(\s*\)\s*=>)\s*\{`)
+	// }); closing a test
+	testClose = regexp.MustCompile(`^\}\s*\)?\s*;?$`)
+
+	// var data = pm.response.json()
+	jsonAlias = regexp.MustCompile(`^(?:var|let|const)\s+(\w+)\s*=\s*(?:pm\.response\.json\(\)|JSON\.parse\(\s*(?:responseBody|pm\.response\.text\(\))\s*\))$`)
+
+	statusIs = []*regexp.Regexp{
+		regexp.MustCompile(`^pm\.response\.to\.have\.status\((\d{3})\)$`),
+		regexp.MustCompile(`^pm\.expect\(pm\.response\.(?:code|status)\)\.to\.(?:eql|equal|be\.equal|eq)\((\d{3})\)$`),
+		regexp.MustCompile(`^tests\[.*\]\s*=\s*responseCode\.code\s*===?\s*(\d{3})$`),
+	}
+	statusOneOf  = regexp.MustCompile(`^pm\.expect\(pm\.response\.code\)\.to\.be\.oneOf\(\[([\d,\s]+)\]\)$`)
+	latencyBelow = regexp.MustCompile(`^pm\.expect\(pm\.response\.responseTime\)\.to\.be\.(?:below|lessThan)\((\d+)\)$`)
+	headerExists = regexp.MustCompile(`^pm\.response\.to\.have\.header\(\s*["']([^"']+)["']\s*\)$`)
+	headerEquals = regexp.MustCompile(`^pm\.expect\(pm\.response\.headers\.get\(\s*["']([^"']+)["']\s*\)\)\.to\.(eql|equal|include|contain)\((.+)\)$`)
+
+	// pm.expect(data.user.id).to.eql(7), with the subject still to resolve
+	expectBody = regexp.MustCompile(`^pm\.expect\((.+?)\)\.to\.(eql|equal|eq|include|contain|be\.above|be\.below|have\.property)\((.+)\)$`)
+	expectTrue = regexp.MustCompile(`^pm\.expect\((.+?)\)\.to\.(exist|be\.ok|not\.be\.empty)$`)
+
+	// pm.environment.set("token", data.token)
+	setVar    = regexp.MustCompile(`^(?:pm\.(?:environment|collectionVariables|globals|variables)\.set|postman\.set(?:Environment|Global)Variable)\(\s*["']([^"']+)["']\s*,\s*(.+)\)$`)
+	headerGet = regexp.MustCompile(`^pm\.response\.headers\.get\(\s*["']([^"']+)["']\s*\)$`)
+	cookieGet = regexp.MustCompile(`^pm\.cookies\.get\(\s*["']([^"']+)["']\s*\)$`)
+
+	// data.user.id split into data and .user.id
+	jsRoot = regexp.MustCompile(`^([A-Za-z_$][\w$]*)(.*)$`)
+	// .user.id, [0], ["name"]
+	jsPath       = regexp.MustCompile(`^(?:\.[A-Za-z_$][\w$]*|\[\d+\]|\[\s*["'][^"']+["']\s*\])*$`)
+	quotedMember = regexp.MustCompile(`\[\s*["']([A-Za-z_]\w*)["']\s*\]`)
+	identName    = regexp.MustCompile(`^[A-Za-z_]\w*$`)
+)
+
+// convertTests adds the assertions and extract rules a test script makes,
+// warning about the statements it cannot translate
+func (w *PostmanWrapper) convertTests(req *HTTPXRequest, lines []string) {
+	aliases := map[string]bool{}
+
+	for _, line := range lines {
+		line = strings.TrimSpace(testOpen.ReplaceAllString(line, ";"))
+		for _, stmt := range strings.Split(line, ";") {
+			stmt = strings.TrimSpace(stmt)
+			if stmt == "" || strings.HasPrefix(stmt, "//") || testClose.MatchString(stmt) {
+				continue
+			}
+			if m := jsonAlias.FindStringSubmatch(stmt); m != nil {
+				aliases[m[1]] = true
+				continue
+			}
+			if !w.convertStatement(req, stmt, aliases) {
+				w.warn("request '%s': test statement not translated: %s", req.Name, stmt)
+			}
+		}
+	}
+}
+
+// convertStatement translates one statement, reporting whether it could
+func (w *PostmanWrapper) convertStatement(req *HTTPXRequest, stmt string, aliases map[string]bool) bool {
+	for _, re := range statusIs {
+		if m := re.FindStringSubmatch(stmt); m != nil {
+			req.addAssert("status == " + m[1])
+			return true
+		}
+	}
+	if m := statusOneOf.FindStringSubmatch(stmt); m != nil {
+		codes := strings.Split(strings.ReplaceAll(m[1], " ", ""), ",")
+		req.addAssert("status in [" + strings.Join(codes, ", ") + "]")
+		return true
+	}
+	if m := latencyBelow.FindStringSubmatch(stmt); m != nil {
+		req.addAssert("latency < " + m[1] + "ms")
+		return true
+	}
+	if m := headerExists.FindStringSubmatch(stmt); m != nil {
+		req.addAssert("header." + m[1] + " exists")
+		return true
+	}
+	if m := headerEquals.FindStringSubmatch(stmt); m != nil {
+		value, ok := jsLiteral(m[3])
+		if !ok {
+			return false
+		}
+		op := "=="
+		if m[2] == "include" || m[2] == "contain" {
+			op = "contains"
+		}
+		req.addAssert("header." + m[1] + " " + op + " " + value)
+		return true
+	}
+
+	if m := expectTrue.FindStringSubmatch(stmt); m != nil {
+		path, ok := bodyPath(m[1], aliases)
+		if !ok {
+			return false
+		}
+		req.addAssert("body" + path[1:] + " exists")
+		return true
+	}
+	if m := expectBody.FindStringSubmatch(stmt); m != nil {
+		path, ok := bodyPath(m[1], aliases)
+		if !ok {
+			return false
+		}
+		value, ok := jsLiteral(m[3])
+		if !ok {
+			return false
+		}
+		field := "body" + path[1:]
+		switch m[2] {
+		case "have.property":
+			name, err := strconv.Unquote(value)
+			if err != nil || !identName.MatchString(name) {
+				return false
+			}
+			req.addAssert(field + "." + name + " exists")
+		case "include", "contain":
+			req.addAssert(field + " contains " + value)
+		case "be.above":
+			req.addAssert(field + " > " + value)
+		case "be.below":
+			req.addAssert(field + " < " + value)
+		default:
+			req.addAssert(field + " == " + value)
+		}
+		return true
+	}
+
+	if m := setVar.FindStringSubmatch(stmt); m != nil {
+		name := w.varName(m[1])
+		source := strings.TrimSpace(m[2])
+		switch {
+		case headerGet.MatchString(source):
+			req.Extract = append(req.Extract, HTTPXExtract{Name: name, Rule: "header:" + headerGet.FindStringSubmatch(source)[1]})
+		case cookieGet.MatchString(source):
+			req.Extract = append(req.Extract, HTTPXExtract{Name: name, Rule: "cookie:" + cookieGet.FindStringSubmatch(source)[1]})
+		default:
+			path, ok := bodyPath(source, aliases)
+			if !ok {
+				return false
+			}
+			req.Extract = append(req.Extract, HTTPXExtract{Name: name, Rule: path})
+		}
+		return true
+	}
+
+	return false
+}
+
+// bodyPath converts a JavaScript expression reading the response JSON, such
+// as data.items[0].id or pm.response.json().id, to a JSONPath
+func bodyPath(expr string, aliases map[string]bool) (string, bool) {
+	expr = strings.TrimSpace(expr)
+
+	var rest string
+	if r, ok := strings.CutPrefix(expr, "pm.response.json()"); ok {
+		rest = r
+	} else {
+		m := jsRoot.FindStringSubmatch(expr)
+		if m == nil || !aliases[m[1]] {
+			return "", false
+		}
+		rest = m[2]
+	}
+
+	if !jsPath.MatchString(rest) {
+		return "", false
+	}
+	// ["name"] is written .name when it is a plain identifier
+	rest = quotedMember.ReplaceAllString(rest, ".$1")
+	return "$" + rest, true
+}
+
+// jsLiteral converts a JavaScript string, number, boolean or null literal to
+// its .httpx form
+func jsLiteral(s string) (string, bool) {
+	s = strings.TrimSpace(s)
+	switch {
+	case s == "true" || s == "false" || s == "null":
+		return s, true
+	case len(s) >= 2 && (s[0] == '\'' && s[len(s)-1] == '\'' || s[0] == '`' && s[len(s)-1] == '`'):
+		inner := s[1 : len(s)-1]
+		if strings.ContainsAny(inner, `\'`+"`") || strings.Contains(inner, "${") {
+			return "", false
+		}
+		return strconv.Quote(inner), true
+	case len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"':
+		var str string
+		if err := json.Unmarshal([]byte(s), &str); err != nil {
+			return "", false
+		}
+		return strconv.Quote(str), true
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return s, true
+	}
+	return "", false
+}
+
+// addAssert adds an assertion unless the request already makes it
+func (r *HTTPXRequest) addAssert(a string) {
+	for _, existing := range r.Assert {
+		if existing == a {
+			return
+		}
+	}
+	r.Assert = append(r.Assert, a)
+}
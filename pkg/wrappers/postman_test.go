@@ -0,0 +1,256 @@
+package wrappers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vikasavnish/httptool/pkg/scenario"
+)
+
+const collection = `{
+  "info": {"name": "Shop API", "schema": "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"},
+  "auth": {"type": "bearer", "bearer": [{"key": "token", "value": "{{token}}", "type": "string"}]},
+  "variable": [
+    {"key": "base-url", "value": "http://localhost:1"},
+    {"key": "token", "value": ""},
+    {"key": "page_size", "value": 20}
+  ],
+  "event": [{"listen": "test", "script": {"exec": ["pm.test(\"fast\", () => { pm.expect(pm.response.responseTime).to.be.below(2000); });"]}}],
+  "item": [
+    {
+      "name": "Auth",
+      "auth": {"type": "noauth"},
+      "item": [{
+        "name": "Log in",
+        "request": {
+          "method": "POST",
+          "header": [{"key": "X-Client", "value": "postman"}, {"key": "X-Debug", "value": "1", "disabled": true}],
+          "url": {"raw": "{{base-url}}/login", "host": ["{{base-url}}"], "path": ["login"]},
+          "body": {"mode": "raw", "raw": "{\"user\": \"{{user}}\", \"password\": \"secret\"}", "options": {"raw": {"language": "json"}}}
+        },
+        "event": [{"listen": "test", "script": {"exec": [
+          "pm.test(\"Status code is 200\", function () {",
+          "    pm.response.to.have.status(200);",
+          "});",
+          "var jsonData = pm.response.json();",
+          "pm.environment.set(\"token\", jsonData.access_token);",
+          "pm.collectionVariables.set(\"user_id\", jsonData.user[\"id\"]);",
+          "pm.expect(jsonData.user.name).to.eql('Ada');",
+          "console.log(jsonData);"
+        ]}}]
+      }]
+    },
+    {
+      "name": "Users",
+      "item": [
+        {
+          "name": "Get user",
+          "request": {
+            "method": "GET",
+            "url": {"raw": "{{base-url}}/users/:id?limit={{page_size}}&owner={{user_id}}", "variable": [{"key": "id", "value": "{{user_id}}"}]}
+          },
+          "event": [{"listen": "test", "script": {"exec": "pm.expect(pm.response.code).to.be.oneOf([200, 304]);\npm.response.to.have.header(\"Content-Type\");"}}]
+        },
+        {
+          "name": "Admin",
+          "auth": {"type": "apikey", "apikey": [{"key": "key", "value": "X-Api-Key"}, {"key": "value", "value": "k-123"}]},
+          "item": [{
+            "name": "Status",
+            "request": {"method": "GET", "url": "{{base-url}}/admin/status?ts={{$timestamp}}"},
+            "event": [{"listen": "prerequest", "script": {"exec": ["pm.variables.set('x', 1);"]}}]
+          }]
+        },
+        {
+          "name": "Rename user",
+          "request": {
+            "method": "PUT",
+            "url": "{{base-url}}/users/{{user_id}}",
+            "body": {"mode": "urlencoded", "urlencoded": [{"key": "name", "value": "Ada L."}, {"key": "by", "value": "{{user}}"}]}
+          }
+        },
+        {
+          "name": "Upload avatar",
+          "request": {"method": "POST", "url": "{{base-url}}/avatar", "body": {"mode": "formdata", "formdata": [{"key": "file", "type": "file", "src": "a.png"}]}}
+        }
+      ]
+    }
+  ]
+}`
+
+const environment = `{
+  "name": "Local",
+  "values": [
+    {"key": "base-url", "value": "BASE", "enabled": true},
+    {"key": "user", "value": "ada@example.com", "enabled": true},
+    {"key": "unused", "value": "x", "enabled": false}
+  ]
+}`
+
+func convertFixture(t *testing.T, base string) (*HTTPXFile, []string, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"collection.json":  collection,
+		"environment.json": strings.Replace(environment, "BASE", base, 1),
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c, err := LoadPostmanCollection(filepath.Join(dir, "collection.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	env, err := LoadPostmanEnvironment(filepath.Join(dir, "environment.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, warnings, err := NewPostmanWrapper().ConvertCollection(c, env)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := file.Write(&out); err != nil {
+		t.Fatal(err)
+	}
+	return file, warnings, out.String()
+}
+
+func TestPostmanWrapper_ConvertCollection(t *testing.T) {
+	file, warnings, text := convertFixture(t, "http://localhost:8080")
+
+	var vars []string
+	for _, v := range file.Vars {
+		vars = append(vars, v.Name+"="+v.Value)
+	}
+	// token is set by a test script, so it is extracted instead of declared
+	want := []string{"base_url=http://localhost:8080", "page_size=20", "user=ada@example.com"}
+	if !reflect.DeepEqual(vars, want) {
+		t.Errorf("vars = %v, want %v", vars, want)
+	}
+
+	var flows []string
+	for _, flow := range file.Scenario.Flows {
+		flows = append(flows, flow.Comment+": "+strings.Join(flow.Runs, " -> "))
+	}
+	wantFlows := []string{
+		"Auth: log_in",
+		"Users: get_user",
+		"Users / Admin: status_request",
+		"Users: rename_user -> upload_avatar",
+	}
+	if !reflect.DeepEqual(flows, wantFlows) {
+		t.Errorf("flows = %v, want %v", flows, wantFlows)
+	}
+
+	requests := make(map[string]*HTTPXRequest)
+	for _, req := range file.Requests {
+		requests[req.Name] = req
+	}
+
+	login := requests["log_in"]
+	if login.IR.Request.Auth != nil || login.IR.Request.Headers["X-Debug"] != "" {
+		t.Errorf("log in request = %+v", login.IR.Request)
+	}
+	wantExtract := []HTTPXExtract{{"token", "$.access_token"}, {"user_id", "$.user.id"}}
+	if !reflect.DeepEqual(login.Extract, wantExtract) {
+		t.Errorf("extract = %v, want %v", login.Extract, wantExtract)
+	}
+	wantAssert := []string{"latency < 2000ms", "status == 200", `body.user.name == "Ada"`}
+	if !reflect.DeepEqual(login.Assert, wantAssert) {
+		t.Errorf("assert = %v, want %v", login.Assert, wantAssert)
+	}
+
+	if got := requests["get_user"]; got.IR.Request.URL != "${base_url}/users/${user_id}?limit=${page_size}&owner=${user_id}" ||
+		got.IR.Request.Auth.Token != "${token}" || !reflect.DeepEqual(got.Assert[1:], []string{"status in [200, 304]", "header.Content-Type exists"}) {
+		t.Errorf("get user = %+v %v", got.IR.Request, got.Assert)
+	}
+	if got := requests["status_request"]; got.Comment != "Status" || got.IR.Request.Headers["X-Api-Key"] != "k-123" {
+		t.Errorf("status = %+v", got)
+	}
+
+	wantWarnings := []string{
+		"request 'log_in': test statement not translated: console.log(jsonData)",
+		"dynamic variable {{$timestamp}} not translated",
+		"request 'status_request': pre-request script not translated",
+		"request 'upload_avatar': formdata body not translated",
+	}
+	if !reflect.DeepEqual(warnings, wantWarnings) {
+		t.Errorf("warnings = %q, want %q", warnings, wantWarnings)
+	}
+
+	if !strings.Contains(text, "request status_request {") || !strings.Contains(text, "  # Users / Admin\n  run status_request\n") {
+		t.Errorf("unexpected .httpx:\n%s", text)
+	}
+}
+
+func TestPostmanWrapper_RunScenario(t *testing.T) {
+	var mu = make(chan struct{}, 1)
+	seen := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu <- struct{}{}
+		seen[r.Method+" "+r.URL.Path] = r.URL.RawQuery + "|" + r.Header.Get("Authorization") + "|" + string(body)
+		<-mu
+
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/login":
+			var creds map[string]string
+			json.Unmarshal(body, &creds)
+			fmt.Fprintf(w, `{"access_token": "tok-%s", "user": {"id": 7, "name": "Ada"}}`, strings.Split(creds["user"], "@")[0])
+		default:
+			fmt.Fprint(w, `{}`)
+		}
+	}))
+	defer server.Close()
+
+	file, _, text := convertFixture(t, server.URL)
+
+	s, err := scenario.NewParser(text).Parse()
+	if err != nil {
+		t.Fatalf("%v\n%s", err, text)
+	}
+	compiled, err := scenario.NewCompiler().Compile(s, file.Scenario.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := scenario.NewExecutor().Execute(context.Background(), compiled)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"log_in", "get_user", "rename_user"} {
+		stats := result.Stats.Requests[name]
+		if stats == nil || stats.Failed != 0 {
+			t.Errorf("%s: %+v", name, stats)
+		}
+	}
+
+	want := map[string]string{
+		"POST /login":  `||{"password":"secret","user":"ada@example.com"}`,
+		"GET /users/7": "limit=20&owner=7|Bearer tok-ada|",
+		"PUT /users/7": "|Bearer tok-ada|by=ada%40example.com&name=Ada+L.",
+		"POST /avatar": "|Bearer tok-ada|",
+	}
+	for key, value := range want {
+		if got := seen[key]; got != value {
+			t.Errorf("%s got %q, want %q (seen %q)", key, got, value, seen)
+		}
+	}
+}